@@ -3,49 +3,140 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"sway-easyshot/internal/config"
-	"sway-easyshot/internal/daemon"
-	"sway-easyshot/internal/state"
-	"sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/commands"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/daemon"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/logging"
+	"github.com/chmouel/sway-easyshot/internal/session"
+	"github.com/chmouel/sway-easyshot/internal/systemd"
+	"github.com/chmouel/sway-easyshot/internal/trace"
+	"github.com/chmouel/sway-easyshot/internal/version"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/state"
 
 	"github.com/urfave/cli/v3"
 )
 
+// jsonOutput is set by --json to print the full responses of the daemon.
+var jsonOutput bool
+
+// timeoutFlag is set by --timeout to replace the configured timeouts.
+var timeoutFlag *time.Duration
+
 func main() {
 	cmd := &cli.Command{
-		Name:  "sway-easyshot",
-		Usage: "Recording and screenshot utility for sway",
+		Name:    "sway-easyshot",
+		Usage:   "Recording and screenshot utility for sway",
+		Version: version.Get().String(),
+		// The completion scripts ask the binary for the commands and flags
+		// as they are typed, so they never go stale
+		EnableShellCompletion: true,
+		ConfigureShellCompletionCommand: func(c *cli.Command) {
+			c.Hidden = false
+			c.Usage = "Print the shell completion script for bash, zsh or fish"
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print the full response of the daemon as JSON, including the state and saved files",
+				Destination: &jsonOutput,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Usage:       "How long to wait for the daemon to answer, 0 for ever",
+				DefaultText: "SWAY_SCREENSHOT_TIMEOUT or 30s",
+				Action: func(_ context.Context, _ *cli.Command, timeout time.Duration) error {
+					if timeout < 0 {
+						return fmt.Errorf("--timeout cannot be negative")
+					}
+					timeoutFlag = &timeout
+					return nil
+				},
+			},
+		},
 		Commands: []*cli.Command{
 			daemonCommand(),
+			daemonStopCommand(),
+			daemonRestartCommand(),
+			reloadConfigCommand(),
 			waybarStatusCommand(),
 			obsToggleRecordingCommand(),
 			obsTogglePauseCommand(),
+			obsToggleSourceCommand(),
+			obsScreenshotCommand(),
 			currentWindowClipboardCommand(),
 			currentWindowFileCommand(),
+			windowFileCommand(),
 			currentScreenClipboardCommand(),
 			selectionFileCommand(),
 			selectionEditCommand(),
 			selectionClipboardCommand(),
+			selectionMultiCommand(),
+			scrollCaptureCommand(),
+			composeCommand(),
+			actionCommand(),
+			pickPaletteCommand(),
+			ocrSelectionCommand(),
+			scanQRCommand(),
+			altTextSelectionCommand(),
 			movieSelectionCommand(),
 			movieScreenCommand(),
 			movieCurrentWindowCommand(),
+			movieZoomCommand(),
 			stopRecordingCommand(),
 			pauseRecordingCommand(),
+			snapshotCommand(),
+			screenLockedCommand(),
+			screenUnlockedCommand(),
 			toggleRecordCommand(),
+			retargetCommand(),
+			repeatLastCommand(),
+			cancelCommand(),
+			undoCommand(),
+			deleteLastCommand(),
+			cleanupCommand(),
+			historyCommand(),
+			galleryCommand(),
+			menuCommand(),
+			lastCommand(),
+			traceCommand(),
+			logsCommand(),
+			eventsCommand(),
+			statusCommand(),
+			versionCommand(),
+			doctorCommand(),
+			installCommand(),
+			tutorialCommand(),
 		},
 	}
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
+		os.Exit(exitFailure)
 	}
 }
 
@@ -58,7 +149,17 @@ func daemonCommand() *cli.Command {
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  "debug",
-				Usage: "Enable debug logging",
+				Usage: "Enable debug logging, as --log-level debug does",
+			},
+			&cli.StringFlag{
+				Name:  "log-level",
+				Usage: "Minimum level logged: debug, info, warn or error",
+				Value: "info",
+			},
+			&cli.StringFlag{
+				Name:  "log-format",
+				Usage: "Format of the log: text or json",
+				Value: "text",
 			},
 		},
 		Action: func(ctx context.Context, c *cli.Command) error {
@@ -66,17 +167,91 @@ func daemonCommand() *cli.Command {
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
-			d := daemon.New(cfg, c.Bool("debug"))
+
+			level, err := logging.ParseLevel(c.String("log-level"))
+			if err != nil {
+				return err
+			}
+			if c.Bool("debug") {
+				level = slog.LevelDebug
+			}
+			opts := logging.Options{Level: level, Format: c.String("log-format")}
+			if cfg.LogMaxSize > 0 {
+				opts.File = cfg.LogFile
+				opts.MaxSize = int64(cfg.LogMaxSize) << 20
+			}
+			closeLog, err := logging.Setup(opts)
+			if err != nil {
+				return err
+			}
+			defer closeLog()
+
+			d := daemon.New(cfg)
 			return d.Start()
 		},
 	}
 }
 
+func daemonStopCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon-stop",
+		Usage: "Stop the running daemon gracefully",
+		Flags: []cli.Flag{forceStopFlag()},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if !isDaemonRunning(cfg) {
+				fmt.Println("Daemon is not running")
+				return nil
+			}
+			return stopDaemon(cfg, c.Bool("force"))
+		},
+	}
+}
+
+func daemonRestartCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon-restart",
+		Usage: "Restart the daemon, e.g. to apply a changed configuration",
+		Flags: []cli.Flag{forceStopFlag()},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if cfg.Remote != "" {
+				return fmt.Errorf("cannot start the daemon at %s from here, stop it with daemon-stop and start it on its machine", cfg.Remote)
+			}
+			if isDaemonRunning(cfg) {
+				if err := stopDaemon(cfg, c.Bool("force")); err != nil {
+					return err
+				}
+			}
+			return ensureDaemonRunning(cfg)
+		},
+	}
+}
+
+func reloadConfigCommand() *cli.Command {
+	return createSimpleCommand("reload-config", "Make the daemon read the configuration file again, without interrupting a recording")
+}
+
+func forceStopFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "force",
+		Usage: "Stop the recording in progress, saving it, rather than refusing to stop",
+	}
+}
+
 func waybarStatusCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "waybar-status",
-		Usage: "Output waybar status (JSON)",
-		Description: "Outputs current recording/screenshot status in Waybar JSON format.\n" +
+		Usage: "Output the status for waybar (JSON) or another bar",
+		Description: "Outputs current recording/screenshot status in Waybar JSON format, or for polybar, i3blocks, i3status-rust or eww.\n" +
 			"Poll interval: SWAY_SCREENSHOT_WAYBAR_POLL_INTERVAL (default: 1s)",
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
@@ -108,11 +283,25 @@ func waybarStatusCommand() *cli.Command {
 				Usage: "Icon for OBS paused recording state",
 				Value: "󰏤",
 			},
+			&cli.StringFlag{
+				Name:  "icon-obs-streaming",
+				Usage: "Icon for OBS streaming state",
+				Value: "󰑊",
+			},
 			&cli.StringFlag{
 				Name:  "icon-countdown",
 				Usage: "Icon for countdown state",
 				Value: "⏱",
 			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format: waybar (JSON), polybar, i3blocks, i3status-rust, eww or plain",
+				Value: "waybar",
+			},
+			&cli.BoolFlag{
+				Name:  "color",
+				Usage: "Colour the text by state, for the polybar and i3blocks formats",
+			},
 			&cli.BoolFlag{
 				Name:  "no-idle-output",
 				Usage: "Output nothing when idle (useful for minimal waybar display)",
@@ -136,40 +325,261 @@ func obsTogglePauseCommand() *cli.Command {
 	return createSimpleCommand("obs-toggle-pause", "Toggle OBS pause state")
 }
 
+func obsScreenshotCommand() *cli.Command {
+	return createSimpleCommand("obs-screenshot", "Save a screenshot of what OBS currently outputs")
+}
+
+func obsToggleSourceCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "obs-toggle-source",
+		Usage: "Show or hide an OBS source, such as a webcam overlay",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scene",
+				Usage: "Scene of the source (default: the scene currently shown)",
+			},
+			&cli.StringFlag{
+				Name:     "source",
+				Usage:    "Name of the source",
+				Required: true,
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			req := protocol.NewRequest("obs-toggle-source", protocol.OBSSourceOptions{
+				Scene:  c.String("scene"),
+				Source: c.String("source"),
+			})
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
 func currentWindowClipboardCommand() *cli.Command {
-	return createScreenshotCommand("current-window-clipboard", "Capture focused window to clipboard")
+	return createScreenshotCommand("current-window-clipboard", "Capture focused window to clipboard", transparentFlag(), prettyFlag(), decorationsFlag(), uploadFlag())
 }
 
 func currentWindowFileCommand() *cli.Command {
-	return createScreenshotCommand("current-window-file", "Capture focused window to file")
+	return createScreenshotCommand("current-window-file", "Capture focused window to file", transparentFlag(), prettyFlag(), decorationsFlag(), uploadFlag(), ephemeralFlag(), encryptFlag())
+}
+
+func windowFileCommand() *cli.Command {
+	return createScreenshotCommand("window-file", "Capture a window to file, even on another workspace",
+		prettyFlag(),
+		uploadFlag(),
+		ephemeralFlag(),
+		encryptFlag(),
+		&cli.StringFlag{
+			Name:  "workspace",
+			Usage: "Workspace of the window",
+		},
+		&cli.StringFlag{
+			Name:  "app-id",
+			Usage: "App id (or X11 class) of the window",
+		})
 }
 
 func currentScreenClipboardCommand() *cli.Command {
-	return createScreenshotCommand("current-screen-clipboard", "Capture focused screen to clipboard")
+	return createScreenshotCommand("current-screen-clipboard", "Capture focused screen to clipboard", append(barsFlags(), currentScreenFlag(), uploadFlag())...)
 }
 
 func selectionFileCommand() *cli.Command {
-	return createScreenshotCommand("selection-file", "Capture selection to file (interactive actions)")
+	return createScreenshotCommand("selection-file", "Capture selection to file (interactive actions)", lastRegionFlag(), prettyFlag(), uploadFlag(), ephemeralFlag(), encryptFlag())
 }
 
 func selectionEditCommand() *cli.Command {
-	return createScreenshotCommand("selection-edit", "Capture selection and open editor")
+	return createScreenshotCommand("selection-edit", "Capture selection and open editor", lastRegionFlag())
 }
 
 func selectionClipboardCommand() *cli.Command {
-	return createScreenshotCommand("selection-clipboard", "Capture selection to clipboard (optional save/edit)")
+	return createScreenshotCommand("selection-clipboard", "Capture selection to clipboard (optional save/edit)", lastRegionFlag(), prettyFlag(), uploadFlag())
+}
+
+func selectionMultiCommand() *cli.Command {
+	return createScreenshotCommand("selection-multi", "Capture several selections in one go (Escape to finish)",
+		ephemeralFlag(),
+		encryptFlag(),
+		&cli.BoolFlag{
+			Name:  "composite",
+			Usage: "Combine the selections side by side into a single image",
+		})
+}
+
+func scrollCaptureCommand() *cli.Command {
+	return createScreenshotCommand("scroll-capture", "Capture the focused window while scrolling it, stitched into one tall image",
+		prettyFlag(),
+		uploadFlag(),
+		ephemeralFlag(),
+		encryptFlag(),
+		&cli.IntFlag{
+			Name:    "max-frames",
+			Aliases: []string{"m"},
+			Usage:   "Maximum number of frames to capture before stopping",
+			Value:   15,
+		})
+}
+
+func composeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "compose",
+		Usage:     "Lay captures out side by side with their names into a single image",
+		ArgsUsage: "[FILE...]",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:    "last",
+				Aliases: []string{"n"},
+				Usage:   "Number of last saved captures to compose when no file is given",
+				Value:   2,
+			},
+			&cli.IntFlag{
+				Name:  "columns",
+				Usage: "Number of columns of the grid (default: a single row)",
+			},
+			&cli.BoolFlag{
+				Name:  "no-labels",
+				Usage: "Leave the file names out",
+			},
+			uploadFlag(),
+			ephemeralFlag(),
+			encryptFlag(),
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			// The daemon does not share our working directory
+			files := make([]string, 0, c.Args().Len())
+			for _, file := range c.Args().Slice() {
+				abs, err := filepath.Abs(file)
+				if err != nil {
+					return err
+				}
+				files = append(files, abs)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			labels := !c.Bool("no-labels")
+			req := protocol.NewRequest("compose", protocol.ComposeOptions{
+				Files:     files,
+				Last:      int(c.Int("last")),
+				Columns:   int(c.Int("columns")),
+				Labels:    &labels,
+				Upload:    c.Bool("upload"),
+				Ephemeral: c.Bool("ephemeral"),
+				Encrypt:   c.Bool("encrypt"),
+			})
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
+func actionCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "action",
+		Usage:     "Run a post-capture action on the last capture or a file, or pick one from a menu",
+		ArgsUsage: "[NAME] [FILE]",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			// The daemon does not share our working directory
+			file := c.Args().Get(1)
+			if file != "" {
+				if file, err = filepath.Abs(file); err != nil {
+					return err
+				}
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			req := protocol.NewRequest("action", protocol.ActionOptions{
+				Name: c.Args().First(),
+				File: file,
+			})
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
+func pickPaletteCommand() *cli.Command {
+	return createScreenshotCommand("pick-palette", "Copy the dominant colours of a selection as hex codes",
+		lastRegionFlag(),
+		&cli.IntFlag{
+			Name:    "colors",
+			Aliases: []string{"n"},
+			Usage:   "Number of colours to extract",
+			Value:   5,
+		},
+		&cli.BoolFlag{
+			Name:  "save",
+			Usage: "Also save a palette strip image",
+		})
+}
+
+func ocrSelectionCommand() *cli.Command {
+	return createScreenshotCommand("ocr-selection", "Recognise the text of a selection and copy it to clipboard",
+		lastRegionFlag(),
+		&cli.StringFlag{
+			Name:  "lang",
+			Usage: "Tesseract languages to recognise, e.g. eng+fra (default: SWAY_SCREENSHOT_OCR_LANG)",
+		},
+		&cli.StringFlag{
+			Name:  "translate",
+			Usage: "Translate the recognised text to this language with aichat (default: SWAY_SCREENSHOT_OCR_TRANSLATE)",
+		})
+}
+
+func scanQRCommand() *cli.Command {
+	return createScreenshotCommand("scan-qr", "Decode QR codes or barcodes in a selection and copy their content", lastRegionFlag())
+}
+
+func altTextSelectionCommand() *cli.Command {
+	return createScreenshotCommand("alt-text-selection", "Copy an AI generated alt text describing a selection", lastRegionFlag())
 }
 
 func movieSelectionCommand() *cli.Command {
-	return createScreenshotCommand("movie-selection", "Record video of selection")
+	return createScreenshotCommand("movie-selection", "Record video of selection", lastRegionFlag(), forFlag(), timerFlag())
 }
 
 func movieScreenCommand() *cli.Command {
-	return createScreenshotCommand("movie-screen", "Record video of screen")
+	return createScreenshotCommand("movie-screen", "Record video of screen", append(barsFlags(), currentScreenFlag(), forFlag(), timerFlag())...)
+}
+
+func movieZoomCommand() *cli.Command {
+	return createScreenshotCommand("movie-zoom", "Record a magnified area of the screen following the pointer",
+		currentScreenFlag(),
+		forFlag(),
+		timerFlag(),
+		&cli.FloatFlag{
+			Name:    "zoom",
+			Aliases: []string{"z"},
+			Usage:   "Magnification factor",
+			Value:   2,
+		})
 }
 
 func movieCurrentWindowCommand() *cli.Command {
-	return createScreenshotCommand("movie-current-window", "Record video of focused window")
+	return createScreenshotCommand("movie-current-window", "Record video of focused window", forFlag(), timerFlag(), decorationsFlag())
 }
 
 func stopRecordingCommand() *cli.Command {
@@ -180,6 +590,74 @@ func pauseRecordingCommand() *cli.Command {
 	return createSimpleCommand("pause-recording", "Pause/resume current recording")
 }
 
+func snapshotCommand() *cli.Command {
+	return createSimpleCommand("snapshot", "Save the last frame of the paused recording as a screenshot")
+}
+
+func screenLockedCommand() *cli.Command {
+	return createSimpleCommand("screen-locked", "Pause the recording for a screen lock not going through logind")
+}
+
+func screenUnlockedCommand() *cli.Command {
+	return createSimpleCommand("screen-unlocked", "Resume the recording paused by screen-locked")
+}
+
+func cancelCommand() *cli.Command {
+	return createSimpleCommand("cancel", "Cancel the countdown or selection in progress")
+}
+
+func undoCommand() *cli.Command {
+	return createSimpleCommand("undo", "Undo the last rename, pixelation, clipboard overwrite or cleanup")
+}
+
+func deleteLastCommand() *cli.Command {
+	return createSimpleCommand("delete-last", "Delete the most recent capture and clear it from the clipboard and history")
+}
+
+func cleanupCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "cleanup",
+		Usage: "Remove the captures older than the cleanup time now, rather than at the next daily cleanup",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print the captures which would be removed",
+			},
+			&cli.StringFlag{
+				Name:  "older-than",
+				Usage: "Remove every capture older than this duration (e.g. 48h) whatever its type",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			resp, err := sendRequest(cfg, protocol.NewRequest("cleanup", protocol.CleanupOptions{
+				DryRun:    c.Bool("dry-run"),
+				OlderThan: c.String("older-than"),
+			}))
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			if jsonOutput {
+				return printResponse(resp)
+			}
+			if !resp.Success {
+				return responseError(resp)
+			}
+
+			fmt.Println(resp.Message)
+			return nil
+		},
+	}
+}
+
 func toggleRecordCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "toggle-record",
@@ -202,6 +680,9 @@ func toggleRecordCommand() *cli.Command {
 				Aliases: []string{"c"},
 				Usage:   "Use current focused screen (for movie-screen action)",
 			},
+			lastRegionFlag(),
+			forFlag(),
+			timerFlag(),
 		},
 		Action: func(ctx context.Context, c *cli.Command) error {
 			cfg, err := config.Load()
@@ -213,27 +694,39 @@ func toggleRecordCommand() *cli.Command {
 				return err
 			}
 
-			req := protocol.Request{
-				Command: "execute",
-				Action:  "toggle-record",
-				Options: map[string]interface{}{
-					"start_action":       c.String("start-action"),
-					"delay":              c.Int("delay"),
-					"use_current_screen": c.Bool("current-screen"),
+			req := protocol.NewRequest("toggle-record", protocol.ToggleRecordOptions{
+				StartAction: c.String("start-action"),
+				CaptureOptions: protocol.CaptureOptions{
+					Delay:            int(c.Int("delay")),
+					UseCurrentScreen: c.Bool("current-screen"),
+					LastRegion:       c.Bool("last-region"),
+					For:              durationOption(c, "for"),
+					Timer:            c.String("timer"),
 				},
-			}
+			})
 
-			return sendAndHandleRequest(cfg.SocketPath, req)
+			return sendAndHandleRequest(cfg, req)
 		},
 	}
 }
 
-// Helper functions for command creation
-
-func createSimpleCommand(name, usage string) *cli.Command {
+func retargetCommand() *cli.Command {
 	return &cli.Command{
-		Name:  name,
-		Usage: usage,
+		Name:  "retarget",
+		Usage: "Switch the current recording to a new target, joining the segments when stopped",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "target",
+				Aliases: []string{"a"},
+				Usage:   "New target: movie-selection, movie-screen, movie-current-window",
+				Value:   "movie-selection",
+			},
+			&cli.BoolFlag{
+				Name:    "current-screen",
+				Aliases: []string{"c"},
+				Usage:   "Use current focused screen (for movie-screen target)",
+			},
+		},
 		Action: func(ctx context.Context, c *cli.Command) error {
 			cfg, err := config.Load()
 			if err != nil {
@@ -244,20 +737,20 @@ func createSimpleCommand(name, usage string) *cli.Command {
 				return err
 			}
 
-			req := protocol.Request{
-				Command: "execute",
-				Action:  name,
-			}
+			req := protocol.NewRequest("retarget", protocol.RetargetOptions{
+				Target:           c.String("target"),
+				UseCurrentScreen: c.Bool("current-screen"),
+			})
 
-			return sendAndHandleRequest(cfg.SocketPath, req)
+			return sendAndHandleRequest(cfg, req)
 		},
 	}
 }
 
-func createScreenshotCommand(name, usage string) *cli.Command {
+func repeatLastCommand() *cli.Command {
 	return &cli.Command{
-		Name:  name,
-		Usage: usage,
+		Name:  "repeat-last",
+		Usage: "Repeat the last region capture on exactly the same region",
 		Flags: []cli.Flag{
 			&cli.IntFlag{
 				Name:    "delay",
@@ -265,11 +758,6 @@ func createScreenshotCommand(name, usage string) *cli.Command {
 				Usage:   "Delay capture/recording in seconds",
 				Value:   0,
 			},
-			&cli.BoolFlag{
-				Name:    "current-screen",
-				Aliases: []string{"c"},
-				Usage:   "Use current focused screen (skip selection)",
-			},
 		},
 		Action: func(ctx context.Context, c *cli.Command) error {
 			cfg, err := config.Load()
@@ -281,62 +769,985 @@ func createScreenshotCommand(name, usage string) *cli.Command {
 				return err
 			}
 
-			req := protocol.Request{
-				Command: "execute",
-				Action:  name,
-				Options: map[string]interface{}{
-					"delay":              c.Int("delay"),
-					"use_current_screen": c.Bool("current-screen"),
-				},
-			}
+			req := protocol.NewRequest("repeat-last", protocol.RepeatLastOptions{Delay: int(c.Int("delay"))})
 
-			return sendAndHandleRequest(cfg.SocketPath, req)
+			return sendAndHandleRequest(cfg, req)
 		},
 	}
 }
 
-func ensureDaemonRunning(cfg *config.Config) error {
-	if !isDaemonRunning(cfg.SocketPath) {
-		if err := startDaemon(cfg); err != nil {
-			return fmt.Errorf("failed to start daemon: %w", err)
-		}
-
-		// Wait for daemon to be ready
-		for i := 0; i < 10; i++ {
-			if isDaemonRunning(cfg.SocketPath) {
-				return nil
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "Show the daemon state and which optional features are available (JSON)",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
 			}
-			time.Sleep(100 * time.Millisecond)
-		}
 
-		return fmt.Errorf("daemon failed to start")
-	}
-	return nil
-}
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
 
-func sendAndHandleRequest(socketPath string, req protocol.Request) error {
-	resp, err := sendRequest(socketPath, req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
+			resp, err := sendRequest(cfg, protocol.NewRequest("status", nil))
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			if jsonOutput {
+				return printResponse(resp)
+			}
+			if !resp.Success {
+				return responseError(resp)
+			}
 
-	if !resp.Success {
-		return fmt.Errorf("command failed: %s", resp.Message)
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(resp.State)
+		},
 	}
-
-	return nil
 }
 
-// Utility functions
+func traceCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "trace",
+		Usage: "Show recent requests, responses and external commands run by the daemon",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
 
-func isDaemonRunning(socketPath string) bool {
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return false
-	}
-	_ = conn.Close()
-	return true
-}
+			if !isDaemonRunning(cfg) {
+				return unreachableError(fmt.Errorf("daemon is not running"))
+			}
+
+			resp, err := sendRequest(cfg, protocol.NewRequest("trace", nil))
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			if jsonOutput {
+				return printResponse(resp)
+			}
+			if !resp.Success {
+				return responseError(resp)
+			}
+
+			var entries []trace.Entry
+			if err := json.Unmarshal([]byte(resp.Message), &entries); err != nil {
+				return fmt.Errorf("failed to parse trace: %w", err)
+			}
+			for _, entry := range entries {
+				fmt.Println(entry.String())
+			}
+			return nil
+		},
+	}
+}
+
+func eventsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "events",
+		Usage: "Print the state of the daemon as a JSON line each time it changes, until interrupted",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			events, stop, err := subscribe(cfg, protocol.SubscribeOptions{})
+			if err != nil {
+				var exit *exitError
+				if errors.As(err, &exit) {
+					return err
+				}
+				return unreachableError(fmt.Errorf("failed to subscribe: %w", err))
+			}
+			defer stop()
+
+			encoder := json.NewEncoder(os.Stdout)
+			for {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						return unreachableError(fmt.Errorf("lost the connection to the daemon"))
+					}
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		},
+	}
+}
+
+func logsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "logs",
+		Usage: "Show the recent log of the daemon",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "follow",
+				Aliases: []string{"f"},
+				Usage:   "Keep printing the lines logged, until interrupted",
+			},
+			&cli.IntFlag{
+				Name:    "lines",
+				Aliases: []string{"n"},
+				Usage:   "Number of recent lines to show (0 for all those kept)",
+				Value:   50,
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if !isDaemonRunning(cfg) {
+				return unreachableError(fmt.Errorf("daemon is not running"))
+			}
+
+			// Following asks again and again on the same connection
+			conn, err := dialDaemon(cfg)
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			defer conn.Close()
+
+			var after uint64
+			n := int(c.Int("lines"))
+			for {
+				resp, err := conn.request(protocol.NewRequest("logs", protocol.LogsOptions{After: after, Lines: n}), nil, c.Bool("follow"))
+				if err != nil {
+					return unreachableError(fmt.Errorf("failed to send request: %w", err))
+				}
+				if !resp.Success {
+					return responseError(resp)
+				}
+
+				var lines []logging.Line
+				if err := json.Unmarshal([]byte(resp.Message), &lines); err != nil {
+					return fmt.Errorf("failed to parse logs: %w", err)
+				}
+				for _, line := range lines {
+					if jsonOutput {
+						data, _ := json.Marshal(line)
+						fmt.Println(string(data))
+					} else {
+						fmt.Println(line.Text)
+					}
+					after = line.Seq
+				}
+
+				if !c.Bool("follow") {
+					return nil
+				}
+				// Only the lines logged from now on
+				n = 0
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(500 * time.Millisecond):
+				}
+			}
+		},
+	}
+}
+
+func historyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "history",
+		Usage: "Query the history of captures and recordings",
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the most recent captures first",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: "Only list captures of this type: screenshot or recording",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"n"},
+						Usage:   "Maximum number of captures to list (0 for all)",
+						Value:   20,
+					},
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "Only list captures taken within this duration (e.g. 24h)",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := config.Load()
+					if err != nil {
+						return fmt.Errorf("failed to load config: %w", err)
+					}
+
+					if err := ensureDaemonRunning(cfg); err != nil {
+						return err
+					}
+
+					resp, err := sendRequest(cfg, protocol.NewRequest("history", protocol.HistoryOptions{
+						Kind:  c.String("type"),
+						Limit: int(c.Int("limit")),
+						Since: c.String("since"),
+					}))
+					if err != nil {
+						return unreachableError(fmt.Errorf("failed to send request: %w", err))
+					}
+					if jsonOutput {
+						return printResponse(resp)
+					}
+					if !resp.Success {
+						return responseError(resp)
+					}
+
+					var entries []history.Entry
+					if err := json.Unmarshal([]byte(resp.Message), &entries); err != nil {
+						return fmt.Errorf("failed to parse history: %w", err)
+					}
+					for _, entry := range entries {
+						file := entry.File
+						if file == "" {
+							file = "(clipboard)"
+						}
+						fmt.Printf("%s  %-10s  %-24s  %s\n", entry.Time.Format("2006-01-02 15:04:05"), entry.Kind, entry.Command, file)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func menuCommand() *cli.Command {
+	return createSimpleCommand("menu", "Pick a capture or recording to start, or control the recording in progress, from a menu")
+}
+
+func galleryCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "gallery",
+		Usage: "Pick a recent capture from a menu with previews to copy, open, edit, upload or delete it",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:    "limit",
+				Aliases: []string{"n"},
+				Usage:   "Number of recent captures to list",
+				Value:   30,
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			limit := int(c.Int("limit"))
+			req := protocol.NewRequest("gallery", protocol.GalleryOptions{Limit: &limit})
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
+func lastCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "last",
+		Usage: "Print the path of the most recent capture or recording",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "type",
+				Usage: "Only consider captures of this type: screenshot or recording",
+			},
+			&cli.BoolFlag{
+				Name:  "copy",
+				Usage: "Also copy the path to the clipboard",
+			},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			resp, err := sendRequest(cfg, protocol.NewRequest("last", protocol.LastOptions{
+				Kind: c.String("type"),
+				Copy: c.Bool("copy"),
+			}))
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			if jsonOutput {
+				return printResponse(resp)
+			}
+			if !resp.Success {
+				return responseError(resp)
+			}
+
+			fmt.Println(resp.Message)
+			return nil
+		},
+	}
+}
+
+func versionCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "version",
+		Usage: "Print the version of sway-easyshot, and of the daemon when it is running",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			versions := struct {
+				Client version.Info  `json:"client"`
+				Daemon *version.Info `json:"daemon,omitempty"`
+			}{Client: version.Get()}
+
+			// A daemon started for this would report the client version
+			if isDaemonRunning(cfg) {
+				resp, err := sendRequest(cfg, protocol.NewRequest("version", nil))
+				if err != nil {
+					return unreachableError(fmt.Errorf("failed to send request: %w", err))
+				}
+				// Daemons older than the version command know no such action
+				if resp.Success {
+					var daemon version.Info
+					if err := json.Unmarshal([]byte(resp.Message), &daemon); err != nil {
+						return fmt.Errorf("failed to parse daemon version: %w", err)
+					}
+					versions.Daemon = &daemon
+				}
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(versions)
+			}
+
+			fmt.Printf("Client: %s\n", versions.Client)
+			switch {
+			case versions.Daemon == nil:
+				fmt.Println("Daemon: not running")
+			case *versions.Daemon != versions.Client:
+				fmt.Printf("Daemon: %s (differs, restart it to run the client version)\n", versions.Daemon)
+			default:
+				fmt.Printf("Daemon: %s\n", versions.Daemon)
+			}
+			return nil
+		},
+	}
+}
+
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check the tools sway-easyshot needs are installed and working, and which features are degraded",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			// Creating the handlers points the features to the configured tools
+			st := state.NewState()
+			commands.NewScreenshotHandler(cfg, st, nil)
+			commands.NewOBSHandler(cfg, st)
+			results := append(commands.Doctor(ctx, cfg), checkDaemon(cfg))
+
+			failed := 0
+			for _, r := range results {
+				if r.Status == commands.DoctorFailed {
+					failed++
+				}
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(results); err != nil {
+					return err
+				}
+			} else {
+				for _, r := range results {
+					mark := "✓"
+					switch r.Status {
+					case commands.DoctorFailed:
+						mark = "✗"
+					case commands.DoctorDegraded:
+						mark = "-"
+					}
+					line := fmt.Sprintf("%s %s: %s", mark, r.Check, r.Status)
+					if r.Detail != "" {
+						line += " (" + r.Detail + ")"
+					}
+					fmt.Println(line)
+					if r.Hint != "" {
+						fmt.Printf("    %s\n", r.Hint)
+					}
+				}
+			}
+
+			if failed > 0 {
+				return &exitError{err: fmt.Errorf("%d check(s) failed", failed), code: exitUnavailable}
+			}
+			return nil
+		},
+	}
+}
+
+func installCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "install",
+		Usage: "Install integrations with the rest of the desktop",
+		Commands: []*cli.Command{
+			{
+				Name:  "systemd",
+				Usage: "Write a user service and socket unit, for systemd to start the daemon on demand",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite units already installed",
+					},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					cfg, err := config.Load()
+					if err != nil {
+						return fmt.Errorf("failed to load config: %w", err)
+					}
+					exe, err := os.Executable()
+					if err != nil {
+						return err
+					}
+
+					dir := systemd.UserUnitDir()
+					units := systemd.Units(exe, cfg.SocketPath, session.RuntimeDir())
+					if !c.Bool("force") {
+						for name := range units {
+							if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+								return fmt.Errorf("%s already exists, use --force to overwrite it", filepath.Join(dir, name))
+							}
+						}
+					}
+					if err := os.MkdirAll(dir, 0o755); err != nil {
+						return fmt.Errorf("failed to create %s: %w", dir, err)
+					}
+					for _, name := range []string{systemd.ServiceUnit, systemd.SocketUnit} {
+						path := filepath.Join(dir, name)
+						if err := os.WriteFile(path, []byte(units[name]), 0o644); err != nil {
+							return fmt.Errorf("failed to write %s: %w", path, err)
+						}
+						fmt.Printf("Wrote %s\n", path)
+					}
+
+					fmt.Printf("\nEnable it with:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s\n", systemd.SocketUnit)
+					fmt.Printf("\nThe daemon needs the session variables, which sway exports with:\n" +
+						"  exec systemctl --user import-environment WAYLAND_DISPLAY SWAYSOCK\n")
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// checkDaemon checks the running daemon answers, speaks the protocol of the
+// client and finds the required tools in its own environment, which may
+// differ from the one of the doctor.
+func checkDaemon(cfg *config.Config) commands.DoctorResult {
+	result := commands.DoctorResult{Check: "daemon", Status: commands.DoctorOK}
+	resp, err := daemonHealth(cfg)
+	switch {
+	case err != nil:
+		result.Detail = "not running, the first command starts it"
+	case checkHealth(resp) != nil:
+		result.Status, result.Detail, result.Hint = commands.DoctorFailed, checkHealth(resp).Error(), "run sway-easyshot daemon-restart"
+	case resp.Health == nil:
+		result.Status, result.Detail, result.Hint = commands.DoctorDegraded, "older than the client", "run sway-easyshot daemon-restart"
+	default:
+		var missing []string
+		for tool, ok := range resp.Health.Tools {
+			if !ok && slices.Contains(commands.RequiredTools(cfg), tool) {
+				missing = append(missing, tool)
+			}
+		}
+		sort.Strings(missing)
+		result.Detail = fmt.Sprintf("version %s, up %s", resp.Health.Version, time.Duration(resp.Health.Uptime*float64(time.Second)).Round(time.Second))
+		if len(missing) > 0 {
+			result.Status = commands.DoctorFailed
+			result.Detail = "missing " + strings.Join(missing, ", ") + " in its PATH"
+			result.Hint = "restart the daemon from an environment with a fuller PATH"
+		}
+	}
+	return result
+}
+
+func tutorialCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "tutorial",
+		Usage: "Walk through each capture mode and check it works on this setup",
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+			resp, err := sendRequest(cfg, protocol.NewRequest("status", nil))
+			if err != nil {
+				return unreachableError(fmt.Errorf("failed to send request: %w", err))
+			}
+			if !resp.Success {
+				return responseError(resp)
+			}
+			fmt.Println("✓ Daemon: running")
+			if resp.State.Recording {
+				return fmt.Errorf("a recording is in progress, stop it before starting the tutorial")
+			}
+
+			// The steps wait on the user for longer than a daemon request may
+			// last, so they run here with the same handlers as the daemon
+			st := state.NewState()
+			notify.SetTitle(cfg.NotifyTitle)
+			results, err := commands.Tutorial(ctx, commands.NewScreenshotHandler(cfg, st, nil), commands.NewRecordingHandler(cfg, st, nil))
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, r := range results {
+				mark := "✓"
+				switch r.Status {
+				case commands.TutorialFailed:
+					mark = "✗"
+					failed++
+				case commands.TutorialSkipped:
+					mark = "-"
+				}
+				line := fmt.Sprintf("%s %s: %s", mark, r.Step, r.Status)
+				if r.Detail != "" {
+					line += " (" + r.Detail + ")"
+				}
+				fmt.Println(line)
+			}
+
+			for _, name := range capability.Set(resp.State.Capabilities).Missing() {
+				fmt.Printf("- Optional feature %v\n", capability.Require(name))
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d tutorial step(s) failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// Helper functions for command creation
+
+func createSimpleCommand(name, usage string) *cli.Command {
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			req := protocol.NewRequest(name, nil)
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
+func forFlag() cli.Flag {
+	return &cli.DurationFlag{
+		Name:  "for",
+		Usage: "Stop and convert the recording automatically after this duration (e.g. 30s)",
+	}
+}
+
+func uploadFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "upload",
+		Aliases: []string{"u"},
+		Usage:   "Upload the capture with SWAY_SCREENSHOT_UPLOAD and copy its URL instead of offering actions",
+	}
+}
+
+func ephemeralFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "ephemeral",
+		Aliases: []string{"e"},
+		Usage:   "Save the capture to a temporary area, deleted after SWAY_SCREENSHOT_EPHEMERAL_TTL unless kept",
+	}
+}
+
+func encryptFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "encrypt",
+		Usage: "Encrypt the capture to SWAY_SCREENSHOT_ENCRYPT_RECIPIENT with age or gpg before saving it",
+	}
+}
+
+func timerFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "timer",
+		Usage: "Burn a timer into the recording: elapsed, clock or off (default: SWAY_SCREENSHOT_RECORDING_TIMER)",
+	}
+}
+
+func currentScreenFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "current-screen",
+		Aliases: []string{"c"},
+		Usage:   "Use current focused screen (skip selection)",
+	}
+}
+
+func lastRegionFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "last-region",
+		Aliases: []string{"l"},
+		Usage:   "Reuse the last selected region instead of asking for a new one",
+	}
+}
+
+func transparentFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "transparent",
+		Aliases: []string{"t"},
+		Usage:   "Preserve window transparency (needs the background to be restorable, see SWAY_SCREENSHOT_WALLPAPER)",
+	}
+}
+
+func decorationsFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "decorations",
+		Usage: "Window area: border (with borders), full (with title bar) or content (default: SWAY_SCREENSHOT_DECORATIONS or border)",
+	}
+}
+
+func prettyFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "pretty",
+		Aliases: []string{"p"},
+		Usage:   "Present the capture on a background with padding, rounded corners and a shadow",
+	}
+}
+
+func barsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "include-bars",
+			Usage: "Capture the whole output, bars included",
+		},
+		&cli.BoolFlag{
+			Name:  "exclude-bars",
+			Usage: "Crop the bars and panels out of the capture (default: SWAY_SCREENSHOT_EXCLUDE_BARS)",
+		},
+	}
+}
+
+// barsOption returns the bars option from the bar flags, empty when neither
+// is set so that the configured default applies.
+func barsOption(c *cli.Command) (string, error) {
+	switch {
+	case c.Bool("include-bars") && c.Bool("exclude-bars"):
+		return "", fmt.Errorf("--include-bars and --exclude-bars are mutually exclusive")
+	case c.Bool("include-bars"):
+		return "include", nil
+	case c.Bool("exclude-bars"):
+		return "exclude", nil
+	}
+	return "", nil
+}
+
+func createScreenshotCommand(name, usage string, extraFlags ...cli.Flag) *cli.Command {
+	flags := []cli.Flag{
+		&cli.IntFlag{
+			Name:    "delay",
+			Aliases: []string{"w"},
+			Usage:   "Delay capture/recording in seconds",
+			Value:   0,
+		},
+	}
+
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: append(flags, extraFlags...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			bars, err := barsOption(c)
+			if err != nil {
+				return err
+			}
+
+			if err := ensureDaemonRunning(cfg); err != nil {
+				return err
+			}
+
+			req := protocol.NewRequest(name, protocol.CaptureOptions{
+				Delay:            int(c.Int("delay")),
+				UseCurrentScreen: c.Bool("current-screen"),
+				Transparent:      c.Bool("transparent"),
+				LastRegion:       c.Bool("last-region"),
+				Composite:        c.Bool("composite"),
+				For:              durationOption(c, "for"),
+				Colors:           int(c.Int("colors")),
+				Save:             c.Bool("save"),
+				Lang:             c.String("lang"),
+				Translate:        c.String("translate"),
+				Workspace:        c.String("workspace"),
+				AppID:            c.String("app-id"),
+				Bars:             bars,
+				Zoom:             c.Float("zoom"),
+				Pretty:           c.Bool("pretty"),
+				Decorations:      c.String("decorations"),
+				MaxFrames:        int(c.Int("max-frames")),
+				Timer:            c.String("timer"),
+				Upload:           c.Bool("upload"),
+				Ephemeral:        c.Bool("ephemeral"),
+				Encrypt:          c.Bool("encrypt"),
+			})
+
+			return sendAndHandleRequest(cfg, req)
+		},
+	}
+}
+
+// durationOption returns a duration flag as a string option, empty when unset.
+func durationOption(c *cli.Command, name string) string {
+	if d := c.Duration(name); d > 0 {
+		return d.String()
+	}
+	return ""
+}
+
+func ensureDaemonRunning(cfg *config.Config) error {
+	resp, err := daemonHealth(cfg)
+	if err == nil {
+		return checkHealth(resp)
+	}
+	// Remote daemons are started on their own machine
+	if cfg.Remote != "" {
+		return unreachableError(fmt.Errorf("daemon at %s is not reachable: %w", cfg.Remote, err))
+	}
+
+	// A daemon holding the pid file may still be starting
+	if session.PidFileOwner(cfg.PidFile) == 0 {
+		if err := startDaemon(cfg); err != nil {
+			return unreachableError(fmt.Errorf("failed to start daemon: %w", err))
+		}
+	}
+
+	// Wait for daemon to be ready
+	for i := 0; i < 10; i++ {
+		if resp, err := daemonHealth(cfg); err == nil {
+			return checkHealth(resp)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if pid := session.PidFileOwner(cfg.PidFile); pid != 0 {
+		return unreachableError(fmt.Errorf("daemon (pid %d) is not responding, stop it with: kill %d", pid, pid))
+	}
+	return unreachableError(fmt.Errorf("daemon failed to start"))
+}
+
+// checkHealth fails when the daemon answering resp to the health action
+// cannot understand the client, e.g. when an upgrade left it running.
+// Daemons older than the action answer without health, but speak the first
+// version of the protocol.
+func checkHealth(resp *protocol.Response) error {
+	if resp.Health == nil || resp.Health.Protocol == protocol.Version {
+		return nil
+	}
+	return unreachableError(fmt.Errorf("daemon speaks version %d of the protocol and the client version %d, restart it with: sway-easyshot daemon-restart",
+		resp.Health.Protocol, protocol.Version))
+}
+
+func sendAndHandleRequest(cfg *config.Config, req protocol.Request) error {
+	// Keybindings run the client too, whose output goes to the session log
+	printer := newProgressPrinter(os.Stderr)
+	resp, err := streamRequest(cfg, req, printer.print)
+	printer.clear()
+	if err != nil {
+		return unreachableError(fmt.Errorf("failed to send request: %w", err))
+	}
+
+	if jsonOutput {
+		return printResponse(resp)
+	}
+
+	if !resp.Success {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// printResponse prints resp as JSON for scripts, still failing when the
+// command failed so that they can rely on the exit status.
+func printResponse(resp *protocol.Response) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(resp); err != nil {
+		return err
+	}
+	if !resp.Success {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Exit codes, for scripts to tell a cancelled capture from a failed one.
+const (
+	exitFailure     = 1
+	exitCancelled   = 2
+	exitUnreachable = 3
+	exitUnavailable = 4
+)
+
+// exitError is an error making the client exit with code.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// responseError returns the error of a failed response, exiting with the
+// code matching why it failed.
+func responseError(resp *protocol.Response) error {
+	code := exitFailure
+	switch resp.Code {
+	case protocol.CodeCancelled:
+		code = exitCancelled
+	case protocol.CodeUnavailable:
+		code = exitUnavailable
+	case protocol.CodeUnsupported:
+		code = exitUnreachable
+	}
+	return &exitError{err: fmt.Errorf("command failed: %s", resp.Message), code: code}
+}
+
+// unreachableError returns err, which kept the client from reaching the
+// daemon.
+func unreachableError(err error) error {
+	return &exitError{err: err, code: exitUnreachable}
+}
+
+// Utility functions
+
+// stopDaemon asks the daemon to shut down and waits for it to be gone.
+func stopDaemon(cfg *config.Config, force bool) error {
+	conn, err := dial(cfg)
+	if err != nil {
+		return unreachableError(fmt.Errorf("failed to send request: %w", err))
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(withToken(cfg, protocol.NewRequest("shutdown", protocol.ShutdownOptions{Force: force}))); err != nil {
+		return unreachableError(fmt.Errorf("failed to send request: %w", err))
+	}
+	var resp protocol.Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return unreachableError(fmt.Errorf("failed to send request: %w", err))
+	}
+	if jsonOutput {
+		err = printResponse(&resp)
+	} else if !resp.Success {
+		err = responseError(&resp)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The daemon closes the connection once stopped. Polling the socket
+	// instead would start it again when systemd listens on it.
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		return fmt.Errorf("daemon failed to stop: %w", err)
+	}
+	return nil
+}
+
+// isDaemonRunning tells whether a daemon answers, rather than merely
+// whether something listens on its socket.
+func isDaemonRunning(cfg *config.Config) bool {
+	_, err := daemonHealth(cfg)
+	return err == nil
+}
+
+// daemonHealth asks the daemon how it does, failing when it does not answer
+// promptly.
+func daemonHealth(cfg *config.Config) (*protocol.Response, error) {
+	conn, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(withToken(cfg, protocol.NewRequest("health", nil))); err != nil {
+		return nil, err
+	}
+	var resp protocol.Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// dial connects to the daemon: the one at SWAY_SCREENSHOT_REMOTE over TCP
+// when set, the one of the session otherwise.
+func dial(cfg *config.Config) (net.Conn, error) {
+	if cfg.Remote != "" {
+		return net.DialTimeout("tcp", cfg.Remote, 5*time.Second)
+	}
+	return net.Dial("unix", cfg.SocketPath)
+}
+
+// withToken returns req carrying the token of the remote daemon, if any.
+func withToken(cfg *config.Config, req protocol.Request) protocol.Request {
+	if cfg.Remote != "" {
+		req.Token = cfg.RemoteToken
+	}
+	return req
+}
 
 func startDaemon(cfg *config.Config) error {
 	// Get the current executable path
@@ -345,7 +1756,13 @@ func startDaemon(cfg *config.Config) error {
 		return err
 	}
 
+	env, err := session.Environment()
+	if err != nil {
+		return fmt.Errorf("cannot start daemon with a working session environment: %w", err)
+	}
+
 	cmd := exec.Command(exe, "daemon") //nolint:gosec
+	cmd.Env = env
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 
@@ -359,29 +1776,137 @@ func startDaemon(cfg *config.Config) error {
 	return nil
 }
 
-func sendRequest(socketPath string, req protocol.Request) (*protocol.Response, error) {
-	conn, err := net.Dial("unix", socketPath)
+func sendRequest(cfg *config.Config, req protocol.Request) (*protocol.Response, error) {
+	return streamRequest(cfg, req, nil)
+}
+
+// streamRequest sends req on a connection of its own and returns the final
+// response, passing the progress the daemon reports until then to progress,
+// unless it is nil.
+func streamRequest(cfg *config.Config, req protocol.Request, progress func(protocol.Progress)) (*protocol.Response, error) {
+	conn, err := dialDaemon(cfg)
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = conn.Close() }()
-
-	// Set timeout
-	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer conn.Close()
+	return conn.request(req, progress, false)
+}
 
-	encoder := json.NewEncoder(conn)
-	decoder := json.NewDecoder(conn)
+// daemonConn is a connection to the daemon, which may be kept alive for
+// several requests.
+type daemonConn struct {
+	cfg     *config.Config
+	conn    net.Conn
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
 
-	if err := encoder.Encode(req); err != nil {
+func dialDaemon(cfg *config.Config) (*daemonConn, error) {
+	conn, err := dial(cfg)
+	if err != nil {
 		return nil, err
 	}
+	return &daemonConn{cfg: cfg, conn: conn, encoder: json.NewEncoder(conn), decoder: json.NewDecoder(conn)}, nil
+}
 
-	var resp protocol.Response
-	if err := decoder.Decode(&resp); err != nil {
+// request sends req and returns the final response, passing the progress
+// the daemon reports until then to progress, unless it is nil. keepAlive
+// keeps the connection open for another request. Each message from the
+// daemon gives it the timeout of the command again, so long commands
+// reporting progress do not time out.
+func (c *daemonConn) request(req protocol.Request, progress func(protocol.Progress), keepAlive bool) (*protocol.Response, error) {
+	timeout := requestTimeout(c.cfg, req.Action)
+	extend := func() {
+		deadline := time.Time{}
+		if timeout > 0 {
+			deadline = time.Now().Add(timeout)
+		}
+		_ = c.conn.SetDeadline(deadline)
+	}
+	extend()
+
+	req.Version = protocol.Version
+	req.ID = requestID()
+	req.Progress = progress != nil
+	req.KeepAlive = keepAlive
+	if err := c.encoder.Encode(withToken(c.cfg, req)); err != nil {
 		return nil, err
 	}
 
-	return &resp, nil
+	for {
+		var resp protocol.Response
+		if err := c.decoder.Decode(&resp); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, fmt.Errorf("no answer from the daemon within %s, raise it with --timeout", timeout)
+			}
+			return nil, err
+		}
+		if resp.Progress == nil {
+			return &resp, nil
+		}
+		extend()
+		if progress != nil && resp.ID == req.ID {
+			progress(*resp.Progress)
+		}
+	}
+}
+
+func (c *daemonConn) Close() {
+	_ = c.conn.Close()
+}
+
+// requestTimeout returns how long to wait for the daemon to answer action:
+// the --timeout given, or the one configured.
+func requestTimeout(cfg *config.Config, action string) time.Duration {
+	if timeoutFlag != nil {
+		return *timeoutFlag
+	}
+	return cfg.TimeoutFor(action)
+}
+
+// requestCount numbers the requests of the client, for their IDs.
+var requestCount int
+
+// requestID returns an ID telling the requests of the client apart.
+func requestID() string {
+	requestCount++
+	return fmt.Sprintf("%d-%d", os.Getpid(), requestCount)
+}
+
+// progressPrinter prints the progress of a request to a terminal, keeping
+// a countdown or place in the queue on a single line rewritten in place.
+type progressPrinter struct {
+	w        *os.File
+	terminal bool
+	// counting is set while such a line is shown
+	counting bool
+}
+
+func newProgressPrinter(w *os.File) *progressPrinter {
+	info, err := w.Stat()
+	return &progressPrinter{w: w, terminal: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+func (p *progressPrinter) print(progress protocol.Progress) {
+	// Keybindings run the client too, whose output goes to the session log
+	if !p.terminal || progress.Stage == protocol.StageWorking {
+		return
+	}
+	p.clear()
+	if progress.Stage == protocol.StageCountdown || progress.Stage == protocol.StageQueued {
+		_, _ = fmt.Fprint(p.w, progress.Message)
+		p.counting = true
+		return
+	}
+	_, _ = fmt.Fprintln(p.w, progress.Message)
+}
+
+// clear removes the line rewritten in place, if any.
+func (p *progressPrinter) clear() {
+	if p.counting {
+		_, _ = fmt.Fprint(p.w, "\r\033[K")
+		p.counting = false
+	}
 }
 
 func handleWaybarStatus(cfg *config.Config, follow, noIdleOutput bool, c *cli.Command) error {
@@ -391,24 +1916,125 @@ func handleWaybarStatus(cfg *config.Config, follow, noIdleOutput bool, c *cli.Co
 		Paused:       c.String("icon-paused"),
 		ObsRecording: c.String("icon-obs-recording"),
 		ObsPaused:    c.String("icon-obs-paused"),
+		ObsStreaming: c.String("icon-obs-streaming"),
 		Countdown:    c.String("icon-countdown"),
 	}
+	write, err := newStatusWriter(os.Stdout, c.String("format"), c.Bool("color"), follow)
+	if err != nil {
+		return err
+	}
 	if follow {
-		return followWaybarStatus(cfg, icons, noIdleOutput)
+		return followWaybarStatus(cfg, icons, noIdleOutput, write)
+	}
+	return outputCurrentStatus(cfg, icons, noIdleOutput, write)
+}
+
+// statusWriter writes a status for a bar.
+type statusWriter func(status *protocol.WaybarStatus) error
+
+// statusColors are the colours of the states, for bars taking them.
+var statusColors = map[string]string{
+	"countdown": "#ffb86c",
+	"recording": "#ff5555",
+	"paused":    "#f1fa8c",
+	"streaming": "#ff5555",
+}
+
+// i3statusStates are the i3status-rust block states of the statuses.
+var i3statusStates = map[string]string{
+	"idle":      "Idle",
+	"countdown": "Info",
+	"recording": "Critical",
+	"paused":    "Warning",
+	"streaming": "Critical",
+}
+
+// newStatusWriter returns the writer of statuses to w in the format of a
+// bar: waybar or i3status-rust JSON, polybar or i3blocks lines, eww
+// key=value lines or plain text. The text is coloured by state when color
+// is set and the bar supports it. following tells whether statuses are
+// written continuously, which i3blocks wants as one line each.
+func newStatusWriter(w io.Writer, format string, color, following bool) (statusWriter, error) {
+	switch format {
+	case "", "waybar":
+		encoder := json.NewEncoder(w)
+		return func(status *protocol.WaybarStatus) error {
+			return encoder.Encode(status)
+		}, nil
+
+	case "i3status-rust":
+		encoder := json.NewEncoder(w)
+		return func(status *protocol.WaybarStatus) error {
+			return encoder.Encode(map[string]string{
+				"text":       status.Text,
+				"short_text": status.Text,
+				"state":      i3statusStates[status.Class],
+			})
+		}, nil
+
+	case "eww":
+		return func(status *protocol.WaybarStatus) error {
+			var b strings.Builder
+			for _, field := range [][2]string{
+				{"text", status.Text},
+				{"tooltip", status.Tooltip},
+				{"class", status.Class},
+				{"thumbnail", status.Thumbnail},
+			} {
+				// One line per key, whatever the value holds
+				fmt.Fprintf(&b, "%s=%s\n", field[0], strings.ReplaceAll(field[1], "\n", `\n`))
+			}
+			if following {
+				b.WriteString("\n")
+			}
+			_, err := io.WriteString(w, b.String())
+			return err
+		}, nil
+
+	case "plain":
+		return func(status *protocol.WaybarStatus) error {
+			_, err := fmt.Fprintln(w, status.Text)
+			return err
+		}, nil
+
+	case "polybar":
+		return func(status *protocol.WaybarStatus) error {
+			text := status.Text
+			if c, ok := statusColors[status.Class]; ok && color && text != "" {
+				text = "%{F" + c + "}" + text + "%{F-}"
+			}
+			_, err := fmt.Fprintln(w, text)
+			return err
+		}, nil
+
+	case "i3blocks":
+		return func(status *protocol.WaybarStatus) error {
+			if following {
+				_, err := fmt.Fprintln(w, status.Text)
+				return err
+			}
+			// Full text, short text and colour
+			lines := status.Text + "\n" + status.Text + "\n"
+			if c, ok := statusColors[status.Class]; ok && color {
+				lines += c + "\n"
+			}
+			_, err := io.WriteString(w, lines)
+			return err
+		}, nil
 	}
-	return outputCurrentStatus(cfg, icons, noIdleOutput)
+	return nil, fmt.Errorf("unknown status format %q (want waybar, polybar, i3blocks, i3status-rust, eww or plain)", format)
 }
 
-func outputCurrentStatus(cfg *config.Config, icons state.Icons, noIdleOutput bool) error {
+func outputCurrentStatus(cfg *config.Config, icons state.Icons, noIdleOutput bool, write statusWriter) error {
 	status := getWaybarStatus(cfg, icons)
 	if noIdleOutput && status.Class == "idle" {
-		status = &protocol.WaybarStatus{Text: "", Tooltip: "", Class: "idle", Alt: "idle"}
+		status = &protocol.WaybarStatus{Text: "", Tooltip: "", Class: "idle", Alt: "idle", Thumbnail: status.Thumbnail}
 	}
-	return json.NewEncoder(os.Stdout).Encode(status)
+	return write(status)
 }
 
 func getWaybarStatus(cfg *config.Config, icons state.Icons) *protocol.WaybarStatus {
-	if !isDaemonRunning(cfg.SocketPath) {
+	if !isDaemonRunning(cfg) {
 		// Daemon not running, return idle status
 		return &protocol.WaybarStatus{
 			Text:    icons.Idle,
@@ -418,15 +2044,9 @@ func getWaybarStatus(cfg *config.Config, icons state.Icons) *protocol.WaybarStat
 		}
 	}
 
-	req := protocol.Request{
-		Command: "execute",
-		Action:  "waybar-status",
-		Options: map[string]interface{}{
-			"icons": icons,
-		},
-	}
+	req := protocol.NewRequest("waybar-status", protocol.WaybarStatusOptions{Icons: iconsOption(icons)})
 
-	resp, err := sendRequest(cfg.SocketPath, req)
+	resp, err := sendRequest(cfg, req)
 	if err != nil {
 		// Fallback to idle status on error
 		return &protocol.WaybarStatus{
@@ -452,46 +2072,137 @@ func getWaybarStatus(cfg *config.Config, icons state.Icons) *protocol.WaybarStat
 	return &status
 }
 
-func followWaybarStatus(cfg *config.Config, icons state.Icons, noIdleOutput bool) error {
+func followWaybarStatus(cfg *config.Config, icons state.Icons, noIdleOutput bool, write statusWriter) error {
 	var previousStatus *protocol.WaybarStatus
-	ticker := time.NewTicker(cfg.WaybarPollInterval)
-	defer ticker.Stop()
+	show := func(currentStatus *protocol.WaybarStatus) error {
+		if statusEqual(previousStatus, currentStatus) {
+			return nil
+		}
+		previousStatus = currentStatus
+		outputStatus := currentStatus
+		if noIdleOutput && currentStatus.Class == "idle" {
+			outputStatus = &protocol.WaybarStatus{Text: "", Tooltip: "", Class: "idle", Alt: "idle", Thumbnail: currentStatus.Thumbnail}
+		}
+		return write(outputStatus)
+	}
 
 	// Signal handling for Ctrl+C
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
-	// Output initial status immediately
-	currentStatus := getWaybarStatus(cfg, icons)
-	outputStatus := currentStatus
-	if noIdleOutput && currentStatus.Class == "idle" {
-		outputStatus = &protocol.WaybarStatus{Text: "", Tooltip: "", Class: "idle", Alt: "idle"}
-	}
-	if err := json.NewEncoder(os.Stdout).Encode(outputStatus); err != nil {
-		return err
-	}
-	previousStatus = currentStatus
-
 	for {
-		select {
-		case <-ticker.C:
-			currentStatus := getWaybarStatus(cfg, icons)
-			if !statusEqual(previousStatus, currentStatus) {
-				outputStatus := currentStatus
-				if noIdleOutput && currentStatus.Class == "idle" {
-					outputStatus = &protocol.WaybarStatus{Text: "", Tooltip: "", Class: "idle", Alt: "idle"}
+		// The daemon pushes the status as it changes
+		events, stop, err := subscribe(cfg, protocol.SubscribeOptions{Icons: iconsOption(icons)})
+		if err != nil {
+			// No daemon answers, or one too old to push events: poll
+			if err := show(getWaybarStatus(cfg, icons)); err != nil {
+				return err
+			}
+			select {
+			case <-time.After(cfg.WaybarPollInterval):
+				continue
+			case <-sigChan:
+				return nil
+			}
+		}
+
+	following:
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					break following
+				}
+				if event.Status == nil {
+					continue
 				}
-				if err := json.NewEncoder(os.Stdout).Encode(outputStatus); err != nil {
+				if err := show(event.Status); err != nil {
+					stop()
 					return err
 				}
-				previousStatus = currentStatus
+			case <-sigChan:
+				stop()
+				return nil
 			}
-		case <-sigChan:
-			return nil
 		}
+		stop()
+	}
+}
+
+// iconsOption returns icons as the daemon takes them in options.
+func iconsOption(icons state.Icons) map[string]string {
+	return map[string]string{
+		"Idle":         icons.Idle,
+		"Recording":    icons.Recording,
+		"Paused":       icons.Paused,
+		"ObsRecording": icons.ObsRecording,
+		"ObsPaused":    icons.ObsPaused,
+		"ObsStreaming": icons.ObsStreaming,
+		"Countdown":    icons.Countdown,
 	}
 }
 
+// subscribe subscribes to the events of the daemon, passed to the returned
+// channel, which is closed when the connection is lost. The returned
+// function ends the subscription.
+func subscribe(cfg *config.Config, options protocol.SubscribeOptions) (<-chan protocol.Event, func(), error) {
+	conn, err := dial(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := withToken(cfg, protocol.NewRequest("subscribe", options))
+	if timeout := requestTimeout(cfg, req.Action); timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	decoder := json.NewDecoder(conn)
+	var first protocol.Response
+	if err := json.NewEncoder(conn).Encode(req); err == nil {
+		err = decoder.Decode(&first)
+	}
+	if err != nil || first.Event == nil {
+		_ = conn.Close()
+		switch {
+		case err != nil:
+			return nil, nil, err
+		case !first.Success:
+			return nil, nil, responseError(&first)
+		}
+		return nil, nil, fmt.Errorf("the daemon sent no event")
+	}
+	// Events only come when something changes
+	_ = conn.SetDeadline(time.Time{})
+
+	events := make(chan protocol.Event, 1)
+	events <- *first.Event
+	done := make(chan struct{})
+	go func() {
+		defer close(events)
+		for {
+			var resp protocol.Response
+			if err := decoder.Decode(&resp); err != nil {
+				return
+			}
+			if resp.Event == nil {
+				continue
+			}
+			select {
+			case events <- *resp.Event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			close(done)
+			_ = conn.Close()
+		})
+	}, nil
+}
+
 func statusEqual(a, b *protocol.WaybarStatus) bool {
 	if a == nil || b == nil {
 		return a == b
@@ -499,5 +2210,6 @@ func statusEqual(a, b *protocol.WaybarStatus) bool {
 	return a.Text == b.Text &&
 		a.Tooltip == b.Tooltip &&
 		a.Class == b.Class &&
-		a.Alt == b.Alt
+		a.Alt == b.Alt &&
+		a.Thumbnail == b.Thumbnail
 }
@@ -1,5 +1,19 @@
-module sway-easyshot
+module github.com/chmouel/sway-easyshot
 
 go 1.25.6
 
-require github.com/urfave/cli/v3 v3.6.2
+require (
+	github.com/godbus/dbus/v5 v5.2.2
+	github.com/urfave/cli/v3 v3.6.2
+	golang.org/x/image v0.25.0
+	google.golang.org/grpc v1.76.0
+	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250804133106-a7a43d27e69b // indirect
+)
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/trace"
+)
+
+// Backend names.
+const (
+	AIChat = "aichat"
+	Ollama = "ollama"
+	OpenAI = "openai"
+)
+
+// Request is a prompt sent to a model, along with either a PNG image or
+// a text to work on.
+type Request struct {
+	Model  string
+	Prompt string
+	Image  []byte
+	Text   string
+}
+
+// Backend answers prompts with a language model.
+type Backend interface {
+	// Chat sends the request and returns the answer, trimmed.
+	Chat(ctx context.Context, req Request) (string, error)
+	// Tools lists the external commands the backend needs.
+	Tools() []string
+}
+
+// New returns the backend called name, endpoint and apiKey being used by the
+// HTTP backends (an empty endpoint selects their usual default) and tempDir
+// by those needing files (the system default when empty).
+func New(name, endpoint, apiKey, tempDir string) (Backend, error) {
+	switch name {
+	case AIChat, "":
+		return aichat{tempDir: tempDir}, nil
+	case Ollama:
+		if endpoint == "" {
+			endpoint = "http://localhost:11434"
+		}
+		return ollama{endpoint: endpoint}, nil
+	case OpenAI:
+		if endpoint == "" {
+			endpoint = "https://api.openai.com/v1"
+		}
+		return openAI{endpoint: endpoint, apiKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI backend: %s (valid: %s, %s, %s)", name, AIChat, Ollama, OpenAI)
+	}
+}
+
+var client = &http.Client{Timeout: 2 * time.Minute}
+
+// postJSON posts body as JSON to url and decodes the JSON answer into out.
+func postJSON(ctx context.Context, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		trace.Add(trace.KindHTTP, "POST %s (error: %v)", url, err)
+		return fmt.Errorf("failed to reach %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	trace.Add(trace.KindHTTP, "POST %s (status %d)", url, resp.StatusCode)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read answer: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s: %s", url, resp.Status, bytes.TrimSpace(data))
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// aichat runs the aichat command, which picks the provider from the model
+// name (e.g. "gemini:gemini-2.5-flash").
+type aichat struct {
+	tempDir string
+}
+
+func (aichat) Tools() []string {
+	return []string{"aichat"}
+}
+
+func (a aichat) Chat(ctx context.Context, req Request) (string, error) {
+	if req.Image == nil {
+		return external.AIChatText(ctx, req.Model, req.Prompt, req.Text)
+	}
+
+	// aichat only reads attachments from files
+	if a.tempDir != "" {
+		if err := os.MkdirAll(a.tempDir, 0o700); err != nil {
+			return "", fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+	}
+	f, err := os.CreateTemp(a.tempDir, "ai-*.png")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(req.Image); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	return external.AIChat(ctx, req.Model, f.Name(), req.Prompt)
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+)
+
+// ollama talks to the generate endpoint of an Ollama server.
+type ollama struct {
+	endpoint string
+}
+
+func (ollama) Tools() []string {
+	return nil
+}
+
+func (o ollama) Chat(ctx context.Context, req Request) (string, error) {
+	body := map[string]interface{}{
+		"model":  req.Model,
+		"prompt": joinPrompt(req),
+		"stream": false,
+	}
+	if req.Image != nil {
+		body["images"] = []string{base64.StdEncoding.EncodeToString(req.Image)}
+	}
+
+	var answer struct {
+		Response string `json:"response"`
+	}
+	if err := postJSON(ctx, strings.TrimSuffix(o.endpoint, "/")+"/api/generate", nil, body, &answer); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer.Response), nil
+}
+
+// joinPrompt appends the text to work on, if any, to the prompt.
+func joinPrompt(req Request) string {
+	if req.Text == "" {
+		return req.Prompt
+	}
+	return req.Prompt + "\n\n" + req.Text
+}
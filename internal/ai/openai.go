@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// openAI talks to the chat completions endpoint of the OpenAI API or of any
+// server compatible with it (llama.cpp, vLLM, LocalAI, OpenRouter...).
+type openAI struct {
+	endpoint string
+	apiKey   string
+}
+
+func (openAI) Tools() []string {
+	return nil
+}
+
+func (o openAI) Chat(ctx context.Context, req Request) (string, error) {
+	content := []map[string]interface{}{
+		{"type": "text", "text": joinPrompt(req)},
+	}
+	if req.Image != nil {
+		content = append(content, map[string]interface{}{
+			"type": "image_url",
+			"image_url": map[string]string{
+				"url": "data:image/png;base64," + base64.StdEncoding.EncodeToString(req.Image),
+			},
+		})
+	}
+	body := map[string]interface{}{
+		"model":    req.Model,
+		"messages": []map[string]interface{}{{"role": "user", "content": content}},
+	}
+
+	headers := map[string]string{}
+	if o.apiKey != "" {
+		headers["Authorization"] = "Bearer " + o.apiKey
+	}
+
+	var answer struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, strings.TrimSuffix(o.endpoint, "/")+"/chat/completions", headers, body, &answer); err != nil {
+		return "", err
+	}
+	if len(answer.Choices) == 0 {
+		return "", fmt.Errorf("no answer returned by the model")
+	}
+	return strings.TrimSpace(answer.Choices[0].Message.Content), nil
+}
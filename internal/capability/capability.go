@@ -0,0 +1,123 @@
+package capability
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Optional features whose availability depends on external tools.
+const (
+	AI          = "ai"
+	OBS         = "obs"
+	Editor      = "editor"
+	Dialog      = "dialog"
+	Menu        = "menu"
+	FileManager = "file-manager"
+	OCR         = "ocr"
+	Barcode     = "barcode"
+	X11Props    = "x11-props"
+	Cursor      = "cursor"
+	Scroll      = "scroll"
+	FullEditor  = "full-editor"
+	QRCode      = "qr-code"
+	Encrypt     = "encrypt"
+)
+
+// Feature describes an optional feature and the tools it needs.
+type Feature struct {
+	Name  string
+	Tools []string
+	Hint  string
+}
+
+// Features lists the optional features known to sway-easyshot.
+var Features = []Feature{
+	{Name: AI, Tools: []string{"aichat"}, Hint: "install aichat (https://github.com/sigoden/aichat) and configure a model"},
+	{Name: OBS, Tools: []string{"pass"}, Hint: "store the OBS WebSocket server password in pass as obs/password, or set SWAY_SCREENSHOT_OBS_PASSWORD_COMMAND"},
+	{Name: Editor, Tools: []string{"satty"}, Hint: "install satty (https://github.com/gabm/satty)"},
+	{Name: FullEditor, Tools: []string{"gimp"}, Hint: "install GIMP, Krita or Inkscape and point SWAY_SCREENSHOT_FULL_EDITOR to it"},
+	{Name: Dialog, Tools: []string{"zenity"}, Hint: "install zenity"},
+	{Name: Menu, Tools: []string{"wofi"}, Hint: "install wofi"},
+	{Name: FileManager, Tools: []string{"nautilus"}, Hint: "install nautilus"},
+	{Name: Barcode, Tools: []string{"zbarimg"}, Hint: "install zbar (https://github.com/mchehab/zbar)"},
+	{Name: X11Props, Tools: []string{"xprop"}, Hint: "install xprop (xorg-xprop) to trim the shadows of client-side decorated Xwayland windows"},
+	{Name: Cursor, Tools: []string{"wl-find-cursor"}, Hint: "install wl-find-cursor (https://github.com/cjacker/wl-find-cursor) for zoomed recordings to follow the pointer rather than the focus"},
+	{Name: Scroll, Hint: "install the tool of SWAY_SCREENSHOT_SCROLL_METHOD (wtype or ydotool) or set it to sway"},
+	{Name: QRCode, Tools: []string{"qrencode", "imv"}, Hint: "install qrencode and the image viewer of SWAY_SCREENSHOT_QR_VIEWER (imv by default)"},
+	{Name: Encrypt, Tools: []string{"age"}, Hint: "install the tool of SWAY_SCREENSHOT_ENCRYPT_TOOL (age or gpg)"},
+	{Name: OCR, Tools: []string{"tesseract"}, Hint: "install tesseract and the language data you need (e.g. tesseract-data-eng)"},
+}
+
+// MissingTools returns the tools of the feature which are not installed.
+func (f Feature) MissingTools() []string {
+	return missing(f)
+}
+
+// Set maps feature names to their availability.
+type Set map[string]bool
+
+// Probe checks the availability of every known feature.
+func Probe() Set {
+	set := make(Set, len(Features))
+	for _, f := range Features {
+		set[f.Name] = len(missing(f)) == 0
+	}
+	return set
+}
+
+// Missing returns the names of the unavailable features, sorted.
+func (s Set) Missing() []string {
+	var names []string
+	for name, ok := range s {
+		if !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ErrUnavailable is wrapped by the errors of Require.
+var ErrUnavailable = errors.New("unavailable")
+
+// Require returns an error with guidance when a feature cannot be used.
+func Require(name string) error {
+	for _, f := range Features {
+		if f.Name != name {
+			continue
+		}
+		if tools := missing(f); len(tools) > 0 {
+			return fmt.Errorf("%s is %w (missing %s): %s", name, ErrUnavailable, strings.Join(tools, ", "), f.Hint)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown feature: %s", name)
+}
+
+// SetTools changes the tools a feature needs, for features whose
+// implementation is configurable.
+func SetTools(name string, tools []string) {
+	for i := range Features {
+		if Features[i].Name == name {
+			Features[i].Tools = tools
+		}
+	}
+}
+
+// Available reports whether a feature can be used.
+func Available(name string) bool {
+	return Require(name) == nil
+}
+
+func missing(f Feature) []string {
+	var tools []string
+	for _, tool := range f.Tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
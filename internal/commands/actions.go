@@ -0,0 +1,516 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/chmouel/sway-easyshot/internal/ai"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// capture is the result of a screenshot handed to post-capture actions.
+// File is empty when the capture only went to the clipboard, Geometry when
+// the captured area is no longer shown where it was.
+type capture struct {
+	File     string
+	Data     []byte
+	Tags     tags.Tags
+	Geometry string
+}
+
+// bytes returns the image data, reading it from the file if needed.
+func (c *capture) bytes() ([]byte, error) {
+	if c.Data == nil && c.File != "" {
+		data, err := os.ReadFile(c.File)
+		if err != nil {
+			return nil, err
+		}
+		c.Data = data
+	}
+	return c.Data, nil
+}
+
+// builtinAction describes an action offered after a capture.
+type builtinAction struct {
+	Label         string
+	NeedsFile     bool
+	NeedsGeometry bool
+	NeedsUploader bool
+	Requires      []string
+}
+
+// builtinActions lists the actions which can be referenced in action sets.
+var builtinActions = map[string]builtinAction{
+	"copyclip": {Label: "Copy image"},
+	"copypath": {Label: "Copy path", NeedsFile: true},
+	"rename":   {Label: "Rename", NeedsFile: true, Requires: []string{capability.Dialog}},
+	"edit":     {Label: "Edit", Requires: []string{capability.Dialog, capability.Editor}},
+	"save":     {Label: "Save", Requires: []string{capability.Dialog}},
+	"saveai":   {Label: "Save with AI", Requires: []string{capability.Dialog, capability.AI}},
+	"alttext":  {Label: "Alt text", Requires: []string{capability.AI}},
+	"redact":   {Label: "Pixelate", NeedsGeometry: true},
+	"layers":   {Label: "Open in full editor", Requires: []string{capability.FullEditor}},
+	"upload":   {Label: "Upload", NeedsUploader: true},
+}
+
+// actionsFor returns the configured actions for a command which can be used
+// on the capture, in order.
+func (h *ScreenshotHandler) actionsFor(command string, c *capture) []notify.Action {
+	var actions []notify.Action
+
+	for _, id := range h.cfg().Actions[command] {
+		if hook, ok := h.cfg().Hook(id); ok {
+			actions = append(actions, notify.Action{ID: id, Label: hook.Label})
+			continue
+		}
+		if provider, ok := h.providers()[id]; ok {
+			actions = append(actions, notify.Action{ID: id, Label: provider.Label()})
+			continue
+		}
+		if aiAction, ok := h.cfg().AIAction(id); ok {
+			if capability.Available(capability.AI) {
+				actions = append(actions, notify.Action{ID: id, Label: aiAction.Label})
+			}
+			continue
+		}
+
+		builtin, ok := builtinActions[id]
+		if !ok || (builtin.NeedsFile && c.File == "") || (builtin.NeedsGeometry && c.Geometry == "") || !available(builtin.Requires) {
+			continue
+		}
+		if _, err := h.defaultProvider(); builtin.NeedsUploader && err != nil {
+			continue
+		}
+		actions = append(actions, notify.Action{ID: id, Label: builtin.Label})
+	}
+
+	place := config.InNotification
+	if command == config.InMenu {
+		place = config.InMenu
+	}
+	for _, hook := range h.cfg().Hooks {
+		if hook.AppearsInPlace(place) && !slices.Contains(h.cfg().Actions[command], hook.Name) {
+			actions = append(actions, notify.Action{ID: hook.Name, Label: hook.Label})
+		}
+	}
+
+	return actions
+}
+
+func available(features []string) bool {
+	for _, feature := range features {
+		if !capability.Available(feature) {
+			return false
+		}
+	}
+	return true
+}
+
+// offerActions shows the post-capture actions configured for command and
+// runs the selected one. It reports false when no action could be offered.
+func (h *ScreenshotHandler) offerActions(ctx context.Context, command, message string, c *capture) (bool, error) {
+	// The actions work on the file, which cannot be read once encrypted
+	if encrypting(ctx) {
+		return false, nil
+	}
+	if autoUpload(ctx) {
+		provider, err := h.defaultProvider()
+		if err != nil {
+			return true, err
+		}
+		return true, h.uploadCapture(ctx, provider, c)
+	}
+
+	actions := h.actionsFor(command, c)
+	if h.isEphemeral(c.File) {
+		actions = append([]notify.Action{{ID: "keep", Label: "Keep"}}, actions...)
+		message = h.ephemeralNote(message)
+	}
+	if len(actions) == 0 {
+		return false, nil
+	}
+
+	action, err := notify.SendWithActions(30000, h.cfg().ScreenshotIcon, message, actions)
+	if err != nil {
+		return false, nil
+	}
+
+	action = strings.TrimSpace(action)
+	if action == "" {
+		return true, nil
+	}
+
+	return true, h.runAction(ctx, action, c)
+}
+
+// runAction runs a built-in, hook, upload or AI action on a capture.
+func (h *ScreenshotHandler) runAction(ctx context.Context, action string, c *capture) error {
+	if hook, ok := h.cfg().Hook(action); ok {
+		return h.runHook(ctx, hook, c)
+	}
+	if provider, ok := h.providers()[action]; ok {
+		return h.uploadCapture(ctx, provider, c)
+	}
+	if aiAction, ok := h.cfg().AIAction(action); ok {
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		return h.copyAIAnswer(ctx, data, aiAction.Prompt, aiAction.Label)
+	}
+
+	switch action {
+	case "copyclip":
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		return h.copyToClipboard(ctx, data, "image/png")
+
+	case "copypath":
+		return h.copyText(ctx, c.File)
+
+	case "keep":
+		return h.keep(c)
+
+	case "rename":
+		newname, err := external.Zenity(ctx, "Rename file", filepath.Base(c.File))
+		if err != nil || newname == "" {
+			return nil
+		}
+		target := filepath.Join(h.cfg().SaveLocation, withPNGExt(newname))
+		if err := moveFile(c.File, target); err != nil {
+			return err
+		}
+		h.undo.Moved("Rename", c.File, target)
+		return h.offerUndo(ctx, fmt.Sprintf("Renamed to %s", filepath.Base(target)))
+
+	case "edit":
+		newname, err := external.Zenity(ctx, "File Name", h.defaultName(c))
+		if err != nil || newname == "" {
+			return nil
+		}
+
+		input := c.File
+		if input == "" {
+			data, err := c.bytes()
+			if err != nil {
+				return err
+			}
+			tmpFile, cleanup, err := h.writeTemp(data)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			input = tmpFile
+		}
+
+		return external.Satty(ctx, input, filepath.Join(h.cfg().SaveLocation, withPNGExt(newname)), true)
+
+	case "redact":
+		return h.redact(ctx, c)
+
+	case "layers":
+		return h.openLayered(ctx, c)
+
+	case "upload":
+		provider, err := h.defaultProvider()
+		if err != nil {
+			return err
+		}
+		return h.uploadCapture(ctx, provider, c)
+
+	case "alttext":
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		return h.copyAIAnswer(ctx, data, h.cfg().AIPrompts["alttext"], "Alt text")
+
+	case "save", "saveai":
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+
+		defaultName := h.defaultName(c)
+		if action == "saveai" {
+			if aiName := h.aiFilename(ctx, data); aiName != "" {
+				defaultName = withPNGExt(aiName)
+			}
+		}
+
+		newname, err := external.Zenity(ctx, "File Name", defaultName)
+		if err != nil || newname == "" {
+			return nil
+		}
+
+		outputFile := filepath.Join(h.cfg().SaveLocation, withPNGExt(newname))
+		if err := os.WriteFile(outputFile, data, 0o600); err != nil {
+			return err
+		}
+		noteSaved(ctx, outputFile)
+
+		// Open in file manager
+		if !capability.Available(capability.FileManager) {
+			return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(outputFile)))
+		}
+		return external.Nautilus(ctx, "file://"+outputFile)
+	}
+
+	return nil
+}
+
+// runHook runs a user-defined action on the capture, saving it to a
+// temporary file first when it only lives in the clipboard.
+func (h *ScreenshotHandler) runHook(ctx context.Context, hook config.Hook, c *capture) error {
+	file := c.File
+	if file == "" {
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		tmpFile, cleanup, err := h.writeTemp(data)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		file = tmpFile
+	}
+
+	if provider, ok := h.providers()[hook.Name]; ok && hook.Upload {
+		return h.upload(ctx, provider, file, c.Tags)
+	}
+
+	output, err := runHookCommand(ctx, hook, file, c.Tags)
+	if err != nil {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s failed: %v", hook.Label, err))
+		return err
+	}
+
+	message := fmt.Sprintf("%s done", hook.Label)
+	if output != "" {
+		message += ": " + output
+	}
+	return notify.Send(3000, h.cfg().ScreenshotIcon, message)
+}
+
+// runHookCommand runs the command of a hook on file and returns its output.
+// The command line is a single template, split into arguments like the
+// shell would once rendered. The values stand in for placeholders until
+// then, so that a path or workspace name with blanks stays one argument.
+func runHookCommand(ctx context.Context, hook config.Hook, file string, t tags.Tags) (string, error) {
+	values := []string{file, filepath.Dir(file), filepath.Base(file), t.Workspace, t.AppID, t.Project}
+	placeholders := make([]string, len(values))
+	var replacements []string
+	for i, value := range values {
+		// Empty values stay empty, for conditions on them to hold
+		if value != "" {
+			placeholders[i] = fmt.Sprintf("\x00%d\x00", i)
+			replacements = append(replacements, placeholders[i], value)
+		}
+	}
+	data := struct {
+		File      string
+		Dir       string
+		Name      string
+		Workspace string
+		AppID     string
+		Project   string
+	}{placeholders[0], placeholders[1], placeholders[2], placeholders[3], placeholders[4], placeholders[5]}
+
+	tmpl, err := template.New(hook.Name).Parse(hook.Exec)
+	if err != nil {
+		return "", fmt.Errorf("invalid exec for hook %s: %w", hook.Name, err)
+	}
+	var line strings.Builder
+	if err := tmpl.Execute(&line, data); err != nil {
+		return "", fmt.Errorf("invalid exec for hook %s: %w", hook.Name, err)
+	}
+	args, err := external.SplitWords(line.String())
+	if err != nil {
+		return "", fmt.Errorf("invalid exec for hook %s: %w", hook.Name, err)
+	}
+	replacer := strings.NewReplacer(replacements...)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	output, err := external.Command(ctx, args)
+	if err != nil {
+		return "", fmt.Errorf("hook %s failed: %w", hook.Name, err)
+	}
+	return output, nil
+}
+
+// defaultName returns the filename proposed when saving a capture.
+func (h *ScreenshotHandler) defaultName(c *capture) string {
+	if c.File != "" {
+		return filepath.Base(c.File)
+	}
+	return filepath.Base(h.cfg().GenerateTaggedFilename(c.Tags))
+}
+
+// aiFilename asks the AI model for a filename slug, empty on failure.
+func (h *ScreenshotHandler) aiFilename(ctx context.Context, data []byte) string {
+	aiName, err := h.ai().Chat(ctx, ai.Request{Model: h.cfg().AIModelImage, Prompt: h.cfg().AIPrompts["filename"], Image: data})
+	if err != nil {
+		return ""
+	}
+	return aiName
+}
+
+// copyAIAnswer sends an image to the AI model with a prompt and copies the
+// answer to the clipboard, label naming it in notifications.
+func (h *ScreenshotHandler) copyAIAnswer(ctx context.Context, data []byte, prompt, label string) error {
+	answer, err := h.ai().Chat(ctx, ai.Request{Model: h.cfg().AIModelImage, Prompt: prompt, Image: data})
+	if err != nil || answer == "" {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s: no answer from the AI model", label))
+		return fmt.Errorf("failed to get %s from AI model: %w", label, err)
+	}
+
+	if err := h.copyText(ctx, answer); err != nil {
+		return err
+	}
+	return notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s copied:\n%s", label, preview(answer, ocrPreviewLength)))
+}
+
+// writeTemp writes image data to a new private temporary file, returning its
+// path and a function removing it.
+func (h *ScreenshotHandler) writeTemp(data []byte) (string, func(), error) {
+	if err := os.MkdirAll(h.cfg().TempDir, 0o700); err != nil {
+		return "", nil, err
+	}
+	f, err := os.CreateTemp(h.cfg().TempDir, "capture-*.png")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// tempDir creates a new private temporary directory, removed along the
+// other temporary files when the daemon stops if the caller does not.
+func (h *ScreenshotHandler) tempDir(pattern string) (string, error) {
+	if err := os.MkdirAll(h.cfg().TempDir, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(h.cfg().TempDir, pattern)
+}
+
+// RemoveTemp removes the temporary files, which only live as long as the
+// operation using them, so that none outlives the daemon even when it
+// crashed.
+func (h *ScreenshotHandler) RemoveTemp() {
+	_ = os.RemoveAll(h.cfg().TempDir)
+}
+
+func withPNGExt(name string) string {
+	if strings.HasSuffix(name, ".png") {
+		return name
+	}
+	return name + ".png"
+}
+
+// RunAction runs the named action on file, or on the last capture when file
+// is empty, outside of a notification. Without a name, the action is chosen
+// from the menu action set.
+func (h *ScreenshotHandler) RunAction(ctx context.Context, name, file string) error {
+	c := &capture{File: file}
+	if c.File == "" {
+		c.File = h.state.GetLastCapture()
+	}
+	if c.File == "" {
+		// The last capture only went to the clipboard
+		data, err := external.WlPaste(ctx, "image/png")
+		if err != nil || len(data) == 0 {
+			return fmt.Errorf("no capture to run an action on")
+		}
+		c.Data = data
+	}
+
+	if name == "" {
+		if err := capability.Require(capability.Menu); err != nil {
+			return err
+		}
+		actions := h.actionsFor(config.InMenu, c)
+		if len(actions) == 0 {
+			return fmt.Errorf("no action available, add some to the %s action set", config.InMenu)
+		}
+		labels := make([]string, len(actions))
+		for i, action := range actions {
+			labels[i] = action.Label
+		}
+		choice, err := external.Wofi(ctx, "Action", labels)
+		if err != nil || choice == "" {
+			return nil
+		}
+		for _, action := range actions {
+			if action.Label == choice {
+				name = action.ID
+				break
+			}
+		}
+		if name == "" {
+			return nil
+		}
+	}
+
+	if err := h.checkAction(name, c); err != nil {
+		return err
+	}
+	return h.runAction(ctx, name, c)
+}
+
+// checkAction returns an error when the action is unknown or cannot be run
+// on the capture.
+func (h *ScreenshotHandler) checkAction(name string, c *capture) error {
+	if _, ok := h.cfg().Hook(name); ok {
+		return nil
+	}
+	if _, ok := h.providers()[name]; ok {
+		return nil
+	}
+	if _, ok := h.cfg().AIAction(name); ok {
+		return capability.Require(capability.AI)
+	}
+
+	builtin, ok := builtinActions[name]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown action %q", name)
+	case builtin.NeedsFile && c.File == "":
+		return fmt.Errorf("%s needs a saved capture", name)
+	case builtin.NeedsGeometry:
+		return fmt.Errorf("%s is only offered right after a capture", name)
+	}
+	for _, feature := range builtin.Requires {
+		if err := capability.Require(feature); err != nil {
+			return err
+		}
+	}
+	if builtin.NeedsUploader {
+		_, err := h.defaultProvider()
+		return err
+	}
+	return nil
+}
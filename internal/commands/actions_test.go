@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+)
+
+func TestRunHookCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		exec string
+		want []string
+	}{
+		{
+			name: "spaced actions",
+			exec: "oxipng -o 4 {{ .File }}",
+			want: []string{"oxipng", "-o", "4", "/my shots/a.png"},
+		},
+		{
+			name: "quoted",
+			exec: `cp {{.File}} "{{.Dir}}/archive/{{.Workspace}} {{.Name}}"`,
+			want: []string{"cp", "/my shots/a.png", "/my shots/archive/1: web a.png"},
+		},
+		{
+			name: "condition on an empty value",
+			exec: "tag{{if .Project}} --project {{.Project}}{{end}} {{.Name}}",
+			want: []string{"tag", "a.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ctx, runner := setup(t)
+			hook := config.Hook{Name: "test", Exec: tt.exec}
+			if _, err := runHookCommand(ctx, hook, "/my shots/a.png", tags.Tags{Workspace: "1: web"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(runner.calls) != 1 || !slices.Equal(runner.calls[0], tt.want) {
+				t.Errorf("ran %q, want %q", runner.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunHookCommandInvalid(t *testing.T) {
+	_, ctx, runner := setup(t)
+	for _, exec := range []string{"cp {{.File", `cp {{.File}} "unterminated`, "cp {{.Missing}}"} {
+		hook := config.Hook{Name: "test", Exec: exec}
+		if _, err := runHookCommand(ctx, hook, "/a.png", tags.Tags{}); err == nil {
+			t.Errorf("%q ran, want an error", exec)
+		}
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("ran %q, want nothing", runner.calls)
+	}
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"context"
+)
+
+// cancelKey holds the context done once a request is cancelled.
+type cancelKey struct{}
+
+// WithCancel returns a context whose countdowns and selections stop, failing
+// with ErrCancelled, once cancel is called. Unlike cancelling the context
+// itself, it leaves alone what the request started, such as a recording.
+// Cancelling an enclosing request cancels this one too.
+func WithCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if outer, ok := ctx.Value(cancelKey{}).(context.Context); ok {
+		parent = outer
+	}
+	cancelled, cancel := context.WithCancel(parent)
+	return context.WithValue(ctx, cancelKey{}, cancelled), cancel
+}
+
+// Waiting returns a context for waiting on the user or for a turn, done
+// once the request of ctx is cancelled.
+func Waiting(ctx context.Context) (context.Context, context.CancelFunc) {
+	wait, stop := context.WithCancel(ctx)
+	if cancelled, ok := ctx.Value(cancelKey{}).(context.Context); ok {
+		unregister := context.AfterFunc(cancelled, stop)
+		return wait, func() {
+			unregister()
+			stop()
+		}
+	}
+	return wait, stop
+}
+
+// cancelled tells whether the request of ctx has been cancelled.
+func cancelled(ctx context.Context) bool {
+	done, ok := ctx.Value(cancelKey{}).(context.Context)
+	return ok && done.Err() != nil
+}
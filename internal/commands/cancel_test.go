@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/state"
+)
+
+func TestWithCancelEnclosed(t *testing.T) {
+	request, cancelRequest := WithCancel(context.Background())
+	defer cancelRequest()
+	ctx, cancel := WithCancel(request)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- sleepWithCountdown(ctx, state.NewState(), 60) }()
+	cancelRequest()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrCancelled) {
+			t.Errorf("sleepWithCountdown() = %v, want it cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the countdown went on once the enclosing request was cancelled")
+	}
+	if ctx.Err() != nil {
+		t.Error("cancelling the request cancelled its context")
+	}
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/trash"
+)
+
+// CleanupReport lists the captures removed by a cleanup, or which would be,
+// and the space they take.
+type CleanupReport struct {
+	Files []string
+	Size  int64
+}
+
+// String lists the files followed by a summary.
+func (r CleanupReport) String() string {
+	var b strings.Builder
+	for _, file := range r.Files {
+		fmt.Fprintln(&b, file)
+	}
+	fmt.Fprintf(&b, "%d file(s), %s", len(r.Files), formatBytes(r.Size))
+	return b.String()
+}
+
+// discard gets rid of a deleted capture once it can no longer be undone:
+// moved to the trash unless configured otherwise.
+func (h *ScreenshotHandler) discard(file, original string) error {
+	if h.cfg().DeleteMode == config.DeleteRemove {
+		return os.Remove(file)
+	}
+	return trash.Move(file, original)
+}
+
+// Cleanup moves the captures kept for longer than the retention of their
+// type, or than olderThan when positive, to the undo area, from where they
+// are discarded once the Undo button has expired, and reports them. Nothing
+// is removed when dryRun is set.
+func (h *ScreenshotHandler) Cleanup(ctx context.Context, dryRun bool, olderThan time.Duration) (CleanupReport, error) {
+	retention := h.cfg().Cleanup.Retention
+	if olderThan > 0 {
+		retention = func(string) time.Duration { return olderThan }
+	}
+	old, err := oldFiles(h.cfg().SaveLocation, retention, time.Now())
+	if err != nil {
+		return CleanupReport{}, err
+	}
+	if dryRun || len(old) == 0 {
+		return newCleanupReport(old, nil), nil
+	}
+
+	files := make([]string, len(old))
+	for i, f := range old {
+		files[i] = f.Path
+	}
+	left, err := h.undo.Trash("Cleanup", files)
+	if err != nil {
+		slog.Warn("Cleanup could not remove files", "count", len(left), "error", err)
+	}
+	report := newCleanupReport(old, left)
+	if len(report.Files) == 0 {
+		return report, err
+	}
+	slog.Info("Cleanup removed files", "count", len(report.Files), "reclaimed", formatBytes(report.Size))
+
+	return report, h.offerUndo(ctx, fmt.Sprintf("Removed %d old capture(s), %s", len(report.Files), formatBytes(report.Size)))
+}
+
+// oldFile is a file found by a cleanup.
+type oldFile struct {
+	Path string
+	Size int64
+}
+
+// oldFiles lists the files under dir last modified longer before now than
+// their retention, leaving out hidden files and directories and those with
+// no retention.
+func oldFiles(dir string, retention func(file string) time.Duration, now time.Time) ([]oldFile, error) {
+	var files []oldFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		keep := retention(path)
+		if !entry.Type().IsRegular() || keep == 0 {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since it was listed
+			return nil
+		}
+		if info.ModTime().Before(now.Add(-keep)) {
+			files = append(files, oldFile{Path: path, Size: info.Size()})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// newCleanupReport reports the files found by a cleanup except those left
+// in place.
+func newCleanupReport(files []oldFile, left []string) CleanupReport {
+	var report CleanupReport
+	for _, f := range files {
+		if slices.Contains(left, f.Path) {
+			continue
+		}
+		report.Files = append(report.Files, f.Path)
+		report.Size += f.Size
+	}
+	return report
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// fakeRunner stands in for grim, slurp, wf-recorder and the other tools,
+// answering each with the next of the outputs queued for it.
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   [][]string
+	outputs map[string][]fakeOutput
+}
+
+type fakeOutput struct {
+	data []byte
+	err  error
+}
+
+// queue adds output to the answers of tool, the last one being repeated.
+func (f *fakeRunner) queue(tool string, data []byte, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.outputs == nil {
+		f.outputs = map[string][]fakeOutput{}
+	}
+	f.outputs[tool] = append(f.outputs[tool], fakeOutput{data: data, err: err})
+}
+
+func (f *fakeRunner) call(cmd *exec.Cmd) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, slices.Clone(cmd.Args))
+	tool := filepath.Base(cmd.Args[0])
+	outputs := f.outputs[tool]
+	if len(outputs) == 0 {
+		return nil, nil
+	}
+	if len(outputs) > 1 {
+		f.outputs[tool] = outputs[1:]
+	}
+	return outputs[0].data, outputs[0].err
+}
+
+// callsOf returns the arguments tool was run with, in order.
+func (f *fakeRunner) callsOf(tool string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls [][]string
+	for _, args := range f.calls {
+		if filepath.Base(args[0]) == tool {
+			calls = append(calls, args[1:])
+		}
+	}
+	return calls
+}
+
+func (f *fakeRunner) Run(cmd *exec.Cmd) error {
+	_, err := f.call(cmd)
+	return err
+}
+
+func (f *fakeRunner) Output(cmd *exec.Cmd) ([]byte, error) { return f.call(cmd) }
+
+func (f *fakeRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return f.call(cmd) }
+
+// Start starts true in place of cmd, which then has a process to wait for.
+func (f *fakeRunner) Start(cmd *exec.Cmd) error {
+	if _, err := f.call(cmd); err != nil {
+		return err
+	}
+	path, err := exec.LookPath("true")
+	if err != nil {
+		return err
+	}
+	// The lookup of the real tool may have failed
+	cmd.Path, cmd.Args, cmd.Err = path, []string{"true"}, nil
+	cmd.Stdout, cmd.Stderr = nil, nil
+	return cmd.Start()
+}
+
+// setup returns the configuration of a session living in a temporary
+// directory, and a context running its commands with a fake runner.
+// Notifications go to a notify-send stub.
+func setup(t *testing.T) (*config.Config, context.Context, *fakeRunner) {
+	t.Helper()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "notify-send"), []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(dir, "runtime"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	t.Setenv("WAYLAND_DISPLAY", "wayland-test")
+	t.Setenv("SWAY_SCREENSHOT_SAVE_LOCATION", filepath.Join(dir, "captures"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(cfg.RuntimeDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	runner := &fakeRunner{}
+	return cfg, external.WithRunner(ctx, runner), runner
+}
+
+// pngFixture returns a 1x1 PNG, which is all grim captures.
+func pngFixture(t *testing.T) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := png.Encode(&out, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// composeGap separates the captures of a collage, in pixels.
+const composeGap = 24
+
+// composeBackground is the background of collages, white to paste well in
+// bug reports.
+var composeBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+// Compose lays the given files, or the last captures saved when there are
+// none, out in a grid of columns (a single row when not positive) under
+// their names and saves the collage to a file.
+func (h *ScreenshotHandler) Compose(ctx context.Context, files []string, last, columns int, labels bool) error {
+	if len(files) == 0 {
+		recent, err := recentCaptures(h.cfg().SaveLocation, last)
+		if err != nil {
+			return err
+		}
+		files = recent
+	}
+	if len(files) < 2 {
+		return fmt.Errorf("at least two captures are needed for a collage")
+	}
+
+	tiles := make([]imaging.Tile, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		img, err := imaging.Decode(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		tile := imaging.Tile{Image: img}
+		if labels {
+			tile.Label = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		tiles = append(tiles, tile)
+	}
+
+	data, err := imaging.EncodePNG(imaging.Collage(tiles, columns, composeGap, composeBackground))
+	if err != nil {
+		return err
+	}
+
+	file := h.captureFile(ctx, tags.Tags{})
+	entry := history.Entry{Command: "compose", File: file}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
+
+	if offered, err := h.offerActions(ctx, "compose", filepath.Base(file), &capture{File: file, Data: data, Tags: tags.Tags{}}); offered {
+		return err
+	}
+
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Collage of %d captures saved: %s", len(files), filepath.Base(file))) //nolint:errcheck
+}
+
+// recentCaptures returns the last count images saved in dir, oldest first.
+func recentCaptures(dir string, count int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type capturedFile struct {
+		path    string
+		modTime time.Time
+	}
+	var captures []capturedFile
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		captures = append(captures, capturedFile{path: filepath.Join(dir, entry.Name()), modTime: info.ModTime()})
+	}
+
+	sort.Slice(captures, func(i, j int) bool { return captures[i].modTime.After(captures[j].modTime) })
+	if len(captures) > count {
+		captures = captures[:count]
+	}
+
+	files := make([]string, len(captures))
+	for i, c := range captures {
+		files[len(captures)-1-i] = c.path
+	}
+	return files, nil
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// Outcomes of a doctor check.
+const (
+	DoctorOK       = "ok"
+	DoctorFailed   = "failed"
+	DoctorDegraded = "degraded"
+)
+
+// doctorTimeout bounds each check, so that a hanging tool cannot hang the
+// doctor.
+const doctorTimeout = 5 * time.Second
+
+// DoctorResult is the outcome of one check of the doctor.
+type DoctorResult struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// requiredTool is a tool no capture works without, with the arguments of a
+// quick run showing it works.
+type requiredTool struct {
+	name string
+	args []string
+	hint string
+}
+
+var requiredTools = []requiredTool{
+	{name: "grim", args: []string{"-g", "0,0 1x1", "-"}, hint: "install grim and run under a compositor supporting wlr-screencopy, such as sway"},
+	{name: "slurp", args: []string{"-h"}, hint: "install slurp"},
+	{name: "wl-copy", args: []string{"--version"}, hint: "install wl-clipboard"},
+	{name: "wf-recorder", args: []string{"--help"}, hint: "install wf-recorder"},
+	{name: "ffmpeg", args: []string{"-version"}, hint: "install ffmpeg"},
+	{name: "notify-send", args: []string{"--version"}, hint: "install libnotify and a notification daemon such as mako"},
+}
+
+// versionArgs are the arguments making the tools of optional features show
+// they run, for those which have such arguments.
+var versionArgs = map[string][]string{
+	"aichat":    {"--version"},
+	"satty":     {"--version"},
+	"zenity":    {"--version"},
+	"tesseract": {"--version"},
+	"age":       {"--version"},
+	"gpg":       {"--version"},
+}
+
+// Doctor checks the tools sway-easyshot runs are installed and work, and
+// which optional features are degraded, with how to fix them. The handlers
+// of cfg must have been created first for the features to need the
+// configured tools.
+func Doctor(ctx context.Context, cfg *config.Config) []DoctorResult {
+	var results []DoctorResult
+	for _, tool := range required(cfg) {
+		result := DoctorResult{Check: tool.name, Status: DoctorOK}
+		if err := runTool(ctx, tool.name, tool.args); err != nil {
+			result.Status, result.Detail, result.Hint = DoctorFailed, err.Error(), tool.hint
+		}
+		results = append(results, result)
+	}
+
+	for _, f := range capability.Features {
+		result := DoctorResult{Check: f.Name, Status: DoctorOK}
+		if tools := f.MissingTools(); len(tools) > 0 {
+			result.Status, result.Detail, result.Hint = DoctorDegraded, "missing "+strings.Join(tools, ", "), f.Hint
+			results = append(results, result)
+			continue
+		}
+		for _, tool := range f.Tools {
+			args, ok := versionArgs[tool]
+			if !ok {
+				continue
+			}
+			if err := runTool(ctx, tool, args); err != nil {
+				result.Status, result.Detail, result.Hint = DoctorDegraded, err.Error(), f.Hint
+				break
+			}
+		}
+		results = append(results, result)
+	}
+
+	return append(results, checkOBS(ctx, cfg))
+}
+
+// Tools reports which of the tools sway-easyshot runs are installed: the
+// required ones and those of the optional features.
+func Tools(cfg *config.Config) map[string]bool {
+	tools := map[string]bool{}
+	for _, tool := range required(cfg) {
+		_, err := exec.LookPath(tool.name)
+		tools[tool.name] = err == nil
+	}
+	for _, f := range capability.Features {
+		for _, tool := range f.Tools {
+			tools[tool] = true
+		}
+		for _, tool := range f.MissingTools() {
+			tools[tool] = false
+		}
+	}
+	return tools
+}
+
+// RequiredTools returns the names of the tools no capture works without.
+func RequiredTools(cfg *config.Config) []string {
+	var names []string
+	for _, tool := range required(cfg) {
+		names = append(names, tool.name)
+	}
+	return names
+}
+
+// required returns the required tools of cfg.
+func required(cfg *config.Config) []requiredTool {
+	tools := slices.Clone(requiredTools)
+	for i, tool := range tools {
+		if fields := strings.Fields(cfg.Selector); tool.name == "slurp" && len(fields) > 0 {
+			// A custom selector replaces slurp, and cannot run without the
+			// user selecting
+			tools[i] = requiredTool{name: fields[0], hint: "install the selector of SWAY_SCREENSHOT_SELECTOR or unset it"}
+		}
+	}
+	return tools
+}
+
+// runTool checks tool is installed and, unless args is nil, that running it
+// with args succeeds.
+func runTool(ctx context.Context, tool string, args []string) error {
+	if _, err := exec.LookPath(tool); err != nil {
+		return fmt.Errorf("not installed")
+	}
+	if args == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	output, err := external.CombinedOutput(ctx, exec.CommandContext(ctx, tool, args...)) //nolint:gosec
+	if err != nil {
+		if line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n"); line != "" {
+			return fmt.Errorf("installed but failing: %s", line)
+		}
+		return fmt.Errorf("installed but failing: %w", err)
+	}
+	return nil
+}
+
+// checkOBS checks the WebSocket server of OBS accepts connections, without
+// asking for its password.
+func checkOBS(ctx context.Context, cfg *config.Config) DoctorResult {
+	result := DoctorResult{Check: "obs-websocket", Status: DoctorOK, Detail: cfg.OBSAddress}
+	dialer := net.Dialer{Timeout: doctorTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.OBSAddress)
+	if err != nil {
+		result.Status = DoctorDegraded
+		result.Detail = fmt.Sprintf("nothing listens on %s", cfg.OBSAddress)
+		result.Hint = "start OBS and enable Tools › WebSocket Server Settings, or set SWAY_SCREENSHOT_OBS_ADDRESS"
+		return result
+	}
+	_ = conn.Close()
+	return result
+}
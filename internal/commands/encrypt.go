@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// encryptKey marks the context of captures which are encrypted before being
+// written to disk.
+type encryptKey struct{}
+
+// WithEncrypt returns a context whose captures are encrypted to the
+// configured recipient, so that only its key can read them.
+func WithEncrypt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, encryptKey{}, true)
+}
+
+func encrypting(ctx context.Context) bool {
+	on, _ := ctx.Value(encryptKey{}).(bool)
+	return on
+}
+
+// encrypt encrypts capture data to the configured recipient.
+func (h *ScreenshotHandler) encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if err := capability.Require(capability.Encrypt); err != nil {
+		return nil, err
+	}
+	if h.cfg().EncryptRecipient == "" {
+		return nil, fmt.Errorf("no recipient to encrypt to, set SWAY_SCREENSHOT_ENCRYPT_RECIPIENT")
+	}
+
+	encrypted, err := external.Encrypt(ctx, h.cfg().EncryptTool, h.cfg().EncryptRecipient, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt capture: %w", err)
+	}
+	return encrypted, nil
+}
+
+// isEncrypted reports whether file holds an encrypted capture.
+func isEncrypted(file string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(file), ".")
+	return ext == config.EncryptAge || ext == config.EncryptGPG
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// ephemeralKey marks the context of captures which are saved to the
+// ephemeral area rather than the screenshots folder.
+type ephemeralKey struct{}
+
+// WithEphemeral returns a context whose captures are saved to a temporary
+// area and deleted after a while unless the user keeps them.
+func WithEphemeral(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ephemeralKey{}, true)
+}
+
+func ephemeral(ctx context.Context) bool {
+	on, _ := ctx.Value(ephemeralKey{}).(bool)
+	return on
+}
+
+// captureFile returns the file to save a capture to: a new file in the
+// screenshots folder, or in the ephemeral area for ephemeral captures, which
+// then get deleted once their time is up.
+func (h *ScreenshotHandler) captureFile(ctx context.Context, t tags.Tags) string {
+	return h.placeCapture(ctx, h.cfg().GenerateTaggedFilename(t))
+}
+
+// placeCapture returns where a capture named file is saved, marked as
+// encrypted and moved to the ephemeral area as the context asks.
+func (h *ScreenshotHandler) placeCapture(ctx context.Context, file string) string {
+	if encrypting(ctx) {
+		file += "." + h.cfg().EncryptTool
+	}
+	if !ephemeral(ctx) {
+		return file
+	}
+
+	if err := os.MkdirAll(h.cfg().EphemeralDir, 0o700); err != nil {
+		return file
+	}
+	file = filepath.Join(h.cfg().EphemeralDir, filepath.Base(file))
+	time.AfterFunc(h.cfg().EphemeralTTL, func() { _ = os.Remove(file) })
+	return file
+}
+
+// isEphemeral reports whether a capture file lives in the ephemeral area.
+func (h *ScreenshotHandler) isEphemeral(file string) bool {
+	return file != "" && filepath.Dir(file) == h.cfg().EphemeralDir
+}
+
+// keep moves an ephemeral capture to the screenshots folder, saving it from
+// deletion.
+func (h *ScreenshotHandler) keep(c *capture) error {
+	target := filepath.Join(h.cfg().SaveLocation, filepath.Base(c.File))
+	if err := moveFile(c.File, target); err != nil {
+		return fmt.Errorf("failed to keep %s: %w", filepath.Base(c.File), err)
+	}
+	c.File = target
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot kept: %s", filepath.Base(target)))
+}
+
+// Sweep deletes the ephemeral captures left over by a previous daemon once
+// their time is up, and the originals it kept for undoing.
+func (h *ScreenshotHandler) Sweep() {
+	h.undo.Sweep()
+
+	entries, err := os.ReadDir(h.cfg().EphemeralDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		file := filepath.Join(h.cfg().EphemeralDir, entry.Name())
+		time.AfterFunc(time.Until(info.ModTime().Add(h.cfg().EphemeralTTL)), func() { _ = os.Remove(file) })
+	}
+}
+
+// moveFile moves a file, copying it when it crosses file systems as the
+// runtime directory usually does.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(from) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(to)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// ephemeralNote returns the notification message of an ephemeral capture.
+func (h *ScreenshotHandler) ephemeralNote(message string) string {
+	ttl := h.cfg().EphemeralTTL.Round(time.Second).String()
+	if strings.HasSuffix(ttl, "m0s") {
+		ttl = strings.TrimSuffix(ttl, "0s")
+	}
+	return fmt.Sprintf("%s\nDeleted in %s unless kept", message, ttl)
+}
@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+)
+
+// galleryImageSize is the width of the previews in the gallery.
+const galleryImageSize = 96
+
+// Gallery lists the last captures saved to a file, with previews, and runs
+// an action on the chosen one.
+func (h *ScreenshotHandler) Gallery(ctx context.Context, limit int) error {
+	if err := capability.Require(capability.Menu); err != nil {
+		return err
+	}
+
+	entries, err := h.history.List(history.Query{Saved: true})
+	if err != nil {
+		return err
+	}
+	var items []history.Entry
+	for _, entry := range entries {
+		if _, err := os.Stat(entry.File); err != nil {
+			continue
+		}
+		items = append(items, entry)
+		if limit > 0 && len(items) == limit {
+			break
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no capture in the history")
+	}
+
+	options := make([]string, len(items))
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = fmt.Sprintf("%d. %s  %s", i+1, filepath.Base(item.File), item.Time.Format("2006-01-02 15:04"))
+		options[i] = labels[i]
+		if preview := h.preview(ctx, item); preview != "" {
+			options[i] = "img:" + preview + ":text:" + labels[i]
+		}
+	}
+	choice, err := external.WofiImages(ctx, "Captures", options, galleryImageSize)
+	if err != nil || choice == "" {
+		return nil
+	}
+	item := -1
+	for i := range items {
+		// wofi prints either the whole option or its text
+		if choice == options[i] || strings.HasSuffix(choice, labels[i]) {
+			item = i
+			break
+		}
+	}
+	if item < 0 {
+		return nil
+	}
+
+	return h.galleryAction(ctx, items[item])
+}
+
+// preview returns the image shown for an entry in the gallery: the capture
+// itself, or the last frame of a recording, extracted once.
+func (h *ScreenshotHandler) preview(ctx context.Context, entry history.Entry) string {
+	if isEncrypted(entry.File) {
+		return ""
+	}
+	if entry.Kind != history.Recording {
+		return entry.File
+	}
+
+	dir := filepath.Join(h.cfg().ThumbnailDir, "gallery")
+	frame := filepath.Join(dir, strings.TrimSuffix(filepath.Base(entry.File), filepath.Ext(entry.File))+".png")
+	if _, err := os.Stat(frame); err == nil {
+		return frame
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ""
+	}
+	if err := transcode.LastFrame(ctx, entry.File, frame); err != nil {
+		return ""
+	}
+	return frame
+}
+
+// galleryAction offers the actions on a capture chosen in the gallery and
+// runs the selected one.
+func (h *ScreenshotHandler) galleryAction(ctx context.Context, entry history.Entry) error {
+	c := &capture{File: entry.File, Tags: entry.Tags}
+	screenshot := entry.Kind != history.Recording
+
+	actions := []string{"Copy", "Open"}
+	if screenshot && available(builtinActions["edit"].Requires) {
+		actions = append(actions, "Edit")
+	}
+	if _, err := h.defaultProvider(); err == nil {
+		actions = append(actions, "Upload")
+	}
+	actions = append(actions, "Delete")
+
+	choice, err := external.Wofi(ctx, filepath.Base(entry.File), actions)
+	if err != nil {
+		return nil
+	}
+
+	switch choice {
+	case "Copy":
+		if screenshot {
+			return h.runAction(ctx, "copyclip", c)
+		}
+		return h.copyText(ctx, entry.File)
+	case "Open":
+		return external.XdgOpen(ctx, entry.File)
+	case "Edit":
+		return h.runAction(ctx, "edit", c)
+	case "Upload":
+		return h.runAction(ctx, "upload", c)
+	case "Delete":
+		left, err := h.undo.Trash("Deletion", []string{entry.File})
+		if len(left) > 0 {
+			return err
+		}
+		return h.offerUndo(ctx, fmt.Sprintf("Deleted %s", filepath.Base(entry.File)))
+	}
+	return nil
+}
+
+// LastCapture returns the file of the most recent capture of a kind, or of
+// any kind when empty, which is still there. The path is also copied when
+// copyPath is set.
+func (h *ScreenshotHandler) LastCapture(ctx context.Context, kind string, copyPath bool) (string, error) {
+	entries, err := h.history.List(history.Query{Kind: kind, Saved: true})
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(entry.File); err != nil {
+			continue
+		}
+		if copyPath {
+			if err := h.copyText(ctx, entry.File); err != nil {
+				return "", err
+			}
+		}
+		return entry.File, nil
+	}
+	return "", fmt.Errorf("no capture in the history")
+}
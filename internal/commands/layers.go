@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+)
+
+// openLayered exports a capture as a layered file next to it and opens it in
+// the full editor: a solid background with room around the screenshot, the
+// screenshot itself and an empty layer for annotations. Inkscape gets an SVG,
+// other editors an OpenRaster file.
+func (h *ScreenshotHandler) openLayered(ctx context.Context, c *capture) error {
+	data, err := c.bytes()
+	if err != nil {
+		return err
+	}
+	shot, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	padding := h.cfg().Pretty.Padding
+	size := shot.Bounds().Size()
+	width, height := size.X+2*padding, size.Y+2*padding
+
+	background := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(background, background.Bounds(), image.NewUniform(h.cfg().Pretty.From), image.Point{}, draw.Src)
+
+	layers := []imaging.Layer{
+		{Name: "Background", Image: background},
+		{Name: "Screenshot", Image: shot, X: padding, Y: padding},
+		{Name: "Annotations"},
+	}
+
+	editor := strings.Fields(h.cfg().FullEditor)
+	if len(editor) == 0 {
+		return fmt.Errorf("no full editor, set SWAY_SCREENSHOT_FULL_EDITOR")
+	}
+	write, ext := imaging.WriteORA, ".ora"
+	if filepath.Base(editor[0]) == "inkscape" {
+		write, ext = imaging.WriteSVG, ".svg"
+	}
+
+	source := c.File
+	if source == "" {
+		source = h.cfg().GenerateTaggedFilename(c.Tags)
+	}
+	file := strings.TrimSuffix(source, filepath.Ext(source)) + ext
+
+	var out bytes.Buffer
+	if err := write(&out, width, height, layers); err != nil {
+		return fmt.Errorf("failed to export layers: %w", err)
+	}
+	if err := os.WriteFile(file, out.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	return external.OpenWith(ctx, editor, file)
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+)
+
+// SessionLocked pauses the recording in progress when the session locks, so
+// that neither the lock screen nor what happens meanwhile ends up in it. On
+// unlock, the recording is resumed if it was paused for the lock and the
+// configuration asks for it; otherwise it stays paused for the user to
+// resume.
+func (h *RecordingHandler) SessionLocked(ctx context.Context, locked bool) error {
+	if !h.cfg().PauseOnLock {
+		return nil
+	}
+
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+
+	st := h.state.GetState()
+	if locked {
+		if !st.Recording || st.Paused {
+			return nil
+		}
+		h.pausedByLock = true
+		return h.PauseRecording(ctx)
+	}
+
+	if !h.pausedByLock {
+		return nil
+	}
+	h.pausedByLock = false
+	if !st.Recording || !st.Paused || !h.cfg().ResumeOnUnlock {
+		return nil
+	}
+	return h.PauseRecording(ctx)
+}
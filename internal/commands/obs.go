@@ -2,43 +2,150 @@ package commands
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"sway-easyshot/internal/config"
-	"sway-easyshot/internal/external"
-	"sway-easyshot/internal/notify"
-	"sway-easyshot/internal/state"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/obs"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/state"
 )
 
 // OBSHandler provides methods to interact with OBS.
 type OBSHandler struct {
-	cfg   *config.Config
-	state *state.State
+	config atomic.Pointer[config.Config]
+	state  *state.State
 }
 
 // NewOBSHandler creates a new OBS handler instance.
 func NewOBSHandler(cfg *config.Config, st *state.State) *OBSHandler {
-	return &OBSHandler{
-		cfg:   cfg,
-		state: st,
+	h := &OBSHandler{state: st}
+	h.Reconfigure(cfg)
+	return h
+}
+
+// Reconfigure makes the handler use cfg from now on.
+func (h *OBSHandler) Reconfigure(cfg *config.Config) {
+	command := strings.Fields(cfg.OBSPasswordCommand)
+	capability.SetTools(capability.OBS, command[:min(1, len(command))])
+	h.config.Store(cfg)
+}
+
+func (h *OBSHandler) cfg() *config.Config { return h.config.Load() }
+
+// recordStatus is the answer of OBS to GetRecordStatus.
+type recordStatus struct {
+	Active bool `json:"outputActive"`
+	Paused bool `json:"outputPaused"`
+}
+
+// connect connects to OBS, getting its password from the password command
+// when it asks for one, so that it is never given on a command line.
+func (h *OBSHandler) connect(ctx context.Context) (*obs.Client, error) {
+	return h.connectFor(ctx, 0)
+}
+
+// connectFor connects to OBS like connect, subscribing to the events of the
+// subscriptions mask.
+func (h *OBSHandler) connectFor(ctx context.Context, subscriptions int) (*obs.Client, error) {
+	if err := capability.Require(capability.OBS); err != nil {
+		return nil, err
+	}
+
+	return obs.Connect(ctx, h.cfg().OBSAddress, func() (string, error) {
+		output, err := external.Command(ctx, strings.Fields(h.cfg().OBSPasswordCommand))
+		if err != nil {
+			return "", fmt.Errorf("failed to get OBS password: %w", err)
+		}
+		// pass prints the password on the first line, then other fields
+		password, _, _ := strings.Cut(output, "\n")
+		return password, nil
+	}, subscriptions)
+}
+
+// Watch keeps the state up to date with the recording and streaming of OBS,
+// whether they are controlled from sway-easyshot or from OBS itself, until
+// the connection is lost or ctx is done.
+func (h *OBSHandler) Watch(ctx context.Context) error {
+	client, err := h.connectFor(ctx, obs.SubscribeOutputs)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+		h.state.SetOBSState(false, false)
+		h.state.SetOBSStreaming(false)
+	}()
+	// Waiting for events does not heed ctx, closing the connection ends it
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = client.Close()
+		case <-done:
+		}
+	}()
+
+	var record recordStatus
+	if err := client.Request(ctx, "GetRecordStatus", nil, &record); err != nil {
+		return err
+	}
+	h.state.SetOBSState(record.Active, record.Paused)
+	var stream recordStatus
+	if err := client.Request(ctx, "GetStreamStatus", nil, &stream); err != nil {
+		return err
+	}
+	h.state.SetOBSStreaming(stream.Active)
+
+	for {
+		event, err := client.NextEvent()
+		if err != nil {
+			return err
+		}
+
+		var output struct {
+			Active bool   `json:"outputActive"`
+			State  string `json:"outputState"`
+		}
+		if err := json.Unmarshal(event.Data, &output); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "RecordStateChanged":
+			h.state.SetOBSState(output.Active, output.State == "OBS_WEBSOCKET_OUTPUT_PAUSED")
+		case "StreamStateChanged":
+			h.state.SetOBSStreaming(output.Active)
+		}
 	}
 }
 
 // ToggleRecording toggles OBS recording state (start/stop).
 func (h *OBSHandler) ToggleRecording(ctx context.Context) error {
-	status, err := external.OBSCli(ctx, "recording", "status")
+	client, err := h.connect(ctx)
 	if err != nil {
-		_ = notify.Send(2000, h.cfg.ScreenshotIcon, "Failed to get OBS status")
+		_ = notify.Send(2000, h.cfg().ScreenshotIcon, "Failed to connect to OBS")
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	var status recordStatus
+	if err := client.Request(ctx, "GetRecordStatus", nil, &status); err != nil {
+		_ = notify.Send(2000, h.cfg().ScreenshotIcon, "Failed to get OBS status")
 		return fmt.Errorf("failed to get OBS recording status: %w", err)
 	}
 
-	if strings.Contains(status, "false") || !strings.Contains(status, "Recording: true") {
+	if !status.Active {
 		// Start recording
 		time.Sleep(1 * time.Second)
 
-		if _, err := external.OBSCli(ctx, "recording", "start"); err != nil {
+		if err := client.Request(ctx, "StartRecord", nil, nil); err != nil {
 			return fmt.Errorf("failed to start OBS recording: %w", err)
 		}
 
@@ -47,35 +154,131 @@ func (h *OBSHandler) ToggleRecording(ctx context.Context) error {
 	}
 
 	// Stop recording
-	if _, err := external.OBSCli(ctx, "recording", "stop"); err != nil {
+	if err := client.Request(ctx, "StopRecord", nil, nil); err != nil {
 		return fmt.Errorf("failed to stop OBS recording: %w", err)
 	}
 
 	time.Sleep(2 * time.Second)
-	_ = notify.Send(2000, h.cfg.RecordingStopIcon, "Recording has stopped")
+	_ = notify.Send(2000, h.cfg().RecordingStopIcon, "Recording has stopped")
 
 	h.state.SetOBSState(false, false)
 	return nil
 }
 
+// ToggleSource shows or hides a source of a scene, of the scene currently
+// shown when empty.
+func (h *OBSHandler) ToggleSource(ctx context.Context, scene, source string) error {
+	client, err := h.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if scene == "" {
+		var current struct {
+			Name string `json:"currentProgramSceneName"`
+		}
+		if err := client.Request(ctx, "GetCurrentProgramScene", nil, &current); err != nil {
+			return fmt.Errorf("failed to get the current OBS scene: %w", err)
+		}
+		scene = current.Name
+	}
+
+	var item struct {
+		ID int `json:"sceneItemId"`
+	}
+	if err := client.Request(ctx, "GetSceneItemId", map[string]string{"sceneName": scene, "sourceName": source}, &item); err != nil {
+		return fmt.Errorf("no source %q in OBS scene %q: %w", source, scene, err)
+	}
+	id := map[string]interface{}{"sceneName": scene, "sceneItemId": item.ID}
+
+	var enabled struct {
+		Enabled bool `json:"sceneItemEnabled"`
+	}
+	if err := client.Request(ctx, "GetSceneItemEnabled", id, &enabled); err != nil {
+		return fmt.Errorf("failed to get the visibility of %s: %w", source, err)
+	}
+	id["sceneItemEnabled"] = !enabled.Enabled
+	if err := client.Request(ctx, "SetSceneItemEnabled", id, nil); err != nil {
+		return fmt.Errorf("failed to toggle %s: %w", source, err)
+	}
+
+	message := fmt.Sprintf("%s shown", source)
+	if enabled.Enabled {
+		message = fmt.Sprintf("%s hidden", source)
+	}
+	return notify.Send(2000, h.cfg().ScreenshotIcon, message)
+}
+
+// ProgramScreenshot returns a PNG screenshot of what OBS currently outputs,
+// at the resolution of its output, with the name of the scene shown.
+func (h *OBSHandler) ProgramScreenshot(ctx context.Context) ([]byte, string, error) {
+	client, err := h.connect(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = client.Close() }()
+
+	var current struct {
+		Name string `json:"currentProgramSceneName"`
+	}
+	if err := client.Request(ctx, "GetCurrentProgramScene", nil, &current); err != nil {
+		return nil, "", fmt.Errorf("failed to get the current OBS scene: %w", err)
+	}
+	var video struct {
+		Width  int `json:"outputWidth"`
+		Height int `json:"outputHeight"`
+	}
+	if err := client.Request(ctx, "GetVideoSettings", nil, &video); err != nil {
+		return nil, "", fmt.Errorf("failed to get the OBS output size: %w", err)
+	}
+
+	var screenshot struct {
+		Image string `json:"imageData"`
+	}
+	if err := client.Request(ctx, "GetSourceScreenshot", map[string]interface{}{
+		"sourceName":  current.Name,
+		"imageFormat": "png",
+		"imageWidth":  video.Width,
+		"imageHeight": video.Height,
+	}, &screenshot); err != nil {
+		return nil, "", fmt.Errorf("failed to get an OBS screenshot: %w", err)
+	}
+
+	// The image comes as a data URL
+	_, encoded, ok := strings.Cut(screenshot.Image, ";base64,")
+	if !ok {
+		return nil, "", fmt.Errorf("OBS returned an unexpected screenshot")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode the OBS screenshot: %w", err)
+	}
+	return data, current.Name, nil
+}
+
 // TogglePause toggles OBS pause state (paused/resumed).
 func (h *OBSHandler) TogglePause(ctx context.Context) error {
-	if _, err := external.OBSCli(ctx, "recording", "pause", "toggle"); err != nil {
+	client, err := h.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Request(ctx, "ToggleRecordPause", nil, nil); err != nil {
 		return fmt.Errorf("failed to toggle OBS pause: %w", err)
 	}
 
-	status, err := external.OBSCli(ctx, "recording", "status")
-	if err != nil {
+	var status recordStatus
+	if err := client.Request(ctx, "GetRecordStatus", nil, &status); err != nil {
 		return fmt.Errorf("failed to get OBS recording status: %w", err)
 	}
 
-	isPaused := strings.Contains(status, "Paused: true")
-
-	if isPaused {
-		_ = notify.Send(2000, h.cfg.RecordingPauseIcon, "Recording paused")
+	if status.Paused {
+		_ = notify.Send(2000, h.cfg().RecordingPauseIcon, "Recording paused")
 		h.state.SetOBSState(true, true)
 	} else {
-		_ = notify.Send(2000, h.cfg.RecordingStartIcon, "Recording resumed")
+		_ = notify.Send(2000, h.cfg().RecordingStartIcon, "Recording resumed")
 		h.state.SetOBSState(true, false)
 	}
 
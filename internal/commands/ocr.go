@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chmouel/sway-easyshot/internal/ai"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// ocrPreviewLength is the number of characters shown in the notification.
+const ocrPreviewLength = 120
+
+// OCRSelection captures a selected region, recognises its text in the given
+// languages and copies it to the clipboard, translated to translateTo when set.
+// Empty lang and translateTo fall back to the configuration.
+func (h *ScreenshotHandler) OCRSelection(ctx context.Context, delay int, lastRegion bool, lang, translateTo string) error {
+	if err := capability.Require(capability.OCR); err != nil {
+		return err
+	}
+	if lang == "" {
+		lang = h.cfg().OCRLanguage
+	}
+	if translateTo == "" {
+		translateTo = h.cfg().OCRTranslate
+	}
+	if translateTo != "" {
+		if err := capability.Require(capability.AI); err != nil {
+			return err
+		}
+	}
+
+	if err := notify.CaptureDelay(delay, "text", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "ocr-selection", "", lastRegion)
+	if err != nil {
+		return err
+	}
+
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+
+	text, err := external.Tesseract(ctx, data, lang)
+	if err != nil {
+		return fmt.Errorf("failed to recognise text: %w", err)
+	}
+	if text == "" {
+		_ = notify.Send(3000, h.cfg().ScreenshotIcon, "No text recognised in selection")
+		return fmt.Errorf("no text recognised")
+	}
+
+	label := "Text copied"
+	if translateTo != "" {
+		translated, err := h.ai().Chat(ctx, ai.Request{
+			Model:  h.cfg().AIModelText,
+			Prompt: fmt.Sprintf("Translate the following text to %s. Return only the translation, nothing else.", translateTo),
+			Text:   text,
+		})
+		if err != nil {
+			// Still hand over the original text rather than nothing
+			_ = h.copyText(ctx, text)
+			return fmt.Errorf("failed to translate text: %w", err)
+		}
+		text = translated
+		label = fmt.Sprintf("Text translated to %s and copied", translateTo)
+	}
+
+	if err := h.copyText(ctx, text); err != nil {
+		return err
+	}
+
+	return notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s:\n%s", label, preview(text, ocrPreviewLength)))
+}
+
+// preview shortens text to at most n characters.
+func preview(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
+
+// AltTextSelection captures a selected region and copies an AI generated
+// description of it to the clipboard.
+func (h *ScreenshotHandler) AltTextSelection(ctx context.Context, delay int, lastRegion bool) error {
+	if err := capability.Require(capability.AI); err != nil {
+		return err
+	}
+
+	if err := notify.CaptureDelay(delay, "selection for alt text", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "alt-text-selection", "", lastRegion)
+	if err != nil {
+		return err
+	}
+
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+
+	return h.copyAIAnswer(ctx, data, h.cfg().AIPrompts["alttext"], "Alt text")
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// PickPalette captures a selected region, extracts its dominant colours and
+// copies them to the clipboard as a list of hex codes, optionally saving a
+// palette strip next to the screenshots.
+func (h *ScreenshotHandler) PickPalette(ctx context.Context, delay int, lastRegion bool, count int, save bool) error {
+	if count <= 0 {
+		count = 5
+	}
+
+	if err := notify.CaptureDelay(delay, "palette", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "pick-palette", "", lastRegion)
+	if err != nil {
+		return err
+	}
+
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	colors := imaging.Palette(img, count)
+	if len(colors) == 0 {
+		return fmt.Errorf("no colours found in selection")
+	}
+
+	hexes := make([]string, 0, len(colors))
+	for _, c := range colors {
+		hexes = append(hexes, imaging.Hex(c))
+	}
+	if err := h.copyText(ctx, strings.Join(hexes, "\n")); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Palette copied: %s", strings.Join(hexes, " "))
+	if save {
+		strip, err := imaging.EncodePNG(imaging.PaletteStrip(colors, 64))
+		if err != nil {
+			return err
+		}
+		file := strings.TrimSuffix(h.cfg().GenerateFilename(), ".png") + "-palette.png"
+		if err := os.WriteFile(file, strip, 0o600); err != nil {
+			return err
+		}
+		noteSaved(ctx, file)
+		message += fmt.Sprintf("\nSaved: %s", filepath.Base(file))
+	}
+
+	return notify.Send(5000, h.cfg().ScreenshotIcon, message)
+}
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// progressKey holds the function reporting the progress of a request.
+type progressKey struct{}
+
+// WithProgress returns a context whose long operations report their progress
+// to report, for the client to show it.
+func WithProgress(ctx context.Context, report func(protocol.Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// ReportProgress reports that the operation of ctx reached stage.
+func ReportProgress(ctx context.Context, stage, message string, remaining int) {
+	report, ok := ctx.Value(progressKey{}).(func(protocol.Progress))
+	if !ok {
+		return
+	}
+	report(protocol.Progress{Stage: stage, Message: message, Remaining: remaining})
+}
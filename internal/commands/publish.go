@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/upload"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// newPublishers returns the video hosts configured to publish recordings to.
+func newPublishers(cfg *config.Config) upload.Providers {
+	publishers := upload.Providers{}
+	if cfg.Publish.YouTube.Configured() {
+		tokenFile := filepath.Join(cfg.StateDir, "youtube-token.json")
+		publishers.Add(upload.NewYouTube(cfg.Publish.YouTube, cfg.Publish.Privacy, tokenFile, func(code, url string) {
+			_ = notify.Send(60000, cfg.ScreenshotIcon, fmt.Sprintf("To publish on YouTube, enter the code %s at %s", code, url))
+			_ = external.OpenWith(context.Background(), []string{"xdg-open"}, url)
+		}))
+	}
+	if cfg.Publish.PeerTube.Configured() {
+		publishers.Add(upload.NewPeerTube(cfg.Publish.PeerTube, cfg.Publish.Privacy))
+	}
+	return publishers
+}
+
+// recordingFinished tells the user that file is available, offering to
+// publish it to the configured video hosts.
+func (h *RecordingHandler) recordingFinished(ctx context.Context, file string) {
+	message := fmt.Sprintf("%s is available", file)
+	if len(h.publishers()) == 0 {
+		_ = notify.Send(5000, h.cfg().RecordingStopIcon, message)
+		return
+	}
+
+	actions := make([]notify.Action, 0, len(h.publishers()))
+	for _, name := range h.publishers().Names() {
+		actions = append(actions, notify.Action{ID: name, Label: h.publishers()[name].Label()})
+	}
+	action, err := notify.SendWithActions(30000, h.cfg().RecordingStopIcon, message, actions)
+	if err != nil {
+		return
+	}
+	publisher, ok := h.publishers()[strings.TrimSpace(action)]
+	if !ok {
+		return
+	}
+	if err := h.publish(ctx, publisher, file); err != nil {
+		_ = notify.Send(5000, h.cfg().RecordingStopIcon, fmt.Sprintf("%s failed: %v", publisher.Label(), err))
+	}
+}
+
+// publish publishes file with publisher and copies the URL of the video.
+func (h *RecordingHandler) publish(ctx context.Context, publisher upload.Provider, file string) error {
+	title, description, err := h.cfg().Publish.Render(file, h.cfg().HostLabel)
+	if err != nil {
+		return err
+	}
+
+	_ = notify.Send(3000, h.cfg().RecordingStopIcon, fmt.Sprintf("%s: uploading %s", publisher.Label(), filepath.Base(file)))
+	url, err := publisher.Upload(ctx, upload.Request{File: file, Title: title, Description: description})
+	if err != nil {
+		return err
+	}
+
+	if err := external.WlCopyText(ctx, url); err != nil {
+		return err
+	}
+	return notify.Send(10000, h.cfg().RecordingStopIcon, fmt.Sprintf("%s done (%s), URL copied:\n%s", publisher.Label(), h.cfg().Publish.Privacy, url))
+}
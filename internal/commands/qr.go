@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// ScanQR captures a selected region, decodes the QR codes and barcodes in it
+// and copies their payload to the clipboard, offering to open it when it is
+// a URL.
+func (h *ScreenshotHandler) ScanQR(ctx context.Context, delay int, lastRegion bool) error {
+	if err := capability.Require(capability.Barcode); err != nil {
+		return err
+	}
+
+	if err := notify.CaptureDelay(delay, "code", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "scan-qr", "", lastRegion)
+	if err != nil {
+		return err
+	}
+
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+
+	tmpFile, cleanup, err := h.writeTemp(data)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	payloads, err := external.ZbarImg(ctx, tmpFile)
+	if err != nil || len(payloads) == 0 {
+		_ = notify.Send(3000, h.cfg().ScreenshotIcon, "No code found in selection")
+		return fmt.Errorf("no code found in selection")
+	}
+
+	payload := strings.Join(payloads, "\n")
+	if err := h.copyText(ctx, payload); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Code copied:\n%s", preview(payload, ocrPreviewLength))
+	if !isURL(payloads[0]) {
+		return notify.Send(5000, h.cfg().ScreenshotIcon, message)
+	}
+
+	action, err := notify.SendWithActions(10000, h.cfg().ScreenshotIcon, message, []notify.Action{{ID: "open", Label: "Open"}})
+	if err != nil || strings.TrimSpace(action) != "open" {
+		return nil
+	}
+	return external.XdgOpen(ctx, payloads[0])
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -3,82 +3,140 @@ package commands
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"sway-easyshot/internal/config"
-	"sway-easyshot/internal/external"
-	"sway-easyshot/internal/notify"
-	"sway-easyshot/internal/state"
-	"sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/internal/upload"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/recording"
+	"github.com/chmouel/sway-easyshot/pkg/state"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
 )
 
 // RecordingHandler provides methods for video recording operations.
 type RecordingHandler struct {
-	cfg   *config.Config
-	state *state.State
+	settings atomic.Pointer[recordingSettings]
+	state    *state.State
+	history  *history.History
+
+	// started describes the recording in progress, added to the history
+	// once converted.
+	startedMu sync.Mutex
+	started   history.Entry
+
+	lockMu sync.Mutex
+	// pausedByLock is set while the recording is paused because the
+	// session is locked.
+	pausedByLock bool
 }
 
-// NewRecordingHandler creates a new recording handler instance.
-func NewRecordingHandler(cfg *config.Config, st *state.State) *RecordingHandler {
-	return &RecordingHandler{
-		cfg:   cfg,
-		state: st,
+// NewRecordingHandler creates a new recording handler instance, recording
+// finished recordings in hist unless it is nil.
+func NewRecordingHandler(cfg *config.Config, st *state.State, hist *history.History) *RecordingHandler {
+	h := &RecordingHandler{
+		state:   st,
+		history: hist,
 	}
+	h.Reconfigure(cfg)
+	return h
 }
 
+// recordingSettings are the configuration of a recording handler and what
+// derives from it, replaced together when the configuration is reloaded.
+type recordingSettings struct {
+	cfg        *config.Config
+	publishers upload.Providers
+}
+
+// Reconfigure makes the handler use cfg from now on. A recording in
+// progress goes on, and is converted and published with cfg.
+func (h *RecordingHandler) Reconfigure(cfg *config.Config) {
+	h.settings.Store(&recordingSettings{cfg: cfg, publishers: newPublishers(cfg)})
+}
+
+func (h *RecordingHandler) cfg() *config.Config { return h.settings.Load().cfg }
+
+func (h *RecordingHandler) publishers() upload.Providers { return h.settings.Load().publishers }
+
 // MovieSelection records a video of a selected region.
-func (h *RecordingHandler) MovieSelection(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "movie selection", h.cfg.RecordingStartIcon); err != nil {
+func (h *RecordingHandler) MovieSelection(ctx context.Context, delay int, lastRegion bool, limit time.Duration, timer string) error {
+	if err := notify.CaptureDelay(delay, "movie selection", h.cfg().RecordingStartIcon); err != nil {
 		return err
 	}
 
-	geom, err := external.Slurp(ctx, "")
-	if err != nil || geom == "" {
-		return fmt.Errorf("selection cancelled or failed: %w", err)
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "movie-selection", "", lastRegion)
+	if err != nil {
+		return err
 	}
 
-	sleepWithCountdown(h.state, delay)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	return h.startRecording(ctx, geom, "")
+	_, err = h.startRecording(ctx, history.Entry{Command: "movie-selection", Geometry: geom}, limit, timer)
+	return err
 }
 
 // MovieScreen records a video of the screen (or current screen if useCurrentScreen is true).
-func (h *RecordingHandler) MovieScreen(ctx context.Context, delay int, useCurrentScreen bool) error {
+func (h *RecordingHandler) MovieScreen(ctx context.Context, delay int, useCurrentScreen, excludeBars bool, limit time.Duration, timer string) error {
 	output, err := sway.SelectOutput(ctx, useCurrentScreen)
 	if err != nil || output == "" {
 		return fmt.Errorf("failed to select output: %w", err)
 	}
+	geom, output, err := screenArea(ctx, output, excludeBars)
+	if err != nil {
+		return err
+	}
 
-	if err := notify.CaptureDelay(delay, "movie screen", h.cfg.RecordingStartIcon); err != nil {
+	if err := notify.CaptureDelay(delay, "movie screen", h.cfg().RecordingStartIcon); err != nil {
 		return err
 	}
 
-	sleepWithCountdown(h.state, delay)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	return h.startRecording(ctx, "", output)
+	_, err = h.startRecording(ctx, history.Entry{Command: "movie-screen", Geometry: geom, Output: output}, limit, timer)
+	return err
 }
 
 // MovieCurrentWindow records a video of the currently focused window.
-func (h *RecordingHandler) MovieCurrentWindow(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "movie current window", h.cfg.RecordingStartIcon); err != nil {
+func (h *RecordingHandler) MovieCurrentWindow(ctx context.Context, delay int, decorations string, limit time.Duration, timer string) error {
+	if err := notify.CaptureDelay(delay, "movie current window", h.cfg().RecordingStartIcon); err != nil {
 		return err
 	}
 
-	geom, err := sway.GetFocusedWindowGeometry(ctx)
+	geom, err := sway.GetFocusedWindowGeometry(ctx, decorations)
 	if err != nil {
 		return fmt.Errorf("failed to get window geometry: %w", err)
 	}
 
-	sleepWithCountdown(h.state, delay)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	return h.startRecording(ctx, geom, "")
+	_, err = h.startRecording(ctx, history.Entry{Command: "movie-current-window", Geometry: geom}, limit, timer)
+	return err
 }
 
-func (h *RecordingHandler) startRecording(ctx context.Context, geometry, output string) error {
-	base := h.cfg.GenerateRecordingBase()
+// startRecording starts wf-recorder on the geometry or output of the entry,
+// stopping it automatically after limit when it is positive, and returns
+// the base name of the recording. The timer overlay, if any, is burned in
+// when converting it.
+func (h *RecordingHandler) startRecording(ctx context.Context, entry history.Entry, limit time.Duration, timer string) (string, error) {
+	base := h.cfg().GenerateRecordingBase()
 	file := base + ".avi"
 
 	// Check if file exists, add PID suffix if needed
@@ -88,67 +146,244 @@ func (h *RecordingHandler) startRecording(ctx context.Context, geometry, output
 	}
 
 	// Save base filename to cache
-	if err := os.WriteFile(h.cfg.CacheFile, []byte(base), 0o600); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
+	if err := os.WriteFile(h.cfg().CacheFile, []byte(base), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write cache file: %w", err)
 	}
 
-	// Start wf-recorder
-	cmd, err := external.StartWfRecorder(ctx, geometry, output, file)
+	if timer != "" && timer != transcode.TimerOff {
+		if err := writeTimerInfo(base, timer); err != nil {
+			return "", err
+		}
+	}
+
+	cmd, err := h.startSegment(ctx, entry.Geometry, entry.Output, file)
 	if err != nil {
-		return fmt.Errorf("failed to start recording: %w", err)
+		return "", err
 	}
 
+	entry.Kind = history.Recording
+	entry.Time = time.Now()
+	entry.Tags = tags.Collect(ctx)
+	h.startedMu.Lock()
+	h.started = entry
+	h.startedMu.Unlock()
+
 	// Update state
 	h.state.SetRecording(true, file, cmd.Process.Pid)
+	ReportProgress(ctx, protocol.StageRecording, "Recording to "+file, 0)
 
-	// Monitor process in background
+	if limit > 0 {
+		deadline := time.Now().Add(limit)
+		h.state.SetRecordingDeadline(deadline)
+		go h.stopAt(ctx, deadline)
+	}
+	go h.remind(ctx, base)
+
+	return base, nil
+}
+
+// startSegment starts wf-recorder into file and clears the recording state
+// when it exits, unless another segment has taken over in the meantime.
+func (h *RecordingHandler) startSegment(ctx context.Context, geometry, output, file string) (*exec.Cmd, error) {
+	cmd, err := recording.Start(ctx, geometry, output, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	pid := cmd.Process.Pid
 	go func() {
 		_ = cmd.Wait()
-		h.state.SetRecording(false, "", 0)
+		h.state.EndRecording(pid)
 	}()
 
+	return cmd, nil
+}
+
+// stopAt stops and converts the recording at deadline, unless it has been
+// stopped or replaced by another recording before.
+func (h *RecordingHandler) stopAt(ctx context.Context, deadline time.Time) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		if !h.state.GetRecordingDeadline().Equal(deadline) {
+			return
+		}
+		if err := h.StopRecording(ctx); err != nil {
+			_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("Failed to stop recording: %v", err))
+		}
+	case <-ctx.Done():
+	}
+}
+
+// Retarget ends the current recording segment, lets the user pick a new
+// target and records it into a new segment. All segments are joined together
+// when the recording stops.
+func (h *RecordingHandler) Retarget(ctx context.Context, target string, useCurrentScreen bool) error {
+	current := h.state.GetState()
+	if !current.Recording {
+		return fmt.Errorf("no recording in progress")
+	}
+
+	data, err := os.ReadFile(h.cfg().CacheFile)
+	if err != nil {
+		return fmt.Errorf("failed to read cache file: %w", err)
+	}
+	base, segments := parseRecordingCache(string(data))
+
+	// Detach the running segment from the state before stopping it so the
+	// recording is still reported while the next target gets picked.
+	pid := h.state.GetRecordingPID()
+	h.state.SetRecordingSegment(current.RecordingFile, 0)
+	if err := stopProcess(pid); err != nil {
+		return err
+	}
+
+	var geometry, output string
+	switch target {
+	case "movie-selection":
+		geometry, err = selectRegion(ctx, h.cfg(), h.state, "movie-selection", "", false)
+	case "movie-screen":
+		output, err = sway.SelectOutput(ctx, useCurrentScreen)
+		if err == nil {
+			geometry, output, err = screenArea(ctx, output, h.cfg().ExcludeBars)
+		}
+	case "movie-current-window":
+		geometry, err = sway.GetFocusedWindowGeometry(ctx, h.cfg().Decorations)
+	default:
+		err = fmt.Errorf("invalid target: %s (valid: movie-selection, movie-screen, movie-current-window)", target)
+	}
+	if err != nil {
+		// The recording stays stoppable: the cache still lists the segments
+		return err
+	}
+
+	file := fmt.Sprintf("%s-part%d.avi", base, len(segments)+1)
+	segments = append(segments, file)
+	if err := os.WriteFile(h.cfg().CacheFile, []byte(strings.Join(append([]string{base}, segments[1:]...), "\n")), 0o600); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	cmd, err := h.startSegment(ctx, geometry, output, file)
+	if err != nil {
+		return err
+	}
+	h.state.SetRecordingSegment(file, cmd.Process.Pid)
+
 	return nil
 }
 
+// stopProcess interrupts a wf-recorder process and waits for it to finish
+// writing its file.
+func stopProcess(pid int) error {
+	if pid == 0 {
+		return nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find recording process: %w", err)
+	}
+	if err := process.Signal(syscall.SIGINT); err != nil {
+		return nil // Already gone
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := process.Signal(syscall.Signal(0)); err != nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return fmt.Errorf("recording process %d did not stop", pid)
+}
+
+// parseRecordingCache returns the recording base name and its segment files
+// from the cache file content: the base on the first line, followed by any
+// additional segment recorded after a retarget.
+func parseRecordingCache(data string) (string, []string) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	base := lines[0]
+	segments := []string{base + ".avi"}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			segments = append(segments, line)
+		}
+	}
+	return base, segments
+}
+
 // StopRecording stops the current recording and converts it to MP4.
 func (h *RecordingHandler) StopRecording(ctx context.Context) error {
 	// Kill wf-recorder
-	_ = exec.Command("killall", "-s", "SIGINT", "wf-recorder").Run() //nolint:gosec
+	_ = external.Run(ctx, exec.Command("killall", "-s", "SIGINT", "wf-recorder")) //nolint:gosec
 
 	// Wait a bit for process to terminate
 	time.Sleep(500 * time.Millisecond)
 
 	// Read cache file for base name
-	data, err := os.ReadFile(h.cfg.CacheFile)
+	data, err := os.ReadFile(h.cfg().CacheFile)
 	if err != nil {
 		return fmt.Errorf("failed to read cache file: %w", err)
 	}
 
-	base := string(data)
-	aviFile := base + ".avi"
+	base, segments := parseRecordingCache(string(data))
 
-	// Check if .avi file exists
-	if _, err := os.Stat(aviFile); os.IsNotExist(err) {
-		_ = notify.Send(5000, h.cfg.ScreenshotIcon, fmt.Sprintf("Could not find %s", aviFile))
-		return fmt.Errorf("recording file not found: %s", aviFile)
+	// Check if .avi files exist
+	for _, aviFile := range segments {
+		if _, err := os.Stat(aviFile); os.IsNotExist(err) {
+			_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("Could not find %s", aviFile))
+			return fmt.Errorf("recording file not found: %s", aviFile)
+		}
 	}
 
-	_ = notify.Send(3000, h.cfg.ScreenshotIcon, "Recording finished, converting")
+	_ = notify.Send(3000, h.cfg().ScreenshotIcon, "Recording finished, converting")
+	ReportProgress(ctx, protocol.StageConverting, "Converting the recording to MP4", 0)
 
 	// Convert to mp4
 	mp4File := base + ".mp4"
-	if err := external.Ffmpeg(ctx, aviFile, mp4File); err != nil {
+	overlay := timerOverlay(base)
+	switch {
+	case len(segments) == 1 && isZoomed(base):
+		err = convertZoomed(ctx, base, segments[0], mp4File, overlay)
+	case len(segments) == 1:
+		err = transcode.Convert(ctx, segments[0], mp4File, overlay)
+	default:
+		err = transcode.Concat(ctx, segments, mp4File, overlay)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to convert video: %w", err)
 	}
 
 	// Clean up
-	_ = os.Remove(aviFile)
-	_ = os.Remove(h.cfg.CacheFile)
+	for _, aviFile := range segments {
+		_ = os.Remove(aviFile)
+	}
+	removeZoomFiles(base)
+	_ = os.Remove(base + ".timer")
+	_ = os.Remove(h.cfg().CacheFile)
 
 	// Update state
 	h.state.SetRecording(false, "", 0)
 
-	_ = notify.Send(5000, h.cfg.RecordingStopIcon, fmt.Sprintf("%s is available", base+".mp4"))
+	h.startedMu.Lock()
+	entry := h.started
+	h.startedMu.Unlock()
+	entry.Kind = history.Recording
+	entry.File = mp4File
+	noteSaved(ctx, mp4File)
+	if err := h.history.Add(entry); err != nil {
+		slog.Warn("Failed to record recording in history", "error", err)
+	}
+	if h.cfg().Sidecar {
+		if err := history.WriteSidecar(entry); err != nil {
+			slog.Warn("Failed to write sidecar", "error", err)
+		}
+	}
+
+	// Publishing can take much longer than the client waits for
+	go h.recordingFinished(ctx, mp4File)
 
 	return nil
 }
@@ -176,16 +411,16 @@ func (h *RecordingHandler) PauseRecording(ctx context.Context) error {
 	h.state.SetPaused(newPausedState)
 
 	if newPausedState {
-		_ = notify.Send(2000, h.cfg.RecordingPauseIcon, "Recording paused")
+		_ = notify.Send(2000, h.cfg().RecordingPauseIcon, "Recording paused")
 	} else {
-		_ = notify.Send(2000, h.cfg.RecordingStartIcon, "Recording resumed")
+		_ = notify.Send(2000, h.cfg().RecordingStartIcon, "Recording resumed")
 	}
 
 	return nil
 }
 
 // ToggleRecord toggles recording state: starts if not recording, stops if recording.
-func (h *RecordingHandler) ToggleRecord(ctx context.Context, startAction string, delay int, useCurrentScreen bool) error {
+func (h *RecordingHandler) ToggleRecord(ctx context.Context, startAction string, delay int, useCurrentScreen, lastRegion bool, limit time.Duration, timer string) error {
 	// Check current state
 	currentState := h.state.GetState()
 
@@ -197,13 +432,13 @@ func (h *RecordingHandler) ToggleRecord(ctx context.Context, startAction string,
 	// Not recording, validate and start with specified action
 	switch startAction {
 	case "movie-selection":
-		return h.MovieSelection(ctx, delay)
+		return h.MovieSelection(ctx, delay, lastRegion, limit, timer)
 
 	case "movie-screen":
-		return h.MovieScreen(ctx, delay, useCurrentScreen)
+		return h.MovieScreen(ctx, delay, useCurrentScreen, h.cfg().ExcludeBars, limit, timer)
 
 	case "movie-current-window":
-		return h.MovieCurrentWindow(ctx, delay)
+		return h.MovieCurrentWindow(ctx, delay, h.cfg().Decorations, limit, timer)
 
 	default:
 		return fmt.Errorf("invalid start action: %s (valid: movie-selection, movie-screen, movie-current-window)", startAction)
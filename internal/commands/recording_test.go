@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chmouel/sway-easyshot/pkg/state"
+)
+
+func TestMovieSelectionRecordsTheSelection(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+	runner.queue("slurp", []byte("1,2 30x40\n"), nil)
+
+	st := state.NewState()
+	h := NewRecordingHandler(cfg, st, nil)
+	if err := h.MovieSelection(ctx, 0, false, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := runner.callsOf("wf-recorder")
+	if len(calls) != 1 {
+		t.Fatalf("wf-recorder ran %d times, want once", len(calls))
+	}
+	args := calls[0]
+	if i := slices.Index(args, "-g"); i < 0 || args[i+1] != "1,2 30x40" {
+		t.Errorf("wf-recorder got %q, want the selection as geometry", args)
+	}
+	if i := slices.Index(args, "-f"); i < 0 || !strings.HasPrefix(args[i+1], cfg.SaveLocation) {
+		t.Errorf("wf-recorder got %q, want a file in %s", args, cfg.SaveLocation)
+	}
+	if region, ok := st.GetLastRegion("movie-selection"); !ok || region != "1,2 30x40" {
+		t.Errorf("last region is %q, want the selection", region)
+	}
+}
+
+func TestMovieSelectionLastRegion(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+
+	st := state.NewState()
+	st.SetLastRegion("movie-selection", "5,6 70x80")
+	h := NewRecordingHandler(cfg, st, nil)
+	if err := h.MovieSelection(ctx, 0, true, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := runner.callsOf("slurp"); len(calls) != 0 {
+		t.Errorf("slurp ran %d times, want the last region reused", len(calls))
+	}
+	calls := runner.callsOf("wf-recorder")
+	if len(calls) != 1 || !slices.Contains(calls[0], "5,6 70x80") {
+		t.Errorf("wf-recorder got %q, want the last region", calls)
+	}
+}
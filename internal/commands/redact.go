@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/pkg/screenshot"
+)
+
+// redactBlockSize is the size of the pixelation blocks, in screen pixels.
+const redactBlockSize = 12
+
+// redact lets the user select areas of the capture, still shown on screen,
+// until the selection is cancelled and pixelates them. The capture is saved
+// again, or copied again when it only went to the clipboard.
+func (h *ScreenshotHandler) redact(ctx context.Context, c *capture) error {
+	data, err := c.bytes()
+	if err != nil {
+		return err
+	}
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	origin, err := parseGeometry(c.Geometry)
+	if err != nil {
+		return err
+	}
+	// The image holds more pixels than the area on HiDPI outputs
+	scale := float64(img.Bounds().Dx()) / float64(origin.Dx())
+
+	count := 0
+	for {
+		geom, err := screenshot.SelectWith(ctx, h.cfg().Selector, "#ff000080")
+		if err != nil {
+			break
+		}
+		area, err := parseGeometry(geom)
+		if err != nil {
+			return err
+		}
+
+		area = area.Intersect(origin).Sub(origin.Min)
+		if area.Empty() {
+			continue
+		}
+		area = image.Rect(
+			int(float64(area.Min.X)*scale), int(float64(area.Min.Y)*scale),
+			int(float64(area.Max.X)*scale), int(float64(area.Max.Y)*scale),
+		)
+		img = imaging.Pixelate(img, area, int(redactBlockSize*scale))
+		count++
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	data, err = imaging.EncodePNG(img)
+	if err != nil {
+		return err
+	}
+	c.Data = data
+
+	if c.File != "" {
+		if err := h.undo.Overwriting("Pixelation", c.File); err != nil {
+			return err
+		}
+		if err := os.WriteFile(c.File, data, 0o600); err != nil {
+			return err
+		}
+	} else if err := h.copyToClipboard(ctx, data, "image/png"); err != nil {
+		return err
+	}
+	h.updateThumbnail(c.File, data)
+
+	return h.offerUndo(ctx, fmt.Sprintf("Pixelated %d area(s)", count))
+}
+
+// parseGeometry parses a geometry in the "x,y wxh" format of slurp.
+func parseGeometry(geom string) (image.Rectangle, error) {
+	var x, y, w, h int
+	if _, err := fmt.Sscanf(geom, "%d,%d %dx%d", &x, &y, &w, &h); err != nil || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid geometry: %q", geom)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
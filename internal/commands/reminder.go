@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// reminderCheck is how often a recording is checked on while it lasts.
+const reminderCheck = time.Minute
+
+// remind keeps an eye on the recording of base until it stops: it tells how
+// long it has been going and how large it is every RecordingReminder, so
+// that a forgotten recording gets noticed, and warns once when the disk it
+// is saved to runs low.
+func (h *RecordingHandler) remind(ctx context.Context, base string) {
+	ticker := time.NewTicker(reminderCheck)
+	defer ticker.Stop()
+
+	started := time.Now()
+	next := started.Add(h.cfg().RecordingReminder)
+	lowDisk := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		data, err := os.ReadFile(h.cfg().CacheFile)
+		if err != nil || !h.state.GetState().Recording {
+			return
+		}
+		current, segments := parseRecordingCache(string(data))
+		if current != base {
+			return
+		}
+
+		if free, err := freeSpace(h.cfg().SaveLocation); err == nil {
+			if free < h.cfg().LowDiskSpace && !lowDisk {
+				_ = notify.Send(10000, h.cfg().RecordingStopIcon,
+					fmt.Sprintf("Only %s left on disk, stop the recording soon", formatBytes(free)))
+			}
+			lowDisk = free < h.cfg().LowDiskSpace
+		}
+
+		if h.cfg().RecordingReminder > 0 && time.Now().After(next) {
+			var size int64
+			for _, segment := range segments {
+				if info, err := os.Stat(segment); err == nil {
+					size += info.Size()
+				}
+			}
+			_ = notify.Send(5000, h.cfg().RecordingStartIcon, fmt.Sprintf("Still recording: %s so far, %s",
+				time.Since(started).Round(time.Minute), formatBytes(size)))
+			next = next.Add(h.cfg().RecordingReminder)
+		}
+	}
+}
+
+// freeSpace returns the space left to the user on the file system of dir,
+// in bytes.
+func freeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * st.Bsize, nil //nolint:gosec
+}
+
+// formatBytes returns a size in bytes for humans, e.g. "1.4 GiB".
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TiB", value)
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// savedKey holds the files saved while serving a request.
+type savedKey struct{}
+
+type savedFiles struct {
+	mu    sync.Mutex
+	files []string
+}
+
+// WithSaved returns a context recording the files its captures are saved to,
+// and a function returning them, for the client to report them.
+func WithSaved(ctx context.Context) (context.Context, func() []string) {
+	saved := &savedFiles{}
+	return context.WithValue(ctx, savedKey{}, saved), func() []string {
+		saved.mu.Lock()
+		defer saved.mu.Unlock()
+		return slices.Clone(saved.files)
+	}
+}
+
+// noteSaved records that a capture was saved to file.
+func noteSaved(ctx context.Context, file string) {
+	saved, ok := ctx.Value(savedKey{}).(*savedFiles)
+	if !ok {
+		return
+	}
+	saved.mu.Lock()
+	saved.files = append(saved.files, file)
+	saved.mu.Unlock()
+}
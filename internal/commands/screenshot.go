@@ -2,305 +2,719 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"image"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"sway-easyshot/internal/config"
-	"sway-easyshot/internal/external"
-	"sway-easyshot/internal/notify"
-	"sway-easyshot/internal/state"
-	"sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/ai"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/internal/nightlight"
+	"github.com/chmouel/sway-easyshot/internal/queue"
+	"github.com/chmouel/sway-easyshot/internal/scroll"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/internal/undo"
+	"github.com/chmouel/sway-easyshot/internal/upload"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/screenshot"
+	"github.com/chmouel/sway-easyshot/pkg/state"
 )
 
 // ScreenshotHandler provides methods for screenshot operations.
 type ScreenshotHandler struct {
-	cfg   *config.Config
-	state *state.State
+	settings atomic.Pointer[screenshotSettings]
+	state    *state.State
+	uploads  *queue.Queue
+	undo     *undo.Journal
+	history  *history.History
 }
 
-// NewScreenshotHandler creates a new screenshot handler instance.
-func NewScreenshotHandler(cfg *config.Config, st *state.State) *ScreenshotHandler {
-	return &ScreenshotHandler{cfg: cfg, state: st}
+// screenshotSettings are the configuration of a screenshot handler and what
+// derives from it, replaced together when the configuration is reloaded.
+type screenshotSettings struct {
+	cfg       *config.Config
+	ai        ai.Backend
+	providers upload.Providers
 }
 
-// sleepWithCountdown sleeps for the given delay while updating the countdown state
-func sleepWithCountdown(st *state.State, delay int) {
+// NewScreenshotHandler creates a new screenshot handler instance, recording
+// captures in hist unless it is nil.
+func NewScreenshotHandler(cfg *config.Config, st *state.State, hist *history.History) *ScreenshotHandler {
+	h := &ScreenshotHandler{
+		state:   st,
+		uploads: queue.New(filepath.Join(cfg.StateDir, "uploads")),
+		history: hist,
+	}
+	h.undo = undo.New(filepath.Join(cfg.StateDir, "undo"), h.discard)
+	h.Reconfigure(cfg)
+	return h
+}
+
+// Reconfigure makes the handler use cfg for the operations it performs from
+// now on. The state directory cannot change.
+func (h *ScreenshotHandler) Reconfigure(cfg *config.Config) {
+	// The backend name has been validated when loading the configuration
+	backend, _ := ai.New(cfg.AIBackend, cfg.AIEndpoint, cfg.AIAPIKey, cfg.TempDir)
+	capability.SetTools(capability.AI, backend.Tools())
+	capability.SetTools(capability.Scroll, scroll.Tools(cfg.ScrollMethod))
+	capability.SetTools(capability.Encrypt, []string{cfg.EncryptTool})
+	if editor := strings.Fields(cfg.FullEditor); len(editor) > 0 {
+		capability.SetTools(capability.FullEditor, editor[:1])
+	}
+	if viewer := strings.Fields(cfg.QRViewer); len(viewer) > 0 {
+		capability.SetTools(capability.QRCode, []string{"qrencode", viewer[0]})
+	}
+
+	h.settings.Store(&screenshotSettings{cfg: cfg, ai: backend, providers: newProviders(cfg)})
+}
+
+func (h *ScreenshotHandler) cfg() *config.Config { return h.settings.Load().cfg }
+
+func (h *ScreenshotHandler) ai() ai.Backend { return h.settings.Load().ai }
+
+func (h *ScreenshotHandler) providers() upload.Providers { return h.settings.Load().providers }
+
+// sleepWithCountdown sleeps for the given delay while updating the countdown
+// state, failing with ErrCancelled when the request is cancelled meanwhile.
+func sleepWithCountdown(ctx context.Context, st *state.State, delay int) error {
 	if delay <= 0 {
-		return
+		return nil
 	}
+	defer st.ClearCountdown()
+	wait, stop := Waiting(ctx)
+	defer stop()
 	for i := delay; i > 0; i-- {
 		st.SetCountdown(i)
-		time.Sleep(time.Second)
+		ReportProgress(ctx, protocol.StageCountdown, fmt.Sprintf("Starting in %ds", i), i)
+		select {
+		case <-time.After(time.Second):
+		case <-wait.Done():
+			return ErrCancelled
+		}
 	}
-	st.ClearCountdown()
+	return nil
 }
 
-// CurrentWindowClipboard captures the focused window and copies it to clipboard.
-func (h *ScreenshotHandler) CurrentWindowClipboard(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "window to clipboard", h.cfg.ScreenshotIcon); err != nil {
-		return err
+// ErrCancelled is returned when the user declines to go on with a capture.
+var ErrCancelled = errors.New("capture cancelled")
+
+// selectRegion asks the user for a region with the configured selector and
+// remembers it for the action, or reuses the previously remembered one when
+// lastRegion is set.
+func selectRegion(ctx context.Context, cfg *config.Config, st *state.State, action, color string, lastRegion bool) (string, error) {
+	if lastRegion {
+		geom, ok := st.GetLastRegion(action)
+		if !ok {
+			return "", fmt.Errorf("no previous region has been selected")
+		}
+		return geom, nil
 	}
 
-	geom, err := sway.GetFocusedWindowGeometry(ctx)
+	ReportProgress(ctx, protocol.StageSelecting, "Waiting for a selection", 0)
+	wait, stop := Waiting(ctx)
+	defer stop()
+	geom, err := screenshot.SelectWith(wait, cfg.Selector, color)
+	if errors.Is(err, screenshot.ErrCancelled) {
+		return "", err
+	}
+	if cancelled(ctx) {
+		return "", ErrCancelled
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get window geometry: %w", err)
+		return "", fmt.Errorf("selection failed: %w", err)
+	}
+
+	st.SetLastRegion(action, geom)
+	return geom, nil
+}
+
+// grab captures a geometry or output, compensating for any night-light
+// filter according to the configuration.
+func (h *ScreenshotHandler) grab(ctx context.Context, geom, output string) ([]byte, error) {
+	if h.cfg().NightLight == nightlight.ModeSuspend {
+		restore := nightlight.Suspend()
+		defer restore()
 	}
 
-	sleepWithCountdown(h.state, delay)
+	return screenshot.Capture(ctx, geom, output, "")
+}
 
-	data, err := external.Grim(ctx, geom, "", "")
+// grabToFile captures the geometry or output of the entry into its file,
+// beautified when pretty is set.
+func (h *ScreenshotHandler) grabToFile(ctx context.Context, entry history.Entry, pretty bool) error {
+	data, err := h.grab(ctx, entry.Geometry, entry.Output)
 	if err != nil {
-		return fmt.Errorf("failed to capture screenshot: %w", err)
+		return err
 	}
+	if data, err = h.beautify(data, pretty); err != nil {
+		return err
+	}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
+	return nil
+}
 
-	return external.WlCopy(ctx, data, "image/png")
+// beautify applies the configured presentation to the capture data when
+// pretty is set.
+func (h *ScreenshotHandler) beautify(data []byte, pretty bool) ([]byte, error) {
+	if !pretty {
+		return data, nil
+	}
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.EncodePNG(imaging.Beautify(img, h.cfg().Pretty))
 }
 
-// CurrentWindowFile captures the focused window and saves it to a file.
-func (h *ScreenshotHandler) CurrentWindowFile(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "window to file", h.cfg.ScreenshotIcon); err != nil {
+// shownAt returns the geometry a capture is shown at on screen for actions
+// working on it there, none once beautified as it no longer matches.
+func shownAt(geom string, pretty bool) string {
+	if pretty {
+		return ""
+	}
+	return geom
+}
+
+// thumbnailSize is the largest dimension of capture thumbnails.
+const thumbnailSize = 256
+
+// writeCapture saves the capture data to the file of the entry, describing
+// it in PNG text chunks when enabled so that where it came from is known
+// wherever the file goes, and encrypted when the context asks for it.
+func (h *ScreenshotHandler) writeCapture(ctx context.Context, entry history.Entry, data []byte) error {
+	if h.cfg().EmbedMetadata {
+		host, _ := os.Hostname()
+		embedded, err := imaging.EmbedText(data, map[string]string{
+			"Software":      "sway-easyshot",
+			"Creation Time": time.Now().Format(time.RFC1123Z),
+			"Source":        host,
+			"Command":       entry.Command,
+			"Application":   entry.AppID,
+			"Title":         entry.Title,
+			"Workspace":     entry.Workspace,
+			"Project":       entry.Project,
+		})
+		if err != nil {
+			slog.Warn("Failed to embed metadata", "error", err)
+		} else {
+			data = embedded
+		}
+	}
+	if encrypting(ctx) {
+		encrypted, err := h.encrypt(ctx, data)
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+	if err := os.WriteFile(entry.File, data, 0o600); err != nil {
 		return err
 	}
+	noteSaved(ctx, entry.File)
+	return nil
+}
+
+// recordCapture adds the capture to the history and remembers it as the
+// most recent one.
+func (h *ScreenshotHandler) recordCapture(ctx context.Context, entry history.Entry, data []byte) {
+	h.addHistory(ctx, entry)
+	h.updateThumbnail(entry.File, data)
+}
+
+// addHistory adds a screenshot to the history, and writes its sidecar when
+// enabled, which are not worth failing the capture for. Encrypted captures
+// get no sidecar, which would tell in clear what they show.
+func (h *ScreenshotHandler) addHistory(ctx context.Context, entry history.Entry) {
+	entry.Kind = history.Screenshot
+	entry.Time = time.Now()
+	if err := h.history.Add(entry); err != nil {
+		slog.Warn("Failed to record capture in history", "error", err)
+	}
+	if h.cfg().Sidecar && !encrypting(ctx) {
+		if err := history.WriteSidecar(entry); err != nil {
+			slog.Warn("Failed to write sidecar", "error", err)
+		}
+	}
+}
 
-	geom, err := sway.GetFocusedWindowGeometry(ctx)
+// updateThumbnail renders the thumbnail of the most recent capture in the
+// background, replacing the previous thumbnail. A capture whose thumbnail
+// is ready after that of a later one is dropped.
+func (h *ScreenshotHandler) updateThumbnail(file string, data []byte) {
+	taken := time.Now()
+	go func() {
+		img, err := imaging.Decode(data)
+		if err != nil {
+			return
+		}
+		thumb, err := imaging.EncodePNG(imaging.Thumbnail(img, thumbnailSize))
+		if err != nil {
+			return
+		}
+
+		if err := os.MkdirAll(h.cfg().ThumbnailDir, 0o700); err != nil {
+			return
+		}
+		// A new path for each capture lets consumers notice the change
+		path := filepath.Join(h.cfg().ThumbnailDir, fmt.Sprintf("thumbnail-%d.png", taken.UnixNano()))
+		if err := os.WriteFile(path, thumb, 0o600); err != nil {
+			return
+		}
+
+		if stale := h.state.SetLastCaptureAt(file, path, taken); stale != "" {
+			_ = os.Remove(stale)
+		}
+	}()
+}
+
+// captureWindow captures the given geometry, preserving the alpha channel
+// when transparent is set.
+func (h *ScreenshotHandler) captureWindow(ctx context.Context, geom string, transparent bool) ([]byte, error) {
+	if !transparent {
+		return h.grab(ctx, geom, "")
+	}
+	return h.captureTransparent(ctx, geom)
+}
+
+// captureTransparent captures the geometry twice, over a black and over a
+// white background, and derives the alpha channel from the difference.
+func (h *ScreenshotHandler) captureTransparent(ctx context.Context, geom string) ([]byte, error) {
+	output, err := sway.GetFocusedOutputName(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get window geometry: %w", err)
+		return nil, err
+	}
+
+	restore := h.cfg().Wallpaper
+	if restore == "" {
+		backgrounds, err := sway.GetOutputBackgrounds(ctx)
+		if err != nil {
+			return nil, err
+		}
+		restore = backgrounds[output]
+		if restore == "" {
+			restore = backgrounds["*"]
+		}
+	}
+	if restore == "" {
+		return nil, fmt.Errorf("cannot determine the background of %s to restore it, please set SWAY_SCREENSHOT_WALLPAPER", output)
 	}
+	defer func() {
+		// Even when the request is cancelled meanwhile
+		if err := sway.SetOutputBackground(context.WithoutCancel(ctx), output, restore); err != nil {
+			_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("Failed to restore background: %v", err))
+		}
+	}()
+
+	var captures [2][]byte
+	for i, bg := range []string{"#000000 solid_color", "#ffffff solid_color"} {
+		if err := sway.SetOutputBackground(ctx, output, bg); err != nil {
+			return nil, err
+		}
+		// Give swaybg a moment to repaint before capturing
+		time.Sleep(250 * time.Millisecond)
 
-	file := h.cfg.GenerateFilename()
-	sleepWithCountdown(h.state, delay)
+		captures[i], err = h.grab(ctx, geom, "")
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	_, err = external.Grim(ctx, geom, "", file)
+	onBlack, err := imaging.Decode(captures[0])
 	if err != nil {
-		return fmt.Errorf("failed to capture screenshot: %w", err)
+		return nil, err
+	}
+	onWhite, err := imaging.Decode(captures[1])
+	if err != nil {
+		return nil, err
 	}
 
-	return notify.Send(3000, h.cfg.ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file))) //nolint:errcheck
+	img, err := imaging.DifferenceMatte(onBlack, onWhite)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.EncodePNG(img)
 }
 
-// CurrentScreenClipboard captures the current screen and copies it to clipboard.
-func (h *ScreenshotHandler) CurrentScreenClipboard(ctx context.Context, delay int, useCurrentScreen bool) error {
-	output, err := sway.SelectOutput(ctx, useCurrentScreen)
-	if err != nil || output == "" {
-		return fmt.Errorf("failed to select output: %w", err)
+// CurrentWindowClipboard captures the focused window and copies it to clipboard.
+func (h *ScreenshotHandler) CurrentWindowClipboard(ctx context.Context, delay int, transparent, pretty bool, decorations string) error {
+	if err := notify.CaptureDelay(delay, "window to clipboard", h.cfg().ScreenshotIcon); err != nil {
+		return err
 	}
 
-	if err := notify.CaptureDelay(delay, "screen to clipboard", h.cfg.ScreenshotIcon); err != nil {
-		return err
+	geom, err := sway.GetFocusedWindowGeometry(ctx, decorations)
+	if err != nil {
+		return fmt.Errorf("failed to get window geometry: %w", err)
 	}
 
-	sleepWithCountdown(h.state, delay)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	data, err := external.Grim(ctx, "", output, "")
+	data, err := h.captureWindow(ctx, geom, transparent)
 	if err != nil {
 		return fmt.Errorf("failed to capture screenshot: %w", err)
 	}
+	if data, err = h.beautify(data, pretty); err != nil {
+		return err
+	}
 
-	return external.WlCopy(ctx, data, "image/png")
+	if err := h.copyToClipboard(ctx, data, "image/png"); err != nil {
+		return err
+	}
+	captureTags := tags.Collect(ctx)
+	h.recordCapture(ctx, history.Entry{Command: "current-window-clipboard", Geometry: geom, Tags: captureTags}, data)
+
+	_, err = h.offerActions(ctx, "current-window-clipboard", "Screenshot captured to clipboard", &capture{Data: data, Tags: captureTags, Geometry: shownAt(geom, pretty)})
+	return err
 }
 
-// SelectionFile captures a selected region and saves it to a file.
-func (h *ScreenshotHandler) SelectionFile(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "selection to file", h.cfg.ScreenshotIcon); err != nil {
+// CurrentWindowFile captures the focused window and saves it to a file.
+func (h *ScreenshotHandler) CurrentWindowFile(ctx context.Context, delay int, transparent, pretty bool, decorations string) error {
+	if err := notify.CaptureDelay(delay, "window to file", h.cfg().ScreenshotIcon); err != nil {
 		return err
 	}
 
-	geom, err := external.Slurp(ctx, "")
-	if err != nil || geom == "" {
-		return fmt.Errorf("selection cancelled or failed: %w", err)
+	geom, err := sway.GetFocusedWindowGeometry(ctx, decorations)
+	if err != nil {
+		return fmt.Errorf("failed to get window geometry: %w", err)
 	}
 
-	file := h.cfg.GenerateFilename()
-	sleepWithCountdown(h.state, delay)
+	captureTags := tags.Collect(ctx)
+	file := h.captureFile(ctx, captureTags)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	_, err = external.Grim(ctx, geom, "", file)
+	data, err := h.captureWindow(ctx, geom, transparent)
 	if err != nil {
 		return fmt.Errorf("failed to capture screenshot: %w", err)
 	}
+	if data, err = h.beautify(data, pretty); err != nil {
+		return err
+	}
+	entry := history.Entry{Command: "current-window-file", File: file, Geometry: geom, Tags: captureTags}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
 
-	// Show notification with actions
-	actions := map[string]string{
-		"copyclip": "Copy image",
-		"rename":   "Rename",
-		"copypath": "Copy path",
-		"edit":     "Edit",
+	if offered, err := h.offerActions(ctx, "current-window-file", filepath.Base(file), &capture{File: file, Data: data, Tags: captureTags, Geometry: shownAt(geom, pretty)}); offered {
+		return err
 	}
 
-	action, err := notify.SendWithActions(30000, h.cfg.ScreenshotIcon, filepath.Base(file), actions)
-	if err != nil {
-		// Action selection failed, but screenshot was saved
-		return notify.Send(5000, h.cfg.ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file)))
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file))) //nolint:errcheck
+}
+
+// windowSettleDelay leaves sway the time to render a workspace switched to
+// before it gets captured.
+const windowSettleDelay = 250 * time.Millisecond
+
+// WindowFile captures a window which may live on another workspace to a file,
+// found by workspace and/or app id. Its workspace is shown just for the
+// capture, after which the previous workspaces and focus are put back.
+func (h *ScreenshotHandler) WindowFile(ctx context.Context, delay int, workspace, appID string, pretty bool) error {
+	if workspace == "" && appID == "" {
+		return fmt.Errorf("a workspace or an app id is required to find the window")
 	}
 
-	action = strings.TrimSpace(action)
+	target, err := sway.FindWindow(ctx, workspace, appID)
+	if err != nil {
+		return err
+	}
 
-	switch action {
-	case "copyclip":
-		data, err := os.ReadFile(file) //nolint:gosec
-		if err != nil {
+	if h.cfg().ConfirmSwitch {
+		if err := capability.Require(capability.Dialog); err != nil {
 			return err
 		}
-		return external.WlCopy(ctx, data, "image/png")
+		question := fmt.Sprintf("Switch to workspace %s to capture %s?", target.Workspace, target.Title)
+		if !external.ZenityQuestion(ctx, question) {
+			return ErrCancelled
+		}
+	}
 
-	case "copypath":
-		return external.WlCopyText(ctx, file)
+	if err := notify.CaptureDelay(delay, "window to file", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	case "rename", "edit":
-		newname, err := external.Zenity(ctx, "Rename file", filepath.Base(file))
-		if err != nil || newname == "" {
-			return nil
-		}
+	restore, err := sway.Reveal(ctx, target)
+	if err != nil {
+		return fmt.Errorf("failed to show window: %w", err)
+	}
+	time.Sleep(windowSettleDelay)
 
-		ext := filepath.Ext(file)
-		if !strings.HasSuffix(newname, ext) {
-			newname += ext
-		}
+	// The window may be laid out differently once its workspace is shown
+	captureTags := tags.Collect(ctx)
+	geom := target.Geometry
+	if win, err := sway.GetFocusedWindow(ctx); err == nil && win.ID == target.ID {
+		geom = win.Geometry
+	}
+	data, err := h.grab(ctx, geom, "")
+	restore()
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	if data, err = h.beautify(data, pretty); err != nil {
+		return err
+	}
 
-		if action == "edit" {
-			outputFile := filepath.Join(h.cfg.SaveLocation, newname)
-			return external.Satty(ctx, file, outputFile, true)
-		}
+	file := h.captureFile(ctx, captureTags)
+	entry := history.Entry{Command: "window-file", File: file, Geometry: geom, Tags: captureTags}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
 
-		newPath := filepath.Join(h.cfg.SaveLocation, newname)
-		return os.Rename(file, newPath)
+	if offered, err := h.offerActions(ctx, "window-file", filepath.Base(file), &capture{File: file, Data: data, Tags: captureTags}); offered {
+		return err
 	}
 
-	return nil
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file))) //nolint:errcheck
 }
 
-// SelectionEdit captures a selected region, opens an editor, and saves the result.
-func (h *ScreenshotHandler) SelectionEdit(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "selection edit", h.cfg.ScreenshotIcon); err != nil {
+// CurrentScreenClipboard captures the current screen and copies it to clipboard.
+func (h *ScreenshotHandler) CurrentScreenClipboard(ctx context.Context, delay int, useCurrentScreen, excludeBars bool) error {
+	output, err := sway.SelectOutput(ctx, useCurrentScreen)
+	if err != nil || output == "" {
+		return fmt.Errorf("failed to select output: %w", err)
+	}
+	geom, output, err := screenArea(ctx, output, excludeBars)
+	if err != nil {
 		return err
 	}
 
-	geom, err := external.Slurp(ctx, "#ff0000ff")
-	if err != nil || geom == "" {
-		return fmt.Errorf("selection cancelled or failed: %w", err)
+	if err := notify.CaptureDelay(delay, "screen to clipboard", h.cfg().ScreenshotIcon); err != nil {
+		return err
 	}
 
-	sleepWithCountdown(h.state, delay)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	data, err := external.Grim(ctx, geom, "", "")
+	data, err := h.grab(ctx, geom, output)
 	if err != nil {
 		return fmt.Errorf("failed to capture screenshot: %w", err)
 	}
 
-	// Write to temporary file for satty
-	tmpFile := fmt.Sprintf("/tmp/screenshot-%d.png", time.Now().Unix())
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+	if err := h.copyToClipboard(ctx, data, "image/png"); err != nil {
 		return err
 	}
-	defer func() { _ = os.Remove(tmpFile) }()
+	captureTags := tags.Collect(ctx)
+	h.recordCapture(ctx, history.Entry{Command: "current-screen-clipboard", Geometry: geom, Output: output, Tags: captureTags}, data)
 
-	outputFile := filepath.Join(h.cfg.SaveLocation, fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-15:04:05")))
-	return external.Satty(ctx, tmpFile, outputFile, true)
+	_, err = h.offerActions(ctx, "current-screen-clipboard", "Screenshot captured to clipboard", &capture{Data: data, Tags: captureTags})
+	return err
 }
 
-// SelectionClipboard captures a selected region and copies it to clipboard.
-func (h *ScreenshotHandler) SelectionClipboard(ctx context.Context, delay int) error {
-	if err := notify.CaptureDelay(delay, "selection to clipboard", h.cfg.ScreenshotIcon); err != nil {
-		return err
+// screenArea returns what to capture of an output: the whole output, or the
+// geometry left by its bars when excludeBars is set.
+func screenArea(ctx context.Context, output string, excludeBars bool) (geometry, out string, err error) {
+	if !excludeBars {
+		return "", output, nil
 	}
-
-	geom, err := external.Slurp(ctx, "")
-	if err != nil || geom == "" {
-		return fmt.Errorf("selection cancelled or failed: %w", err)
+	geometry, err = sway.GetUsableArea(ctx, output)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to exclude bars: %w", err)
 	}
+	return geometry, "", nil
+}
 
-	sleepWithCountdown(h.state, delay)
+// SelectionFile captures a selected region and saves it to a file.
+func (h *ScreenshotHandler) SelectionFile(ctx context.Context, delay int, lastRegion, pretty bool) error {
+	if err := notify.CaptureDelay(delay, "selection to file", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
 
-	data, err := external.Grim(ctx, geom, "", "")
+	captureTags := tags.Collect(ctx)
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "selection-file", "", lastRegion)
 	if err != nil {
-		return fmt.Errorf("failed to capture screenshot: %w", err)
+		return err
 	}
 
-	if err := external.WlCopy(ctx, data, "image/png"); err != nil {
+	file := h.captureFile(ctx, captureTags)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
 		return err
 	}
 
-	// Show notification with actions
-	actions := map[string]string{
-		"save":   "Save",
-		"saveai": "Save with AI",
-		"edit":   "Edit",
+	entry := history.Entry{Command: "selection-file", File: file, Geometry: geom, Tags: captureTags}
+	if err := h.grabToFile(ctx, entry, pretty); err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
 	}
 
-	action, err := notify.SendWithActions(30000, h.cfg.ScreenshotIcon, "Screenshot captured to clipboard", actions)
-	if err != nil {
-		return nil // Clipboard copy succeeded, ignore action error
+	offered, err := h.offerActions(ctx, "selection-file", filepath.Base(file), &capture{File: file, Tags: captureTags, Geometry: shownAt(geom, pretty)})
+	if !offered {
+		// No action could be offered, but screenshot was saved
+		return notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file)))
+	}
+	return err
+}
+
+// SelectionMulti lets the user select regions until selection is cancelled
+// and saves each of them to its own file, or a single composite image.
+func (h *ScreenshotHandler) SelectionMulti(ctx context.Context, delay int, composite bool) error {
+	captureTags := tags.Collect(ctx)
+	var regions []string
+	wait, stop := Waiting(ctx)
+	var err error
+	for {
+		// Cancelling the selector ends the selections, anything else fails
+		var geom string
+		geom, err = screenshot.SelectWith(wait, h.cfg().Selector, "")
+		if err != nil {
+			break
+		}
+		regions = append(regions, geom)
+	}
+	stop()
+	if cancelled(ctx) {
+		return ErrCancelled
+	}
+	if !errors.Is(err, screenshot.ErrCancelled) {
+		return fmt.Errorf("selection failed: %w", err)
 	}
+	if len(regions) == 0 {
+		return screenshot.ErrCancelled
+	}
+	h.state.SetLastRegion("selection-multi", regions[len(regions)-1])
 
-	action = strings.TrimSpace(action)
+	if err := notify.CaptureDelay(delay, fmt.Sprintf("%d selections", len(regions)), h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
 
-	if action == "" || (action != "save" && action != "saveai" && action != "edit") {
-		return nil
+	captures := make([][]byte, 0, len(regions))
+	for _, geom := range regions {
+		data, err := h.grab(ctx, geom, "")
+		if err != nil {
+			return fmt.Errorf("failed to capture screenshot: %w", err)
+		}
+		captures = append(captures, data)
 	}
 
-	defaultName := filepath.Base(h.cfg.GenerateFilename())
+	name := h.cfg().GenerateTaggedFilename(captureTags)
 
-	if action == "saveai" {
-		tmpFile := fmt.Sprintf("/tmp/screenshot-%d.png", time.Now().Unix())
-		clipData, err := external.WlPaste(ctx, "image/png")
+	if composite {
+		file := h.placeCapture(ctx, name)
+		images := make([]image.Image, 0, len(captures))
+		for _, data := range captures {
+			img, err := imaging.Decode(data)
+			if err != nil {
+				return err
+			}
+			images = append(images, img)
+		}
+		data, err := imaging.EncodePNG(imaging.SideBySide(images, 16))
 		if err != nil {
 			return err
 		}
+		entry := history.Entry{Command: "selection-multi", File: file, Tags: captureTags}
+		if err := h.writeCapture(ctx, entry, data); err != nil {
+			return err
+		}
+		h.recordCapture(ctx, entry, data)
+		return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Screenshot saved: %s", filepath.Base(file)))
+	}
 
-		if err := os.WriteFile(tmpFile, clipData, 0o600); err != nil {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	var partFile string
+	for i, data := range captures {
+		partFile = h.placeCapture(ctx, fmt.Sprintf("%s-%d.png", base, i+1))
+		entry := history.Entry{Command: "selection-multi", File: partFile, Geometry: regions[i], Tags: captureTags}
+		if err := h.writeCapture(ctx, entry, data); err != nil {
 			return err
 		}
-		defer func() { _ = os.Remove(tmpFile) }()
+		h.addHistory(ctx, entry)
+	}
+	h.updateThumbnail(partFile, captures[len(captures)-1])
 
-		aiName, err := external.AIChat(ctx, h.cfg.AIModelImage, tmpFile,
-			"identify a filename for that image and return only the slug of the filename, nothing else")
+	pattern := strings.Replace(filepath.Base(partFile), fmt.Sprintf("-%d.png", len(captures)), "-*.png", 1)
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("%d screenshots saved: %s", len(captures), pattern))
+}
 
-		if err == nil && aiName != "" {
-			defaultName = aiName
-			if !strings.HasSuffix(defaultName, ".png") {
-				defaultName += ".png"
-			}
-		}
+// SelectionEdit captures a selected region, opens an editor, and saves the result.
+func (h *ScreenshotHandler) SelectionEdit(ctx context.Context, delay int, lastRegion bool) error {
+	if err := capability.Require(capability.Editor); err != nil {
+		return err
 	}
 
-	newname, err := external.Zenity(ctx, "File Name", defaultName)
-	if err != nil || newname == "" {
-		return nil
+	if err := notify.CaptureDelay(delay, "selection edit", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "selection-edit", "#ff0000ff", lastRegion)
+	if err != nil {
+		return err
 	}
 
-	if !strings.HasSuffix(newname, ".png") {
-		newname += ".png"
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
 	}
 
-	outputFile := filepath.Join(h.cfg.SaveLocation, newname)
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
 
-	if action == "edit" {
-		clipData, err := external.WlPaste(ctx, "image/png")
-		if err != nil {
-			return err
-		}
+	h.recordCapture(ctx, history.Entry{Command: "selection-edit", Geometry: geom, Tags: tags.Collect(ctx)}, data)
 
-		tmpFile := fmt.Sprintf("/tmp/screenshot-%d.png", time.Now().Unix())
-		if err := os.WriteFile(tmpFile, clipData, 0o600); err != nil {
-			return err
-		}
-		defer func() { _ = os.Remove(tmpFile) }()
+	// Write to temporary file for satty
+	tmpFile, cleanup, err := h.writeTemp(data)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
-		return external.Satty(ctx, tmpFile, outputFile, true)
+	outputFile := filepath.Join(h.cfg().SaveLocation, fmt.Sprintf("screenshot-%s.png", time.Now().Format("20060102-15:04:05")))
+	return external.Satty(ctx, tmpFile, outputFile, true)
+}
+
+// SelectionClipboard captures a selected region and copies it to clipboard.
+func (h *ScreenshotHandler) SelectionClipboard(ctx context.Context, delay int, lastRegion, pretty bool) error {
+	if err := notify.CaptureDelay(delay, "selection to clipboard", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	captureTags := tags.Collect(ctx)
+	geom, err := selectRegion(ctx, h.cfg(), h.state, "selection-clipboard", "", lastRegion)
+	if err != nil {
+		return err
 	}
 
-	// Save action
-	clipData, err := external.WlPaste(ctx, "image/png")
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	data, err := h.grab(ctx, geom, "")
 	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	if data, err = h.beautify(data, pretty); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(outputFile, clipData, 0o600); err != nil {
+	if err := h.copyToClipboard(ctx, data, "image/png"); err != nil {
 		return err
 	}
+	h.recordCapture(ctx, history.Entry{Command: "selection-clipboard", Geometry: geom, Tags: captureTags}, data)
 
-	// Open in file manager
-	return external.Nautilus(ctx, "file://"+outputFile)
+	_, err = h.offerActions(ctx, "selection-clipboard", "Screenshot captured to clipboard", &capture{Data: data, Tags: captureTags, Geometry: shownAt(geom, pretty)})
+	return err
 }
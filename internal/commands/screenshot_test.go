@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/screenshot"
+	"github.com/chmouel/sway-easyshot/pkg/state"
+)
+
+func TestSelectionMultiSavesEachSelection(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+	runner.queue("slurp", []byte("0,0 10x10\n"), nil)
+	runner.queue("slurp", []byte("20,20 5x5\n"), nil)
+	// Escape prints nothing
+	runner.queue("slurp", nil, nil)
+	runner.queue("grim", pngFixture(t), nil)
+
+	st := state.NewState()
+	h := NewScreenshotHandler(cfg, st, nil)
+	if err := h.SelectionMulti(ctx, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	waitThumbnail(t, st)
+
+	var grabbed []string
+	for _, args := range runner.callsOf("grim") {
+		if i := slices.Index(args, "-g"); i >= 0 {
+			grabbed = append(grabbed, args[i+1])
+		}
+	}
+	if want := []string{"0,0 10x10", "20,20 5x5"}; !slices.Equal(grabbed, want) {
+		t.Errorf("grim captured %q, want %q", grabbed, want)
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.SaveLocation, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !strings.HasSuffix(files[0], "-1.png") || !strings.HasSuffix(files[1], "-2.png") {
+		t.Fatalf("saved %q, want a file per selection", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(pngFixture(t)) {
+		t.Error("the saved file is not the capture")
+	}
+}
+
+func TestSelectionMultiComposite(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+	runner.queue("slurp", []byte("0,0 10x10\n"), nil)
+	runner.queue("slurp", []byte("20,20 5x5\n"), nil)
+	runner.queue("slurp", nil, nil)
+	runner.queue("grim", pngFixture(t), nil)
+
+	st := state.NewState()
+	h := NewScreenshotHandler(cfg, st, nil)
+	if err := h.SelectionMulti(ctx, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	waitThumbnail(t, st)
+
+	files, err := filepath.Glob(filepath.Join(cfg.SaveLocation, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("saved %q, want a single composite", files)
+	}
+}
+
+func TestSelectionMultiCancelled(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+	runner.queue("slurp", nil, nil)
+
+	h := NewScreenshotHandler(cfg, state.NewState(), nil)
+	if err := h.SelectionMulti(ctx, 0, false); !errors.Is(err, screenshot.ErrCancelled) {
+		t.Fatalf("SelectionMulti() = %v without a selection, want it cancelled", err)
+	}
+	if calls := runner.callsOf("grim"); len(calls) != 0 {
+		t.Errorf("grim ran %d times without a selection", len(calls))
+	}
+}
+
+func TestSelectionMultiSelectorFailure(t *testing.T) {
+	cfg, ctx, runner := setup(t)
+	broken := errors.New("slurp is broken")
+	runner.queue("slurp", []byte("0,0 10x10\n"), nil)
+	runner.queue("slurp", nil, broken)
+
+	h := NewScreenshotHandler(cfg, state.NewState(), nil)
+	if err := h.SelectionMulti(ctx, 0, false); !errors.Is(err, broken) {
+		t.Fatalf("SelectionMulti() = %v, want the selector failure", err)
+	}
+	if calls := runner.callsOf("grim"); len(calls) != 0 {
+		t.Errorf("grim ran %d times after the selector failed", len(calls))
+	}
+}
+
+// waitThumbnail waits for the thumbnail rendered in the background, which
+// would otherwise be written once the test is over.
+func waitThumbnail(t *testing.T, st *state.State) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); st.GetLastThumbnail() == ""; {
+		if time.Now().After(deadline) {
+			t.Fatal("no thumbnail was rendered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEncryptedCaptureHasNoSidecar(t *testing.T) {
+	t.Setenv("SWAY_SCREENSHOT_SIDECAR", "true")
+	t.Setenv("SWAY_SCREENSHOT_ENCRYPT_RECIPIENT", "age1recipient")
+	cfg, ctx, runner := setup(t)
+	age := filepath.Join(t.TempDir(), "age")
+	if err := os.WriteFile(age, []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", filepath.Dir(age)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	runner.queue("slurp", []byte("0,0 10x10\n"), nil)
+	runner.queue("grim", pngFixture(t), nil)
+	runner.queue("age", []byte("encrypted"), nil)
+
+	st := state.NewState()
+	h := NewScreenshotHandler(cfg, st, nil)
+	if err := h.SelectionFile(WithEncrypt(ctx), 0, false, false); err != nil {
+		t.Fatal(err)
+	}
+	waitThumbnail(t, st)
+
+	files, err := filepath.Glob(filepath.Join(cfg.SaveLocation, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".png.age") {
+		t.Fatalf("saved %q, want the encrypted capture alone", files)
+	}
+}
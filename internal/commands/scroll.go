@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/internal/scroll"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// scrollSettleDelay leaves the application the time to finish a smooth
+// scrolling animation before the next frame gets captured.
+const scrollSettleDelay = 400 * time.Millisecond
+
+// ScrollCapture captures the focused window, scrolls it down and captures it
+// again until its end or maxFrames, then stitches the overlapping frames
+// into one tall image saved to a file.
+func (h *ScreenshotHandler) ScrollCapture(ctx context.Context, delay, maxFrames int, pretty bool) error {
+	if err := capability.Require(capability.Scroll); err != nil {
+		return err
+	}
+
+	if err := notify.CaptureDelay(delay, "scrolling window to file", h.cfg().ScreenshotIcon); err != nil {
+		return err
+	}
+
+	geom, err := sway.GetFocusedWindowGeometry(ctx, h.cfg().Decorations)
+	if err != nil {
+		return fmt.Errorf("failed to get window geometry: %w", err)
+	}
+	rect, err := parseGeometry(geom)
+	if err != nil {
+		return err
+	}
+	center := rect.Min.Add(rect.Size().Div(2))
+
+	captureTags := tags.Collect(ctx)
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	first, err := h.grabImage(ctx, geom)
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	stitcher := imaging.NewStitcher(first)
+
+	incomplete := false
+	for stitcher.Frames() < maxFrames {
+		if err := scroll.Down(ctx, h.cfg().ScrollMethod, center.X, center.Y, h.cfg().ScrollClicks); err != nil {
+			return err
+		}
+		time.Sleep(scrollSettleDelay)
+
+		frame, err := h.grabImage(ctx, geom)
+		if err != nil {
+			return fmt.Errorf("failed to capture screenshot: %w", err)
+		}
+		err = stitcher.Add(frame)
+		if errors.Is(err, imaging.ErrNoScroll) {
+			break
+		}
+		if err != nil {
+			// Keep what could be stitched rather than losing it all
+			incomplete = true
+			break
+		}
+	}
+
+	data, err := imaging.EncodePNG(stitcher.Image())
+	if err != nil {
+		return err
+	}
+	if data, err = h.beautify(data, pretty); err != nil {
+		return err
+	}
+
+	file := h.captureFile(ctx, captureTags)
+	entry := history.Entry{Command: "scroll-capture", File: file, Geometry: geom, Tags: captureTags}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
+
+	message := fmt.Sprintf("Scrolling capture saved: %s (%d frames)", filepath.Base(file), stitcher.Frames())
+	if incomplete {
+		message = fmt.Sprintf("Scrolling capture stopped after %d frames, the next one could not be matched: %s", stitcher.Frames(), filepath.Base(file))
+	}
+
+	if offered, err := h.offerActions(ctx, "scroll-capture", message, &capture{File: file, Data: data, Tags: captureTags}); offered {
+		return err
+	}
+
+	return notify.Send(3000, h.cfg().ScreenshotIcon, message) //nolint:errcheck
+}
+
+// grabImage captures a geometry and decodes it.
+func (h *ScreenshotHandler) grabImage(ctx context.Context, geom string) (image.Image, error) {
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Decode(data)
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+)
+
+// Snapshot saves the last frame of the paused recording as a screenshot,
+// which is then handled like any other capture, without stopping it.
+func (h *ScreenshotHandler) Snapshot(ctx context.Context) error {
+	st := h.state.GetState()
+	if !st.Recording {
+		return fmt.Errorf("no recording in progress")
+	}
+	if !st.Paused {
+		return fmt.Errorf("pause the recording first so that its last frame is the one shown")
+	}
+
+	dir, err := h.tempDir("snapshot-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	frame := filepath.Join(dir, "frame.png")
+	if err := transcode.LastFrame(ctx, st.RecordingFile, frame); err != nil {
+		return fmt.Errorf("failed to extract the last frame: %w", err)
+	}
+	data, err := os.ReadFile(frame)
+	if err != nil {
+		return err
+	}
+
+	captureTags := tags.Collect(ctx)
+	file := h.captureFile(ctx, captureTags)
+	entry := history.Entry{Command: "snapshot", File: file, Tags: captureTags}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
+
+	if offered, err := h.offerActions(ctx, "snapshot", filepath.Base(file), &capture{File: file, Data: data, Tags: captureTags}); offered {
+		return err
+	}
+
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Snapshot saved: %s", filepath.Base(file))) //nolint:errcheck
+}
+
+// OBSScreenshot saves what OBS currently outputs as a screenshot, which is
+// then handled like any other capture.
+func (h *ScreenshotHandler) OBSScreenshot(ctx context.Context, obs *OBSHandler) error {
+	data, scene, err := obs.ProgramScreenshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	file := h.captureFile(ctx, tags.Tags{})
+	entry := history.Entry{Command: "obs-screenshot", File: file, Tags: tags.Tags{Title: scene}}
+	if err := h.writeCapture(ctx, entry, data); err != nil {
+		return err
+	}
+	h.recordCapture(ctx, entry, data)
+
+	if offered, err := h.offerActions(ctx, "obs-screenshot", filepath.Base(file), &capture{File: file, Data: data, Tags: entry.Tags}); offered {
+		return err
+	}
+
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("OBS screenshot saved: %s", filepath.Base(file))) //nolint:errcheck
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+)
+
+// timerInfo is saved next to a recording whose timer overlay gets burned in
+// when it is converted.
+type timerInfo struct {
+	Mode  string    `json:"mode"`
+	Start time.Time `json:"start"`
+}
+
+// writeTimerInfo records that the recording started now wants a timer.
+func writeTimerInfo(base, mode string) error {
+	data, err := json.Marshal(timerInfo{Mode: mode, Start: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".timer", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write timer file: %w", err)
+	}
+	return nil
+}
+
+// timerOverlay returns the ffmpeg filter drawing the timer of a recording,
+// empty when it has none.
+func timerOverlay(base string) string {
+	data, err := os.ReadFile(base + ".timer")
+	if err != nil {
+		return ""
+	}
+	var info timerInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return ""
+	}
+	return transcode.TimerFilter(info.Mode, info.Start)
+}
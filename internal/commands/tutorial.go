@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/screenshot"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+)
+
+// Outcomes of a tutorial step.
+const (
+	TutorialPassed  = "passed"
+	TutorialFailed  = "failed"
+	TutorialSkipped = "skipped"
+)
+
+// tutorialDelay leaves the user the time to focus a window or look at the
+// screen before it gets captured or recorded.
+const tutorialDelay = 3 * time.Second
+
+// TutorialResult is the outcome of one step of the tutorial.
+type TutorialResult struct {
+	Step   string
+	Status string
+	Detail string
+}
+
+// tutorialStep is a capture mode the tutorial walks through. run performs the
+// capture into dir and returns what has been verified.
+type tutorialStep struct {
+	title  string
+	prompt string
+	run    func(ctx context.Context, dir string) (string, error)
+}
+
+// Tutorial walks the user through each capture mode with a notification
+// explaining it, performs the capture and verifies its result. Captures are
+// made in a temporary directory and removed afterwards, so that it doubles as
+// a smoke test of the local setup without leaving anything behind.
+func Tutorial(ctx context.Context, screenshots *ScreenshotHandler, recordings *RecordingHandler) ([]TutorialResult, error) {
+	dir, err := screenshots.tempDir("tutorial-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tutorial directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	steps := []tutorialStep{
+		{
+			title:  "Selection to clipboard",
+			prompt: "Select a region of the screen with the mouse, it will be copied to the clipboard.",
+			run:    screenshots.tutorialSelection,
+		},
+		{
+			title:  "Window to file",
+			prompt: "Focus the window you would like to capture, it will be saved to a file in 3 seconds.",
+			run:    screenshots.tutorialWindow,
+		},
+		{
+			title:  "Screen",
+			prompt: "The focused screen will be captured.",
+			run:    screenshots.tutorialScreen,
+		},
+		{
+			title:  "Recording",
+			prompt: "The focused screen will be recorded for 3 seconds, then converted to MP4.",
+			run:    recordings.tutorialRecording,
+		},
+	}
+
+	results := make([]TutorialResult, 0, len(steps))
+	quit := false
+	for i, step := range steps {
+		if quit {
+			results = append(results, TutorialResult{Step: step.title, Status: TutorialSkipped})
+			continue
+		}
+
+		choice := screenshots.tutorialPrompt(i+1, len(steps), step)
+		if choice != "go" {
+			quit = choice == "quit"
+			results = append(results, TutorialResult{Step: step.title, Status: TutorialSkipped})
+			continue
+		}
+
+		detail, err := step.run(ctx, dir)
+		if err != nil {
+			results = append(results, TutorialResult{Step: step.title, Status: TutorialFailed, Detail: err.Error()})
+			continue
+		}
+		results = append(results, TutorialResult{Step: step.title, Status: TutorialPassed, Detail: detail})
+	}
+
+	_ = notify.Send(5000, screenshots.cfg().ScreenshotIcon, tutorialSummary(results))
+	return results, nil
+}
+
+// tutorialPrompt explains a step and returns the choice of the user: go, skip
+// or quit. A dismissed notification skips the step.
+func (h *ScreenshotHandler) tutorialPrompt(n, total int, step tutorialStep) string {
+	message := fmt.Sprintf("Tutorial %d/%d: %s\n%s", n, total, step.title, step.prompt)
+	choice, err := notify.SendWithActions(0, h.cfg().ScreenshotIcon, message, []notify.Action{
+		{ID: "go", Label: "Go"},
+		{ID: "skip", Label: "Skip"},
+		{ID: "quit", Label: "Quit"},
+	})
+	if err != nil {
+		return "quit"
+	}
+	return strings.TrimSpace(choice)
+}
+
+// tutorialSummary returns the notification shown once the tutorial is over.
+func tutorialSummary(results []TutorialResult) string {
+	var passed, failed int
+	for _, r := range results {
+		switch r.Status {
+		case TutorialPassed:
+			passed++
+		case TutorialFailed:
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Sprintf("Tutorial finished: %d passed, %d failed", passed, failed)
+	}
+	return fmt.Sprintf("Tutorial finished: %d passed", passed)
+}
+
+// tutorialSelection copies a selected region to the clipboard and checks the
+// clipboard now holds the same image.
+func (h *ScreenshotHandler) tutorialSelection(ctx context.Context, _ string) (string, error) {
+	geom, err := screenshot.SelectWith(ctx, h.cfg().Selector, "")
+	if errors.Is(err, screenshot.ErrCancelled) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("selection failed: %w", err)
+	}
+
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	if err := external.WlCopy(ctx, data, "image/png"); err != nil {
+		return "", err
+	}
+
+	pasted, err := external.WlPaste(ctx, "image/png")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the clipboard back: %w", err)
+	}
+	want, err := imageSize(data)
+	if err != nil {
+		return "", err
+	}
+	got, err := imageSize(pasted)
+	if err != nil {
+		return "", fmt.Errorf("the clipboard does not hold the capture: %w", err)
+	}
+	if got != want {
+		return "", fmt.Errorf("the clipboard holds a %s image rather than %s", formatSize(got), formatSize(want))
+	}
+	return fmt.Sprintf("%s image copied to the clipboard", formatSize(got)), nil
+}
+
+// tutorialWindow saves the focused window to a file and checks it reads back.
+func (h *ScreenshotHandler) tutorialWindow(ctx context.Context, dir string) (string, error) {
+	time.Sleep(tutorialDelay)
+
+	geom, err := sway.GetFocusedWindowGeometry(ctx, h.cfg().Decorations)
+	if err != nil {
+		return "", fmt.Errorf("failed to get window geometry: %w", err)
+	}
+
+	file := filepath.Join(dir, "window.png")
+	data, err := h.grab(ctx, geom, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return "", err
+	}
+
+	saved, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	size, err := imageSize(saved)
+	if err != nil {
+		return "", fmt.Errorf("the saved file is not a valid image: %w", err)
+	}
+	return fmt.Sprintf("%s image saved to a file", formatSize(size)), nil
+}
+
+// tutorialScreen captures the focused output and checks its size matches the
+// one reported by sway.
+func (h *ScreenshotHandler) tutorialScreen(ctx context.Context, _ string) (string, error) {
+	output, err := sway.SelectOutput(ctx, true)
+	if err != nil || output == "" {
+		return "", fmt.Errorf("failed to select output: %w", err)
+	}
+
+	data, err := h.grab(ctx, "", output)
+	if err != nil {
+		return "", fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+	size, err := imageSize(data)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := sway.GetOutput(ctx, output)
+	if err != nil {
+		return "", err
+	}
+	// Fractional scales round the physical size either way
+	wantWidth := float64(out.Width) * out.Scale
+	if diff := float64(size.X) - wantWidth; diff < -1 || diff > 1 {
+		return "", fmt.Errorf("captured %s for output %s, expected a width of %.0f", formatSize(size), output, wantWidth)
+	}
+	return fmt.Sprintf("%s image of %s", formatSize(size), output), nil
+}
+
+// tutorialRecording records the focused output for a few seconds and checks
+// the converted video can be probed. The video is removed afterwards.
+func (h *RecordingHandler) tutorialRecording(ctx context.Context, _ string) (string, error) {
+	if _, err := os.Stat(h.cfg().CacheFile); err == nil {
+		return "", fmt.Errorf("a recording is already in progress")
+	}
+
+	output, err := sway.SelectOutput(ctx, true)
+	if err != nil || output == "" {
+		return "", fmt.Errorf("failed to select output: %w", err)
+	}
+
+	base, err := h.startRecording(ctx, history.Entry{Output: output}, 0, transcode.TimerOff)
+	if err != nil {
+		return "", err
+	}
+	time.Sleep(tutorialDelay)
+	if err := h.StopRecording(ctx); err != nil {
+		return "", err
+	}
+
+	file := base + ".mp4"
+	defer func() { _ = os.Remove(file) }()
+	width, height, err := transcode.Size(ctx, file)
+	if err != nil {
+		return "", fmt.Errorf("the recording cannot be read: %w", err)
+	}
+	return fmt.Sprintf("%dx%d video recorded and converted", width, height), nil
+}
+
+// imageSize decodes an image and returns its size.
+func imageSize(data []byte) (image.Point, error) {
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return img.Bounds().Size(), nil
+}
+
+// formatSize formats an image size as WIDTHxHEIGHT.
+func formatSize(size image.Point) string {
+	return fmt.Sprintf("%dx%d", size.X, size.Y)
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/undo"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// undoTimeout is how long the Undo button of destructive operations is
+// offered, in milliseconds.
+const undoTimeout = 10000
+
+// Undo reverts the last destructive operation while its originals are kept.
+func (h *ScreenshotHandler) Undo(ctx context.Context) error {
+	op, err := h.undo.Undo(func(data []byte, mimeType string) error {
+		return external.WlCopy(ctx, data, mimeType)
+	})
+	if errors.Is(err, undo.ErrNothing) {
+		_ = notify.Send(3000, h.cfg().ScreenshotIcon, "Nothing to undo")
+		return err
+	}
+	if err != nil {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("Undo of %s failed: %v", op.Label, err))
+		return err
+	}
+	return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("Undone: %s", op.Label))
+}
+
+// offerUndo tells the user about a destructive operation which has just been
+// recorded and undoes it if they click Undo.
+func (h *ScreenshotHandler) offerUndo(ctx context.Context, message string) error {
+	action, err := notify.SendWithActions(undoTimeout, h.cfg().ScreenshotIcon, message, []notify.Action{{ID: "undo", Label: "Undo"}})
+	if err != nil || action != "undo" {
+		return nil
+	}
+	return h.Undo(ctx)
+}
+
+// copyToClipboard copies data to the clipboard, keeping what it held so that
+// the overwrite can be undone.
+func (h *ScreenshotHandler) copyToClipboard(ctx context.Context, data []byte, mimeType string) error {
+	h.saveClipboard(ctx)
+	return external.WlCopy(ctx, data, mimeType)
+}
+
+// copyText copies text to the clipboard, keeping what it held.
+func (h *ScreenshotHandler) copyText(ctx context.Context, text string) error {
+	return h.copyToClipboard(ctx, []byte(text), "text/plain")
+}
+
+// saveClipboard records the clipboard content in the undo journal, in the
+// richest type it is offered as which can be restored.
+func (h *ScreenshotHandler) saveClipboard(ctx context.Context) {
+	types, err := external.WlPasteTypes(ctx)
+	if err != nil || len(types) == 0 {
+		return
+	}
+	mimeType := types[0]
+	for _, preferred := range []string{"image/png", "text/plain;charset=utf-8", "text/plain"} {
+		if slices.Contains(types, preferred) {
+			mimeType = preferred
+			break
+		}
+	}
+
+	data, err := external.WlPaste(ctx, mimeType)
+	if err != nil {
+		return
+	}
+	h.undo.Clipboard("Clipboard overwrite", mimeType, data)
+}
+
+// DeleteLast deletes the most recent capture: its file is moved to the undo
+// area, and it is cleared from the clipboard when still there and from the
+// history, all of which the notification offers to undo.
+func (h *ScreenshotHandler) DeleteLast(ctx context.Context) error {
+	entries, err := h.history.List(history.Query{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no capture in the history")
+	}
+	entry := entries[0]
+
+	file := entry.File
+	if file != "" {
+		if _, err := os.Stat(file); err != nil {
+			file = ""
+		}
+	}
+	clipType, clipData := h.clipboardHolding(ctx, entry)
+
+	restore := func() error {
+		if clipType != "" {
+			if err := external.WlCopy(ctx, clipData, clipType); err != nil {
+				return fmt.Errorf("failed to restore the clipboard: %w", err)
+			}
+		}
+		return h.history.Add(entry)
+	}
+	if err := h.undo.Delete("Deletion", file, restore); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", filepath.Base(file), err)
+	}
+	if clipType != "" {
+		if err := external.WlClear(ctx); err != nil {
+			return err
+		}
+	}
+	if err := h.history.Remove(entry); err != nil {
+		return err
+	}
+
+	name := "the last capture"
+	if entry.File != "" {
+		name = filepath.Base(entry.File)
+	}
+	return h.offerUndo(ctx, fmt.Sprintf("Deleted %s", name))
+}
+
+// clipboardHolding returns the clipboard content when it holds the capture
+// of entry, as an image or its path. Captures which only went to the
+// clipboard cannot be compared and are assumed to be the image there.
+func (h *ScreenshotHandler) clipboardHolding(ctx context.Context, entry history.Entry) (string, []byte) {
+	types, err := external.WlPasteTypes(ctx)
+	if err != nil {
+		return "", nil
+	}
+	for _, mimeType := range []string{"image/png", "text/plain"} {
+		if !slices.Contains(types, mimeType) {
+			continue
+		}
+		data, err := external.WlPaste(ctx, mimeType)
+		if err != nil {
+			continue
+		}
+		var holds bool
+		switch {
+		case mimeType == "text/plain":
+			holds = entry.File != "" && strings.TrimSpace(string(data)) == entry.File
+		case entry.File == "":
+			holds = entry.Kind == history.Screenshot
+		default:
+			saved, err := os.ReadFile(entry.File)
+			holds = err == nil && bytes.Equal(saved, data)
+		}
+		if holds {
+			return mimeType, data
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/queue"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/internal/upload"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+)
+
+// uploadKey marks the context of captures which get uploaded straight away.
+type uploadKey struct{}
+
+// WithUpload returns a context whose captures are uploaded with the default
+// provider instead of offering the post-capture actions.
+func WithUpload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uploadKey{}, true)
+}
+
+func autoUpload(ctx context.Context) bool {
+	on, _ := ctx.Value(uploadKey{}).(bool)
+	return on
+}
+
+// newProviders returns the upload providers available with cfg: the file
+// hosts, which need no account, the built-in ones which are configured, and
+// the upload hooks.
+func newProviders(cfg *config.Config) upload.Providers {
+	providers := upload.Providers{}
+	providers.Add(upload.NewZeroXZero(cfg.ZeroXZeroURL, cfg.FileHostRetention))
+	providers.Add(upload.NewTransferSh(cfg.TransferShURL, cfg.FileHostRetention))
+	if cfg.ImgurClientID != "" {
+		providers.Add(upload.NewImgur(cfg.ImgurClientID))
+	}
+	if cfg.S3.Configured() {
+		providers.Add(upload.NewS3(cfg.S3))
+	}
+	if cfg.Nextcloud.Configured() {
+		providers.Add(upload.NewNextcloud(cfg.Nextcloud))
+	}
+	for _, hook := range cfg.Hooks {
+		if !hook.Upload {
+			continue
+		}
+		providers.Add(upload.Func{
+			ID:    hook.Name,
+			Title: hook.Label,
+			Run: func(ctx context.Context, req upload.Request) (string, error) {
+				output, err := runHookCommand(ctx, hook, req.File, req.Tags)
+				if err != nil {
+					return "", err
+				}
+				return shareURL(output), nil
+			},
+		})
+	}
+	return providers
+}
+
+// shareURL returns the URL printed by an upload command: the last line
+// which is one, as uploaders often report their progress first, or the whole
+// output when there is none.
+func shareURL(output string) string {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "https://") || strings.HasPrefix(line, "http://") {
+			return line
+		}
+	}
+	return output
+}
+
+// defaultProvider returns the provider used by the upload action and
+// --upload.
+func (h *ScreenshotHandler) defaultProvider() (upload.Provider, error) {
+	if h.cfg().UploadProvider == "" {
+		return nil, fmt.Errorf("no upload provider configured: set SWAY_SCREENSHOT_UPLOAD to one of: %s", strings.Join(h.providers().Names(), ", "))
+	}
+	provider, ok := h.providers()[h.cfg().UploadProvider]
+	if !ok {
+		return nil, fmt.Errorf("unknown upload provider %q (available: %s)", h.cfg().UploadProvider, strings.Join(h.providers().Names(), ", "))
+	}
+	return provider, nil
+}
+
+// uploadCapture uploads a capture with provider, writing clipboard captures
+// to a temporary file first.
+func (h *ScreenshotHandler) uploadCapture(ctx context.Context, provider upload.Provider, c *capture) error {
+	file := c.File
+	if file == "" {
+		data, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		tmpFile, cleanup, err := h.writeTemp(data)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		file = tmpFile
+	}
+	return h.upload(ctx, provider, file, c.Tags)
+}
+
+// upload uploads file with provider and copies the URL it is shared at. When
+// it fails the capture is queued and retried in the background, so the share
+// is not lost when offline.
+func (h *ScreenshotHandler) upload(ctx context.Context, provider upload.Provider, file string, t tags.Tags) error {
+	url, err := provider.Upload(ctx, upload.Request{File: file, Tags: t})
+	if err == nil {
+		return h.uploaded(ctx, provider.Label(), url)
+	}
+
+	data, readErr := os.ReadFile(file) //nolint:gosec
+	if readErr != nil {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s failed: %v", provider.Label(), err))
+		return err
+	}
+	if _, qErr := h.uploads.Add(provider.Name(), filepath.Base(file), data); qErr != nil {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s failed: %v", provider.Label(), err))
+		return errors.Join(err, qErr)
+	}
+
+	return notify.Send(5000, h.cfg().ScreenshotIcon,
+		fmt.Sprintf("%s failed, it will be retried in the background: %v", provider.Label(), err))
+}
+
+// uploaded copies the URL of a finished upload and tells the user.
+func (h *ScreenshotHandler) uploaded(ctx context.Context, label, url string) error {
+	if url == "" {
+		return notify.Send(3000, h.cfg().ScreenshotIcon, fmt.Sprintf("%s done", label))
+	}
+	if err := h.copyText(ctx, url); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("%s done, URL copied:\n%s", label, url)
+	if !capability.Available(capability.QRCode) {
+		return notify.Send(5000, h.cfg().ScreenshotIcon, message)
+	}
+	if h.cfg().UploadQR {
+		_ = notify.Send(5000, h.cfg().ScreenshotIcon, message)
+		return h.showQR(ctx, url)
+	}
+	action, err := notify.SendWithActions(10000, h.cfg().ScreenshotIcon, message, []notify.Action{{ID: "qr", Label: "QR code"}})
+	if err != nil || action != "qr" {
+		return nil
+	}
+	return h.showQR(ctx, url)
+}
+
+// qrModuleSize is the size of the modules of QR codes, in pixels, large
+// enough for phones to scan them from across a desk.
+const qrModuleSize = 16
+
+// showQR shows url as a QR code in the image viewer, to open it on a phone.
+func (h *ScreenshotHandler) showQR(ctx context.Context, url string) error {
+	file := filepath.Join(h.cfg().RuntimeDir, "share-qr.png")
+	if err := external.QREncode(ctx, url, file, qrModuleSize); err != nil {
+		return fmt.Errorf("failed to render QR code: %w", err)
+	}
+	return external.OpenWith(ctx, strings.Fields(h.cfg().QRViewer), file)
+}
+
+// RunUploadQueue retries the queued uploads until ctx is done.
+func (h *ScreenshotHandler) RunUploadQueue(ctx context.Context) {
+	if pending := len(h.uploads.Items()); pending > 0 {
+		slog.Info("Uploads pending, retrying them in the background", "count", pending)
+	}
+	h.uploads.Run(ctx, h.retryUpload)
+}
+
+// retryUpload retries a queued upload.
+func (h *ScreenshotHandler) retryUpload(ctx context.Context, item queue.Item) error {
+	provider, ok := h.providers()[item.Uploader]
+	if !ok {
+		slog.Warn("Dropping queued upload, its upload provider is gone", "file", item.Name, "uploader", item.Uploader)
+		return queue.ErrGone
+	}
+
+	url, err := provider.Upload(ctx, upload.Request{File: item.File})
+	if err != nil {
+		return err
+	}
+	return h.uploaded(ctx, fmt.Sprintf("Queued %s of %s", provider.Label(), item.Name), url)
+}
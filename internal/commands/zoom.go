@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+)
+
+const (
+	// zoomSampleInterval is how often the followed position gets sampled.
+	zoomSampleInterval = 100 * time.Millisecond
+	// zoomSmoothing is how far the zoomed area moves towards the followed
+	// position at each sample, so that it glides rather than jumps.
+	zoomSmoothing = 0.25
+)
+
+// zoomInfo is saved next to a zoomed recording, whose full output gets
+// recorded and cropped around the followed positions when converted.
+type zoomInfo struct {
+	Zoom   float64     `json:"zoom"`
+	Output sway.Output `json:"output"`
+}
+
+// MovieZoom records a magnified area of the screen following the pointer,
+// or the focused window when the pointer position cannot be read.
+func (h *RecordingHandler) MovieZoom(ctx context.Context, delay int, useCurrentScreen bool, zoom float64, limit time.Duration, timer string) error {
+	if zoom < 1 {
+		return fmt.Errorf("invalid zoom %g: it must be at least 1", zoom)
+	}
+
+	output, err := sway.SelectOutput(ctx, useCurrentScreen)
+	if err != nil || output == "" {
+		return fmt.Errorf("failed to select output: %w", err)
+	}
+	out, err := sway.GetOutput(ctx, output)
+	if err != nil {
+		return err
+	}
+
+	if err := notify.CaptureDelay(delay, "zoomed movie", h.cfg().RecordingStartIcon); err != nil {
+		return err
+	}
+
+	if err := sleepWithCountdown(ctx, h.state, delay); err != nil {
+		return err
+	}
+
+	base, err := h.startRecording(ctx, history.Entry{Command: "movie-zoom", Output: output}, limit, timer)
+	if err != nil {
+		return err
+	}
+
+	info, err := json.Marshal(zoomInfo{Zoom: zoom, Output: *out})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".zoom", info, 0o600); err != nil {
+		return fmt.Errorf("failed to write zoom file: %w", err)
+	}
+
+	go h.followPosition(ctx, base+".cursor", h.state.GetRecordingPID())
+
+	return nil
+}
+
+// followPosition samples the position to follow into path, one "seconds x y"
+// line each time it changes, for as long as the recording process runs.
+func (h *RecordingHandler) followPosition(ctx context.Context, path string, pid int) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	x, y := -1, -1
+	position := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return x, y
+	}
+
+	usePointer := capability.Available(capability.Cursor)
+	if !usePointer {
+		if win, err := sway.GetFocusedWindow(ctx); err == nil {
+			if r, err := parseGeometry(win.Geometry); err == nil {
+				x, y = (r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2
+			}
+		}
+		go func() {
+			_ = sway.WatchFocus(ctx, func(fx, fy int) {
+				mu.Lock()
+				defer mu.Unlock()
+				x, y = fx, fy
+			})
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(zoomSampleInterval)
+	defer ticker.Stop()
+
+	lastX, lastY := -1, -1
+	for h.state.GetRecordingPID() == pid {
+		px, py := position()
+		if usePointer {
+			px, py, err = external.CursorPosition(ctx)
+			if err != nil {
+				px, py = lastX, lastY
+			}
+		}
+		if px >= 0 && (px != lastX || py != lastY) {
+			fmt.Fprintf(f, "%.3f %d %d\n", time.Since(start).Seconds(), px, py)
+			lastX, lastY = px, py
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// isZoomed reports whether the recording was started by MovieZoom.
+func isZoomed(base string) bool {
+	_, err := os.Stat(base + ".zoom")
+	return err == nil
+}
+
+// convertZoomed converts a zoomed recording, turning the followed positions
+// into crop commands for ffmpeg.
+func convertZoomed(ctx context.Context, base, input, output, overlay string) error {
+	data, err := os.ReadFile(base + ".zoom")
+	if err != nil {
+		return fmt.Errorf("failed to read zoom file: %w", err)
+	}
+	var info zoomInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("failed to parse zoom file: %w", err)
+	}
+
+	out := info.Output
+	width, height := int(float64(out.Width)*out.Scale), int(float64(out.Height)*out.Scale)
+	cropW, cropH := even(float64(width)/info.Zoom), even(float64(height)/info.Zoom)
+
+	var commands strings.Builder
+	// Start centred until the first position is known
+	cx, cy := float64(width)/2, float64(height)/2
+	fmt.Fprintf(&commands, "0 crop x %d, crop y %d;\n", clampInt(int(cx)-cropW/2, 0, width-cropW), clampInt(int(cy)-cropH/2, 0, height-cropH))
+
+	if f, err := os.Open(base + ".cursor"); err == nil {
+		scanner := bufio.NewScanner(f)
+		first := true
+		for scanner.Scan() {
+			var t float64
+			var x, y int
+			if _, err := fmt.Sscanf(scanner.Text(), "%f %d %d", &t, &x, &y); err != nil {
+				continue
+			}
+			tx, ty := float64(x-out.X)*out.Scale, float64(y-out.Y)*out.Scale
+			if first {
+				cx, cy, first = tx, ty, false
+			} else {
+				cx += (tx - cx) * zoomSmoothing
+				cy += (ty - cy) * zoomSmoothing
+			}
+			fmt.Fprintf(&commands, "%.3f crop x %d, crop y %d;\n", t,
+				clampInt(int(cx)-cropW/2, 0, width-cropW), clampInt(int(cy)-cropH/2, 0, height-cropH))
+		}
+		_ = f.Close()
+	}
+
+	commandsFile := base + ".sendcmd"
+	if err := os.WriteFile(commandsFile, []byte(commands.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write crop commands: %w", err)
+	}
+
+	return transcode.Zoom(ctx, input, output, commandsFile, cropW, cropH, width, overlay)
+}
+
+// removeZoomFiles removes the files kept alongside a zoomed recording.
+func removeZoomFiles(base string) {
+	for _, ext := range []string{".zoom", ".cursor", ".sendcmd"} {
+		_ = os.Remove(base + ext)
+	}
+}
+
+// even rounds v down to an even number, as video encoders require.
+func even(v float64) int {
+	return int(math.Floor(v/2)) * 2
+}
+
+func clampInt(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
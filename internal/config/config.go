@@ -1,24 +1,348 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"image/color"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/ai"
+	"github.com/chmouel/sway-easyshot/internal/imaging"
+	"github.com/chmouel/sway-easyshot/internal/nightlight"
+	"github.com/chmouel/sway-easyshot/internal/scroll"
+	"github.com/chmouel/sway-easyshot/internal/session"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/tags"
+	"github.com/chmouel/sway-easyshot/internal/upload"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds all configuration for sway-easyshot.
 type Config struct {
 	SaveLocation       string
+	RuntimeDir         string
+	StateDir           string
 	CacheFile          string
-	CleanupTime        time.Duration
+	ThumbnailDir       string
+	EphemeralDir       string
+	TempDir            string
+	HistoryFile        string
+	HistorySize        int
+	LogFile            string
+	LogMaxSize         int
+	Sidecar            bool
+	EmbedMetadata      bool
+	EncryptTool        string
+	EncryptRecipient   string
+	OBSAddress         string
+	OBSPasswordCommand string
+	OBSWatch           bool
+	EphemeralTTL       time.Duration
+	DeleteMode         string
+	AIBackend          string
+	AIEndpoint         string
+	AIAPIKey           string
 	AIModelImage       string
+	AIModelText        string
+	OCRLanguage        string
+	OCRTranslate       string
 	ScreenshotIcon     string
 	RecordingStartIcon string
 	RecordingStopIcon  string
 	RecordingPauseIcon string
 	SocketPath         string
+	PidFile            string
+	StatusFile         string
 	WaybarPollInterval time.Duration
+	Wallpaper          string
+	NightLight         string
+	Selector           string
+	ConfirmSwitch      bool
+	PauseOnLock        bool
+	ResumeOnUnlock     bool
+	ExcludeBars        bool
+	Decorations        string
+	ScrollMethod       string
+	FullEditor         string
+	RecordingTimer     string
+	RecordingReminder  time.Duration
+	// LowDiskSpace is the free space, in bytes, under which recordings warn
+	// that the disk is about to be full.
+	LowDiskSpace      int64
+	UploadProvider    string
+	UploadQR          bool
+	QRViewer          string
+	ImgurClientID     string
+	ZeroXZeroURL      string
+	TransferShURL     string
+	FileHostRetention time.Duration
+	ScrollClicks      int
+	FilenameTemplate  string
+	// HostLabel tells this machine apart from others whose captures end
+	// up side by side, such as in a synced folder.
+	HostLabel string
+	// NotifyTitle is the title of notifications, rendered from the
+	// SWAY_SCREENSHOT_NOTIFY_TITLE template; empty for none.
+	NotifyTitle string
+	ConfigFile  string
+	// Actions maps capture commands to the post-capture actions offered
+	// in their notification, in order.
+	Actions map[string][]string
+	Hooks   []Hook
+	// AIPrompts holds the prompts of the built-in AI features, keyed by
+	// feature ("filename", "alttext").
+	AIPrompts map[string]string
+	AIActions []AIAction
+	// Pretty is the presentation applied to captures taken with --pretty.
+	Pretty imaging.Style
+	// S3 configures the S3 upload provider, which is off without a bucket.
+	S3 upload.S3Options
+	// Nextcloud configures the Nextcloud upload provider, which is off
+	// without a URL.
+	Nextcloud upload.NextcloudOptions
+	Publish   Publish
+	// Cleanup sets how long captures are kept in the save location.
+	Cleanup Cleanup
+	// Waybar sets templates replacing the default status text and tooltip.
+	Waybar Waybar
+	// Timeout is how long the client waits for the daemon to answer, 0 for
+	// ever. Progress restarts it.
+	Timeout time.Duration
+	// Timeouts replace Timeout for the commands they name.
+	Timeouts map[string]time.Duration
+	// Listen configures the TCP endpoint of the daemon.
+	Listen Listen
+	// HTTPAddress is the loopback address of the HTTP API of the daemon,
+	// off when empty.
+	HTTPAddress string
+	// GRPCSocket is the socket of the gRPC service of the daemon, off when
+	// empty.
+	GRPCSocket string
+	// DBus registers the daemon on the session bus.
+	DBus bool
+	// Remote is the address of the daemon the client talks to over TCP,
+	// the local one when empty, with RemoteToken.
+	Remote      string
+	RemoteToken string
+}
+
+// TimeoutFor returns how long the client waits for the daemon to answer
+// command.
+func (c *Config) TimeoutFor(command string) time.Duration {
+	if timeout, ok := c.Timeouts[command]; ok {
+		return timeout
+	}
+	return c.Timeout
+}
+
+// Listen configures the TCP endpoint of the daemon, off without an
+// address. Each token is allowed the actions of its scopes, there and on
+// the HTTP API.
+type Listen struct {
+	Address string  `yaml:"address"`
+	Tokens  []Token `yaml:"tokens"`
+}
+
+// Token is a secret allowing the remote clients sending it the actions of
+// its scopes, status alone when it has none.
+type Token struct {
+	// Name tells the token apart in the log, "token N" when unset
+	Name   string   `yaml:"name"`
+	Token  string   `yaml:"token"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// Scopes of the tokens, from reading the state to everything.
+const (
+	ScopeStatus     = "status"
+	ScopeScreenshot = "screenshot"
+	ScopeRecord     = "record"
+	ScopeAdmin      = "admin"
+)
+
+// minTokenLength keeps tokens from being guessed.
+const minTokenLength = 16
+
+// Allows reports whether the token has scope, admin having them all.
+func (t Token) Allows(scope string) bool {
+	return scope == ScopeStatus || slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeAdmin)
+}
+
+// valid checks the endpoint can be opened safely, and the tokens are
+// hard to guess.
+func (l Listen) valid() error {
+	if l.Address != "" && len(l.Tokens) == 0 {
+		return errors.New("no token allowed to connect")
+	}
+	for _, token := range l.Tokens {
+		if len(token.Token) < minTokenLength {
+			return fmt.Errorf("%s is shorter than %d characters", token.Name, minTokenLength)
+		}
+		for _, scope := range token.Scopes {
+			if scope != ScopeStatus && scope != ScopeScreenshot && scope != ScopeRecord && scope != ScopeAdmin {
+				return fmt.Errorf("unknown scope %q of %s (want %s, %s, %s or %s)",
+					scope, token.Name, ScopeStatus, ScopeScreenshot, ScopeRecord, ScopeAdmin)
+			}
+		}
+	}
+	return nil
+}
+
+// loopbackAddress checks address is on the loopback interface, the HTTP API
+// sending its tokens in clear.
+func loopbackAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%s is not a loopback address, other machines use the TCP endpoint", host)
+	}
+	return nil
+}
+
+// Waybar holds Go templates rendering the waybar text and tooltip from a
+// state.StatusData; empty ones keep the default.
+type Waybar struct {
+	Text    string `yaml:"text"`
+	Tooltip string `yaml:"tooltip"`
+}
+
+// Cleanup sets how long captures of each type are kept before the daily
+// cleanup removes them; zero keeps them forever.
+type Cleanup struct {
+	Screenshots time.Duration
+	Recordings  time.Duration
+	// Edited is for the layered files made for full editors.
+	Edited time.Duration
+	// Other is for anything else, such as the files saved by hooks.
+	Other time.Duration
+}
+
+// defaultRetention is how long captures are kept unless configured
+// otherwise.
+const defaultRetention = 3 * 24 * time.Hour
+
+// Retention returns how long file is kept, according to its type.
+func (c Cleanup) Retention(file string) time.Duration {
+	ext := strings.ToLower(filepath.Ext(file))
+	// Sidecars and encrypted captures are kept as long as the capture they
+	// describe or hold
+	wrapper := ext == ".json" || ext == "."+EncryptAge || ext == "."+EncryptGPG
+	if capture := strings.TrimSuffix(file, filepath.Ext(file)); wrapper && filepath.Ext(capture) != "" {
+		return c.Retention(capture)
+	}
+	switch ext {
+	case ".png", ".jpg", ".jpeg", ".webp":
+		return c.Screenshots
+	case ".mp4", ".avi", ".mkv", ".webm", ".gif":
+		return c.Recordings
+	case ".ora", ".svg", ".xcf", ".kra":
+		return c.Edited
+	default:
+		return c.Other
+	}
+}
+
+// Publish configures the video hosts recordings can be published to. Title
+// and Description are templates receiving the recording.
+type Publish struct {
+	Title       string                 `yaml:"title"`
+	Description string                 `yaml:"description"`
+	Privacy     string                 `yaml:"privacy"`
+	YouTube     upload.YouTubeOptions  `yaml:"youtube"`
+	PeerTube    upload.PeerTubeOptions `yaml:"peertube"`
+}
+
+// AIAction is a post-capture action sending the capture to the AI model with
+// a prompt and copying the answer to the clipboard.
+type AIAction struct {
+	Name   string `yaml:"name"`
+	Label  string `yaml:"label"`
+	Prompt string `yaml:"prompt"`
+}
+
+// Hook is a user-defined post-capture action running an external command.
+// Exec is a single Go template receiving the capture, e.g.
+// `cp {{.File}} "{{.Dir}}/archive/{{.Name}}"`, whose rendering is split
+// into arguments following the quoting rules of the shell, each value
+// staying a single argument. Upload hooks print the URL of the upload, which
+// is copied to the clipboard, and are retried later when they fail.
+// AppearsIn adds the hook to every capture notification and to the action
+// menu, beside the action sets listing it.
+type Hook struct {
+	Name      string   `yaml:"name"`
+	Label     string   `yaml:"label"`
+	Exec      string   `yaml:"exec"`
+	Upload    bool     `yaml:"upload"`
+	AppearsIn []string `yaml:"appears_in"`
+}
+
+// Places where hooks may appear.
+const (
+	InNotification = "notification"
+	InMenu         = "menu"
+)
+
+// AppearsInPlace reports whether the hook appears in place wherever it is
+// not listed.
+func (h Hook) AppearsInPlace(place string) bool {
+	return slices.Contains(h.AppearsIn, place)
+}
+
+// Modes of getting rid of deleted captures once they can no longer be
+// undone: moving them to the trash, or removing them for good.
+const (
+	DeleteTrash  = "trash"
+	DeleteRemove = "remove"
+)
+
+// Tools encrypting captures, also used as the extension of encrypted files.
+const (
+	EncryptAge = "age"
+	EncryptGPG = "gpg"
+)
+
+// UploadCommand names the upload hook made from
+// SWAY_SCREENSHOT_UPLOAD_COMMAND, for uploaders which need no more than a
+// command line.
+const UploadCommand = "command"
+
+// fileConfig is the structured configuration read from the config file.
+type fileConfig struct {
+	Actions map[string][]string `yaml:"actions"`
+	Hooks   []Hook              `yaml:"hooks"`
+	Pretty  struct {
+		Padding    *int   `yaml:"padding"`
+		Radius     *int   `yaml:"radius"`
+		Shadow     *int   `yaml:"shadow"`
+		Background string `yaml:"background"`
+	} `yaml:"pretty"`
+	AI struct {
+		Prompts map[string]string `yaml:"prompts"`
+		Actions []AIAction        `yaml:"actions"`
+	} `yaml:"ai"`
+	S3        upload.S3Options        `yaml:"s3"`
+	Nextcloud upload.NextcloudOptions `yaml:"nextcloud"`
+	Publish   Publish                 `yaml:"publish"`
+	Cleanup   struct {
+		Screenshots *time.Duration `yaml:"screenshots"`
+		Recordings  *time.Duration `yaml:"recordings"`
+		Edited      *time.Duration `yaml:"edited"`
+		Other       *time.Duration `yaml:"other"`
+	} `yaml:"cleanup"`
+	Waybar   Waybar                   `yaml:"waybar"`
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+	Listen   Listen                   `yaml:"listen"`
 }
 
 // Load loads the configuration from environment variables and defaults.
@@ -28,19 +352,187 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	uid := os.Getuid()
+	// Everything tied to a running daemon lives in a directory private to
+	// the user and the Wayland session, so that several sessions or users
+	// never share sockets or recordings in progress.
+	runtimeDir, err := session.Dir()
+	if err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
 		SaveLocation:       getEnv("SWAY_SCREENSHOT_SAVE_LOCATION", filepath.Join(homeDir, "Downloads", "Screenshots")),
-		CacheFile:          filepath.Join(homeDir, ".cache", ".sway-easyshot-recording"),
-		CleanupTime:        3 * 24 * time.Hour, // 3 days
+		RuntimeDir:         runtimeDir,
+		StateDir:           defaultStateDir(homeDir),
+		CacheFile:          filepath.Join(runtimeDir, "recording"),
+		ThumbnailDir:       filepath.Join(runtimeDir, "thumbnails"),
+		EphemeralDir:       filepath.Join(runtimeDir, "ephemeral"),
+		TempDir:            filepath.Join(runtimeDir, "tmp"),
+		HistoryFile:        filepath.Join(defaultStateDir(homeDir), "history.jsonl"),
+		HistorySize:        getEnvInt("SWAY_SCREENSHOT_HISTORY_SIZE", 1000),
+		LogFile:            filepath.Join(defaultStateDir(homeDir), "daemon.log"),
+		LogMaxSize:         getEnvInt("SWAY_SCREENSHOT_LOG_MAX_SIZE", 5),
+		Sidecar:            getEnvBool("SWAY_SCREENSHOT_SIDECAR", false),
+		EmbedMetadata:      getEnvBool("SWAY_SCREENSHOT_EMBED_METADATA", false),
+		EncryptTool:        getEnv("SWAY_SCREENSHOT_ENCRYPT_TOOL", EncryptAge),
+		EncryptRecipient:   os.Getenv("SWAY_SCREENSHOT_ENCRYPT_RECIPIENT"),
+		OBSAddress:         getEnv("SWAY_SCREENSHOT_OBS_ADDRESS", "127.0.0.1:4455"),
+		OBSPasswordCommand: getEnv("SWAY_SCREENSHOT_OBS_PASSWORD_COMMAND", "pass show obs/password"),
+		OBSWatch:           getEnvBool("SWAY_SCREENSHOT_OBS_WATCH", true),
+		EphemeralTTL:       getEnvDuration("SWAY_SCREENSHOT_EPHEMERAL_TTL", 10*time.Minute),
+		DeleteMode:         getEnv("SWAY_SCREENSHOT_DELETE_MODE", DeleteTrash),
+		AIBackend:          getEnv("SWAY_SCREENSHOT_AI_BACKEND", ai.AIChat),
+		AIEndpoint:         os.Getenv("SWAY_SCREENSHOT_AI_ENDPOINT"),
+		AIAPIKey:           getEnv("SWAY_SCREENSHOT_AI_API_KEY", os.Getenv("OPENAI_API_KEY")),
 		AIModelImage:       getEnv("SWAY_SCREENSHOT_AI_MODEL", "gemini:gemini-2.5-flash-image"),
+		AIModelText:        getEnv("SWAY_SCREENSHOT_AI_TEXT_MODEL", "gemini:gemini-2.5-flash"),
+		OCRLanguage:        os.Getenv("SWAY_SCREENSHOT_OCR_LANG"),
+		OCRTranslate:       os.Getenv("SWAY_SCREENSHOT_OCR_TRANSLATE"),
 		ScreenshotIcon:     filepath.Join(homeDir, ".local", "share", "icons", "screenshot.svg"),
 		RecordingStartIcon: filepath.Join(homeDir, ".local", "share", "icons", "record-start.svg"),
 		RecordingStopIcon:  filepath.Join(homeDir, ".local", "share", "icons", "record-stop.svg"),
 		RecordingPauseIcon: filepath.Join(homeDir, ".local", "share", "icons", "record-pause.svg"),
-		SocketPath:         fmt.Sprintf("/run/user/%d/sway-easyshot.sock", uid),
+		SocketPath:         filepath.Join(runtimeDir, "daemon.sock"),
+		PidFile:            filepath.Join(runtimeDir, "daemon.pid"),
 		WaybarPollInterval: getPollInterval(),
+		Wallpaper:          os.Getenv("SWAY_SCREENSHOT_WALLPAPER"),
+		NightLight:         getEnv("SWAY_SCREENSHOT_NIGHTLIGHT", "off"),
+		Selector:           strings.TrimSpace(os.Getenv("SWAY_SCREENSHOT_SELECTOR")),
+		ConfirmSwitch:      getEnvBool("SWAY_SCREENSHOT_CONFIRM_SWITCH", false),
+		PauseOnLock:        getEnvBool("SWAY_SCREENSHOT_PAUSE_ON_LOCK", true),
+		ResumeOnUnlock:     getEnvBool("SWAY_SCREENSHOT_RESUME_ON_UNLOCK", true),
+		ExcludeBars:        getEnvBool("SWAY_SCREENSHOT_EXCLUDE_BARS", false),
+		Decorations:        getEnv("SWAY_SCREENSHOT_DECORATIONS", sway.DecorationsBorder),
+		ScrollMethod:       getEnv("SWAY_SCREENSHOT_SCROLL_METHOD", scroll.MethodSway),
+		FullEditor:         getEnv("SWAY_SCREENSHOT_FULL_EDITOR", "gimp"),
+		UploadProvider:     os.Getenv("SWAY_SCREENSHOT_UPLOAD"),
+		UploadQR:           getEnvBool("SWAY_SCREENSHOT_UPLOAD_QR", false),
+		QRViewer:           getEnv("SWAY_SCREENSHOT_QR_VIEWER", "imv -f"),
+		ImgurClientID:      os.Getenv("SWAY_SCREENSHOT_IMGUR_CLIENT_ID"),
+		ZeroXZeroURL:       getEnv("SWAY_SCREENSHOT_0X0_URL", "https://0x0.st"),
+		TransferShURL:      getEnv("SWAY_SCREENSHOT_TRANSFER_SH_URL", "https://transfer.sh"),
+		FileHostRetention:  getEnvDuration("SWAY_SCREENSHOT_FILE_HOST_RETENTION", 24*time.Hour),
+		RecordingTimer:     getEnv("SWAY_SCREENSHOT_RECORDING_TIMER", transcode.TimerOff),
+		RecordingReminder:  getEnvDuration("SWAY_SCREENSHOT_RECORDING_REMINDER", 30*time.Minute),
+		LowDiskSpace:       int64(getEnvInt("SWAY_SCREENSHOT_LOW_DISK_MB", 2048)) << 20,
+		ScrollClicks:       getEnvInt("SWAY_SCREENSHOT_SCROLL_CLICKS", 5),
+		FilenameTemplate:   getEnv("SWAY_SCREENSHOT_FILENAME_TEMPLATE", defaultFilenameTemplate),
+		HostLabel:          getEnv("SWAY_SCREENSHOT_HOST_LABEL", defaultHostLabel()),
+		ConfigFile:         getEnv("SWAY_SCREENSHOT_CONFIG", defaultConfigFile(homeDir)),
+		Timeout:            getEnvDuration("SWAY_SCREENSHOT_TIMEOUT", 30*time.Second),
+		Remote:             os.Getenv("SWAY_SCREENSHOT_REMOTE"),
+		RemoteToken:        os.Getenv("SWAY_SCREENSHOT_REMOTE_TOKEN"),
+		HTTPAddress:        os.Getenv("SWAY_SCREENSHOT_HTTP_ADDRESS"),
+		DBus:               getEnvBool("SWAY_SCREENSHOT_DBUS", false),
+		Actions: map[string][]string{
+			"selection-file":      {"copyclip", "rename", "copypath", "edit"},
+			"selection-clipboard": {"save", "saveai", "edit"},
+			"obs-screenshot":      {"copyclip", "rename", "copypath", "edit"},
+			InMenu:                {"copyclip", "copypath", "rename", "edit", "upload", "alttext", "layers"},
+		},
+		AIPrompts: map[string]string{
+			"filename": "identify a filename for that image and return only the slug of the filename, nothing else",
+			"alttext": "Write alt text for this screenshot for people using screen readers: " +
+				"describe concisely what it shows and transcribe any important text. Return only the alt text, nothing else.",
+		},
+		Cleanup: Cleanup{
+			Screenshots: defaultRetention,
+			Recordings:  defaultRetention,
+			Edited:      defaultRetention,
+			Other:       defaultRetention,
+		},
+		Publish: Publish{
+			Title:       "{{.Name}}",
+			Description: "Recorded on {{.Host}} on {{.Date}}",
+			Privacy:     upload.Unlisted,
+		},
+		Pretty: imaging.Style{
+			Padding: 64,
+			Radius:  12,
+			Shadow:  24,
+			From:    color.NRGBA{R: 0x6a, G: 0x5a, B: 0xcd, A: 0xff},
+			To:      color.NRGBA{R: 0xff, G: 0x7e, B: 0xb3, A: 0xff},
+		},
+		AIActions: []AIAction{
+			{
+				Name:   "summary",
+				Label:  "Summarise",
+				Prompt: "Summarise the content of this screenshot in a few sentences. Return only the summary.",
+			},
+			{
+				Name:   "csv",
+				Label:  "Extract table as CSV",
+				Prompt: "Extract the table shown in this screenshot as CSV with a header row. Return only the CSV, without code fences.",
+			},
+		},
+	}
+
+	if getEnvBool("SWAY_SCREENSHOT_STATUS_FILE", false) {
+		cfg.StatusFile = filepath.Join(runtimeDir, "status.json")
+	}
+	if getEnvBool("SWAY_SCREENSHOT_GRPC", false) {
+		cfg.GRPCSocket = filepath.Join(runtimeDir, "grpc.sock")
+	}
+
+	if err := cfg.loadFile(); err != nil {
+		return nil, err
+	}
+
+	// Reminders are the only periodic setting which can be turned off
+	if os.Getenv("SWAY_SCREENSHOT_RECORDING_REMINDER") == "0" {
+		cfg.RecordingReminder = 0
+	}
+	if os.Getenv("SWAY_SCREENSHOT_TIMEOUT") == "0" {
+		cfg.Timeout = 0
+	}
+
+	if command := os.Getenv("SWAY_SCREENSHOT_UPLOAD_COMMAND"); command != "" {
+		if _, ok := cfg.Hook(UploadCommand); !ok {
+			cfg.Hooks = append(cfg.Hooks, Hook{Name: UploadCommand, Label: "Upload", Exec: command, Upload: true})
+		}
+	}
+
+	if title := os.Getenv("SWAY_SCREENSHOT_NOTIFY_TITLE"); title != "" {
+		if cfg.NotifyTitle, err = renderTemplate(title, struct{ Host string }{cfg.HostLabel}); err != nil {
+			return nil, fmt.Errorf("invalid SWAY_SCREENSHOT_NOTIFY_TITLE: %w", err)
+		}
+	}
+
+	if _, err := ai.New(cfg.AIBackend, cfg.AIEndpoint, cfg.AIAPIKey, cfg.TempDir); err != nil {
+		return nil, err
+	}
+
+	if err := sway.ValidDecorations(cfg.Decorations); err != nil {
+		return nil, err
+	}
+
+	if err := scroll.Valid(cfg.ScrollMethod); err != nil {
+		return nil, err
+	}
+
+	if err := nightlight.Valid(cfg.NightLight); err != nil {
+		return nil, err
+	}
+
+	if err := transcode.ValidTimer(cfg.RecordingTimer); err != nil {
+		return nil, err
+	}
+
+	if cfg.DeleteMode != DeleteTrash && cfg.DeleteMode != DeleteRemove {
+		return nil, fmt.Errorf("unknown delete mode %q (want %s or %s)", cfg.DeleteMode, DeleteTrash, DeleteRemove)
+	}
+
+	if cfg.HTTPAddress != "" {
+		if err := loopbackAddress(cfg.HTTPAddress); err != nil {
+			return nil, fmt.Errorf("invalid SWAY_SCREENSHOT_HTTP_ADDRESS: %w", err)
+		}
+		if len(cfg.Listen.Tokens) == 0 {
+			return nil, fmt.Errorf("invalid SWAY_SCREENSHOT_HTTP_ADDRESS: no token allowed to connect, list them under listen in %s", cfg.ConfigFile)
+		}
+	}
+
+	if cfg.EncryptTool != EncryptAge && cfg.EncryptTool != EncryptGPG {
+		return nil, fmt.Errorf("unknown encryption tool %q (want %s or %s)", cfg.EncryptTool, EncryptAge, EncryptGPG)
 	}
 
 	// Ensure save location exists
@@ -51,9 +543,312 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// loadFile merges the structured settings of the config file, if any.
+func (c *Config) loadFile() error {
+	data, err := os.ReadFile(c.ConfigFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fc fileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", c.ConfigFile, err)
+	}
+
+	for command, actions := range fc.Actions {
+		c.Actions[command] = actions
+	}
+
+	if fc.Pretty.Padding != nil {
+		c.Pretty.Padding = *fc.Pretty.Padding
+	}
+	if fc.Pretty.Radius != nil {
+		c.Pretty.Radius = *fc.Pretty.Radius
+	}
+	if fc.Pretty.Shadow != nil {
+		c.Pretty.Shadow = *fc.Pretty.Shadow
+	}
+	if fc.Pretty.Background != "" {
+		if c.Pretty.From, c.Pretty.To, err = imaging.ParseBackground(fc.Pretty.Background); err != nil {
+			return fmt.Errorf("invalid pretty background in %s: %w", c.ConfigFile, err)
+		}
+	}
+
+	for _, hook := range fc.Hooks {
+		if hook.Name == "" || hook.Exec == "" {
+			return fmt.Errorf("invalid hook in %s: name and exec are required", c.ConfigFile)
+		}
+		if hook.Label == "" {
+			hook.Label = hook.Name
+		}
+		for _, place := range hook.AppearsIn {
+			if place != InNotification && place != InMenu {
+				return fmt.Errorf("invalid hook %s in %s: appears_in must be %s or %s", hook.Name, c.ConfigFile, InNotification, InMenu)
+			}
+		}
+		c.Hooks = append(c.Hooks, hook)
+	}
+
+	if fc.S3.Configured() {
+		if fc.S3.AccessKey == "" {
+			fc.S3.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if fc.S3.SecretKey == "" && fc.S3.SecretKeyCommand == "" {
+			fc.S3.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if err := fc.S3.Valid(); err != nil {
+			return fmt.Errorf("invalid s3 settings in %s: %w", c.ConfigFile, err)
+		}
+		c.S3 = fc.S3
+	}
+
+	if fc.Nextcloud.Configured() {
+		if err := fc.Nextcloud.Valid(); err != nil {
+			return fmt.Errorf("invalid nextcloud settings in %s: %w", c.ConfigFile, err)
+		}
+		c.Nextcloud = fc.Nextcloud
+	}
+
+	for _, retention := range []struct {
+		value  *time.Duration
+		target *time.Duration
+	}{
+		{fc.Cleanup.Screenshots, &c.Cleanup.Screenshots},
+		{fc.Cleanup.Recordings, &c.Cleanup.Recordings},
+		{fc.Cleanup.Edited, &c.Cleanup.Edited},
+		{fc.Cleanup.Other, &c.Cleanup.Other},
+	} {
+		if retention.value == nil {
+			continue
+		}
+		if *retention.value < 0 {
+			return fmt.Errorf("invalid cleanup settings in %s: durations cannot be negative", c.ConfigFile)
+		}
+		*retention.target = *retention.value
+	}
+
+	for _, text := range []string{fc.Waybar.Text, fc.Waybar.Tooltip} {
+		if _, err := template.New("").Parse(text); err != nil {
+			return fmt.Errorf("invalid waybar template in %s: %w", c.ConfigFile, err)
+		}
+	}
+	c.Waybar = fc.Waybar
+
+	for command, timeout := range fc.Timeouts {
+		if timeout < 0 {
+			return fmt.Errorf("invalid timeout for %s in %s: durations cannot be negative", command, c.ConfigFile)
+		}
+	}
+	c.Timeouts = fc.Timeouts
+
+	for i := range fc.Listen.Tokens {
+		if fc.Listen.Tokens[i].Name == "" {
+			fc.Listen.Tokens[i].Name = fmt.Sprintf("token %d", i+1)
+		}
+	}
+	if err := fc.Listen.valid(); err != nil {
+		return fmt.Errorf("invalid listen settings in %s: %w", c.ConfigFile, err)
+	}
+	c.Listen = fc.Listen
+
+	if err := c.loadPublish(fc.Publish); err != nil {
+		return fmt.Errorf("invalid publish settings in %s: %w", c.ConfigFile, err)
+	}
+
+	for feature, prompt := range fc.AI.Prompts {
+		c.AIPrompts[feature] = prompt
+	}
+
+	for _, action := range fc.AI.Actions {
+		if action.Name == "" || action.Prompt == "" {
+			return fmt.Errorf("invalid AI action in %s: name and prompt are required", c.ConfigFile)
+		}
+		if action.Label == "" {
+			action.Label = action.Name
+		}
+		// Actions named like a default one replace it
+		replaced := false
+		for i := range c.AIActions {
+			if c.AIActions[i].Name == action.Name {
+				c.AIActions[i] = action
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.AIActions = append(c.AIActions, action)
+		}
+	}
+
+	return nil
+}
+
+// Render returns the title and description of the recording file, taken on
+// host.
+func (p Publish) Render(file, host string) (string, string, error) {
+	data := struct {
+		Name string
+		Date string
+		Host string
+	}{
+		Name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		Date: time.Now().Format("2006-01-02 15:04"),
+		Host: host,
+	}
+	title, err := renderTemplate(p.Title, data)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid publish title: %w", err)
+	}
+	description, err := renderTemplate(p.Description, data)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid publish description: %w", err)
+	}
+	return title, description, nil
+}
+
+// loadPublish merges the publish settings of the config file.
+func (c *Config) loadPublish(p Publish) error {
+	if p.Title != "" {
+		c.Publish.Title = p.Title
+	}
+	if p.Description != "" {
+		c.Publish.Description = p.Description
+	}
+	if p.Privacy != "" {
+		c.Publish.Privacy = p.Privacy
+	}
+	if err := upload.ValidPrivacy(c.Publish.Privacy); err != nil {
+		return err
+	}
+	for _, text := range []string{c.Publish.Title, c.Publish.Description} {
+		if _, err := template.New("").Parse(text); err != nil {
+			return err
+		}
+	}
+
+	if p.YouTube.Configured() {
+		if err := p.YouTube.Valid(); err != nil {
+			return fmt.Errorf("youtube: %w", err)
+		}
+		c.Publish.YouTube = p.YouTube
+	}
+	if p.PeerTube.Configured() {
+		if err := p.PeerTube.Valid(); err != nil {
+			return fmt.Errorf("peertube: %w", err)
+		}
+		c.Publish.PeerTube = p.PeerTube
+	}
+	return nil
+}
+
+// AIAction returns the AI action with the given name.
+func (c *Config) AIAction(name string) (AIAction, bool) {
+	for _, action := range c.AIActions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return AIAction{}, false
+}
+
+// Hook returns the hook with the given name.
+func (c *Config) Hook(name string) (Hook, bool) {
+	for _, hook := range c.Hooks {
+		if hook.Name == name {
+			return hook, true
+		}
+	}
+	return Hook{}, false
+}
+
+// defaultHostLabel returns the short host name of the machine.
+func defaultHostLabel() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	host, _, _ = strings.Cut(host, ".")
+	return host
+}
+
+// defaultStateDir returns where data outliving the daemon is kept.
+func defaultStateDir(homeDir string) string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "sway-easyshot")
+}
+
+func defaultConfigFile(homeDir string) string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "sway-easyshot", "config.yaml")
+}
+
+const defaultFilenameTemplate = "Screenshot_{{.Time}}"
+
 // GenerateFilename generates a unique filename for a screenshot.
 func (c *Config) GenerateFilename() string {
-	return filepath.Join(c.SaveLocation, fmt.Sprintf("Screenshot_%s.png", time.Now().Format("2006-01-02-15:04.05")))
+	return c.GenerateTaggedFilename(tags.Tags{})
+}
+
+// GenerateTaggedFilename generates a filename for a screenshot from the
+// filename template, which may refer to the capture tags.
+func (c *Config) GenerateTaggedFilename(t tags.Tags) string {
+	data := struct {
+		Time      string
+		Host      string
+		Workspace string
+		AppID     string
+		Title     string
+		Project   string
+	}{
+		Time:      time.Now().Format("2006-01-02-15:04.05"),
+		Host:      tags.Slug(c.HostLabel),
+		Workspace: tags.Slug(t.Workspace),
+		AppID:     tags.Slug(t.AppID),
+		Title:     tags.Slug(t.Title),
+		Project:   tags.Slug(t.Project),
+	}
+
+	name, err := renderFilename(c.FilenameTemplate, data)
+	if err != nil || name == "" {
+		name, _ = renderFilename(defaultFilenameTemplate, data)
+	}
+
+	return filepath.Join(c.SaveLocation, name+".png")
+}
+
+func renderFilename(text string, data interface{}) (string, error) {
+	name, err := renderTemplate(text, data)
+	if err != nil {
+		return "", err
+	}
+
+	// Collapse separators left over by empty tags and keep it a base name
+	name = strings.ReplaceAll(name, "/", "-")
+	for strings.Contains(name, "__") {
+		name = strings.ReplaceAll(name, "__", "_")
+	}
+	return strings.Trim(name, "_-"), nil
+}
+
+func renderTemplate(text string, data interface{}) (string, error) {
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
 
 // GenerateRecordingBase generates a base filename for a recording.
@@ -68,6 +863,30 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
 func getPollInterval() time.Duration {
 	intervalStr := os.Getenv("SWAY_SCREENSHOT_WAYBAR_POLL_INTERVAL")
 	if intervalStr == "" {
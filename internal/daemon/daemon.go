@@ -1,158 +1,505 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"sway-easyshot/internal/commands"
-	"sway-easyshot/internal/config"
-	"sway-easyshot/internal/external"
-	"sway-easyshot/internal/state"
-	"sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/commands"
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/internal/history"
+	"github.com/chmouel/sway-easyshot/internal/logging"
+	"github.com/chmouel/sway-easyshot/internal/session"
+	"github.com/chmouel/sway-easyshot/internal/sway"
+	"github.com/chmouel/sway-easyshot/internal/systemd"
+	"github.com/chmouel/sway-easyshot/internal/trace"
+	"github.com/chmouel/sway-easyshot/internal/version"
+	"github.com/chmouel/sway-easyshot/pkg/notify"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/screenshot"
+	"github.com/chmouel/sway-easyshot/pkg/state"
+	"github.com/chmouel/sway-easyshot/pkg/transcode"
+
+	"github.com/godbus/dbus/v5"
+	"google.golang.org/grpc"
 )
 
 // Daemon manages the socket server for executing screenshot and recording commands.
 type Daemon struct {
-	cfg               *config.Config
+	// cfg is replaced when the configuration is reloaded
+	cfg               atomic.Pointer[config.Config]
 	state             *state.State
 	listener          net.Listener
+	remoteListener    net.Listener
+	httpServer        *http.Server
+	grpcServer        *grpc.Server
+	bus               *dbus.Conn
+	pidFile           *session.PidFile
 	screenshotHandler *commands.ScreenshotHandler
 	recordingHandler  *commands.RecordingHandler
 	obsHandler        *commands.OBSHandler
+	history           *history.History
+	inflight          inflight
+	captures          captureQueue
+	subscribers       subscribers
+	started           time.Time
 	ctx               context.Context
 	cancel            context.CancelFunc
-	debug             bool
+	// activated is set when systemd owns the socket, which must then
+	// outlive the daemon to start it again
+	activated bool
+	stopOnce  sync.Once
+	// stopped is closed once Stop has cleaned up, for Start to return
+	stopped chan struct{}
 }
 
 // New creates a new daemon instance.
-func New(cfg *config.Config, debug bool) *Daemon {
+func New(cfg *config.Config) *Daemon {
 	st := state.NewState()
+	st.SetHost(cfg.HostLabel)
+	// The templates were checked when loading the configuration
+	_ = st.SetTemplates(cfg.Waybar.Text, cfg.Waybar.Tooltip)
+	notify.SetTitle(cfg.NotifyTitle)
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Daemon{
-		cfg:               cfg,
+	var hist *history.History
+	if cfg.HistorySize > 0 {
+		hist = history.New(cfg.HistoryFile, cfg.HistorySize)
+	}
+
+	d := &Daemon{
 		state:             st,
-		screenshotHandler: commands.NewScreenshotHandler(cfg, st),
-		recordingHandler:  commands.NewRecordingHandler(cfg, st),
+		screenshotHandler: commands.NewScreenshotHandler(cfg, st, hist),
+		recordingHandler:  commands.NewRecordingHandler(cfg, st, hist),
 		obsHandler:        commands.NewOBSHandler(cfg, st),
+		history:           hist,
 		ctx:               ctx,
 		cancel:            cancel,
-		debug:             debug,
+		stopped:           make(chan struct{}),
 	}
+	d.cfg.Store(cfg)
+	return d
 }
 
 // Start starts the daemon server listening on the unix socket.
 func (d *Daemon) Start() error {
-	// Remove existing socket if present
-	_ = os.Remove(d.cfg.SocketPath)
+	cfg := d.cfg.Load()
+	if err := session.EnsureDir(cfg.RuntimeDir); err != nil {
+		return err
+	}
 
-	var err error
-	d.listener, err = net.Listen("unix", d.cfg.SocketPath)
+	// Holding the pid file makes the socket ours to replace: one left
+	// behind is stale, and a second daemon stops here instead
+	pidFile, err := session.LockPidFile(cfg.PidFile)
 	if err != nil {
-		return fmt.Errorf("failed to create socket: %w", err)
+		return err
 	}
+	d.pidFile = pidFile
+	d.started = time.Now()
 
-	// Set socket permissions
-	if err := os.Chmod(d.cfg.SocketPath, 0o600); err != nil {
-		return fmt.Errorf("failed to set socket permissions: %w", err)
+	listener, err := systemd.Listener()
+	if err != nil {
+		return err
 	}
+	if listener != nil {
+		d.listener = listener
+		d.activated = true
+		slog.Info("Daemon started, socket-activated", "socket", listener.Addr().String())
+	} else {
+		// A socket left by a crashed daemon is stale
+		_ = os.Remove(cfg.SocketPath)
 
-	log.Printf("Daemon started, listening on %s", d.cfg.SocketPath)
+		d.listener, err = net.Listen("unix", cfg.SocketPath)
+		if err != nil {
+			return fmt.Errorf("failed to create socket: %w", err)
+		}
+
+		// Set socket permissions
+		if err := os.Chmod(cfg.SocketPath, 0o600); err != nil {
+			return fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+
+		slog.Info("Daemon started", "socket", cfg.SocketPath)
+	}
+
+	// A remote endpoint failing to open leaves the local socket working
+	if cfg.Listen.Address != "" {
+		if err := d.listenRemote(cfg.Listen.Address); err != nil {
+			slog.Error("Remote clients cannot connect", "error", err)
+		}
+	}
+	if cfg.HTTPAddress != "" {
+		if err := d.listenHTTP(cfg.HTTPAddress); err != nil {
+			slog.Error("The HTTP API is not served", "error", err)
+		}
+	}
+	if cfg.GRPCSocket != "" {
+		if err := d.listenGRPC(cfg.GRPCSocket); err != nil {
+			slog.Error("gRPC is not served", "error", err)
+		}
+	}
+	if cfg.DBus {
+		if err := d.serveBus(); err != nil {
+			slog.Error("The D-Bus service is not served", "error", err)
+		}
+	}
+
+	capabilities := capability.Probe()
+	if missing := capabilities.Missing(); len(missing) > 0 {
+		slog.Warn("Degraded features, run with a fuller PATH or install the missing tools", "features", strings.Join(missing, ", "))
+	}
+	d.state.SetCapabilities(capabilities)
 
 	// Start cleanup routine
 	go d.cleanupRoutine()
 
+	// Exit with the session rather than lingering as an orphan
+	go d.sessionWatch()
+
+	if cfg.PauseOnLock {
+		go d.lockWatch()
+	}
+
+	if cfg.OBSWatch {
+		go d.obsWatch()
+	}
+
+	go d.broadcast()
+
+	if cfg.StatusFile != "" {
+		go d.statusFileRoutine()
+	}
+
+	// Temporary files left by a crash
+	d.screenshotHandler.RemoveTemp()
+
+	// Retry the uploads which failed, including in previous runs
+	go d.screenshotHandler.RunUploadQueue(d.ctx)
+	d.screenshotHandler.Sweep()
+
 	// Handle signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
 	go func() {
 		<-sigChan
-		log.Println("Received shutdown signal")
+		slog.Info("Received shutdown signal")
 		d.Stop()
 	}()
 
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		for range hupChan {
+			if err := d.Reload(); err != nil {
+				slog.Error("Failed to reload config", "error", err)
+			}
+		}
+	}()
+
 	// Accept connections
 	for {
 		conn, err := d.listener.Accept()
 		if err != nil {
 			select {
 			case <-d.ctx.Done():
+				<-d.stopped
 				return nil
 			default:
-				log.Printf("Error accepting connection: %v", err)
+				slog.Error("Error accepting connection", "error", err)
 				continue
 			}
 		}
 
-		go d.handleConnection(conn)
+		go d.handleConnection(conn, false)
 	}
 }
 
+// Reload loads the configuration again and hands it to the handlers, without
+// interrupting a recording in progress. The environment is the one the
+// daemon started with, so only the configuration file brings changes.
+func (d *Daemon) Reload() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	if err := d.state.SetTemplates(cfg.Waybar.Text, cfg.Waybar.Tooltip); err != nil {
+		return err
+	}
+
+	d.screenshotHandler.Reconfigure(cfg)
+	d.recordingHandler.Reconfigure(cfg)
+	d.obsHandler.Reconfigure(cfg)
+	d.cfg.Store(cfg)
+	d.state.SetCapabilities(capability.Probe())
+	slog.Info("Configuration reloaded")
+	return nil
+}
+
 // Stop stops the daemon server.
 func (d *Daemon) Stop() {
-	log.Println("Stopping daemon")
-	d.cancel()
+	d.stopOnce.Do(func() {
+		cfg := d.cfg.Load()
+		slog.Info("Stopping daemon")
+		d.cancel()
 
-	if d.listener != nil {
-		_ = d.listener.Close()
-	}
+		if d.listener != nil {
+			_ = d.listener.Close()
+		}
+		if d.remoteListener != nil {
+			_ = d.remoteListener.Close()
+		}
+		if d.httpServer != nil {
+			_ = d.httpServer.Close()
+		}
+		if d.grpcServer != nil {
+			d.stopGRPC()
+			_ = os.Remove(cfg.GRPCSocket)
+		}
 
-	_ = os.Remove(d.cfg.SocketPath)
+		if !d.activated {
+			_ = os.Remove(cfg.SocketPath)
+		}
+		d.screenshotHandler.RemoveTemp()
+		if cfg.StatusFile != "" {
+			_ = os.Remove(cfg.StatusFile)
+		}
+		if d.pidFile != nil {
+			d.pidFile.Release()
+		}
+		if d.bus != nil {
+			_ = d.bus.Close()
+		}
+		close(d.stopped)
+	})
 }
 
-func (d *Daemon) handleConnection(conn net.Conn) {
+// handleConnection serves the requests of conn, a remote one coming from
+// the TCP endpoint.
+func (d *Daemon) handleConnection(conn net.Conn, remote bool) {
 	defer func() { _ = conn.Close() }()
 
-	decoder := json.NewDecoder(conn)
+	// Remote clients are strangers until their token is checked: they get
+	// little time and room to send each request
+	var reader io.Reader = conn
+	var limit *io.LimitedReader
+	if remote {
+		limit = &io.LimitedReader{R: conn, N: maxRemoteRequest}
+		reader = limit
+		_ = conn.SetReadDeadline(time.Now().Add(remoteReadTimeout))
+	}
+	decoder := json.NewDecoder(reader)
 	encoder := json.NewEncoder(conn)
 
-	var req protocol.Request
-	if err := decoder.Decode(&req); err != nil {
-		if errors.Is(err, io.EOF) {
+	// Requests asking to keep the connection alive are followed by others,
+	// each on its own line
+	for {
+		var req protocol.Request
+		if err := decoder.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+				return
+			}
+			slog.Warn("Error decoding request", "error", err)
+			_ = encoder.Encode(protocol.Response{
+				Success: false,
+				Message: fmt.Sprintf("Invalid request: %v", err),
+			})
 			return
 		}
-		log.Printf("Error decoding request: %v", err)
-		_ = encoder.Encode(protocol.Response{
-			Success: false,
-			Message: fmt.Sprintf("Invalid request: %v", err),
-		})
-		return
+		_ = conn.SetReadDeadline(time.Time{})
+		if limit != nil {
+			limit.N = maxRemoteRequest
+		}
+
+		if !d.serveRequest(conn, encoder, req, remote) || !req.KeepAlive {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
 	}
+}
 
-	if req.Action != "waybar-status" || d.debug {
-		log.Printf("Received command: %s, action: %s", req.Command, req.Action)
+// serveRequest answers req, returning false when the connection cannot
+// serve another request. Remote requests are only served when their token
+// allows them.
+func (d *Daemon) serveRequest(conn net.Conn, encoder *json.Encoder, req protocol.Request, remote bool) bool {
+	// Bars poll, which would drown everything else, and logs following the
+	// log would feed themselves
+	level := slog.LevelInfo
+	if req.Action == "waybar-status" || req.Action == "subscribe" || req.Action == "ping" || req.Action == "health" {
+		level = slog.LevelDebug
+	}
+	if req.Action != "logs" {
+		slog.Log(d.ctx, level, "Received command", "command", req.Command, "action", req.Action)
 	}
 
-	resp := d.executeCommand(req)
-	if err := encoder.Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	if remote {
+		if resp, ok := d.authorize(req, conn.RemoteAddr().String()); !ok {
+			resp.Version = protocol.Version
+			resp.ID = req.ID
+			_ = encoder.Encode(resp)
+			return false
+		}
 	}
-}
 
-func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
+	// Subscribed connections stay open for the events
+	if req.Action == "subscribe" && req.Version <= protocol.Version {
+		var subscribe protocol.SubscribeOptions
+		if err := req.DecodeOptions(&subscribe); err != nil {
+			resp := invalidOptions(err)
+			resp.Version = protocol.Version
+			resp.ID = req.ID
+			_ = encoder.Encode(resp)
+			return true
+		}
+		d.setIcons(subscribe.Icons)
+		d.serveEvents(conn, encoder)
+		return false
+	}
+
+	out := newStream(encoder, req.ID)
 	ctx := d.ctx
+	stopKeepAlive := func() {}
+	if req.Progress {
+		ctx = commands.WithProgress(ctx, out.progress)
+		stopKeepAlive = out.keepAlive(keepAliveInterval)
+	}
+
+	resp := d.answer(ctx, req)
+	err := out.finish(resp)
+	stopKeepAlive()
+	if err != nil {
+		slog.Warn("Error encoding response", "error", err)
+		return false
+	}
+
+	if req.Action == "shutdown" && resp.Success {
+		slog.Info("Received shutdown request")
+		d.Stop()
+		return false
+	}
+	return true
+}
 
-	// Extract common options
-	delay := 0
-	useCurrentScreen := false
+// answer executes req, tracing it, and returns the final response to it.
+func (d *Daemon) answer(ctx context.Context, req protocol.Request) protocol.Response {
+	traced := req.Action != "waybar-status" && req.Action != "trace" && req.Action != "history" && req.Action != "logs"
+	if traced {
+		options, _ := json.Marshal(req.Options)
+		trace.Add(trace.KindRequest, "%s %s", req.Action, options)
+	}
 
-	if req.Options != nil {
-		if d, ok := req.Options["delay"].(float64); ok {
-			delay = int(d)
+	var resp protocol.Response
+	if req.Version > protocol.Version {
+		resp = protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("the client speaks version %d of the protocol and the daemon version %d, restart it with: sway-easyshot daemon-restart",
+				req.Version, protocol.Version),
+			Code: protocol.CodeUnsupported,
 		}
-		if u, ok := req.Options["use_current_screen"].(bool); ok {
-			useCurrentScreen = u
+	} else {
+		resp = d.executeCommand(ctx, req)
+	}
+	if traced {
+		trace.Add(trace.KindResponse, "%s success=%t %s", req.Action, resp.Success, resp.Message)
+	}
+	return resp
+}
+
+func (d *Daemon) executeCommand(ctx context.Context, req protocol.Request) protocol.Response {
+	ctx, saved := commands.WithSaved(ctx)
+	cfg := d.cfg.Load()
+
+	// A second press of the same keybinding is ignored while the first
+	// still waits for a selection
+	if interactiveActions[req.Action] {
+		var cancel context.CancelFunc
+		ctx, cancel = commands.WithCancel(ctx)
+		defer cancel()
+		if !d.inflight.begin(req.Action, cancel) {
+			_ = notify.Send(2000, cfg.ScreenshotIcon, fmt.Sprintf("%s is already in progress", req.Action))
+			return protocol.Response{
+				Success: false,
+				Message: fmt.Sprintf("%s is already in progress", req.Action),
+				Code:    protocol.CodeBusy,
+			}
 		}
+		defer d.inflight.end(req.Action)
+	}
+
+	// Options common to the captures and recordings
+	var opts protocol.CaptureOptions
+	var startAction string
+	switch {
+	case captureActions[req.Action]:
+		if err := req.DecodeOptions(&opts); err != nil {
+			return invalidOptions(err)
+		}
+	case req.Action == "toggle-record":
+		var toggle protocol.ToggleRecordOptions
+		if err := req.DecodeOptions(&toggle); err != nil {
+			return invalidOptions(err)
+		}
+		opts, startAction = toggle.CaptureOptions, toggle.StartAction
+	}
+	if err := opts.ValidFor(req.Action); err != nil {
+		return invalidOptions(err)
+	}
+
+	excludeBars := cfg.ExcludeBars
+	switch opts.Bars {
+	case "include":
+		excludeBars = false
+	case "exclude":
+		excludeBars = true
+	}
+	decorations := cfg.Decorations
+	if opts.Decorations != "" {
+		if err := sway.ValidDecorations(opts.Decorations); err != nil {
+			return invalidOptions(err)
+		}
+		decorations = opts.Decorations
+	}
+	timer := cfg.RecordingTimer
+	if opts.Timer != "" {
+		if err := transcode.ValidTimer(opts.Timer); err != nil {
+			return invalidOptions(err)
+		}
+		timer = opts.Timer
+	}
+	// Validated with the options
+	limit, _ := time.ParseDuration(opts.For)
+	delay, useCurrentScreen, lastRegion, pretty := opts.Delay, opts.UseCurrentScreen, opts.LastRegion, opts.Pretty
+	ctx = destinations(ctx, opts.Upload, opts.Ephemeral, opts.Encrypt)
+
+	// Interactive actions requested together run one after the other
+	if interactiveActions[req.Action] && !holdsTurn(ctx) {
+		t, ahead := d.captures.join()
+		defer d.captures.leave(t)
+		if ahead > 0 {
+			_ = notify.Send(2000, cfg.ScreenshotIcon, fmt.Sprintf("%s queued: %s", req.Action, strings.ToLower(queuedMessage(ahead))))
+			if err := d.awaitTurn(ctx, t, ahead); err != nil {
+				return protocol.Response{Success: false, Message: err.Error(), State: d.state.GetState(), Code: errorCode(err)}
+			}
+		}
+		ctx = context.WithValue(ctx, turnKey{}, true)
 	}
 
 	var err error
@@ -160,47 +507,171 @@ func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
 	switch req.Action {
 	// Screenshot commands
 	case "current-window-clipboard":
-		err = d.screenshotHandler.CurrentWindowClipboard(ctx, delay)
+		err = d.screenshotHandler.CurrentWindowClipboard(ctx, delay, opts.Transparent, pretty, decorations)
 
 	case "current-window-file":
-		err = d.screenshotHandler.CurrentWindowFile(ctx, delay)
+		err = d.screenshotHandler.CurrentWindowFile(ctx, delay, opts.Transparent, pretty, decorations)
+
+	case "window-file":
+		err = d.screenshotHandler.WindowFile(ctx, delay, opts.Workspace, opts.AppID, pretty)
 
 	case "current-screen-clipboard":
-		err = d.screenshotHandler.CurrentScreenClipboard(ctx, delay, useCurrentScreen)
+		err = d.screenshotHandler.CurrentScreenClipboard(ctx, delay, useCurrentScreen, excludeBars)
 
 	case "selection-file":
-		err = d.screenshotHandler.SelectionFile(ctx, delay)
+		err = d.screenshotHandler.SelectionFile(ctx, delay, lastRegion, pretty)
+
+	case "scroll-capture":
+		maxFrames := 15
+		if opts.MaxFrames > 0 {
+			maxFrames = opts.MaxFrames
+		}
+		err = d.screenshotHandler.ScrollCapture(ctx, delay, maxFrames, pretty)
+
+	case "compose":
+		var compose protocol.ComposeOptions
+		if err := req.DecodeOptions(&compose); err != nil {
+			return invalidOptions(err)
+		}
+		last, labels := 2, true
+		if compose.Last > 0 {
+			last = compose.Last
+		}
+		if compose.Labels != nil {
+			labels = *compose.Labels
+		}
+		ctx = destinations(ctx, compose.Upload, compose.Ephemeral, compose.Encrypt)
+		err = d.screenshotHandler.Compose(ctx, compose.Files, last, compose.Columns, labels)
+
+	case "menu":
+		return d.menu(ctx)
+
+	case "gallery":
+		var gallery protocol.GalleryOptions
+		if err := req.DecodeOptions(&gallery); err != nil {
+			return invalidOptions(err)
+		}
+		limit := 30
+		if gallery.Limit != nil {
+			limit = *gallery.Limit
+		}
+		err = d.screenshotHandler.Gallery(ctx, limit)
+
+	case "action":
+		var action protocol.ActionOptions
+		if err := req.DecodeOptions(&action); err != nil {
+			return invalidOptions(err)
+		}
+		err = d.screenshotHandler.RunAction(ctx, action.Name, action.File)
+
+	case "selection-multi":
+		err = d.screenshotHandler.SelectionMulti(ctx, delay, opts.Composite)
+
+	case "ocr-selection":
+		err = d.screenshotHandler.OCRSelection(ctx, delay, lastRegion, opts.Lang, opts.Translate)
+
+	case "alt-text-selection":
+		err = d.screenshotHandler.AltTextSelection(ctx, delay, lastRegion)
+
+	case "scan-qr":
+		err = d.screenshotHandler.ScanQR(ctx, delay, lastRegion)
+
+	case "pick-palette":
+		err = d.screenshotHandler.PickPalette(ctx, delay, lastRegion, opts.Colors, opts.Save)
 
 	case "selection-edit":
-		err = d.screenshotHandler.SelectionEdit(ctx, delay)
+		err = d.screenshotHandler.SelectionEdit(ctx, delay, lastRegion)
 
 	case "selection-clipboard":
-		err = d.screenshotHandler.SelectionClipboard(ctx, delay)
+		err = d.screenshotHandler.SelectionClipboard(ctx, delay, lastRegion, pretty)
 
 	// Recording commands
 	case "movie-selection":
-		err = d.recordingHandler.MovieSelection(ctx, delay)
+		err = d.recordingHandler.MovieSelection(ctx, delay, lastRegion, limit, timer)
 
 	case "movie-screen":
-		err = d.recordingHandler.MovieScreen(ctx, delay, useCurrentScreen)
+		err = d.recordingHandler.MovieScreen(ctx, delay, useCurrentScreen, excludeBars, limit, timer)
 
 	case "movie-current-window":
-		err = d.recordingHandler.MovieCurrentWindow(ctx, delay)
+		err = d.recordingHandler.MovieCurrentWindow(ctx, delay, decorations, limit, timer)
+
+	case "movie-zoom":
+		zoom := 2.0
+		if opts.Zoom > 0 {
+			zoom = opts.Zoom
+		}
+		err = d.recordingHandler.MovieZoom(ctx, delay, useCurrentScreen, zoom, limit, timer)
 
 	case "stop-recording":
 		err = d.recordingHandler.StopRecording(ctx)
 
+	case "reload-config":
+		err = d.Reload()
+
+	case "shutdown":
+		// The daemon stops once the response is sent
+		var shutdown protocol.ShutdownOptions
+		if err := req.DecodeOptions(&shutdown); err != nil {
+			return invalidOptions(err)
+		}
+		if d.state.GetState().Recording {
+			if !shutdown.Force {
+				return protocol.Response{Success: false, Message: "A recording is in progress, stop it first or use --force"}
+			}
+			err = d.recordingHandler.StopRecording(ctx)
+		}
+
+	case "retarget":
+		var retarget protocol.RetargetOptions
+		if err := req.DecodeOptions(&retarget); err != nil {
+			return invalidOptions(err)
+		}
+		target := "movie-selection" // default
+		if retarget.Target != "" {
+			target = retarget.Target
+		}
+		err = d.recordingHandler.Retarget(ctx, target, retarget.UseCurrentScreen)
+
 	case "pause-recording":
 		err = d.recordingHandler.PauseRecording(ctx)
 
+	case "screen-locked":
+		err = d.recordingHandler.SessionLocked(ctx, true)
+
+	case "screen-unlocked":
+		err = d.recordingHandler.SessionLocked(ctx, false)
+
+	case "snapshot":
+		err = d.screenshotHandler.Snapshot(ctx)
+
+	case "undo":
+		err = d.screenshotHandler.Undo(ctx)
+
+	case "delete-last":
+		err = d.screenshotHandler.DeleteLast(ctx)
+
 	case "toggle-record":
-		startAction := "movie-selection" // default
-		if req.Options != nil {
-			if sa, ok := req.Options["start_action"].(string); ok && sa != "" {
-				startAction = sa
+		if startAction == "" {
+			startAction = "movie-selection" // default
+		}
+		err = d.recordingHandler.ToggleRecord(ctx, startAction, delay, useCurrentScreen, lastRegion, limit, timer)
+
+	case "repeat-last":
+		action := d.state.GetLastRegionAction()
+		if action == "" {
+			return protocol.Response{
+				Success: false,
+				Message: "No previous region capture to repeat",
 			}
 		}
-		err = d.recordingHandler.ToggleRecord(ctx, startAction, delay, useCurrentScreen)
+		var repeat protocol.RepeatLastOptions
+		if err := req.DecodeOptions(&repeat); err != nil {
+			return invalidOptions(err)
+		}
+		opts := protocol.CaptureOptions{Delay: repeat.Delay}
+		// Several selections are made again
+		opts.LastRegion = slices.Contains(protocol.CaptureFields[action], "last_region")
+		return d.executeCommand(ctx, protocol.NewRequest(action, opts))
 
 	// OBS commands
 	case "obs-toggle-recording":
@@ -209,33 +680,24 @@ func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
 	case "obs-toggle-pause":
 		err = d.obsHandler.TogglePause(ctx)
 
+	case "obs-screenshot":
+		err = d.screenshotHandler.OBSScreenshot(ctx, d.obsHandler)
+
+	case "obs-toggle-source":
+		var source protocol.OBSSourceOptions
+		if err := req.DecodeOptions(&source); err != nil {
+			return invalidOptions(err)
+		}
+		err = d.obsHandler.ToggleSource(ctx, source.Scene, source.Source)
+
 	// Waybar status
 	case "waybar-status":
 		// Check if custom icons were provided in the request
-		if req.Options != nil {
-			if iconsMap, ok := req.Options["icons"].(map[string]interface{}); ok {
-				icons := state.DefaultIcons()
-				if idle, ok := iconsMap["Idle"].(string); ok {
-					icons.Idle = idle
-				}
-				if recording, ok := iconsMap["Recording"].(string); ok {
-					icons.Recording = recording
-				}
-				if paused, ok := iconsMap["Paused"].(string); ok {
-					icons.Paused = paused
-				}
-				if obsRecording, ok := iconsMap["ObsRecording"].(string); ok {
-					icons.ObsRecording = obsRecording
-				}
-				if obsPaused, ok := iconsMap["ObsPaused"].(string); ok {
-					icons.ObsPaused = obsPaused
-				}
-				if countdown, ok := iconsMap["Countdown"].(string); ok {
-					icons.Countdown = countdown
-				}
-				d.state.SetIcons(icons)
-			}
+		var waybar protocol.WaybarStatusOptions
+		if err := req.DecodeOptions(&waybar); err != nil {
+			return invalidOptions(err)
 		}
+		d.setIcons(waybar.Icons)
 		status := d.state.GetWaybarStatus()
 		data, _ := json.Marshal(status)
 		return protocol.Response{
@@ -244,6 +706,122 @@ func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
 			State:   d.state.GetState(),
 		}
 
+	case "cancel":
+		cancelled := d.inflight.cancel()
+		if len(cancelled) == 0 {
+			return protocol.Response{Success: true, Message: "Nothing to cancel", State: d.state.GetState()}
+		}
+		return protocol.Response{
+			Success: true,
+			Message: "Cancelled " + strings.Join(cancelled, ", "),
+			State:   d.state.GetState(),
+		}
+
+	case "ping":
+		return protocol.Response{Success: true, Message: "pong"}
+
+	case "health":
+		return protocol.Response{
+			Success: true,
+			Message: "Daemon is running",
+			Health: &protocol.Health{
+				Protocol: protocol.Version,
+				Version:  version.Get().Version,
+				Uptime:   time.Since(d.started).Seconds(),
+				Tools:    commands.Tools(cfg),
+			},
+		}
+
+	case "status":
+		d.state.SetCapabilities(capability.Probe())
+		return protocol.Response{
+			Success: true,
+			Message: "Status retrieved",
+			State:   d.state.GetState(),
+		}
+
+	case "history":
+		var options protocol.HistoryOptions
+		if err := req.DecodeOptions(&options); err != nil {
+			return invalidOptions(err)
+		}
+		if err := validKind(options.Kind); err != nil {
+			return invalidOptions(err)
+		}
+		query := history.Query{Kind: options.Kind, Limit: options.Limit}
+		if options.Since != "" {
+			since, _ := time.ParseDuration(options.Since)
+			query.Since = time.Now().Add(-since)
+		}
+		entries, err := d.history.List(query)
+		if err != nil {
+			return protocol.Response{Success: false, Message: err.Error()}
+		}
+		data, _ := json.Marshal(entries)
+		return protocol.Response{
+			Success: true,
+			Message: string(data),
+			State:   d.state.GetState(),
+		}
+
+	case "last":
+		var last protocol.LastOptions
+		if err := req.DecodeOptions(&last); err != nil {
+			return invalidOptions(err)
+		}
+		if err := validKind(last.Kind); err != nil {
+			return invalidOptions(err)
+		}
+		file, err := d.screenshotHandler.LastCapture(ctx, last.Kind, last.Copy)
+		if err != nil {
+			return protocol.Response{Success: false, Message: err.Error()}
+		}
+		return protocol.Response{
+			Success: true,
+			Message: file,
+			State:   d.state.GetState(),
+		}
+
+	case "cleanup":
+		var cleanup protocol.CleanupOptions
+		if err := req.DecodeOptions(&cleanup); err != nil {
+			return invalidOptions(err)
+		}
+		olderThan, _ := time.ParseDuration(cleanup.OlderThan)
+		report, err := d.screenshotHandler.Cleanup(ctx, cleanup.DryRun, olderThan)
+		if err != nil {
+			return protocol.Response{Success: false, Message: err.Error()}
+		}
+		return protocol.Response{
+			Success: true,
+			Message: report.String(),
+			State:   d.state.GetState(),
+		}
+
+	case "version":
+		data, _ := json.Marshal(version.Get())
+		return protocol.Response{
+			Success: true,
+			Message: string(data),
+			State:   d.state.GetState(),
+		}
+
+	case "logs":
+		var logs protocol.LogsOptions
+		if err := req.DecodeOptions(&logs); err != nil {
+			return invalidOptions(err)
+		}
+		data, _ := json.Marshal(logging.Lines(logs.After, logs.Lines))
+		return protocol.Response{Success: true, Message: string(data)}
+
+	case "trace":
+		data, _ := json.Marshal(trace.Entries())
+		return protocol.Response{
+			Success: true,
+			Message: string(data),
+			State:   d.state.GetState(),
+		}
+
 	default:
 		return protocol.Response{
 			Success: false,
@@ -256,6 +834,7 @@ func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
 			Success: false,
 			Message: err.Error(),
 			State:   d.state.GetState(),
+			Code:    errorCode(err),
 		}
 	}
 
@@ -263,7 +842,217 @@ func (d *Daemon) executeCommand(req protocol.Request) protocol.Response {
 		Success: true,
 		Message: "Command executed successfully",
 		State:   d.state.GetState(),
+		Files:   saved(),
+	}
+}
+
+// captureActions are the actions taking protocol.CaptureOptions.
+var captureActions = map[string]bool{
+	"current-window-clipboard": true,
+	"current-window-file":      true,
+	"window-file":              true,
+	"current-screen-clipboard": true,
+	"selection-file":           true,
+	"selection-edit":           true,
+	"selection-clipboard":      true,
+	"selection-multi":          true,
+	"scroll-capture":           true,
+	"pick-palette":             true,
+	"ocr-selection":            true,
+	"scan-qr":                  true,
+	"alt-text-selection":       true,
+	"movie-selection":          true,
+	"movie-screen":             true,
+	"movie-zoom":               true,
+	"movie-current-window":     true,
+}
+
+// invalidOptions returns the response to a request whose options err
+// rejected.
+func invalidOptions(err error) protocol.Response {
+	return protocol.Response{Success: false, Message: err.Error(), Code: protocol.CodeInvalid}
+}
+
+// destinations returns ctx with the capture uploaded, ephemeral or
+// encrypted as asked.
+func destinations(ctx context.Context, upload, ephemeral, encrypt bool) context.Context {
+	if upload {
+		ctx = commands.WithUpload(ctx)
+	}
+	if ephemeral {
+		ctx = commands.WithEphemeral(ctx)
+	}
+	if encrypt {
+		ctx = commands.WithEncrypt(ctx)
 	}
+	return ctx
+}
+
+// errorCode returns the code telling the client why a command failed, so
+// that cancelling a capture is not reported like a failure.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, screenshot.ErrCancelled), errors.Is(err, commands.ErrCancelled):
+		return protocol.CodeCancelled
+	case errors.Is(err, capability.ErrUnavailable), errors.Is(err, exec.ErrNotFound):
+		return protocol.CodeUnavailable
+	}
+	return ""
+}
+
+// lockWatch pauses the recording in progress while logind has the session
+// locked or the system asleep.
+func (d *Daemon) lockWatch() {
+	err := session.WatchLock(d.ctx, func(locked bool) {
+		if err := d.recordingHandler.SessionLocked(d.ctx, locked); err != nil {
+			slog.Warn("Failed to follow the session lock", "error", err)
+		}
+	})
+	if err != nil && d.ctx.Err() == nil {
+		slog.Warn("Stopped watching the session lock", "error", err)
+	}
+}
+
+// OBS watch retry delays: OBS is looked for again quickly after it goes
+// away, then less and less often while it cannot be reached.
+const (
+	obsWatchMinRetry = 10 * time.Second
+	obsWatchMaxRetry = 5 * time.Minute
+)
+
+// obsWatch follows the recording and streaming of OBS, reconnecting when
+// it is started again after being closed.
+func (d *Daemon) obsWatch() {
+	retry := obsWatchMinRetry
+	for {
+		started := time.Now()
+		err := d.obsHandler.Watch(d.ctx)
+		if d.ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > obsWatchMinRetry {
+			// It was connected for a while, OBS has just gone away
+			retry = obsWatchMinRetry
+		}
+		slog.Debug("OBS watch failed, retrying", "error", err, "retry", retry)
+
+		select {
+		case <-time.After(retry):
+		case <-d.ctx.Done():
+			return
+		}
+		retry = min(retry*2, obsWatchMaxRetry)
+	}
+}
+
+// sessionWatch stops the daemon once the Wayland display or the sway IPC
+// socket it was started for goes away, finishing any recording first.
+func (d *Daemon) sessionWatch() {
+	var checks []func() error
+	if display := os.Getenv("WAYLAND_DISPLAY"); display != "" {
+		if !filepath.IsAbs(display) {
+			display = filepath.Join(session.RuntimeDir(), display)
+		}
+		checks = append(checks, func() error {
+			_, err := os.Stat(display)
+			return err
+		})
+	}
+	if swaysock := os.Getenv("SWAYSOCK"); swaysock != "" {
+		checks = append(checks, func() error {
+			conn, err := net.DialTimeout("unix", swaysock, time.Second)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+	if len(checks) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ticker.C:
+			var err error
+			for _, check := range checks {
+				if err = check(); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				failures = 0
+				continue
+			}
+
+			// Tolerate a transient failure, e.g. while sway reloads
+			failures++
+			if failures < 3 {
+				continue
+			}
+
+			slog.Info("Session ended, shutting down", "reason", err)
+			if d.state.GetState().Recording {
+				if err := d.recordingHandler.StopRecording(d.ctx); err != nil {
+					slog.Error("Failed to finish recording", "error", err)
+				}
+			}
+			d.Stop()
+			return
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// statusFileRoutine keeps the status file up to date for consumers which
+// would rather read a file than talk to the socket. The file is replaced
+// atomically so that readers never see it half written.
+func (d *Daemon) statusFileRoutine() {
+	cfg := d.cfg.Load()
+	ticker := time.NewTicker(cfg.WaybarPollInterval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		data, err := json.Marshal(d.state.GetWaybarStatus())
+		if err == nil && !bytes.Equal(data, last) {
+			if err := writeFileAtomic(cfg.StatusFile, append(data, '\n')); err != nil {
+				slog.Warn("Failed to write status file", "error", err)
+			} else {
+				last = data
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func (d *Daemon) cleanupRoutine() {
@@ -284,8 +1073,17 @@ func (d *Daemon) cleanupRoutine() {
 }
 
 func (d *Daemon) cleanup() {
-	log.Println("Running cleanup routine")
-	if err := external.CleanupOldFiles(d.ctx, d.cfg.SaveLocation, d.cfg.CleanupTime); err != nil {
-		log.Printf("Cleanup error: %v", err)
+	slog.Debug("Running cleanup routine")
+	if _, err := d.screenshotHandler.Cleanup(d.ctx, false, 0); err != nil {
+		slog.Error("Cleanup error", "error", err)
+	}
+}
+
+// validKind returns an error when kind is neither empty nor a capture type
+// of the history.
+func validKind(kind string) error {
+	if kind != "" && kind != history.Screenshot && kind != history.Recording {
+		return fmt.Errorf("unknown capture type %q (want %s or %s)", kind, history.Screenshot, history.Recording)
 	}
+	return nil
 }
@@ -0,0 +1,179 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// The name, object and interface of the daemon on the session bus.
+const (
+	busName      = "org.sway.Screenshot"
+	busPath      = dbus.ObjectPath("/org/sway/Screenshot")
+	busInterface = "org.sway.Screenshot"
+)
+
+// busActions are the actions offered as methods on the bus, named in
+// CamelCase, all but subscribe, which the properties replace.
+var busActions = []string{
+	"action", "alt-text-selection", "cancel", "cleanup", "compose",
+	"current-screen-clipboard", "current-window-clipboard", "current-window-file",
+	"delete-last", "gallery", "health", "history", "last", "logs", "menu",
+	"movie-current-window", "movie-screen", "movie-selection", "movie-zoom",
+	"obs-screenshot", "obs-toggle-pause", "obs-toggle-recording", "obs-toggle-source",
+	"ocr-selection", "pause-recording", "pick-palette", "ping", "reload-config",
+	"repeat-last", "retarget", "scan-qr", "screen-locked", "screen-unlocked",
+	"scroll-capture", "selection-clipboard", "selection-edit", "selection-file",
+	"selection-multi", "shutdown", "snapshot", "status", "stop-recording",
+	"toggle-record", "trace", "undo", "version", "waybar-status", "window-file",
+}
+
+// serveBus registers the daemon on the session bus, with a method per
+// action and the state as properties, until the daemon stops.
+func (d *Daemon) serveBus() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+
+	methods := map[string]interface{}{}
+	var described []introspect.Method
+	for _, action := range busActions {
+		name := busMethod(action)
+		methods[name] = d.busCall(action)
+		described = append(described, introspect.Method{
+			Name: name,
+			Args: []introspect.Arg{
+				{Name: "options", Type: "a{sv}", Direction: "in"},
+				{Name: "message", Type: "s", Direction: "out"},
+				{Name: "files", Type: "as", Direction: "out"},
+			},
+		})
+	}
+	if err := conn.ExportMethodTable(methods, busPath, busInterface); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to export the methods: %w", err)
+	}
+
+	props, err := prop.Export(conn, busPath, prop.Map{busInterface: busProperties(d.state.GetState())})
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to export the properties: %w", err)
+	}
+	node := &introspect.Node{
+		Name: string(busPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: busInterface, Methods: described, Properties: props.Introspection(busInterface)},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), busPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to export the introspection: %w", err)
+	}
+
+	// Another session's daemon may hold the name already
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		_ = conn.Close()
+		if err == nil {
+			err = fmt.Errorf("%s is already taken", busName)
+		}
+		return fmt.Errorf("failed to own %s: %w", busName, err)
+	}
+	d.bus = conn
+	slog.Info("Registered on the session bus", "name", busName)
+
+	go d.busUpdate(props)
+	return nil
+}
+
+// busUpdate sets the properties from the state as it changes, each change
+// emitting PropertiesChanged.
+func (d *Daemon) busUpdate(props *prop.Properties) {
+	sub := d.subscribers.add()
+	defer d.subscribers.remove(sub)
+
+	for {
+		select {
+		case event := <-sub.events:
+			if event.State == nil {
+				continue
+			}
+			for name, property := range busProperties(event.State) {
+				if props.GetMust(busInterface, name) != property.Value {
+					props.SetMust(busInterface, name, property.Value)
+				}
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// busProperties returns the properties showing st.
+func busProperties(st *protocol.State) map[string]*prop.Prop {
+	values := map[string]interface{}{
+		"Host":          st.Host,
+		"Recording":     st.Recording,
+		"Paused":        st.Paused,
+		"RecordingFile": st.RecordingFile,
+		"ObsRecording":  st.OBSRecording,
+		"ObsPaused":     st.OBSPaused,
+		"ObsStreaming":  st.OBSStreaming,
+	}
+	props := make(map[string]*prop.Prop, len(values))
+	for name, value := range values {
+		props[name] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	return props
+}
+
+// busCall returns the method running action with the options given, and
+// returning the message and files of the response, or an error named after
+// its code.
+func (d *Daemon) busCall(action string) func(map[string]dbus.Variant) (string, []string, *dbus.Error) {
+	return func(options map[string]dbus.Variant) (string, []string, *dbus.Error) {
+		slog.Info("Received D-Bus call", "action", action)
+		req := protocol.NewRequest(action, nil)
+		req.Options = make(map[string]interface{}, len(options))
+		for name, value := range options {
+			req.Options[name] = value.Value()
+		}
+
+		resp := d.answer(d.ctx, req)
+		if !resp.Success {
+			return "", nil, dbus.NewError(busError(resp.Code), []interface{}{resp.Message})
+		}
+		if action == "shutdown" {
+			slog.Info("Received shutdown request")
+			go d.Stop()
+		}
+		return resp.Message, append([]string{}, resp.Files...), nil
+	}
+}
+
+// busMethod returns the method of action, e.g. SelectionFile.
+func busMethod(action string) string {
+	var name strings.Builder
+	for _, word := range strings.Split(action, "-") {
+		name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return name.String()
+}
+
+// busError returns the name of the error of a response failing with code,
+// e.g. org.sway.Screenshot.Error.Cancelled.
+func busError(code string) string {
+	if code == "" {
+		return busInterface + ".Error.Failed"
+	}
+	return busInterface + ".Error." + busMethod(code)
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/state"
+)
+
+// subscribers is the registry of the connections subscribed to events.
+type subscribers struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+	// last is the latest event, which new subscribers get first
+	last *protocol.Event
+}
+
+// subscriber is a subscribed connection, only ever sent the latest event
+// so that a slow client cannot hold the others back.
+type subscriber struct {
+	events chan protocol.Event
+}
+
+func (r *subscribers) add() *subscriber {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub := &subscriber{events: make(chan protocol.Event, 1)}
+	if r.last != nil {
+		sub.events <- *r.last
+	}
+	if r.subs == nil {
+		r.subs = map[*subscriber]struct{}{}
+	}
+	r.subs[sub] = struct{}{}
+	return sub
+}
+
+func (r *subscribers) remove(sub *subscriber) {
+	r.mu.Lock()
+	delete(r.subs, sub)
+	r.mu.Unlock()
+}
+
+// send sends event to every subscriber, replacing the event it has not
+// written yet, if any.
+func (r *subscribers) send(event protocol.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = &event
+	for sub := range r.subs {
+		select {
+		case <-sub.events:
+		default:
+		}
+		sub.events <- event
+	}
+}
+
+// count returns the number of subscribers.
+func (r *subscribers) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.subs)
+}
+
+// broadcast sends an event to the subscribers whenever the state or the
+// status for bars changes. The status is looked at every second too, for
+// the time recorded so far.
+func (d *Daemon) broadcast() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		changed := d.state.Changed()
+		event := protocol.Event{
+			Type:   protocol.EventState,
+			State:  d.state.GetState(),
+			Status: d.state.GetWaybarStatus(),
+		}
+		if data, err := json.Marshal(event); err == nil && !bytes.Equal(data, last) {
+			d.subscribers.send(event)
+			last = data
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// serveEvents pushes the events to the client of conn until it hangs up or
+// the daemon stops.
+func (d *Daemon) serveEvents(conn net.Conn, encoder *json.Encoder) {
+	sub := d.subscribers.add()
+	defer d.subscribers.remove(sub)
+	slog.Debug("Client subscribed", "subscribers", d.subscribers.count())
+
+	// Subscribed clients send nothing more, reading only tells when they
+	// hang up
+	gone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case event := <-sub.events:
+			err := encoder.Encode(protocol.Response{
+				Success: true,
+				Version: protocol.Version,
+				Event:   &event,
+			})
+			if err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// setIcons replaces the icons of the status with those named in icons, if
+// any, keeping the default ones for the others.
+func (d *Daemon) setIcons(iconsMap map[string]string) {
+	if iconsMap == nil {
+		return
+	}
+	icons := state.DefaultIcons()
+	if idle, ok := iconsMap["Idle"]; ok {
+		icons.Idle = idle
+	}
+	if recording, ok := iconsMap["Recording"]; ok {
+		icons.Recording = recording
+	}
+	if paused, ok := iconsMap["Paused"]; ok {
+		icons.Paused = paused
+	}
+	if obsRecording, ok := iconsMap["ObsRecording"]; ok {
+		icons.ObsRecording = obsRecording
+	}
+	if obsPaused, ok := iconsMap["ObsPaused"]; ok {
+		icons.ObsPaused = obsPaused
+	}
+	if obsStreaming, ok := iconsMap["ObsStreaming"]; ok {
+		icons.ObsStreaming = obsStreaming
+	}
+	if countdown, ok := iconsMap["Countdown"]; ok {
+		icons.Countdown = countdown
+	}
+	d.state.SetIcons(icons)
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/commands"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+	"github.com/chmouel/sway-easyshot/pkg/rpc"
+
+	"google.golang.org/grpc"
+)
+
+// grpcStopTimeout is how long the calls being answered may take to finish
+// once the daemon stops.
+const grpcStopTimeout = time.Second
+
+// rpcServer serves the gRPC service of the daemon.
+type rpcServer struct {
+	rpc.UnimplementedDaemonServer
+	d *Daemon
+}
+
+// listenGRPC serves the gRPC service on the socket at path until the daemon
+// stops.
+func (d *Daemon) listenGRPC(path string) error {
+	// A socket left by a crashed daemon is stale
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to set gRPC socket permissions: %w", err)
+	}
+
+	d.grpcServer = grpc.NewServer()
+	rpc.RegisterDaemonServer(d.grpcServer, &rpcServer{d: d})
+	slog.Info("Serving gRPC", "socket", path)
+
+	go func() {
+		if err := d.grpcServer.Serve(listener); err != nil {
+			slog.Error("gRPC service stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// stopGRPC lets the calls being answered finish, such as the one asking
+// the daemon to stop, before closing the connections of those which do not
+// in time.
+func (d *Daemon) stopGRPC() {
+	stopped := make(chan struct{})
+	go func() {
+		d.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcStopTimeout):
+		d.grpcServer.Stop()
+	}
+}
+
+func (s *rpcServer) Execute(req *rpc.Request, stream grpc.ServerStreamingServer[rpc.Response]) error {
+	slog.Info("Received gRPC request", "action", req.GetAction())
+	request := protocol.NewRequest(req.GetAction(), nil)
+	request.Options = req.GetOptions().AsMap()
+
+	// Progress comes from the goroutines of the command, and no longer
+	// once the final response is sent
+	var mu sync.Mutex
+	done := false
+	// A client going away cancels the request, leaving alone what it
+	// started, such as a recording
+	ctx, cancel := commands.WithCancel(s.d.ctx)
+	defer cancel()
+	stop := context.AfterFunc(stream.Context(), cancel)
+	defer stop()
+	if req.GetProgress() {
+		ctx = commands.WithProgress(ctx, func(progress protocol.Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !done {
+				_ = stream.Send(&rpc.Response{Success: true, Progress: rpc.NewProgress(progress)})
+			}
+		})
+	}
+
+	resp := s.d.answer(ctx, request)
+	mu.Lock()
+	done = true
+	err := stream.Send(rpc.NewResponse(resp))
+	mu.Unlock()
+
+	// Stopping waits for this call to finish
+	if request.Action == "shutdown" && resp.Success {
+		slog.Info("Received shutdown request")
+		go s.d.Stop()
+	}
+	return err
+}
+
+func (s *rpcServer) Subscribe(req *rpc.SubscribeRequest, stream grpc.ServerStreamingServer[rpc.Event]) error {
+	s.d.setIcons(req.GetIcons())
+	sub := s.d.subscribers.add()
+	defer s.d.subscribers.remove(sub)
+
+	for {
+		select {
+		case event := <-sub.events:
+			if err := stream.Send(rpc.NewEvent(event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-s.d.ctx.Done():
+			return nil
+		}
+	}
+}
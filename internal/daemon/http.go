@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/commands"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// maxBodySize bounds the options posted to the HTTP API.
+const maxBodySize = 64 << 10
+
+// listenHTTP serves the HTTP API at address, on the loopback interface,
+// until the daemon stops. Clients send one of the tokens of the remote
+// clients, the loopback interface being shared by every local user.
+func (d *Daemon) listenHTTP(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	d.httpServer = &http.Server{
+		Handler:           localOnly(d.httpHandler()),
+		ReadHeaderTimeout: remoteReadTimeout,
+		ReadTimeout:       remoteReadTimeout,
+	}
+	slog.Info("Serving the HTTP API", "address", listener.Addr().String())
+
+	go func() {
+		if err := d.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP API stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// httpHandler routes the HTTP API: a few routes for the common actions,
+// and /actions/{action} for all of them, posting their options as JSON.
+func (d *Daemon) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		d.serveHTTP(w, r, protocol.NewRequest("status", nil))
+	})
+	mux.HandleFunc("GET /last", func(w http.ResponseWriter, r *http.Request) {
+		d.serveHTTP(w, r, protocol.NewRequest("last", protocol.LastOptions{Kind: r.URL.Query().Get("kind")}))
+	})
+	for route, action := range map[string]string{
+		"POST /capture/selection": "selection-file",
+		"POST /capture/window":    "current-window-file",
+		"POST /actions/{action}":  "",
+	} {
+		mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			name := action
+			if name == "" {
+				name = r.PathValue("action")
+			}
+			req := protocol.NewRequest(name, nil)
+			if err := decodeBody(r, &req.Options); err != nil {
+				writeHTTP(w, invalidOptions(err))
+				return
+			}
+			d.serveHTTP(w, r, req)
+		})
+	}
+	return mux
+}
+
+// serveHTTP answers req, received over HTTP as r, when the bearer token of
+// r allows it.
+func (d *Daemon) serveHTTP(w http.ResponseWriter, r *http.Request, req protocol.Request) {
+	if req.Action == "subscribe" {
+		writeHTTP(w, invalidOptions(errors.New("subscribe is only served on the socket")))
+		return
+	}
+	slog.Info("Received HTTP request", "action", req.Action)
+
+	req.Token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if resp, ok := d.authorize(req, r.RemoteAddr); !ok {
+		writeHTTP(w, resp)
+		return
+	}
+
+	// A client going away cancels the request, leaving alone what it
+	// started, such as a recording
+	ctx, cancel := commands.WithCancel(d.ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+
+	resp := d.answer(ctx, req)
+	writeHTTP(w, resp)
+
+	if req.Action == "shutdown" && resp.Success {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		slog.Info("Received shutdown request")
+		d.Stop()
+	}
+}
+
+// decodeBody decodes the JSON options posted in the body of r, if any.
+func decodeBody(r *http.Request, options *map[string]interface{}) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	if err := json.Unmarshal(data, options); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// writeHTTP writes resp as JSON, with the HTTP status matching why it
+// failed.
+func writeHTTP(w http.ResponseWriter, resp protocol.Response) {
+	resp.Version = protocol.Version
+	status := http.StatusOK
+	if !resp.Success {
+		switch resp.Code {
+		case protocol.CodeInvalid, protocol.CodeUnsupported:
+			status = http.StatusBadRequest
+		case protocol.CodeUnauthorized:
+			status = http.StatusUnauthorized
+		case protocol.CodeBusy, protocol.CodeCancelled:
+			status = http.StatusConflict
+		case protocol.CodeUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// localOnly refuses the requests of web pages, which browsers send with an
+// Origin, and those naming another host, which is how DNS rebinding would
+// get a page to reach the API.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		ip := net.ParseIP(host)
+		if r.Header.Get("Origin") != "" || (host != "localhost" && (ip == nil || !ip.IsLoopback())) {
+			http.Error(w, "only local clients may use the API", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// interactiveActions are the actions asking for a selection, a window or a
+// choice, which a mashed keybinding would otherwise start several times over.
+var interactiveActions = map[string]bool{
+	"current-window-clipboard": true,
+	"current-window-file":      true,
+	"window-file":              true,
+	"current-screen-clipboard": true,
+	"selection-file":           true,
+	"selection-edit":           true,
+	"selection-clipboard":      true,
+	"selection-multi":          true,
+	"scroll-capture":           true,
+	"compose":                  true,
+	"menu":                     true,
+	"gallery":                  true,
+	"action":                   true,
+	"ocr-selection":            true,
+	"alt-text-selection":       true,
+	"scan-qr":                  true,
+	"pick-palette":             true,
+	"movie-selection":          true,
+	"movie-screen":             true,
+	"movie-current-window":     true,
+	"movie-zoom":               true,
+	"toggle-record":            true,
+	"repeat-last":              true,
+}
+
+// inflight tracks the interactive actions being served, and how to cancel
+// them.
+type inflight struct {
+	mu      sync.Mutex
+	actions map[string]context.CancelFunc
+}
+
+// begin marks action as being served, cancelled by cancel, returning false
+// when it already is.
+func (f *inflight) begin(action string, cancel context.CancelFunc) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.actions[action]; ok {
+		return false
+	}
+	if f.actions == nil {
+		f.actions = map[string]context.CancelFunc{}
+	}
+	f.actions[action] = cancel
+	return true
+}
+
+// end marks action as served.
+func (f *inflight) end(action string) {
+	f.mu.Lock()
+	delete(f.actions, action)
+	f.mu.Unlock()
+}
+
+// cancel cancels the actions being served and returns their names.
+func (f *inflight) cancel() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.actions))
+	for action, cancel := range f.actions {
+		cancel()
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
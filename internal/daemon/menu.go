@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// menuEntry is an entry of the menu, running action when chosen if the
+// feature it requires, if any, is available.
+type menuEntry struct {
+	label    string
+	action   string
+	requires string
+}
+
+// captureMenu lists the captures and recordings which can be started.
+var captureMenu = []menuEntry{
+	{label: "Selection to clipboard", action: "selection-clipboard"},
+	{label: "Selection to file", action: "selection-file"},
+	{label: "Selection to editor", action: "selection-edit", requires: capability.Editor},
+	{label: "Several selections", action: "selection-multi"},
+	{label: "Window to clipboard", action: "current-window-clipboard"},
+	{label: "Window to file", action: "current-window-file"},
+	{label: "Screen to clipboard", action: "current-screen-clipboard"},
+	{label: "Scrolling capture", action: "scroll-capture", requires: capability.Scroll},
+	{label: "Text from selection", action: "ocr-selection", requires: capability.OCR},
+	{label: "Scan QR code", action: "scan-qr", requires: capability.Barcode},
+	{label: "Colour palette", action: "pick-palette"},
+	{label: "Record selection", action: "movie-selection"},
+	{label: "Record window", action: "movie-current-window"},
+	{label: "Record screen", action: "movie-screen"},
+	{label: "Start OBS recording", action: "obs-toggle-recording", requires: capability.OBS},
+	{label: "Gallery", action: "gallery"},
+	{label: "Undo", action: "undo"},
+}
+
+// menu offers the captures and recordings which can be started, or the
+// controls of the recording in progress, and runs the chosen one.
+func (d *Daemon) menu(ctx context.Context) protocol.Response {
+	if err := capability.Require(capability.Menu); err != nil {
+		return protocol.Response{Success: false, Message: err.Error()}
+	}
+
+	entries := d.menuEntries()
+	labels := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		labels = append(labels, entry.label)
+	}
+	choice, err := external.Wofi(ctx, "sway-easyshot", labels)
+	if err != nil || choice == "" {
+		return protocol.Response{Success: true, Message: "Nothing chosen", State: d.state.GetState()}
+	}
+
+	for _, entry := range entries {
+		if entry.label == choice {
+			return d.executeCommand(ctx, protocol.NewRequest(entry.action, nil))
+		}
+	}
+	return protocol.Response{Success: false, Message: "Unknown menu entry: " + choice}
+}
+
+// menuEntries returns the entries fitting the current state.
+func (d *Daemon) menuEntries() []menuEntry {
+	st := d.state.GetState()
+	switch {
+	case st.Recording:
+		entries := []menuEntry{{label: "Stop recording", action: "stop-recording"}}
+		if st.Paused {
+			return append(entries,
+				menuEntry{label: "Resume recording", action: "pause-recording"},
+				menuEntry{label: "Snapshot", action: "snapshot"})
+		}
+		return append(entries, menuEntry{label: "Pause recording", action: "pause-recording"})
+
+	case st.OBSRecording:
+		pause := "Pause OBS recording"
+		if st.OBSPaused {
+			pause = "Resume OBS recording"
+		}
+		return []menuEntry{
+			{label: "Stop OBS recording", action: "obs-toggle-recording"},
+			{label: pause, action: "obs-toggle-pause"},
+			{label: "OBS screenshot", action: "obs-screenshot"},
+		}
+	}
+
+	var entries []menuEntry
+	for _, entry := range captureMenu {
+		if entry.requires == "" || capability.Available(entry.requires) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/chmouel/sway-easyshot/internal/commands"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// turnKey marks the context of a request holding the turn of the capture
+// queue, so that the action it runs in turn, as the menu does, does not
+// queue behind itself.
+type turnKey struct{}
+
+// captureQueue runs the interactive actions one at a time, in the order they
+// were requested, so that two selectors never fight over the pointer.
+type captureQueue struct {
+	mu      sync.Mutex
+	tickets []*ticket
+}
+
+// ticket is the place of a request in the queue.
+type ticket struct {
+	// ready is closed once the request has its turn
+	ready chan struct{}
+	// moved is signalled when the request moves up the queue
+	moved chan struct{}
+}
+
+// join queues a request and returns its ticket, with the number of requests
+// ahead of it.
+func (q *captureQueue) join() (*ticket, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t := &ticket{ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	q.tickets = append(q.tickets, t)
+	if len(q.tickets) == 1 {
+		close(t.ready)
+	}
+	return t, len(q.tickets) - 1
+}
+
+// ahead returns the number of requests ahead of t.
+func (q *captureQueue) ahead(t *ticket) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Index(q.tickets, t)
+}
+
+// leave takes t out of the queue, once served or given up, and lets the
+// requests behind it move up.
+func (q *captureQueue) leave(t *ticket) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := slices.Index(q.tickets, t)
+	if i < 0 {
+		return
+	}
+	q.tickets = slices.Delete(q.tickets, i, i+1)
+	for j, behind := range q.tickets[i:] {
+		if i+j == 0 {
+			close(behind.ready)
+		}
+		select {
+		case behind.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// holdsTurn tells whether the request of ctx already has its turn.
+func holdsTurn(ctx context.Context) bool {
+	held, _ := ctx.Value(turnKey{}).(bool)
+	return held
+}
+
+// awaitTurn waits for the turn of t, reporting how many requests are ahead
+// of it as they are served, and fails with commands.ErrCancelled when the
+// request is cancelled meanwhile.
+func (d *Daemon) awaitTurn(ctx context.Context, t *ticket, ahead int) error {
+	wait, stop := commands.Waiting(ctx)
+	defer stop()
+	for {
+		commands.ReportProgress(ctx, protocol.StageQueued, queuedMessage(ahead), ahead)
+		select {
+		case <-t.ready:
+			return nil
+		case <-t.moved:
+			// The turn comes with the last move
+			if ahead = d.captures.ahead(t); ahead == 0 {
+				return nil
+			}
+		case <-wait.Done():
+			return commands.ErrCancelled
+		}
+	}
+}
+
+func queuedMessage(ahead int) string {
+	if ahead == 1 {
+		return "Waiting for 1 capture to finish"
+	}
+	return fmt.Sprintf("Waiting for %d captures to finish", ahead)
+}
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/config"
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// remoteReadTimeout is how long a remote client has to send its request,
+// which is read before its token can be checked.
+const remoteReadTimeout = 10 * time.Second
+
+// maxRemoteRequest bounds each request read from a remote client.
+const maxRemoteRequest = 64 << 10
+
+// statusActions only read the state, which every token may do.
+var statusActions = map[string]bool{
+	"ping":          true,
+	"health":        true,
+	"status":        true,
+	"waybar-status": true,
+	"subscribe":     true,
+	"version":       true,
+	"last":          true,
+	"history":       true,
+}
+
+// recordActions start or control a recording.
+var recordActions = map[string]bool{
+	"stop-recording":       true,
+	"pause-recording":      true,
+	"toggle-record":        true,
+	"retarget":             true,
+	"obs-toggle-recording": true,
+	"obs-toggle-pause":     true,
+	"obs-toggle-source":    true,
+}
+
+// screenshotActions take a screenshot, besides the capture actions which do
+// not record.
+var screenshotActions = map[string]bool{
+	"compose":        true,
+	"snapshot":       true,
+	"obs-screenshot": true,
+}
+
+// actionScopes returns the scopes any of which allows action, admin for the
+// actions changing the daemon or the captures already taken, or showing a
+// menu on the screen.
+func (d *Daemon) actionScopes(action string) []string {
+	if action == "repeat-last" {
+		action = d.state.GetLastRegionAction()
+	}
+	switch {
+	case statusActions[action]:
+		return []string{config.ScopeStatus}
+	case action == "cancel":
+		return []string{config.ScopeScreenshot, config.ScopeRecord}
+	case recordActions[action], captureActions[action] && strings.HasPrefix(action, "movie-"):
+		return []string{config.ScopeRecord}
+	case screenshotActions[action], captureActions[action]:
+		return []string{config.ScopeScreenshot}
+	default:
+		return []string{config.ScopeAdmin}
+	}
+}
+
+// authorize checks the token of req, received over TCP from the address
+// from, allows its action, returning the response refusing it otherwise.
+func (d *Daemon) authorize(req protocol.Request, from string) (protocol.Response, bool) {
+	token, ok := findToken(d.cfg.Load().Listen.Tokens, req.Token)
+	if !ok {
+		slog.Warn("Refused a request with an invalid token", "from", from, "action", req.Action)
+		return unauthorized("invalid token"), false
+	}
+	scopes := d.actionScopes(req.Action)
+	for _, scope := range scopes {
+		if token.Allows(scope) {
+			return protocol.Response{}, true
+		}
+	}
+	slog.Warn("Refused a request outside the scopes of its token", "from", from, "token", token.Name, "action", req.Action)
+	return unauthorized(fmt.Sprintf("%s needs the %s scope", req.Action, strings.Join(scopes, " or "))), false
+}
+
+// findToken returns the token whose secret is secret, comparing them in
+// constant time.
+func findToken(tokens []config.Token, secret string) (config.Token, bool) {
+	var found config.Token
+	ok := false
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token.Token), []byte(secret)) == 1 {
+			found, ok = token, true
+		}
+	}
+	return found, ok
+}
+
+func unauthorized(message string) protocol.Response {
+	return protocol.Response{Success: false, Message: message, Code: protocol.CodeUnauthorized}
+}
+
+// listenRemote opens the TCP endpoint at address and serves the clients of
+// other machines on it until the daemon stops.
+func (d *Daemon) listenRemote(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	d.remoteListener = listener
+	slog.Info("Listening for remote clients", "address", listener.Addr().String())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if d.ctx.Err() != nil {
+					return
+				}
+				slog.Error("Error accepting remote connection", "error", err)
+				continue
+			}
+			go d.handleConnection(conn, true)
+		}
+	}()
+	return nil
+}
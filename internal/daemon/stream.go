@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// idleTimeout is how long a connection kept alive waits for the next
+// request before being closed.
+const idleTimeout = 5 * time.Minute
+
+// keepAliveInterval is how often a request asking for progress is told it
+// is still being served, well within the default client timeout.
+const keepAliveInterval = 10 * time.Second
+
+// stream writes the responses to a request: the progress asked for, then the
+// final response. Operations outliving the request, such as a recording
+// stopping at its limit, may still report progress, which is dropped.
+type stream struct {
+	encoder *json.Encoder
+	id      string
+
+	mu   sync.Mutex
+	done bool
+}
+
+func newStream(encoder *json.Encoder, id string) *stream {
+	return &stream{encoder: encoder, id: id}
+}
+
+// progress sends p to the client, unless it already got its response.
+func (s *stream) progress(p protocol.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	_ = s.encoder.Encode(protocol.Response{
+		Success:  true,
+		Message:  p.Message,
+		Version:  protocol.Version,
+		ID:       s.id,
+		Progress: &p,
+	})
+}
+
+// keepAlive reports the request is still being served every interval,
+// until the returned function is called.
+func (s *stream) keepAlive(interval time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.progress(protocol.Progress{Stage: protocol.StageWorking, Message: "Still working"})
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// finish sends the final response.
+func (s *stream) finish(resp protocol.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	resp.Version = protocol.Version
+	resp.ID = s.id
+	return s.encoder.Encode(resp)
+}
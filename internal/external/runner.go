@@ -0,0 +1,88 @@
+package external
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/chmouel/sway-easyshot/internal/trace"
+)
+
+// Runner runs external commands. Every tool the handlers use runs through
+// the runner of their context, so that tests can fake grim, slurp,
+// wf-recorder and the others without a compositor.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+	Output(cmd *exec.Cmd) ([]byte, error)
+	CombinedOutput(cmd *exec.Cmd) ([]byte, error)
+	Start(cmd *exec.Cmd) error
+}
+
+// ExecRunner runs commands for real, recording them in the trace.
+type ExecRunner struct{}
+
+// Run runs cmd and waits for it to finish.
+func (ExecRunner) Run(cmd *exec.Cmd) error { return trace.Run(cmd) }
+
+// Output runs cmd and returns its standard output.
+func (ExecRunner) Output(cmd *exec.Cmd) ([]byte, error) { return trace.Output(cmd) }
+
+// CombinedOutput runs cmd and returns its standard output and error.
+func (ExecRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return trace.CombinedOutput(cmd) }
+
+// Start starts cmd without waiting for it.
+func (ExecRunner) Start(cmd *exec.Cmd) error { return trace.Start(cmd) }
+
+// untracedRunner runs commands for real, leaving them out of the trace.
+type untracedRunner struct{}
+
+func (untracedRunner) Run(cmd *exec.Cmd) error                      { return cmd.Run() }
+func (untracedRunner) Output(cmd *exec.Cmd) ([]byte, error)         { return cmd.Output() }
+func (untracedRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) { return cmd.CombinedOutput() }
+func (untracedRunner) Start(cmd *exec.Cmd) error                    { return cmd.Start() }
+
+// runnerKey holds the runner of a context.
+type runnerKey struct{}
+
+// WithRunner returns a context whose commands run with r.
+func WithRunner(ctx context.Context, r Runner) context.Context {
+	return context.WithValue(ctx, runnerKey{}, r)
+}
+
+// RunnerFrom returns the runner of ctx, an ExecRunner unless WithRunner set
+// another.
+func RunnerFrom(ctx context.Context) Runner {
+	if r, ok := ctx.Value(runnerKey{}).(Runner); ok {
+		return r
+	}
+	return ExecRunner{}
+}
+
+// untraced returns ctx whose commands are left out of the trace, unless
+// WithRunner set a runner of its own.
+func untraced(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(runnerKey{}).(Runner); ok {
+		return ctx
+	}
+	return WithRunner(ctx, untracedRunner{})
+}
+
+// Run runs cmd with the runner of ctx and waits for it to finish.
+func Run(ctx context.Context, cmd *exec.Cmd) error {
+	return RunnerFrom(ctx).Run(cmd)
+}
+
+// Output runs cmd with the runner of ctx and returns its standard output.
+func Output(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return RunnerFrom(ctx).Output(cmd)
+}
+
+// CombinedOutput runs cmd with the runner of ctx and returns its standard
+// output and error.
+func CombinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return RunnerFrom(ctx).CombinedOutput(cmd)
+}
+
+// Start starts cmd with the runner of ctx without waiting for it.
+func Start(ctx context.Context, cmd *exec.Cmd) error {
+	return RunnerFrom(ctx).Start(cmd)
+}
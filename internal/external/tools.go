@@ -6,57 +6,15 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
-	"time"
 )
 
-// Grim captures a screenshot
-func Grim(ctx context.Context, geometry, output, filename string) ([]byte, error) {
-	args := []string{"-t", "png"}
-
-	if geometry != "" {
-		args = append(args, "-g", geometry)
-	}
-	if output != "" {
-		args = append(args, "-o", output)
-	}
-
-	if filename == "" {
-		args = append(args, "-")
-	} else {
-		args = append(args, filename)
-	}
-
-	cmd := exec.CommandContext(ctx, "grim", args...)
-
-	if filename == "" {
-		return cmd.Output()
-	}
-
-	return nil, cmd.Run()
-}
-
-// Slurp performs interactive region selection
-func Slurp(ctx context.Context, color string) (string, error) {
-	args := []string{}
-	if color != "" {
-		args = append(args, "-c", color)
-	}
-
-	cmd := exec.CommandContext(ctx, "slurp", args...) //nolint:gosec
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
 // WlCopy copies data to clipboard
 func WlCopy(ctx context.Context, data []byte, mimeType string) error {
 	cmd := exec.CommandContext(ctx, "wl-copy", "-t", mimeType)
 	cmd.Stdin = bytes.NewReader(data)
-	return cmd.Run()
+	return Run(ctx, cmd)
 }
 
 // WlCopyText copies text to clipboard
@@ -64,34 +22,29 @@ func WlCopyText(ctx context.Context, text string) error {
 	return WlCopy(ctx, []byte(text), "text/plain")
 }
 
+// WlClear clears the clipboard
+func WlClear(ctx context.Context) error {
+	return Run(ctx, exec.CommandContext(ctx, "wl-copy", "--clear"))
+}
+
 // WlPaste pastes from clipboard
 func WlPaste(ctx context.Context, mimeType string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, "wl-paste", "--type", mimeType)
-	return cmd.Output()
+	return Output(ctx, cmd)
 }
 
-// StartWfRecorder starts video recording
-func StartWfRecorder(ctx context.Context, geometry, output, filename string) (*exec.Cmd, error) {
-	args := []string{}
-
-	if geometry != "" {
-		args = append(args, "-g", geometry)
-	}
-	if output != "" {
-		args = append(args, "-o", output)
-	}
-
-	args = append(args, "-f", filename)
-
-	cmd := exec.CommandContext(ctx, "wf-recorder", args...) //nolint:gosec
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
+// WlPasteTypes lists the MIME types the clipboard content is offered as,
+// none when the clipboard is empty.
+func WlPasteTypes(ctx context.Context) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "wl-paste", "--list-types")
+	output, err := CombinedOutput(ctx, cmd)
+	if err != nil {
+		if bytes.Contains(output, []byte("Nothing is copied")) {
+			return nil, nil
+		}
 		return nil, err
 	}
-
-	return cmd, nil
+	return strings.Fields(string(output)), nil
 }
 
 // Satty opens the satty image editor
@@ -108,7 +61,7 @@ func Satty(ctx context.Context, inputFile, outputFile string, earlyExit bool) er
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return Run(ctx, cmd)
 }
 
 // Zenity shows a text entry dialog
@@ -120,7 +73,7 @@ func Zenity(ctx context.Context, text, entryText string) (string, error) {
 	}
 
 	cmd := exec.CommandContext(ctx, "zenity", args...) //nolint:gosec
-	output, err := cmd.Output()
+	output, err := Output(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
@@ -128,6 +81,33 @@ func Zenity(ctx context.Context, text, entryText string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// XpropFrameExtents returns the invisible margins an X11 window draws around
+// itself, as advertised in its _GTK_FRAME_EXTENTS property
+func XpropFrameExtents(ctx context.Context, windowID int64) (left, right, top, bottom int, err error) {
+	cmd := exec.CommandContext(ctx, "xprop", "-id", strconv.FormatInt(windowID, 10), "_GTK_FRAME_EXTENTS") //nolint:gosec
+	output, err := Output(ctx, cmd)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	// _GTK_FRAME_EXTENTS(CARDINAL) = 26, 26, 23, 29
+	_, values, ok := strings.Cut(string(output), "=")
+	if !ok {
+		return 0, 0, 0, 0, fmt.Errorf("no frame extents set")
+	}
+	var extents [4]int
+	if _, err := fmt.Sscanf(strings.TrimSpace(values), "%d, %d, %d, %d", &extents[0], &extents[1], &extents[2], &extents[3]); err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("failed to parse frame extents: %w", err)
+	}
+	return extents[0], extents[1], extents[2], extents[3], nil
+}
+
+// ZenityQuestion asks a yes/no question, reporting whether it was accepted
+func ZenityQuestion(ctx context.Context, text string) bool {
+	cmd := exec.CommandContext(ctx, "zenity", "--question", "--text", text) //nolint:gosec
+	return Run(ctx, cmd) == nil
+}
+
 // AIChat uses aichat to generate a filename
 func AIChat(ctx context.Context, model, imagePath, prompt string) (string, error) {
 	args := []string{
@@ -137,7 +117,7 @@ func AIChat(ctx context.Context, model, imagePath, prompt string) (string, error
 	}
 
 	cmd := exec.CommandContext(ctx, "aichat", args...) //nolint:gosec
-	output, err := cmd.Output()
+	output, err := Output(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
@@ -145,43 +125,68 @@ func AIChat(ctx context.Context, model, imagePath, prompt string) (string, error
 	return strings.TrimSpace(string(output)), nil
 }
 
-// Ffmpeg converts video files
-func Ffmpeg(ctx context.Context, inputFile, outputFile string) error {
-	args := []string{
-		"-i", fmt.Sprintf("file:%s", inputFile),
-		"-vf", "scale='min(1920,iw)':-2",
-		"-c:v", "libx264",
-		"-preset", "veryfast",
-		"-crf", "23",
-		"-pix_fmt", "yuv420p",
-		"-movflags", "+faststart",
-		outputFile,
+// Encrypt encrypts data to recipient with age or gpg, named by tool
+func Encrypt(ctx context.Context, tool, recipient string, data []byte) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch tool {
+	case "age":
+		cmd = exec.CommandContext(ctx, "age", "--recipient", recipient)
+	case "gpg":
+		cmd = exec.CommandContext(ctx, "gpg", "--batch", "--yes", "--encrypt", "--recipient", recipient, "--output", "-")
+	default:
+		return nil, fmt.Errorf("unknown encryption tool: %s", tool)
 	}
+	cmd.Stdin = bytes.NewReader(data)
+	return Output(ctx, cmd)
+}
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...) //nolint:gosec
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// Tesseract recognises the text of a PNG image, lang being a tesseract
+// language specification such as "eng+fra" (empty for the default)
+func Tesseract(ctx context.Context, data []byte, lang string) (string, error) {
+	args := []string{"stdin", "stdout"}
+	if lang != "" {
+		args = append(args, "-l", lang)
+	}
+
+	cmd := exec.CommandContext(ctx, "tesseract", args...) //nolint:gosec
+	cmd.Stdin = bytes.NewReader(data)
+	output, err := Output(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
 }
 
-// OBSCli executes obs-cli commands
-func OBSCli(ctx context.Context, args ...string) (string, error) {
-	// Get password from pass
-	passCmd := exec.CommandContext(ctx, "pass", "show", "obs/password")
-	password, err := passCmd.Output()
+// ZbarImg decodes the QR codes and barcodes found in an image file, one
+// payload per entry
+func ZbarImg(ctx context.Context, imagePath string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "zbarimg", "--quiet", "--raw", imagePath) //nolint:gosec
+	output, err := Output(ctx, cmd)
 	if err != nil {
-		return "", fmt.Errorf("failed to get OBS password: %w", err)
+		return nil, err
 	}
 
-	cmdArgs := []string{
-		"--host", "127.0.0.1",
-		"-p", "4444",
-		"--password", strings.TrimSpace(string(password)),
+	var payloads []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			payloads = append(payloads, line)
+		}
 	}
-	cmdArgs = append(cmdArgs, args...)
+	return payloads, nil
+}
 
-	cmd := exec.CommandContext(ctx, "obs-cli", cmdArgs...) //nolint:gosec
-	output, err := cmd.Output()
+// XdgOpen opens a file or URL with the default application
+func XdgOpen(ctx context.Context, target string) error {
+	cmd := exec.CommandContext(ctx, "xdg-open", target) //nolint:gosec
+	return Start(ctx, cmd)
+}
+
+// AIChatText sends text along with a prompt to aichat and returns its answer
+func AIChatText(ctx context.Context, model, prompt, text string) (string, error) {
+	cmd := exec.CommandContext(ctx, "aichat", "--model", model, prompt) //nolint:gosec
+	cmd.Stdin = strings.NewReader(text)
+	output, err := Output(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
@@ -189,17 +194,36 @@ func OBSCli(ctx context.Context, args ...string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// CursorPosition returns the position of the pointer in the layout. It is
+// not traced as it gets sampled several times a second while recording.
+func CursorPosition(ctx context.Context) (x, y int, err error) {
+	cmd := exec.CommandContext(ctx, "wl-find-cursor", "-p")
+	output, err := Output(untraced(ctx), cmd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &x, &y); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse cursor position: %w", err)
+	}
+	return x, y, nil
+}
+
 // Wofi shows a selection menu
 func Wofi(ctx context.Context, prompt string, options []string) (string, error) {
-	args := []string{
-		"--dmenu",
-		"--prompt", prompt,
-	}
+	return wofi(ctx, options, "--dmenu", "--prompt", prompt)
+}
+
+// WofiImages shows a selection menu whose options may start with an image,
+// as "img:PATH:text:LABEL", shown size pixels wide.
+func WofiImages(ctx context.Context, prompt string, options []string, size int) (string, error) {
+	return wofi(ctx, options, "--dmenu", "--prompt", prompt, "--allow-images", "--define", fmt.Sprintf("image_size=%d", size))
+}
 
+func wofi(ctx context.Context, options []string, args ...string) (string, error) {
 	cmd := exec.CommandContext(ctx, "wofi", args...) //nolint:gosec
 	cmd.Stdin = strings.NewReader(strings.Join(options, "\n"))
 
-	output, err := cmd.Output()
+	output, err := Output(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
@@ -207,22 +231,42 @@ func Wofi(ctx context.Context, prompt string, options []string) (string, error)
 	return strings.TrimSpace(string(output)), nil
 }
 
-// Nautilus opens a file in nautilus
-func Nautilus(ctx context.Context, fileURI string) error {
-	cmd := exec.CommandContext(ctx, "nautilus", fileURI)
-	return cmd.Start()
+// Command runs an arbitrary command and returns its trimmed standard output
+func Command(ctx context.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec
+	output, err := Output(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
 }
 
-// CleanupOldFiles removes files older than the specified duration
-func CleanupOldFiles(ctx context.Context, directory string, olderThan time.Duration) error {
-	beforeTime := fmt.Sprintf("%dd", int(olderThan.Hours()/24))
+// OpenWith opens a file with an application command line, without waiting
+// for it to exit
+func OpenWith(ctx context.Context, command []string, file string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	args := append(append([]string{}, command[1:]...), file)
+	cmd := exec.CommandContext(ctx, command[0], args...) //nolint:gosec
+	return Start(ctx, cmd)
+}
 
-	cmd := exec.CommandContext(ctx, "fd", //nolint:gosec
-		"-t", "f",
-		"--full-path", directory,
-		"--changed-before", beforeTime,
-		"--exec-batch", "rm", "-vf",
-	)
+// QREncode renders text as a QR code in the PNG file output, with modules
+// of size pixels.
+func QREncode(ctx context.Context, text, output string, size int) error {
+	cmd := exec.CommandContext(ctx, "qrencode", "-s", strconv.Itoa(size), "-m", "2", "-o", output, text) //nolint:gosec
+	return Run(ctx, cmd)
+}
 
-	return cmd.Run()
+// Nautilus opens a file in nautilus
+func Nautilus(ctx context.Context, fileURI string) error {
+	cmd := exec.CommandContext(ctx, "nautilus", fileURI)
+	return Start(ctx, cmd)
 }
@@ -0,0 +1,37 @@
+package external
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "oxipng -o 4  file.png", want: []string{"oxipng", "-o", "4", "file.png"}},
+		{line: `cp a "my dir/b"`, want: []string{"cp", "a", "my dir/b"}},
+		{line: `echo 'it''s' "a \"quote\"" \$HOME`, want: []string{"echo", "its", `a "quote"`, "$HOME"}},
+		{line: `echo "a\b" a\ b ''`, want: []string{"echo", `a\b`, "a b", ""}},
+		{line: "  ", want: nil},
+	}
+	for _, tt := range tests {
+		got, err := SplitWords(tt.line)
+		if err != nil {
+			t.Errorf("SplitWords(%q): %v", tt.line, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SplitWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitWordsUnterminated(t *testing.T) {
+	for _, line := range []string{`echo "a`, `echo 'a`, `echo a\`} {
+		if got, err := SplitWords(line); err == nil {
+			t.Errorf("SplitWords(%q) = %q, want an error", line, got)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/tags"
+)
+
+// Kinds of captures.
+const (
+	Screenshot = "screenshot"
+	Recording  = "recording"
+)
+
+// Entry describes a capture. File is empty for captures which only went to
+// the clipboard.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Command  string    `json:"command,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Geometry string    `json:"geometry,omitempty"`
+	Output   string    `json:"output,omitempty"`
+	tags.Tags
+}
+
+// Query selects entries: of a kind when set, taken since a time when set,
+// saved to a file when Saved is set, and at most Limit of them when
+// positive.
+type Query struct {
+	Kind  string
+	Since time.Time
+	Saved bool
+	Limit int
+}
+
+// History keeps the most recent captures as JSON lines in a file. A nil
+// history records nothing.
+type History struct {
+	file string
+	size int
+
+	mu sync.Mutex
+}
+
+// New returns the history kept in file, holding at most size entries.
+func New(file string, size int) *History {
+	return &History{file: file, size: size}
+}
+
+// Add records an entry, dropping the oldest ones beyond the history size.
+func (h *History) Add(e Entry) error {
+	if h == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.load()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+	if len(entries) > h.size {
+		entries = entries[len(entries)-h.size:]
+	}
+	return h.save(entries)
+}
+
+// Remove forgets the entry recorded as e.
+func (h *History) Remove(e Entry) error {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.load()
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(entries, func(other Entry) bool {
+		return other.Time.Equal(e.Time) && other.Kind == e.Kind && other.File == e.File
+	})
+	return h.save(kept)
+}
+
+// List returns the entries matching q, most recent first.
+func (h *History) List(q Query) ([]Entry, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	entries, err := h.load()
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if (q.Kind != "" && e.Kind != q.Kind) || e.Time.Before(q.Since) || (q.Saved && e.File == "") {
+			continue
+		}
+		matches = append(matches, e)
+		if q.Limit > 0 && len(matches) == q.Limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// SidecarExt is appended to the name of a capture to name its sidecar.
+const SidecarExt = ".json"
+
+// WriteSidecar writes e as JSON next to its file, for tools to find what a
+// capture shows without the history.
+func WriteSidecar(e Entry) error {
+	if e.File == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(e.File+SidecarExt, append(data, '\n'), 0o600)
+}
+
+// load reads the entries, oldest first, skipping lines which cannot be
+// parsed rather than losing the whole history.
+func (h *History) load() ([]Entry, error) {
+	data, err := os.ReadFile(h.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// save replaces the history file with entries, atomically so that a crash
+// leaves the previous history rather than a truncated one.
+func (h *History) save(entries []Entry) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.file), 0o700); err != nil {
+		return err
+	}
+	tmp := h.file + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.file)
+}
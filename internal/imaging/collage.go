@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// labelScale enlarges the bitmap font of collage labels, which would be
+// hard to read next to full resolution captures otherwise.
+const labelScale = 2
+
+// Tile is an image of a collage and the label shown above it.
+type Tile struct {
+	Image image.Image
+	Label string
+}
+
+// Collage lays tiles out in a grid of columns, a single row when columns is
+// not positive, separated by gap pixels on a solid background. Each tile is
+// centred in its cell under its label, cells being as large as the largest
+// tile.
+func Collage(tiles []Tile, columns, gap int, background color.NRGBA) *image.NRGBA {
+	if columns <= 0 || columns > len(tiles) {
+		columns = len(tiles)
+	}
+	rows := (len(tiles) + columns - 1) / columns
+
+	face := basicfont.Face7x13
+	labelHeight := 0
+	cellWidth, cellHeight := 0, 0
+	for _, t := range tiles {
+		b := t.Image.Bounds()
+		cellWidth = max(cellWidth, b.Dx())
+		cellHeight = max(cellHeight, b.Dy())
+		if t.Label != "" {
+			labelHeight = face.Height*labelScale + gap/2
+		}
+	}
+	cellHeight += labelHeight
+
+	width := columns*cellWidth + (columns+1)*gap
+	height := rows*cellHeight + (rows+1)*gap
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(out, out.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	ink := color.NRGBA{A: 0xff}
+	if luminance(background) < 128 {
+		ink = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	}
+
+	for i, t := range tiles {
+		x := gap + (i%columns)*(cellWidth+gap)
+		y := gap + (i/columns)*(cellHeight+gap)
+		if t.Label != "" {
+			drawLabel(out, t.Label, x, y, cellWidth, ink)
+		}
+
+		b := t.Image.Bounds()
+		left := x + (cellWidth-b.Dx())/2
+		top := y + labelHeight
+		draw.Draw(out, image.Rect(left, top, left+b.Dx(), top+b.Dy()), t.Image, b.Min, draw.Over)
+	}
+
+	return out
+}
+
+// drawLabel draws text centred in a cell of the given width at x,y, cut to
+// fit it.
+func drawLabel(dst *image.NRGBA, text string, x, y, width int, ink color.NRGBA) {
+	face := basicfont.Face7x13
+	runes := []rune(text)
+	if fit := width / (face.Advance * labelScale); len(runes) > fit {
+		runes = append(runes[:max(fit-3, 0)], []rune("...")...)
+	}
+	text = string(runes)
+
+	// Render at the font size, then enlarge without smoothing to keep the
+	// bitmap glyphs crisp
+	small := image.NewNRGBA(image.Rect(0, 0, len(runes)*face.Advance, face.Height))
+	d := font.Drawer{
+		Dst:  small,
+		Src:  image.NewUniform(ink),
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	d.DrawString(text)
+
+	left := x + (width-small.Bounds().Dx()*labelScale)/2
+	for sy := 0; sy < small.Bounds().Dy(); sy++ {
+		for sx := 0; sx < small.Bounds().Dx(); sx++ {
+			c := small.NRGBAAt(sx, sy)
+			if c.A == 0 {
+				continue
+			}
+			r := image.Rect(left+sx*labelScale, y+sy*labelScale, left+(sx+1)*labelScale, y+(sy+1)*labelScale)
+			draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Over)
+		}
+	}
+}
+
+// luminance returns the perceived brightness of a colour, from 0 to 255.
+func luminance(c color.NRGBA) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
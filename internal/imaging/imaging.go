@@ -0,0 +1,167 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // decoding of captures given as JPEG files
+	"image/png"
+	"math"
+)
+
+// Decode decodes PNG (or JPEG) data into an image.
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// EncodePNG encodes an image as PNG data, keeping any alpha channel.
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DifferenceMatte reconstructs an image with its alpha channel from two
+// captures of the same region, one composited over black and one over white.
+func DifferenceMatte(onBlack, onWhite image.Image) (*image.NRGBA, error) {
+	bounds := onBlack.Bounds()
+	if bounds.Dx() != onWhite.Bounds().Dx() || bounds.Dy() != onWhite.Bounds().Dy() {
+		return nil, fmt.Errorf("captures differ in size: %v and %v", bounds, onWhite.Bounds())
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	wb := onWhite.Bounds()
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			b := color.NRGBAModel.Convert(onBlack.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			w := color.NRGBAModel.Convert(onWhite.At(wb.Min.X+x, wb.Min.Y+y)).(color.NRGBA)
+
+			// The amount of background showing through is the difference
+			// between both captures, averaged across channels.
+			diff := (int(w.R) - int(b.R) + int(w.G) - int(b.G) + int(w.B) - int(b.B)) / 3
+			alpha := clamp(255 - diff)
+			if alpha == 0 {
+				out.SetNRGBA(x, y, color.NRGBA{})
+				continue
+			}
+
+			out.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(clamp(int(b.R) * 255 / alpha)),
+				G: uint8(clamp(int(b.G) * 255 / alpha)),
+				B: uint8(clamp(int(b.B) * 255 / alpha)),
+				A: uint8(alpha),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+func clamp(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// SideBySide lays images out in a single row separated by gap pixels on a
+// transparent background, aligning them to the top.
+func SideBySide(images []image.Image, gap int) *image.NRGBA {
+	width, height := 0, 0
+	for i, img := range images {
+		if i > 0 {
+			width += gap
+		}
+		width += img.Bounds().Dx()
+		height = max(height, img.Bounds().Dy())
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	x := 0
+	for _, img := range images {
+		b := img.Bounds()
+		draw.Draw(out, image.Rect(x, 0, x+b.Dx(), b.Dy()), img, b.Min, draw.Src)
+		x += b.Dx() + gap
+	}
+
+	return out
+}
+
+// Thumbnail scales an image down so that it fits in a size×size square,
+// averaging the source pixels covered by each thumbnail pixel.
+func Thumbnail(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scale := math.Max(float64(w)/float64(size), float64(h)/float64(size))
+	if scale < 1 {
+		scale = 1
+	}
+	tw, th := max(int(float64(w)/scale), 1), max(int(float64(h)/scale), 1)
+
+	out := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, max((ty+1)*h/th, ty*h/th+1)
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, max((tx+1)*w/tw, tx*w/tw+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+					r += int(c.R)
+					g += int(c.G)
+					b += int(c.B)
+					a += int(c.A)
+					n++
+				}
+			}
+			out.SetNRGBA(tx, ty, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+
+	return out
+}
+
+// Pixelate returns a copy of img with the area r (in image coordinates)
+// replaced by blocks of block×block pixels of their average colour.
+func Pixelate(img image.Image, r image.Rectangle, block int) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+
+	r = r.Intersect(out.Bounds())
+	block = max(block, 1)
+	for by := r.Min.Y; by < r.Max.Y; by += block {
+		for bx := r.Min.X; bx < r.Max.X; bx += block {
+			cell := image.Rect(bx, by, min(bx+block, r.Max.X), min(by+block, r.Max.Y))
+
+			var red, green, blue, alpha, n int
+			for y := cell.Min.Y; y < cell.Max.Y; y++ {
+				for x := cell.Min.X; x < cell.Max.X; x++ {
+					c := out.NRGBAAt(x, y)
+					red += int(c.R)
+					green += int(c.G)
+					blue += int(c.B)
+					alpha += int(c.A)
+					n++
+				}
+			}
+			avg := color.NRGBA{R: uint8(red / n), G: uint8(green / n), B: uint8(blue / n), A: uint8(alpha / n)}
+			draw.Draw(out, cell, &image.Uniform{C: avg}, image.Point{}, draw.Src)
+		}
+	}
+
+	return out
+}
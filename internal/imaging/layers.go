@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// oraThumbnailSize is the largest side of the thumbnail stored in
+// OpenRaster files.
+const oraThumbnailSize = 256
+
+// Layer is a layer of a layered image, placed at X,Y on the canvas. A nil
+// Image is an empty layer covering the canvas, ready to draw on.
+type Layer struct {
+	Name  string
+	Image image.Image
+	X, Y  int
+}
+
+// image returns the layer image, an empty one of the canvas size for empty
+// layers.
+func (l Layer) image(width, height int) image.Image {
+	if l.Image == nil {
+		return image.NewNRGBA(image.Rect(0, 0, width, height))
+	}
+	return l.Image
+}
+
+// Flatten composes layers, given from the bottom up, on a transparent canvas.
+func Flatten(width, height int, layers []Layer) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for _, l := range layers {
+		if l.Image == nil {
+			continue
+		}
+		b := l.Image.Bounds()
+		draw.Draw(out, image.Rect(l.X, l.Y, l.X+b.Dx(), l.Y+b.Dy()), l.Image, b.Min, draw.Over)
+	}
+	return out
+}
+
+// WriteORA writes layers, given from the bottom up, as an OpenRaster file
+// which GIMP, Krita and MyPaint open with their layers.
+func WriteORA(w io.Writer, width, height int, layers []Layer) error {
+	z := zip.NewWriter(w)
+
+	// The mimetype must come first and uncompressed for the file to be
+	// recognised
+	mimetype, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "image/openraster"); err != nil {
+		return err
+	}
+
+	var stack bytes.Buffer
+	fmt.Fprintf(&stack, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<image version=\"0.0.3\" w=\"%d\" h=\"%d\">\n <stack>\n", width, height)
+	// The stack lists layers from the top down
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		src := fmt.Sprintf("data/layer%d.png", i)
+		if err := writeZipPNG(z, src, l.image(width, height)); err != nil {
+			return err
+		}
+		fmt.Fprintf(&stack, "  <layer name=\"%s\" src=\"%s\" x=\"%d\" y=\"%d\" />\n", escapeXML(l.Name), src, l.X, l.Y)
+	}
+	stack.WriteString(" </stack>\n</image>\n")
+
+	f, err := z.Create("stack.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(stack.Bytes()); err != nil {
+		return err
+	}
+
+	merged := Flatten(width, height, layers)
+	if err := writeZipPNG(z, "mergedimage.png", merged); err != nil {
+		return err
+	}
+	if err := writeZipPNG(z, "Thumbnails/thumbnail.png", Thumbnail(merged, oraThumbnailSize)); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// WriteSVG writes layers, given from the bottom up, as an SVG document whose
+// groups Inkscape shows as layers.
+func WriteSVG(w io.Writer, width, height int, layers []Layer) error {
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" "+
+		"xmlns:inkscape=\"http://www.inkscape.org/namespaces/inkscape\" "+
+		"width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+
+	for i, l := range layers {
+		fmt.Fprintf(&doc, " <g id=\"layer%d\" inkscape:groupmode=\"layer\" inkscape:label=\"%s\">\n", i, escapeXML(l.Name))
+		if l.Image != nil {
+			data, err := EncodePNG(l.Image)
+			if err != nil {
+				return err
+			}
+			b := l.Image.Bounds()
+			fmt.Fprintf(&doc, "  <image x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" xlink:href=\"data:image/png;base64,%s\" />\n",
+				l.X, l.Y, b.Dx(), b.Dy(), base64.StdEncoding.EncodeToString(data))
+		}
+		doc.WriteString(" </g>\n")
+	}
+	doc.WriteString("</svg>\n")
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+func writeZipPNG(z *zip.Writer, name string, img image.Image) error {
+	data, err := EncodePNG(img)
+	if err != nil {
+		return err
+	}
+	f, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"slices"
+)
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// EmbedText returns PNG data with text chunks holding the values of text,
+// keyed by their keyword, placed after the header. ASCII values go in tEXt
+// chunks, which every tool reads, others in UTF-8 iTXt ones. Empty values
+// are skipped.
+func EmbedText(data []byte, text map[string]string) ([]byte, error) {
+	// The header chunk is 13 bytes of data with its length, type and CRC
+	headerEnd := len(pngSignature) + 8 + 13 + 4
+	if len(data) < headerEnd || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("not a PNG image")
+	}
+
+	keywords := make([]string, 0, len(text))
+	for keyword, value := range text {
+		if value != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	slices.Sort(keywords)
+
+	var buf bytes.Buffer
+	buf.Write(data[:headerEnd])
+	for _, keyword := range keywords {
+		if len(keyword) == 0 || len(keyword) > 79 {
+			return nil, fmt.Errorf("invalid PNG text keyword %q", keyword)
+		}
+		value := text[keyword]
+		if isASCII(value) {
+			writeChunk(&buf, "tEXt", []byte(keyword+"\x00"+value))
+			continue
+		}
+		// Uncompressed, with neither language nor translated keyword
+		writeChunk(&buf, "iTXt", []byte(keyword+"\x00\x00\x00\x00\x00"+value))
+	}
+	buf.Write(data[headerEnd:])
+	return buf.Bytes(), nil
+}
+
+// writeChunk writes a PNG chunk of type kind holding data.
+func writeChunk(buf *bytes.Buffer, kind string, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data))) //nolint:gosec
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write([]byte(kind))
+	_, _ = crc.Write(data)
+	buf.WriteString(kind)
+	buf.Write(data)
+	_ = binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+// isASCII reports whether s holds printable ASCII text only, which is valid
+// Latin-1 as tEXt chunks require.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < 0x20 && s[i] != '\n') || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Palette extracts up to n dominant colours of an image using median cut
+// quantisation, most represented colour first.
+func Palette(img image.Image, n int) []color.NRGBA {
+	bounds := img.Bounds()
+	pixels := make([]color.NRGBA, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			pixels = append(pixels, c)
+		}
+	}
+	if len(pixels) == 0 || n <= 0 {
+		return nil
+	}
+
+	boxes := [][]color.NRGBA{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest channel range
+		widest, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			c, r := widestChannel(box)
+			if r > spread {
+				widest, channel, spread = i, c, r
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.Slice(box, func(i, j int) bool {
+			return channelValue(box[i], channel) < channelValue(box[j], channel)
+		})
+		mid := len(box) / 2
+		boxes[widest] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool { return len(boxes[i]) > len(boxes[j]) })
+
+	colors := make([]color.NRGBA, 0, len(boxes))
+	for _, box := range boxes {
+		colors = append(colors, average(box))
+	}
+	return colors
+}
+
+// Hex formats a colour as #rrggbb.
+func Hex(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// PaletteStrip renders colours as a row of square swatches.
+func PaletteStrip(colors []color.NRGBA, size int) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, size*len(colors), size))
+	for i, c := range colors {
+		draw.Draw(out, image.Rect(i*size, 0, (i+1)*size, size), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return out
+}
+
+func widestChannel(box []color.NRGBA) (channel, spread int) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range box {
+			v := channelValue(c, ch)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi-lo > spread {
+			channel, spread = ch, hi-lo
+		}
+	}
+	return channel, spread
+}
+
+func channelValue(c color.NRGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	default:
+		return int(c.B)
+	}
+}
+
+func average(box []color.NRGBA) color.NRGBA {
+	var r, g, b int
+	for _, c := range box {
+		r += int(c.R)
+		g += int(c.G)
+		b += int(c.B)
+	}
+	n := len(box)
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255}
+}
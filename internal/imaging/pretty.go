@@ -0,0 +1,170 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Style describes how Beautify presents an image.
+type Style struct {
+	// Padding is the space around the image, in pixels.
+	Padding int
+	// Radius rounds the corners of the image.
+	Radius int
+	// Shadow is the blur radius of the drop shadow, 0 for none.
+	Shadow int
+	// From and To are the colours of the diagonal background gradient,
+	// equal for a solid background.
+	From color.NRGBA
+	To   color.NRGBA
+}
+
+// shadowOpacity is the opacity of the drop shadow under the image.
+const shadowOpacity = 0.45
+
+// Beautify lays an image on a background with padding, rounding its corners
+// and casting a soft shadow under it.
+func Beautify(img image.Image, style Style) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pad := max(style.Padding, 0)
+	width, height := w+2*pad, h+2*pad
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	// Background gradient from the top left to the bottom right corner
+	span := float64(max(width+height-2, 1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out.SetNRGBA(x, y, mix(style.From, style.To, float64(x+y)/span))
+		}
+	}
+
+	radius := float64(min(max(style.Radius, 0), min(w, h)/2))
+
+	if style.Shadow > 0 {
+		mask := make([]float64, width*height)
+		offset := style.Shadow / 3
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				sy := y + pad + offset
+				if sy < height {
+					mask[sy*width+x+pad] = roundedCoverage(x, y, w, h, radius)
+				}
+			}
+		}
+		// Three box blurs approximate a gaussian one
+		for range 3 {
+			boxBlur(mask, width, height, max(style.Shadow/3, 1))
+		}
+		for i, a := range mask {
+			if a > 0 {
+				c := out.Pix[i*4 : i*4+4]
+				k := 1 - a*shadowOpacity
+				c[0], c[1], c[2] = uint8(float64(c[0])*k), uint8(float64(c[1])*k), uint8(float64(c[2])*k)
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			a := float64(c.A) / 255 * roundedCoverage(x, y, w, h, radius)
+			if a == 0 {
+				continue
+			}
+			bg := out.NRGBAAt(x+pad, y+pad)
+			out.SetNRGBA(x+pad, y+pad, color.NRGBA{
+				R: uint8(float64(c.R)*a + float64(bg.R)*(1-a)),
+				G: uint8(float64(c.G)*a + float64(bg.G)*(1-a)),
+				B: uint8(float64(c.B)*a + float64(bg.B)*(1-a)),
+				A: uint8(math.Max(float64(bg.A), a*255)),
+			})
+		}
+	}
+
+	return out
+}
+
+// roundedCoverage returns how much of the pixel at x,y lies inside a w×h
+// rectangle with corners rounded by radius, for anti-aliased edges.
+func roundedCoverage(x, y, w, h int, radius float64) float64 {
+	if radius <= 0 {
+		return 1
+	}
+	px, py := float64(x)+0.5, float64(y)+0.5
+	cx := math.Max(radius, math.Min(px, float64(w)-radius))
+	cy := math.Max(radius, math.Min(py, float64(h)-radius))
+	dist := math.Hypot(px-cx, py-cy)
+	return math.Max(0, math.Min(1, radius-dist+0.5))
+}
+
+// boxBlur blurs values laid out in rows of width in place, horizontally then
+// vertically, averaging each over a window of 2×r+1.
+func boxBlur(values []float64, width, height, r int) {
+	tmp := make([]float64, len(values))
+	blurLine := func(get func(int) float64, set func(int, float64), n int) {
+		sum := 0.0
+		for i := -r; i <= r; i++ {
+			if i >= 0 && i < n {
+				sum += get(i)
+			}
+		}
+		for i := 0; i < n; i++ {
+			set(i, sum/float64(2*r+1))
+			if out := i - r; out >= 0 {
+				sum -= get(out)
+			}
+			if in := i + r + 1; in < n {
+				sum += get(in)
+			}
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		row := y * width
+		blurLine(func(i int) float64 { return values[row+i] }, func(i int, v float64) { tmp[row+i] = v }, width)
+	}
+	for x := 0; x < width; x++ {
+		blurLine(func(i int) float64 { return tmp[i*width+x] }, func(i int, v float64) { values[i*width+x] = v }, height)
+	}
+}
+
+func mix(a, b color.NRGBA, t float64) color.NRGBA {
+	lerp := func(u, v uint8) uint8 { return uint8(float64(u) + (float64(v)-float64(u))*t) }
+	return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+}
+
+// ParseBackground parses a background specification: a single "#rrggbb"
+// colour, or two separated by ".." for a gradient.
+func ParseBackground(spec string) (from, to color.NRGBA, err error) {
+	first, second, gradient := strings.Cut(spec, "..")
+	if from, err = ParseHex(strings.TrimSpace(first)); err != nil {
+		return from, to, err
+	}
+	if !gradient {
+		return from, from, nil
+	}
+	to, err = ParseHex(strings.TrimSpace(second))
+	return from, to, err
+}
+
+// ParseHex parses a "#rrggbb" or "#rrggbbaa" colour.
+func ParseHex(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 255}
+	var err error
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("wrong length")
+	}
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q: %w", s, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,194 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/draw"
+)
+
+var (
+	// ErrNoScroll is returned when a frame shows the same content as the
+	// previous one, usually because the end has been reached.
+	ErrNoScroll = errors.New("the content did not scroll")
+	// ErrNoOverlap is returned when a frame cannot be matched with the
+	// previous one, for instance when it scrolled by more than a frame.
+	ErrNoOverlap = errors.New("no overlap found with the previous frame")
+)
+
+const (
+	// stitchMinRows is the number of distinct rows which must match for
+	// an overlap to be trusted.
+	stitchMinRows = 8
+	// stitchScrollbar is the width ignored on the right, where a moving
+	// scrollbar would prevent rows from matching.
+	stitchScrollbar = 24
+)
+
+// row identifies a row of pixels of a frame.
+type row struct {
+	hash uint64
+	// flat rows, of a single colour, match anywhere and are not counted
+	// to find an overlap.
+	flat bool
+}
+
+// Stitcher joins the frames of a window scrolled down into one tall image.
+// The offset between two frames is found by comparing their rows, leaving
+// aside a header and a footer which do not move, such as sticky toolbars.
+type Stitcher struct {
+	frames  []*image.NRGBA
+	rows    [][]row
+	offsets []int
+	footer  int
+}
+
+// NewStitcher starts stitching from the first frame.
+func NewStitcher(first image.Image) *Stitcher {
+	frame := copyFrame(first)
+	return &Stitcher{
+		frames:  []*image.NRGBA{frame},
+		rows:    [][]row{frameRows(frame)},
+		offsets: []int{0},
+	}
+}
+
+// Frames returns the number of frames stitched so far.
+func (s *Stitcher) Frames() int {
+	return len(s.frames)
+}
+
+// Add stitches the next frame below the previous one. It returns ErrNoScroll
+// when nothing moved and ErrNoOverlap when the frames cannot be matched, in
+// which case the frame is left out.
+func (s *Stitcher) Add(img image.Image) error {
+	frame := copyFrame(img)
+	prev := s.frames[len(s.frames)-1]
+	if frame.Bounds().Size() != prev.Bounds().Size() {
+		return fmt.Errorf("frame size changed from %v to %v", prev.Bounds().Size(), frame.Bounds().Size())
+	}
+
+	a, b := s.rows[len(s.rows)-1], frameRows(frame)
+	header, footer := fixedRows(a, b)
+	if header == len(a) {
+		return ErrNoScroll
+	}
+
+	offset, ok := findOffset(a, b, header, footer)
+	if !ok {
+		return ErrNoOverlap
+	}
+
+	s.frames = append(s.frames, frame)
+	s.rows = append(s.rows, b)
+	s.offsets = append(s.offsets, offset)
+	s.footer = max(s.footer, footer)
+	return nil
+}
+
+// Image returns the stitched image: the first frame without its footer, the
+// rows each following frame scrolled into view, then the footer once.
+func (s *Stitcher) Image() *image.NRGBA {
+	first := s.frames[0].Bounds()
+	width, height := first.Dx(), first.Dy()
+	total := height
+	for _, offset := range s.offsets[1:] {
+		total += offset
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, total))
+	bottom := height - s.footer
+	draw.Draw(out, image.Rect(0, 0, width, bottom), s.frames[0], image.Point{}, draw.Src)
+
+	y := bottom
+	for i, frame := range s.frames[1:] {
+		offset := s.offsets[i+1]
+		draw.Draw(out, image.Rect(0, y, width, y+offset), frame, image.Pt(0, bottom-offset), draw.Src)
+		y += offset
+	}
+
+	last := s.frames[len(s.frames)-1]
+	draw.Draw(out, image.Rect(0, y, width, total), last, image.Pt(0, bottom), draw.Src)
+	return out
+}
+
+// copyFrame copies a frame into an NRGBA image starting at the origin.
+func copyFrame(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+	return out
+}
+
+// fixedRows returns the number of rows at the top and at the bottom which
+// are identical in both frames, each capped to a third of the frame so that
+// unchanged content is not mistaken for a toolbar. A header as high as the
+// frame means nothing moved at all.
+func fixedRows(a, b []row) (header, footer int) {
+	for header < len(a) && a[header] == b[header] {
+		header++
+	}
+	if header == len(a) {
+		return header, 0
+	}
+
+	limit := len(a) / 3
+	header = min(header, limit)
+	for footer < limit && a[len(a)-1-footer] == b[len(b)-1-footer] {
+		footer++
+	}
+	return header, footer
+}
+
+// findOffset returns by how many rows the content between header and footer
+// scrolled from a to b: the offset where most distinct rows of b match those
+// of a, provided enough of them do.
+func findOffset(a, b []row, header, footer int) (int, bool) {
+	end := len(a) - footer
+	best, bestScore := 0, 0
+	for offset := 1; offset < end-header-stitchMinRows; offset++ {
+		score, total := 0, 0
+		for y := header; y+offset < end; y++ {
+			if b[y].flat {
+				continue
+			}
+			total++
+			if b[y] == a[y+offset] {
+				score++
+			}
+		}
+		// Tolerate a few rows changed by animations or a blinking cursor
+		if score >= stitchMinRows && score*10 >= total*9 && score > bestScore {
+			best, bestScore = offset, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+// frameRows hashes each row of a frame, leaving the scrollbar aside when the
+// frame is wide enough.
+func frameRows(img *image.NRGBA) []row {
+	b := img.Bounds()
+	right := b.Dx()
+	if right > 4*stitchScrollbar {
+		right -= stitchScrollbar
+	}
+
+	rows := make([]row, b.Dy())
+	for y := range rows {
+		pix := img.Pix[img.PixOffset(0, y):img.PixOffset(right, y)]
+
+		h := fnv.New64a()
+		_, _ = h.Write(pix)
+		flat := true
+		for i := 4; i < len(pix); i += 4 {
+			if pix[i] != pix[0] || pix[i+1] != pix[1] || pix[i+2] != pix[2] || pix[i+3] != pix[3] {
+				flat = false
+				break
+			}
+		}
+		rows[y] = row{hash: h.Sum64(), flat: flat}
+	}
+	return rows
+}
@@ -0,0 +1,68 @@
+// Package logging sets up the structured logger of the daemon, writing to
+// its standard error, to a log file rotated by size and to memory, for
+// clients to fetch the recent lines.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// backups is the number of rotated log files kept.
+const backups = 3
+
+// Options configure the logger.
+type Options struct {
+	// Level is the minimum level logged
+	Level slog.Level
+	// Format is text or json
+	Format string
+	// File is the log file, none when empty
+	File string
+	// MaxSize is the size in bytes the log file is rotated at
+	MaxSize int64
+}
+
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Setup makes the logger configured by opts the default one, which the log
+// package writes through too. It returns a function closing the log file.
+func Setup(opts Options) (func(), error) {
+	// Standard error comes last, as a closed one would stop the writes
+	writers := []io.Writer{ring{}}
+	closeFile := func() {}
+	if opts.File != "" {
+		file, err := OpenRotatingFile(opts.File, opts.MaxSize, backups)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, file)
+		closeFile = func() { _ = file.Close() }
+	}
+	out := io.MultiWriter(append(writers, os.Stderr)...)
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		closeFile()
+		return nil, fmt.Errorf("invalid log format %q, expected text or json", opts.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closeFile, nil
+}
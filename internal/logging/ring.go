@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+)
+
+// Capacity is the number of log lines kept in memory.
+const Capacity = 1000
+
+// Line is a log line, numbered for clients following the log to ask for
+// the lines after the last one they got.
+type Line struct {
+	Seq  uint64 `json:"seq"`
+	Text string `json:"text"`
+}
+
+var (
+	mu    sync.Mutex
+	lines = make([]Line, 0, Capacity)
+	next  int
+	seq   uint64
+)
+
+// ring is the writer keeping the recent log lines in memory.
+type ring struct{}
+
+func (ring) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, text := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		seq++
+		line := Line{Seq: seq, Text: text}
+		if len(lines) < Capacity {
+			lines = append(lines, line)
+			continue
+		}
+		lines[next] = line
+		next = (next + 1) % Capacity
+	}
+	return len(p), nil
+}
+
+// Lines returns the last n log lines logged after the line numbered after,
+// oldest first; all of them when n is 0.
+func Lines(after uint64, n int) []Line {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var out []Line
+	for i := range lines {
+		line := lines[(next+i)%len(lines)]
+		if line.Seq > after {
+			out = append(out, line)
+		}
+	}
+	if n > 0 && len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package nightlight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Compensation modes for night-light filters. The filters cannot tell
+// whether they are tinting the screen at the moment, so captures are not
+// corrected afterwards.
+const (
+	ModeOff     = "off"
+	ModeSuspend = "suspend"
+)
+
+// Valid checks mode is a known compensation mode.
+func Valid(mode string) error {
+	switch mode {
+	case ModeOff, ModeSuspend:
+		return nil
+	}
+	return fmt.Errorf("unknown night-light mode %q (want %s or %s)", mode, ModeOff, ModeSuspend)
+}
+
+// Filter describes a running night-light daemon.
+type Filter struct {
+	Name string
+	PID  int
+}
+
+// supported lists the night-light daemons we know how to pause, with the
+// number of SIGUSR1 needed to switch them to neutral and back again.
+// gammastep toggles on and off with each signal whereas wlsunset cycles
+// through automatic, forced day and forced night modes.
+var supported = map[string]struct{ suspend, resume int }{
+	"gammastep": {suspend: 1, resume: 1},
+	"wlsunset":  {suspend: 1, resume: 2},
+}
+
+// Detect returns the running night-light filters.
+func Detect() []Filter {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var filters []Filter
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm")) //nolint:gosec
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(string(comm))
+		if _, ok := supported[name]; ok {
+			filters = append(filters, Filter{Name: name, PID: pid})
+		}
+	}
+
+	return filters
+}
+
+// Suspend switches the running night-light filters to neutral colours and
+// returns a function restoring them.
+func Suspend() func() {
+	filters := Detect()
+	if len(filters) == 0 {
+		return func() {}
+	}
+
+	for _, f := range filters {
+		signal(f, supported[f.Name].suspend)
+	}
+	// Gamma ramps are faded in by the daemons, let them settle
+	time.Sleep(300 * time.Millisecond)
+
+	return func() {
+		for _, f := range filters {
+			signal(f, supported[f.Name].resume)
+		}
+	}
+}
+
+func signal(f Filter, count int) {
+	for range count {
+		_ = syscall.Kill(f.PID, syscall.SIGUSR1)
+	}
+}
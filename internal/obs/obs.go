@@ -0,0 +1,218 @@
+package obs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// obs-websocket 5 message opcodes.
+const (
+	opHello           = 0
+	opIdentify        = 1
+	opIdentified      = 2
+	opEvent           = 5
+	opRequest         = 6
+	opRequestResponse = 7
+)
+
+// rpcVersion is the obs-websocket RPC version spoken.
+const rpcVersion = 1
+
+// SubscribeOutputs subscribes to the events of the outputs: recording,
+// streaming, replay buffer and virtual camera.
+const SubscribeOutputs = 1 << 6
+
+// dialTimeout bounds connecting when the context has no deadline.
+const dialTimeout = 5 * time.Second
+
+// message is the envelope of every obs-websocket message.
+type message struct {
+	Op   int             `json:"op"`
+	Data json.RawMessage `json:"d"`
+}
+
+// Client is a connection to the obs-websocket server of OBS Studio. The
+// password is only ever sent hashed, and never leaves the process.
+type Client struct {
+	ws *websocket
+
+	mu     sync.Mutex
+	nextID int
+}
+
+// Event is an event sent by OBS.
+type Event struct {
+	Type string          `json:"eventType"`
+	Data json.RawMessage `json:"eventData"`
+}
+
+// Connect connects to OBS at address (host:port), authenticating with the
+// password returned by password when OBS asks for one, and subscribing to
+// the events of the subscriptions mask.
+func Connect(ctx context.Context, address string, password func() (string, error), subscriptions int) (*Client, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OBS, is its WebSocket server enabled? %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	ws, err := handshake(conn, address, "obswebsocket.json")
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c := &Client{ws: ws}
+	if err := c.identify(password, subscriptions); err != nil {
+		_ = ws.close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// identify answers the hello of the server, with the authentication string
+// derived from the password when it asks for it.
+func (c *Client) identify(password func() (string, error), subscriptions int) error {
+	var hello struct {
+		Authentication *struct {
+			Challenge string `json:"challenge"`
+			Salt      string `json:"salt"`
+		} `json:"authentication"`
+	}
+	if err := c.read(opHello, &hello); err != nil {
+		return err
+	}
+
+	identify := map[string]interface{}{
+		"rpcVersion":         rpcVersion,
+		"eventSubscriptions": subscriptions,
+	}
+	if auth := hello.Authentication; auth != nil {
+		secret, err := password()
+		if err != nil {
+			return err
+		}
+		if secret == "" {
+			return fmt.Errorf("OBS asks for a password and none is configured")
+		}
+		hash := sha256.Sum256([]byte(secret + auth.Salt))
+		answer := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(hash[:]) + auth.Challenge))
+		identify["authentication"] = base64.StdEncoding.EncodeToString(answer[:])
+	}
+	if err := c.write(opIdentify, identify); err != nil {
+		return err
+	}
+	if err := c.read(opIdentified, nil); err != nil {
+		return fmt.Errorf("OBS refused the connection, check the password: %w", err)
+	}
+	return nil
+}
+
+// Request sends a request of type kind with data, which may be nil, and
+// decodes the data of the answer into out unless it is nil.
+func (c *Client) Request(ctx context.Context, kind string, data, out interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.ws.conn.SetDeadline(deadline)
+		defer func() { _ = c.ws.conn.SetDeadline(time.Time{}) }()
+	}
+
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	request := map[string]interface{}{"requestType": kind, "requestId": id}
+	if data != nil {
+		request["requestData"] = data
+	}
+	if err := c.write(opRequest, request); err != nil {
+		return err
+	}
+
+	for {
+		var answer struct {
+			RequestID     string `json:"requestId"`
+			RequestStatus struct {
+				Result  bool   `json:"result"`
+				Code    int    `json:"code"`
+				Comment string `json:"comment"`
+			} `json:"requestStatus"`
+			ResponseData json.RawMessage `json:"responseData"`
+		}
+		if err := c.read(opRequestResponse, &answer); err != nil {
+			return err
+		}
+		if answer.RequestID != id {
+			continue
+		}
+
+		if status := answer.RequestStatus; !status.Result {
+			if status.Comment != "" {
+				return fmt.Errorf("OBS failed %s: %s", kind, status.Comment)
+			}
+			return fmt.Errorf("OBS failed %s (code %d)", kind, status.Code)
+		}
+		if out == nil || len(answer.ResponseData) == 0 {
+			return nil
+		}
+		return json.Unmarshal(answer.ResponseData, out)
+	}
+}
+
+// NextEvent waits for the next event OBS sends. Events arriving while a
+// request waits for its answer are skipped.
+func (c *Client) NextEvent() (Event, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var event Event
+	err := c.read(opEvent, &event)
+	return event, err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.ws.close()
+}
+
+func (c *Client) write(op int, data interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"op": op, "d": data})
+	if err != nil {
+		return err
+	}
+	return c.ws.writeMessage(payload)
+}
+
+// read reads messages until one with opcode op, whose data it decodes into
+// out unless it is nil. Other messages, such as events, are skipped.
+func (c *Client) read(op int, out interface{}) error {
+	for {
+		payload, err := c.ws.readMessage()
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return fmt.Errorf("failed to parse OBS message: %w", err)
+		}
+		if msg.Op != op {
+			continue
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(msg.Data, out)
+	}
+}
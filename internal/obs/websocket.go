@@ -0,0 +1,189 @@
+package obs
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // mandated by the WebSocket handshake
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// WebSocket opcodes used by obs-websocket.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// websocketGUID is appended to the key of the handshake to compute the
+// accept header of the server.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize bounds the messages read, screenshots being the largest.
+const maxMessageSize = 64 << 20
+
+// websocket is a minimal WebSocket client connection, exchanging the text
+// messages obs-websocket speaks.
+type websocket struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// handshake upgrades conn to a WebSocket speaking subprotocol.
+func handshake(conn net.Conn, host, subprotocol string) (*websocket, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Protocol: %s\r\n\r\n", host, key, subprotocol)
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the handshake answer: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("server refused the WebSocket upgrade: %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID)) //nolint:gosec
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("server answered the WebSocket upgrade wrongly")
+	}
+
+	return &websocket{conn: conn, r: r}, nil
+}
+
+// writeMessage sends data as a single text frame, masked as clients must.
+func (ws *websocket) writeMessage(data []byte) error {
+	return ws.writeFrame(opText, data)
+}
+
+func (ws *websocket) writeFrame(opcode byte, data []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(data); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xffff:
+		header = append(header, 0x80|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame := append(header, mask...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// readMessage returns the next text message, joining fragmented ones and
+// answering pings on the way.
+func (ws *websocket) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			if len(payload) >= 2 {
+				return nil, fmt.Errorf("connection closed by OBS (%d: %s)", binary.BigEndian.Uint16(payload), payload[2:])
+			}
+			return nil, io.EOF
+		case opText, opContinuation:
+		default:
+			return nil, fmt.Errorf("unexpected WebSocket frame type %d", opcode)
+		}
+
+		if len(message)+len(payload) > maxMessageSize {
+			return nil, errors.New("WebSocket message too large")
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (ws *websocket) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errors.New("WebSocket frame too large")
+	}
+
+	var mask [4]byte
+	masked := header[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// close tells the server the connection is closing and closes it.
+func (ws *websocket) close() error {
+	// Normal closure
+	_ = ws.writeFrame(opClose, []byte{0x03, 0xe8})
+	return ws.conn.Close()
+}
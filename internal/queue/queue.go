@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// checkInterval is how often the network state is checked.
+	checkInterval = 5 * time.Second
+	// minBackoff and maxBackoff bound the delay between two attempts of an
+	// upload while the network looks up.
+	minBackoff = 30 * time.Second
+	maxBackoff = 30 * time.Minute
+)
+
+// ErrGone is returned by upload functions for items which can never succeed
+// (e.g. their uploader was removed from the configuration) and should be
+// dropped rather than retried.
+var ErrGone = errors.New("upload can no longer be retried")
+
+// Item is an upload waiting to be retried.
+type Item struct {
+	ID string `json:"id"`
+	// Uploader names what uploads the item (e.g. a hook).
+	Uploader string `json:"uploader"`
+	// File is the queued copy of the capture.
+	File      string    `json:"file"`
+	Name      string    `json:"name"`
+	Added     time.Time `json:"added"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	NextTry   time.Time `json:"next_try"`
+}
+
+// Queue keeps failed uploads on disk until they succeed, so that shares
+// survive being offline and daemon restarts alike.
+type Queue struct {
+	dir   string
+	mu    sync.Mutex
+	items []Item
+	wake  chan struct{}
+}
+
+// New returns the queue stored in dir, loading the uploads left pending.
+func New(dir string) *Queue {
+	q := &Queue{dir: dir, wake: make(chan struct{}, 1)}
+	if data, err := os.ReadFile(q.index()); err == nil {
+		_ = json.Unmarshal(data, &q.items)
+	}
+	return q
+}
+
+func (q *Queue) index() string {
+	return filepath.Join(q.dir, "queue.json")
+}
+
+// Add queues a copy of the capture data for uploader, name being the file
+// name of the capture.
+func (q *Queue) Add(uploader, name string, data []byte) (Item, error) {
+	if err := os.MkdirAll(q.dir, 0o700); err != nil {
+		return Item{}, fmt.Errorf("failed to create upload queue: %w", err)
+	}
+
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	item := Item{
+		ID:       id,
+		Uploader: uploader,
+		File:     filepath.Join(q.dir, id+filepath.Ext(name)),
+		Name:     name,
+		Added:    time.Now(),
+		NextTry:  time.Now().Add(minBackoff),
+	}
+	if err := os.WriteFile(item.File, data, 0o600); err != nil {
+		return Item{}, fmt.Errorf("failed to queue upload: %w", err)
+	}
+
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	err := q.save()
+	q.mu.Unlock()
+
+	return item, err
+}
+
+// Items returns the pending uploads, oldest first.
+func (q *Queue) Items() []Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Item(nil), q.items...)
+}
+
+// Retry makes every pending upload due now.
+func (q *Queue) Retry() {
+	q.mu.Lock()
+	for i := range q.items {
+		q.items[i].NextTry = time.Time{}
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run retries the due uploads with upload until ctx is done. Uploads are
+// retried as soon as the network comes back, and otherwise with a growing
+// delay between attempts. Successful uploads leave the queue.
+func (q *Queue) Run(ctx context.Context, upload func(context.Context, Item) error) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	wasOnline := Online()
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		online := Online()
+		if online && !wasOnline {
+			q.Retry()
+		}
+		wasOnline = online
+		if !online {
+			continue
+		}
+
+		for _, item := range q.Items() {
+			if time.Now().Before(item.NextTry) {
+				continue
+			}
+			err := upload(ctx, item)
+			q.done(item, err)
+		}
+	}
+}
+
+// done removes a successful upload, or schedules the next attempt.
+func (q *Queue) done(item Item, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.items {
+		if q.items[i].ID != item.ID {
+			continue
+		}
+		if err == nil || errors.Is(err, ErrGone) {
+			_ = os.Remove(item.File)
+			q.items = append(q.items[:i], q.items[i+1:]...)
+		} else {
+			it := &q.items[i]
+			it.Attempts++
+			it.LastError = err.Error()
+			backoff := min(minBackoff<<min(it.Attempts, 10), maxBackoff)
+			it.NextTry = time.Now().Add(backoff)
+		}
+		_ = q.save()
+		return
+	}
+}
+
+// save writes the queue index, the caller holding the lock.
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := q.index() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save upload queue: %w", err)
+	}
+	return os.Rename(tmp, q.index())
+}
+
+// Online reports whether the machine has a default route, which is what
+// uploads need and what goes away when it is offline.
+func Online() bool {
+	for _, table := range []string{"/proc/net/route", "/proc/net/ipv6_route"} {
+		if hasDefaultRoute(table) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDefaultRoute(table string) bool {
+	f, err := os.Open(table) //nolint:gosec
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) >= 2 && fields[1] == "00000000" && fields[0] != "lo":
+			// IPv4: Iface Destination ...
+			return true
+		case len(fields) == 10 && fields[0] == strings.Repeat("0", 32) && fields[1] == "00" && fields[9] != "lo":
+			// IPv6: destination, prefix length, ..., device
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package scroll
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// Methods of scrolling a window for scrolling captures.
+const (
+	// MethodSway sends wheel events through the sway seat, needing no tool.
+	MethodSway = "sway"
+	// MethodWtype presses the Down key with wtype, for applications which
+	// ignore synthetic wheel events.
+	MethodWtype = "wtype"
+	// MethodYdotool sends wheel events through the kernel with ydotool.
+	MethodYdotool = "ydotool"
+)
+
+// Valid returns an error when method is not a known scrolling method.
+func Valid(method string) error {
+	switch method {
+	case MethodSway, MethodWtype, MethodYdotool:
+		return nil
+	}
+	return fmt.Errorf("unknown scroll method %q (want %s, %s or %s)", method, MethodSway, MethodWtype, MethodYdotool)
+}
+
+// Tools returns the external tools a method needs besides swaymsg.
+func Tools(method string) []string {
+	switch method {
+	case MethodWtype, MethodYdotool:
+		return []string{method}
+	}
+	return nil
+}
+
+// Down moves the pointer to x,y, over the window to scroll, and scrolls it
+// down by clicks wheel notches, or key presses with wtype.
+func Down(ctx context.Context, method string, x, y, clicks int) error {
+	commands := []string{fmt.Sprintf("seat - cursor set %d %d", x, y)}
+	if method == MethodSway {
+		for i := 0; i < clicks; i++ {
+			commands = append(commands, "seat - cursor press button5", "seat - cursor release button5")
+		}
+	}
+	cmd := exec.CommandContext(ctx, "swaymsg", strings.Join(commands, ", ")) //nolint:gosec
+	if out, err := external.CombinedOutput(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to scroll: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	switch method {
+	case MethodWtype:
+		args := make([]string, 0, 2*clicks)
+		for i := 0; i < clicks; i++ {
+			args = append(args, "-k", "Down")
+		}
+		cmd = exec.CommandContext(ctx, "wtype", args...) //nolint:gosec
+	case MethodYdotool:
+		cmd = exec.CommandContext(ctx, "ydotool", "mousemove", "--wheel", "-x", "0", "-y", strconv.Itoa(-clicks)) //nolint:gosec
+	default:
+		return nil
+	}
+	if out, err := external.CombinedOutput(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to scroll with %s: %w: %s", method, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
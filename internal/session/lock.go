@@ -0,0 +1,104 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Names logind sends its lock and sleep signals under.
+const (
+	logindName    = "org.freedesktop.login1"
+	logindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	logindSession = "org.freedesktop.login1.Session"
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// WatchLock calls onLock with true when logind asks the session to lock,
+// which swayidle turns into running the locker, or tells the system is
+// about to sleep, and with false when it asks the session to unlock, until
+// ctx is done or the bus goes away. Waking up is left to the unlock, as the
+// locker may be showing by then.
+func WatchLock(ctx context.Context, onLock func(locked bool)) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the system bus: %w", err)
+	}
+
+	var session dbus.ObjectPath
+	sessionMatch := []dbus.MatchOption{dbus.WithMatchSender(logindName), dbus.WithMatchInterface(logindSession)}
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		session = sessionPath(id)
+		sessionMatch = append(sessionMatch, dbus.WithMatchObjectPath(session))
+	}
+	sleepMatch := []dbus.MatchOption{
+		dbus.WithMatchSender(logindName),
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManager),
+		dbus.WithMatchMember("PrepareForSleep"),
+	}
+	for _, match := range [][]dbus.MatchOption{sessionMatch, sleepMatch} {
+		if err := conn.AddMatchSignalContext(ctx, match...); err != nil {
+			return fmt.Errorf("failed to watch logind: %w", err)
+		}
+		// The connection is shared
+		defer func() { _ = conn.RemoveMatchSignal(match...) }()
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case signal, ok := <-signals:
+			if !ok {
+				return errors.New("the system bus went away")
+			}
+			if locked, ok := lockSignal(signal, session); ok {
+				onLock(locked)
+			}
+		}
+	}
+}
+
+// lockSignal tells whether signal asks to lock or unlock the session at
+// path, any session when empty, ok being false for other signals.
+func lockSignal(signal *dbus.Signal, path dbus.ObjectPath) (locked, ok bool) {
+	switch signal.Name {
+	case logindSession + ".Lock", logindSession + ".Unlock":
+		if path != "" && signal.Path != path {
+			return false, false
+		}
+		return signal.Name == logindSession+".Lock", true
+	case logindManager + ".PrepareForSleep":
+		// Sent with false once awake
+		var sleeping bool
+		if len(signal.Body) == 1 {
+			sleeping, _ = signal.Body[0].(bool)
+		}
+		return sleeping, sleeping
+	}
+	return false, false
+}
+
+// sessionPath returns the logind object path of the session id, escaped the
+// way D-Bus object paths are.
+func sessionPath(id string) dbus.ObjectPath {
+	var b strings.Builder
+	for i, c := range []byte(id) {
+		alnum := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		if !alnum || (i == 0 && '0' <= c && c <= '9') {
+			fmt.Fprintf(&b, "_%02x", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return dbus.ObjectPath("/org/freedesktop/login1/session/" + b.String())
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestLockSignal(t *testing.T) {
+	ours := sessionPath("2")
+	tests := []struct {
+		name   string
+		signal dbus.Signal
+		path   dbus.ObjectPath
+		locked bool
+		ok     bool
+	}{
+		{name: "lock", signal: dbus.Signal{Name: logindSession + ".Lock", Path: ours}, path: ours, locked: true, ok: true},
+		{name: "unlock", signal: dbus.Signal{Name: logindSession + ".Unlock", Path: ours}, path: ours, ok: true},
+		{name: "another session", signal: dbus.Signal{Name: logindSession + ".Lock", Path: sessionPath("3")}, path: ours},
+		{name: "any session", signal: dbus.Signal{Name: logindSession + ".Lock", Path: sessionPath("3")}, locked: true, ok: true},
+		{name: "sleep", signal: dbus.Signal{Name: logindManager + ".PrepareForSleep", Path: logindPath, Body: []interface{}{true}}, path: ours, locked: true, ok: true},
+		{name: "wake up", signal: dbus.Signal{Name: logindManager + ".PrepareForSleep", Path: logindPath, Body: []interface{}{false}}, path: ours},
+		{name: "other", signal: dbus.Signal{Name: logindManager + ".SessionNew", Path: logindPath}, path: ours},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locked, ok := lockSignal(&tt.signal, tt.path)
+			if locked != tt.locked || ok != tt.ok {
+				t.Errorf("lockSignal() = %t, %t, want %t, %t", locked, ok, tt.locked, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSessionPath(t *testing.T) {
+	for id, want := range map[string]dbus.ObjectPath{
+		"c2":  "/org/freedesktop/login1/session/c2",
+		"2":   "/org/freedesktop/login1/session/_32",
+		"a-b": "/org/freedesktop/login1/session/a_2db",
+	} {
+		if got := sessionPath(id); got != want {
+			t.Errorf("sessionPath(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrRunning is returned when another daemon holds the pid file.
+var ErrRunning = errors.New("another daemon is already running")
+
+// PidFile is the pid file of the daemon, locked for as long as it runs so
+// that a second daemon of the same session refuses to start rather than
+// taking over its socket.
+type PidFile struct {
+	path string
+	file *os.File
+}
+
+// LockPidFile locks the pid file at path and writes the pid of the process
+// to it, failing with ErrRunning when another daemon holds it.
+func LockPidFile(path string) (*PidFile, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pid file: %w", err)
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			_ = file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				if pid := PidFileOwner(path); pid != 0 {
+					return nil, fmt.Errorf("%w (pid %d)", ErrRunning, pid)
+				}
+				return nil, ErrRunning
+			}
+			return nil, fmt.Errorf("failed to lock pid file: %w", err)
+		}
+
+		// The daemon stopping removes the file before unlocking it: the
+		// lock only counts when taken on the file still at path
+		if same, err := sameFile(file, path); err != nil || !same {
+			_ = file.Close()
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to check pid file: %w", err)
+			}
+			continue
+		}
+
+		if err := file.Truncate(0); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write pid file: %w", err)
+		}
+		if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write pid file: %w", err)
+		}
+		return &PidFile{path: path, file: file}, nil
+	}
+}
+
+// Release removes the pid file and unlocks it.
+func (p *PidFile) Release() {
+	_ = os.Remove(p.path)
+	_ = p.file.Close()
+}
+
+// PidFileOwner returns the pid of the daemon holding the pid file at path,
+// or 0 when no running daemon does, e.g. when the previous one crashed.
+func PidFileOwner(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer func() { _ = file.Close() }()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func sameFile(file *os.File, path string) (bool, error) {
+	opened, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(opened, current), nil
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// RuntimeDir returns the XDG runtime directory of the user.
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return fmt.Sprintf("/run/user/%d", os.Getuid())
+}
+
+// Name identifies the graphical session: the base name of its Wayland
+// display, discovered when WAYLAND_DISPLAY is not set, or "default" when
+// there is none. Several displays are an error, as any of them could be
+// the wrong one.
+func Name() (string, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		var err error
+		display, err = discover(RuntimeDir(), "wayland-*", func(name string) bool {
+			return !strings.HasSuffix(name, ".lock")
+		})
+		var none noMatchError
+		if errors.As(err, &none) {
+			return "default", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("WAYLAND_DISPLAY is not set and %w; export it", err)
+		}
+	}
+	return filepath.Base(display), nil
+}
+
+// Dir returns the directory holding the socket and runtime files of the
+// daemon serving the current session, so that concurrent sessions of the
+// same user, and different users, never share them.
+func Dir() (string, error) {
+	name, err := Name()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(RuntimeDir(), "sway-easyshot", name), nil
+}
+
+// EnsureDir creates the session directory if needed and makes sure it is
+// private to the user: a directory created beforehand by someone else, or
+// a symlink pointing elsewhere, is refused.
+func EnsureDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check session directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("session directory %s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("session directory %s is owned by uid %d", dir, st.Uid)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to restrict session directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Environment returns the environment the daemon should run with: the
+// current one, completed with the Wayland and sway variables it needs when
+// they can be discovered unambiguously. It fails with a diagnostic when they
+// cannot, rather than starting a daemon unable to capture anything.
+func Environment() ([]string, error) {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	runtimeDir := RuntimeDir()
+	if _, err := os.Stat(runtimeDir); err != nil {
+		return nil, fmt.Errorf("XDG_RUNTIME_DIR %s is not usable: %w", runtimeDir, err)
+	}
+	env["XDG_RUNTIME_DIR"] = runtimeDir
+
+	if env["WAYLAND_DISPLAY"] == "" {
+		display, err := discover(runtimeDir, "wayland-*", func(name string) bool {
+			return !strings.HasSuffix(name, ".lock")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("WAYLAND_DISPLAY is not set and %w; run from within your sway session or export it", err)
+		}
+		env["WAYLAND_DISPLAY"] = display
+	}
+
+	if env["SWAYSOCK"] == "" {
+		sock, err := discover(runtimeDir, fmt.Sprintf("sway-ipc.%d.*.sock", os.Getuid()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("SWAYSOCK is not set and %w; run from within your sway session or export it", err)
+		}
+		env["SWAYSOCK"] = filepath.Join(runtimeDir, sock)
+	}
+
+	if _, err := os.Stat(env["SWAYSOCK"]); err != nil {
+		return nil, fmt.Errorf("SWAYSOCK %s is not usable: %w", env["SWAYSOCK"], err)
+	}
+	if _, err := os.Stat(filepath.Join(runtimeDir, env["WAYLAND_DISPLAY"])); err != nil && !filepath.IsAbs(env["WAYLAND_DISPLAY"]) {
+		return nil, fmt.Errorf("WAYLAND_DISPLAY %s is not usable: %w", env["WAYLAND_DISPLAY"], err)
+	}
+
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	return out, nil
+}
+
+// noMatchError tells discover found no entry at all.
+type noMatchError struct {
+	pattern, dir string
+}
+
+func (e noMatchError) Error() string {
+	return fmt.Sprintf("no %s found in %s", e.pattern, e.dir)
+}
+
+// discover returns the single entry of dir matching pattern and accepted by
+// keep, failing when there are none or several.
+func discover(dir, pattern string, keep func(string) bool) (string, error) {
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+
+	var names []string
+	for _, m := range matches {
+		name := filepath.Base(m)
+		if keep == nil || keep(name) {
+			names = append(names, name)
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return "", noMatchError{pattern: pattern, dir: dir}
+	case 1:
+		return names[0], nil
+	default:
+		return "", fmt.Errorf("several candidates found in %s (%s)", dir, strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runtimeWith sets up a runtime directory holding files, and returns it.
+func runtimeWith(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	return dir
+}
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name    string
+		display string
+		files   []string
+		want    string
+	}{
+		{name: "set", display: "wayland-1", files: []string{"wayland-1", "wayland-2"}, want: "wayland-1"},
+		{name: "absolute", display: "/run/other/wayland-3", want: "wayland-3"},
+		{name: "discovered", files: []string{"wayland-1", "wayland-1.lock"}, want: "wayland-1"},
+		{name: "none", want: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtimeWith(t, tt.files...)
+			t.Setenv("WAYLAND_DISPLAY", tt.display)
+
+			got, err := Name()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Name() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameAmbiguous(t *testing.T) {
+	runtimeWith(t, "wayland-1", "wayland-1.lock", "wayland-2")
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	if got, err := Name(); err == nil {
+		t.Errorf("Name() = %q with two displays, want an error", got)
+	}
+	if got, err := Dir(); err == nil {
+		t.Errorf("Dir() = %q with two displays, want an error", got)
+	}
+}
+
+func TestDir(t *testing.T) {
+	runtime := runtimeWith(t)
+	t.Setenv("WAYLAND_DISPLAY", "wayland-1")
+
+	got, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(runtime, "sway-easyshot", "wayland-1"); got != want {
+		t.Errorf("Dir() = %q, want %q", got, want)
+	}
+}
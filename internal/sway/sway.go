@@ -7,7 +7,8 @@ import (
 	"os/exec"
 	"strings"
 
-	"sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/capability"
+	"github.com/chmouel/sway-easyshot/internal/external"
 )
 
 type swayRect struct {
@@ -18,47 +19,136 @@ type swayRect struct {
 }
 
 type swayNode struct {
-	Focused      bool       `json:"focused"`
-	Rect         swayRect   `json:"rect"`
-	Type         string     `json:"type"`
-	Nodes        []swayNode `json:"nodes"`
+	ID               int64    `json:"id"`
+	Focused          bool     `json:"focused"`
+	Rect             swayRect `json:"rect"`
+	WindowRect       swayRect `json:"window_rect"`
+	DecoRect         swayRect `json:"deco_rect"`
+	Type             string   `json:"type"`
+	Shell            string   `json:"shell"`
+	Window           int64    `json:"window"`
+	Name             string   `json:"name"`
+	AppID            string   `json:"app_id"`
+	PID              int      `json:"pid"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
 	FloatingNodes []swayNode `json:"floating_nodes"`
 }
 
+// Window describes the focused window and where it lives.
+type Window struct {
+	ID        int64
+	AppID     string
+	Title     string
+	PID       int
+	Workspace string
+	Output    string
+	Geometry  string
+}
+
+type swayWorkspace struct {
+	Name    string   `json:"name"`
+	Visible bool     `json:"visible"`
+	Focused bool     `json:"focused"`
+	Output  string   `json:"output"`
+	Rect    swayRect `json:"rect"`
+}
+
 type swayOutput struct {
-	Name    string `json:"name"`
-	Active  bool   `json:"active"`
-	Focused bool   `json:"focused"`
-	Make    string `json:"make"`
-	Model   string `json:"model"`
+	Name    string   `json:"name"`
+	Active  bool     `json:"active"`
+	Focused bool     `json:"focused"`
+	Make    string   `json:"make"`
+	Model   string   `json:"model"`
+	Rect    swayRect `json:"rect"`
+	Scale   float64  `json:"scale"`
+}
+
+// Output describes where an output lies in the layout, in logical pixels,
+// and the scale turning them into physical ones.
+type Output struct {
+	Name   string
+	X      int
+	Y      int
+	Width  int
+	Height int
+	Scale  float64
 }
 
-// GetFocusedWindowGeometry returns the geometry of the focused window
-func GetFocusedWindowGeometry(ctx context.Context) (string, error) {
+func getTree(ctx context.Context) (*swayNode, error) {
 	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_tree")
-	output, err := cmd.Output()
+	output, err := external.Output(ctx, cmd)
 	if err != nil {
-		return "", fmt.Errorf("failed to get sway tree: %w", err)
+		return nil, fmt.Errorf("failed to get sway tree: %w", err)
 	}
 
 	var tree swayNode
 	if err := json.Unmarshal(output, &tree); err != nil {
-		return "", fmt.Errorf("failed to parse sway tree: %w", err)
+		return nil, fmt.Errorf("failed to parse sway tree: %w", err)
 	}
 
-	focused := findFocused(&tree)
+	return &tree, nil
+}
+
+// Decoration modes choosing how much of a window its geometry covers.
+const (
+	// DecorationsBorder covers the window with its borders, as sway reports it
+	DecorationsBorder = "border"
+	// DecorationsFull adds the title bar
+	DecorationsFull = "full"
+	// DecorationsContent only covers what the application draws
+	DecorationsContent = "content"
+)
+
+// ValidDecorations checks a decoration mode
+func ValidDecorations(mode string) error {
+	switch mode {
+	case DecorationsBorder, DecorationsFull, DecorationsContent:
+		return nil
+	}
+	return fmt.Errorf("invalid decorations mode: %s (valid: %s, %s, %s)", mode,
+		DecorationsBorder, DecorationsFull, DecorationsContent)
+}
+
+// GetFocusedWindowGeometry returns the geometry of the focused window,
+// decorations being one of the Decorations modes (empty for the default)
+func GetFocusedWindowGeometry(ctx context.Context, decorations string) (string, error) {
+	tree, err := getTree(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	focused := findFocused(tree)
 	if focused == nil {
 		return "", fmt.Errorf("no focused window found")
 	}
 
-	rect := focused.Rect
-	return fmt.Sprintf("%d,%d %dx%d", rect.X, rect.Y, rect.Width, rect.Height), nil
+	return focused.geometry(ctx, decorations), nil
+}
+
+// GetFocusedWindow returns the focused window along with its workspace and output
+func GetFocusedWindow(ctx context.Context) (*Window, error) {
+	tree, err := getTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	win := &Window{}
+	node := findFocusedWindow(tree, win)
+	if node == nil {
+		return nil, fmt.Errorf("no focused window found")
+	}
+	win.Geometry = node.geometry(ctx, "")
+
+	return win, nil
 }
 
 // GetFocusedOutputName returns the name of the focused output
 func GetFocusedOutputName(ctx context.Context) (string, error) {
 	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_outputs")
-	output, err := cmd.Output()
+	output, err := external.Output(ctx, cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get sway outputs: %w", err)
 	}
@@ -77,6 +167,31 @@ func GetFocusedOutputName(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("no focused output found")
 }
 
+// GetOutput returns the layout of the named output
+func GetOutput(ctx context.Context, name string) (*Output, error) {
+	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_outputs")
+	output, err := external.Output(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sway outputs: %w", err)
+	}
+
+	var outputs []swayOutput
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse sway outputs: %w", err)
+	}
+
+	for _, o := range outputs {
+		if o.Name == name {
+			scale := o.Scale
+			if scale <= 0 {
+				scale = 1
+			}
+			return &Output{Name: o.Name, X: o.Rect.X, Y: o.Rect.Y, Width: o.Rect.Width, Height: o.Rect.Height, Scale: scale}, nil
+		}
+	}
+	return nil, fmt.Errorf("output %s not found", name)
+}
+
 // SelectOutput provides interactive output selection
 func SelectOutput(ctx context.Context, useCurrentScreen bool) (string, error) {
 	if useCurrentScreen {
@@ -84,7 +199,7 @@ func SelectOutput(ctx context.Context, useCurrentScreen bool) (string, error) {
 	}
 
 	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_outputs")
-	output, err := cmd.Output()
+	output, err := external.Output(ctx, cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get sway outputs: %w", err)
 	}
@@ -95,7 +210,7 @@ func SelectOutput(ctx context.Context, useCurrentScreen bool) (string, error) {
 	}
 
 	var activeOutputs []string
-	var outputMap = make(map[string]string)
+	outputMap := make(map[string]string)
 
 	for _, output := range outputs {
 		if output.Active {
@@ -113,6 +228,10 @@ func SelectOutput(ctx context.Context, useCurrentScreen bool) (string, error) {
 		return outputMap[activeOutputs[0]], nil
 	}
 
+	if err := capability.Require(capability.Menu); err != nil {
+		return "", err
+	}
+
 	selected, err := external.Wofi(ctx, "Select output", activeOutputs)
 	if err != nil {
 		return "", err
@@ -131,6 +250,330 @@ func SelectOutput(ctx context.Context, useCurrentScreen bool) (string, error) {
 	return name, nil
 }
 
+// FindWindow returns the first window matching the workspace and the app id
+// (or X11 class), either of which may be empty to match any.
+func FindWindow(ctx context.Context, workspace, appID string) (*Window, error) {
+	tree, err := getTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	win := &Window{}
+	node := findWindow(tree, workspace, appID, win)
+	if node == nil {
+		return nil, fmt.Errorf("no window found matching workspace %q and app id %q", workspace, appID)
+	}
+	win.Geometry = node.geometry(ctx, "")
+	return win, nil
+}
+
+// Reveal focuses a window, switching to its workspace if needed, and returns
+// a function putting back the workspaces shown on every output and the focus
+// as they were.
+func Reveal(ctx context.Context, win *Window) (func(), error) {
+	workspaces, err := getWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The focused workspace comes last so that it ends up focused, followed
+	// by the window which had the focus in it, if any.
+	var restore []string
+	focused := ""
+	for _, ws := range workspaces {
+		switch {
+		case ws.Focused:
+			focused = ws.Name
+		case ws.Visible:
+			restore = append(restore, "workspace --no-auto-back-and-forth "+quote(ws.Name))
+		}
+	}
+	if focused != "" {
+		restore = append(restore, "workspace --no-auto-back-and-forth "+quote(focused))
+	}
+	if prev, err := GetFocusedWindow(ctx); err == nil && prev.ID != 0 && prev.Workspace == focused {
+		restore = append(restore, fmt.Sprintf("[con_id=%d] focus", prev.ID))
+	}
+
+	if err := Command(ctx, fmt.Sprintf("[con_id=%d] focus", win.ID)); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// Sent as a single command list so sway applies it in one go
+		_ = Command(context.Background(), strings.Join(restore, "; "))
+	}, nil
+}
+
+// GetUsableArea returns the geometry of an output without the bars and panels
+// reserving space along its edges, which is the area of the workspace shown
+// on it.
+func GetUsableArea(ctx context.Context, output string) (string, error) {
+	workspaces, err := getWorkspaces(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ws := range workspaces {
+		if ws.Visible && ws.Output == output {
+			r := ws.Rect
+			return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.Width, r.Height), nil
+		}
+	}
+	return "", fmt.Errorf("no workspace shown on output %s", output)
+}
+
+func getWorkspaces(ctx context.Context) ([]swayWorkspace, error) {
+	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_workspaces")
+	output, err := external.Output(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sway workspaces: %w", err)
+	}
+
+	var workspaces []swayWorkspace
+	if err := json.Unmarshal(output, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to parse sway workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// WatchFocus calls fn with the centre of each window getting the focus, until
+// ctx is done or sway goes away.
+func WatchFocus(ctx context.Context, fn func(x, y int)) error {
+	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "subscribe", "-m", `["window"]`)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := external.Start(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to watch sway focus: %w", err)
+	}
+	defer func() { _ = cmd.Wait() }()
+
+	decoder := json.NewDecoder(stdout)
+	for {
+		var event struct {
+			Change    string   `json:"change"`
+			Container swayNode `json:"container"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			return nil
+		}
+		if event.Change == "focus" {
+			r := event.Container.Rect
+			fn(r.X+r.Width/2, r.Y+r.Height/2)
+		}
+	}
+}
+
+// Command runs a sway command.
+func Command(ctx context.Context, command string) error {
+	cmd := exec.CommandContext(ctx, "swaymsg", command) //nolint:gosec
+	if out, err := external.CombinedOutput(ctx, cmd); err != nil {
+		return fmt.Errorf("sway command %q failed: %w: %s", command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// GetOutputBackgrounds returns the background specification of each output
+// as declared in the loaded sway configuration, keyed by output name or "*".
+func GetOutputBackgrounds(ctx context.Context) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "swaymsg", "-t", "get_config")
+	output, err := external.Output(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sway config: %w", err)
+	}
+
+	var cfg struct {
+		Config string `json:"config"`
+	}
+	if err := json.Unmarshal(output, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse sway config: %w", err)
+	}
+
+	return parseOutputBackgrounds(cfg.Config), nil
+}
+
+// SetOutputBackground changes the background of an output, spec being
+// anything sway accepts after "bg" (e.g. "#000000 solid_color").
+func SetOutputBackground(ctx context.Context, output, spec string) error {
+	cmd := exec.CommandContext(ctx, "swaymsg", "output", output, "bg", spec) //nolint:gosec
+	if out, err := external.CombinedOutput(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to set background on %s: %w: %s", output, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func parseOutputBackgrounds(config string) map[string]string {
+	vars := map[string]string{}
+	backgrounds := map[string]string{}
+
+	for _, line := range strings.Split(config, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] == "set" && len(fields) >= 3 && strings.HasPrefix(fields[1], "$") {
+			vars[fields[1]] = strings.Join(fields[2:], " ")
+			continue
+		}
+
+		if fields[0] != "output" || len(fields) < 4 {
+			continue
+		}
+		if fields[2] != "bg" && fields[2] != "background" {
+			continue
+		}
+
+		spec := strings.Join(fields[3:], " ")
+		for name, value := range vars {
+			spec = strings.ReplaceAll(spec, name, value)
+		}
+		backgrounds[strings.Trim(fields[1], `"`)] = spec
+	}
+
+	return backgrounds
+}
+
+// findFocusedWindow walks the tree down to the focused node, filling win with
+// the workspace and output it traverses on the way.
+func findFocusedWindow(node *swayNode, win *Window) *swayNode {
+	switch node.Type {
+	case "output":
+		win.Output = node.Name
+	case "workspace":
+		win.Workspace = node.Name
+	}
+
+	if node.Focused {
+		win.ID = node.ID
+		win.AppID = node.AppID
+		if win.AppID == "" {
+			win.AppID = node.WindowProperties.Class
+		}
+		win.Title = node.Name
+		win.PID = node.PID
+		return node
+	}
+
+	for i := range node.Nodes {
+		if found := findFocusedWindow(&node.Nodes[i], win); found != nil {
+			return found
+		}
+	}
+
+	for i := range node.FloatingNodes {
+		if found := findFocusedWindow(&node.FloatingNodes[i], win); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// findWindow walks the tree looking for a window matching the criteria,
+// filling win with it along with its workspace and output.
+func findWindow(node *swayNode, workspace, appID string, win *Window) *swayNode {
+	switch node.Type {
+	case "output":
+		win.Output = node.Name
+	case "workspace":
+		win.Workspace = node.Name
+	}
+
+	if (node.Type == "con" || node.Type == "floating_con") && len(node.Nodes) == 0 && node.PID != 0 {
+		id := node.AppID
+		if id == "" {
+			id = node.WindowProperties.Class
+		}
+		if (workspace == "" || win.Workspace == workspace) && (appID == "" || strings.EqualFold(id, appID)) {
+			win.ID = node.ID
+			win.AppID = id
+			win.Title = node.Name
+			win.PID = node.PID
+			return node
+		}
+	}
+
+	for i := range node.Nodes {
+		if found := findWindow(&node.Nodes[i], workspace, appID, win); found != nil {
+			return found
+		}
+	}
+
+	for i := range node.FloatingNodes {
+		if found := findWindow(&node.FloatingNodes[i], workspace, appID, win); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// geometry returns the area of the screen showing the window, in the format
+// grim and wf-recorder expect, with the decorations asked for.
+func (n *swayNode) geometry(ctx context.Context, decorations string) string {
+	rect := n.Rect
+	switch {
+	case decorations == DecorationsFull:
+		// The title bar sits right above the rect, which excludes it (for
+		// tabbed and stacked containers, that is the strip of tabs)
+		if n.DecoRect.Height > 0 {
+			rect.Y -= n.DecoRect.Height
+			rect.Height += n.DecoRect.Height
+		}
+	case decorations == DecorationsContent && n.Shell != "xwayland":
+		if n.WindowRect.Width > 0 && n.WindowRect.Height > 0 {
+			rect = swayRect{
+				X:      n.Rect.X + n.WindowRect.X,
+				Y:      n.Rect.Y + n.WindowRect.Y,
+				Width:  n.WindowRect.Width,
+				Height: n.WindowRect.Height,
+			}
+		}
+	case n.Shell == "xwayland":
+		rect = n.xwaylandRect(ctx)
+	}
+	return fmt.Sprintf("%d,%d %dx%d", rect.X, rect.Y, rect.Width, rect.Height)
+}
+
+// xwaylandRect returns the rect of an Xwayland window. Their outer rect does
+// not account for server-side decorations the way native windows do, so the
+// content rect is used instead. Client-side decorated X11 windows also draw
+// invisible shadow margins, which they advertise in _GTK_FRAME_EXTENTS and
+// get trimmed when xprop can read them.
+func (n *swayNode) xwaylandRect(ctx context.Context) swayRect {
+	rect := n.Rect
+	if n.WindowRect.Width > 0 && n.WindowRect.Height > 0 {
+		rect = swayRect{
+			X:      n.Rect.X + n.WindowRect.X,
+			Y:      n.Rect.Y + n.WindowRect.Y,
+			Width:  n.WindowRect.Width,
+			Height: n.WindowRect.Height,
+		}
+	}
+
+	if n.Window == 0 || !capability.Available(capability.X11Props) {
+		return rect
+	}
+	left, right, top, bottom, err := external.XpropFrameExtents(ctx, n.Window)
+	if err != nil || left+right >= rect.Width || top+bottom >= rect.Height {
+		return rect
+	}
+
+	return swayRect{
+		X:      rect.X + left,
+		Y:      rect.Y + top,
+		Width:  rect.Width - left - right,
+		Height: rect.Height - top - bottom,
+	}
+}
+
 func findFocused(node *swayNode) *swayNode {
 	if node.Focused {
 		return node
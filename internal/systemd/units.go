@@ -0,0 +1,73 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Unit names, the socket activating the service of the same name.
+const (
+	ServiceUnit = "sway-easyshot.service"
+	SocketUnit  = "sway-easyshot.socket"
+)
+
+// Target is the target the socket is started with and stopped with.
+const Target = "sway-session.target"
+
+// UserUnitDir returns the directory of the units of the user.
+func UserUnitDir() string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, _ := os.UserHomeDir()
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "systemd", "user")
+}
+
+// Units returns the content of the service and socket units, by name, for
+// the daemon run as executable and listening on socketPath. A socket path
+// below runtimeDir is written relative to %t, systemd's runtime directory.
+func Units(executable, socketPath, runtimeDir string) map[string]string {
+	if rel, err := filepath.Rel(runtimeDir, socketPath); err == nil && !strings.HasPrefix(rel, "..") {
+		socketPath = "%t/" + rel
+	}
+
+	service := fmt.Sprintf(`[Unit]
+Description=sway-easyshot daemon
+Requires=%[1]s
+After=%[1]s
+
+[Service]
+Type=simple
+ExecStart=%[2]s daemon
+Restart=on-failure
+RestartSec=5
+`, SocketUnit, quote(executable))
+
+	socket := fmt.Sprintf(`[Unit]
+Description=sway-easyshot daemon socket
+PartOf=%[1]s
+
+[Socket]
+ListenStream=%[2]s
+SocketMode=0600
+DirectoryMode=0700
+
+[Install]
+WantedBy=%[1]s
+`, Target, socketPath)
+
+	return map[string]string{ServiceUnit: service, SocketUnit: socket}
+}
+
+// quote escapes the specifiers systemd expands in path, and quotes it for
+// ExecStart when it holds spaces.
+func quote(path string) string {
+	path = strings.ReplaceAll(path, "%", "%%")
+	if !strings.ContainsAny(path, " \t\"") {
+		return path
+	}
+	return `"` + strings.ReplaceAll(path, `"`, `\"`) + `"`
+}
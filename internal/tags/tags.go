@@ -0,0 +1,116 @@
+package tags
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/sway"
+)
+
+// Tags describes the context a capture was taken in.
+type Tags struct {
+	Workspace string `json:"workspace,omitempty"`
+	AppID     string `json:"app_id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Project   string `json:"project,omitempty"`
+}
+
+// Collect derives tags from the focused window. Missing information is left
+// empty rather than failing the capture.
+func Collect(ctx context.Context) Tags {
+	win, err := sway.GetFocusedWindow(ctx)
+	if err != nil {
+		return Tags{}
+	}
+
+	return Tags{
+		Workspace: win.Workspace,
+		AppID:     win.AppID,
+		Title:     win.Title,
+		Project:   project(win.PID),
+	}
+}
+
+// Slug returns a version of a tag value safe to use in a filename.
+func Slug(value string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, value), "-.")
+}
+
+// project returns the name of the project the window's process tree is
+// working in: the repository root when inside one, the directory otherwise.
+// For terminals this follows the most recently started descendant (the shell
+// or the program running in it).
+func project(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+
+	cwd, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(youngestDescendant(pid)), "cwd"))
+	if err != nil {
+		return ""
+	}
+
+	home, _ := os.UserHomeDir()
+	for dir := cwd; dir != "/" && dir != home && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return filepath.Base(dir)
+		}
+	}
+
+	if cwd == home {
+		return ""
+	}
+	return filepath.Base(cwd)
+}
+
+// youngestDescendant follows the most recently created child process down
+// from pid and returns the deepest one.
+func youngestDescendant(pid int) int {
+	children := make(map[int][]int)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return pid
+	}
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat")) //nolint:gosec
+		if err != nil {
+			continue
+		}
+		// The command name may contain spaces, fields start after its ')'
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	for {
+		kids := children[pid]
+		if len(kids) == 0 {
+			return pid
+		}
+		// PIDs are allocated increasingly, the highest is the youngest
+		youngest := kids[0]
+		for _, k := range kids[1:] {
+			youngest = max(youngest, k)
+		}
+		pid = youngest
+	}
+}
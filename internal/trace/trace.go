@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Capacity is the number of entries kept in the ring buffer.
+const Capacity = 256
+
+// Kinds of trace entries.
+const (
+	KindRequest  = "request"
+	KindResponse = "response"
+	KindExec     = "exec"
+	KindHTTP     = "http"
+)
+
+// Entry is a single recorded event.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// String formats the entry as a single log line.
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %-8s %s", e.Time.Format("15:04:05.000"), e.Kind, e.Message)
+}
+
+var (
+	mu      sync.Mutex
+	entries = make([]Entry, 0, Capacity)
+	next    int
+)
+
+// Add records an event, evicting the oldest one when the buffer is full.
+func Add(kind, format string, args ...interface{}) {
+	entry := Entry{Time: time.Now(), Kind: kind, Message: fmt.Sprintf(format, args...)}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(entries) < Capacity {
+		entries = append(entries, entry)
+		return
+	}
+	entries[next] = entry
+	next = (next + 1) % Capacity
+}
+
+// Entries returns the recorded events, oldest first.
+func Entries() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	out := make([]Entry, 0, len(entries))
+	out = append(out, entries[next:]...)
+	return append(out, entries[:next]...)
+}
+
+// Exec records the outcome of an external command invocation.
+func Exec(cmd *exec.Cmd, err error) {
+	code := 0
+	if err != nil {
+		code = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+	}
+
+	if err != nil && code == -1 {
+		Add(KindExec, "%s (error: %v)", commandLine(cmd), err)
+		return
+	}
+	Add(KindExec, "%s (exit %d)", commandLine(cmd), code)
+}
+
+// Run runs cmd and records its outcome.
+func Run(cmd *exec.Cmd) error {
+	err := cmd.Run()
+	Exec(cmd, err)
+	return err
+}
+
+// Output runs cmd, records its outcome and returns its standard output.
+func Output(cmd *exec.Cmd) ([]byte, error) {
+	out, err := cmd.Output()
+	Exec(cmd, err)
+	return out, err
+}
+
+// CombinedOutput runs cmd, records its outcome and returns its combined
+// standard output and standard error.
+func CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	out, err := cmd.CombinedOutput()
+	Exec(cmd, err)
+	return out, err
+}
+
+// Start starts cmd and records whether it could be started.
+func Start(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	if err != nil {
+		Add(KindExec, "%s (error: %v)", commandLine(cmd), err)
+		return err
+	}
+	Add(KindExec, "%s (started, pid %d)", commandLine(cmd), cmd.Process.Pid)
+	return nil
+}
+
+// commandLine returns the command line of cmd with secrets masked.
+func commandLine(cmd *exec.Cmd) string {
+	args := make([]string, len(cmd.Args))
+	copy(args, cmd.Args)
+	for i := 1; i < len(args); i++ {
+		if args[i-1] == "--password" {
+			args[i] = "********"
+		}
+	}
+	return strings.Join(args, " ")
+}
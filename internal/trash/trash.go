@@ -0,0 +1,99 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Move moves file to the trash of the user, following the freedesktop.org
+// trash specification so that file managers can restore it to original.
+func Move(file, original string) error {
+	dir := Dir()
+	if err := os.MkdirAll(filepath.Join(dir, "files"), 0o700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "info"), 0o700); err != nil {
+		return err
+	}
+
+	name, err := reserve(dir, original)
+	if err != nil {
+		return err
+	}
+	if err := moveFile(file, filepath.Join(dir, "files", name)); err != nil {
+		_ = os.Remove(filepath.Join(dir, "info", name+".trashinfo"))
+		return fmt.Errorf("failed to trash %s: %w", filepath.Base(original), err)
+	}
+	return nil
+}
+
+// Dir returns the home trash directory.
+func Dir() string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, _ := os.UserHomeDir()
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash")
+}
+
+// reserve writes the info file of original under a name no other trashed
+// file uses and returns that name.
+func reserve(dir, original string) (string, error) {
+	base := filepath.Base(original)
+	ext := filepath.Ext(base)
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: original}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = strings.TrimSuffix(base, ext) + "." + strconv.Itoa(i) + ext
+		}
+		f, err := os.OpenFile(filepath.Join(dir, "info", name+".trashinfo"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(info); err != nil {
+			_ = f.Close()
+			return "", err
+		}
+		return name, f.Close()
+	}
+}
+
+// moveFile moves a file, copying it when it crosses file systems.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(from) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(to)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
@@ -0,0 +1,269 @@
+package undo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TTL is how long the last destructive operation can be undone, after which
+// the originals kept for it are deleted.
+const TTL = 10 * time.Minute
+
+// ErrNothing is returned by Undo when there is no operation left to undo.
+var ErrNothing = errors.New("nothing to undo")
+
+// Move is a file moved by an operation, which undoing moves back from To to
+// From.
+type Move struct {
+	From string
+	To   string
+}
+
+// Operation is a destructive operation which can be undone.
+type Operation struct {
+	// Label describes the operation in notifications (e.g. "Rename").
+	Label string
+	Moves []Move
+	// ClipboardType and Clipboard hold what the clipboard held before the
+	// operation overwrote it.
+	ClipboardType string
+	Clipboard     []byte
+	At            time.Time
+
+	// restore puts back what else the operation cleared, if anything.
+	restore func() error
+	// deleted is set when the files were deleted by the user, rather than
+	// kept as a backup before being overwritten.
+	deleted bool
+}
+
+// Discard gets rid of a deleted file, once it can no longer be undone,
+// given where it was deleted from.
+type Discard func(file, original string) error
+
+// Journal remembers the last destructive operation and keeps the originals
+// it replaced in a trash area until it expires or another one replaces it.
+type Journal struct {
+	dir     string
+	discard Discard
+	mu      sync.Mutex
+	last    *Operation
+}
+
+// New returns a journal keeping its originals in dir. Deleted files are
+// handed to discard once they expire, or removed when it is nil.
+func New(dir string, discard Discard) *Journal {
+	return &Journal{dir: dir, discard: discard}
+}
+
+// Sweep discards the originals left over by a previous daemon, which can no
+// longer be undone.
+func (j *Journal) Sweep() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.last != nil {
+		return
+	}
+	if j.discard != nil {
+		// Where they came from is not known anymore
+		entries, _ := os.ReadDir(j.dir)
+		for _, entry := range entries {
+			file := filepath.Join(j.dir, entry.Name())
+			_ = j.discard(file, file)
+		}
+	}
+	_ = os.RemoveAll(j.dir)
+}
+
+// Moved records that file from has been moved to to.
+func (j *Journal) Moved(label, from, to string) {
+	j.record(&Operation{Label: label, Moves: []Move{{From: from, To: to}}})
+}
+
+// Overwriting keeps a copy of file, which is about to be overwritten.
+func (j *Journal) Overwriting(label, file string) error {
+	backup, err := j.trashPath(file)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(file, backup); err != nil {
+		return fmt.Errorf("failed to keep a copy of %s: %w", filepath.Base(file), err)
+	}
+	j.record(&Operation{Label: label, Moves: []Move{{From: file, To: backup}}})
+	return nil
+}
+
+// Trash moves files to the trash area instead of deleting them. Files which
+// cannot be moved are left in place and returned with the error.
+func (j *Journal) Trash(label string, files []string) ([]string, error) {
+	op := &Operation{Label: label, deleted: true}
+	var left []string
+	var errs []error
+	for _, file := range files {
+		target, err := j.trashPath(file)
+		if err == nil {
+			err = moveFile(file, target)
+		}
+		if err != nil {
+			left = append(left, file)
+			errs = append(errs, err)
+			continue
+		}
+		op.Moves = append(op.Moves, Move{From: file, To: target})
+	}
+	if len(op.Moves) > 0 {
+		j.record(op)
+	}
+	return left, errors.Join(errs...)
+}
+
+// Delete moves file, unless empty, to the trash area and records the
+// deletion along with restore, which undoing runs to put back what else was
+// cleared with the file.
+func (j *Journal) Delete(label, file string, restore func() error) error {
+	op := &Operation{Label: label, restore: restore, deleted: true}
+	if file != "" {
+		target, err := j.trashPath(file)
+		if err != nil {
+			return err
+		}
+		if err := moveFile(file, target); err != nil {
+			return err
+		}
+		op.Moves = []Move{{From: file, To: target}}
+	}
+	j.record(op)
+	return nil
+}
+
+// Clipboard records the clipboard content about to be overwritten.
+func (j *Journal) Clipboard(label, mimeType string, data []byte) {
+	j.record(&Operation{Label: label, ClipboardType: mimeType, Clipboard: data})
+}
+
+// Last returns the operation which would be undone, nil when there is none.
+func (j *Journal) Last() *Operation {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+// Undo reverts the last operation, restoring the clipboard with restore,
+// and returns it. The operation is forgotten even when some of it could not be
+// reverted, so that undoing twice does not move files around again.
+func (j *Journal) Undo(restore func(data []byte, mimeType string) error) (*Operation, error) {
+	j.mu.Lock()
+	op := j.last
+	j.last = nil
+	j.mu.Unlock()
+	if op == nil {
+		return nil, ErrNothing
+	}
+
+	var errs []error
+	for _, m := range op.Moves {
+		if err := os.MkdirAll(filepath.Dir(m.From), 0o700); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := moveFile(m.To, m.From); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", filepath.Base(m.From), err))
+		}
+	}
+	if op.ClipboardType != "" {
+		if err := restore(op.Clipboard, op.ClipboardType); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore the clipboard: %w", err))
+		}
+	}
+	if op.restore != nil {
+		if err := op.restore(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return op, errors.Join(errs...)
+}
+
+// record makes op the last operation, dropping the originals of the previous
+// one, and forgets it once it expires.
+func (j *Journal) record(op *Operation) {
+	op.At = time.Now()
+
+	j.mu.Lock()
+	previous := j.last
+	j.last = op
+	j.mu.Unlock()
+
+	j.purge(previous)
+	time.AfterFunc(TTL, func() {
+		j.mu.Lock()
+		expired := j.last == op
+		if expired {
+			j.last = nil
+		}
+		j.mu.Unlock()
+		if expired {
+			j.purge(op)
+		}
+	})
+}
+
+// purge gets rid of the originals kept in the trash area for op. Deleted
+// files which cannot be discarded are left for the next sweep.
+func (j *Journal) purge(op *Operation) {
+	if op == nil {
+		return
+	}
+	for _, m := range op.Moves {
+		if filepath.Dir(m.To) != j.dir {
+			continue
+		}
+		if op.deleted && j.discard != nil {
+			_ = j.discard(m.To, m.From)
+			continue
+		}
+		_ = os.Remove(m.To)
+	}
+}
+
+// trashPath returns a new path in the trash area for file.
+func (j *Journal) trashPath(file string) (string, error) {
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create undo area: %w", err)
+	}
+	return filepath.Join(j.dir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(file))), nil
+}
+
+// moveFile moves a file, copying it when it crosses file systems.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyFile(from, to); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(to)
+		return err
+	}
+	return out.Close()
+}
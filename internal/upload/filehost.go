@@ -0,0 +1,103 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the file host providers.
+const (
+	ZeroXZero  = "0x0"
+	TransferSh = "transfer.sh"
+)
+
+// userAgent identifies uploads to file hosts, some of which refuse
+// anonymous clients.
+const userAgent = "sway-easyshot"
+
+// zeroXZero uploads to a 0x0.st instance, which keeps files for retention.
+type zeroXZero struct {
+	endpoint  string
+	retention time.Duration
+}
+
+// NewZeroXZero returns the provider uploading to the 0x0.st instance at
+// endpoint, asking for files to be kept for retention.
+func NewZeroXZero(endpoint string, retention time.Duration) Provider {
+	return &zeroXZero{endpoint: endpoint, retention: retention}
+}
+
+func (z *zeroXZero) Name() string  { return ZeroXZero }
+func (z *zeroXZero) Label() string { return "Upload to 0x0.st" }
+
+func (z *zeroXZero) Upload(ctx context.Context, r Request) (string, error) {
+	// The retention is given in hours
+	hours := strconv.Itoa(int(math.Ceil(z.retention.Hours())))
+	body, contentType, err := multipartFile("file", r.File, map[string]string{"expires": hours})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, z.endpoint, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", userAgent)
+
+	return plainURL(send(req))
+}
+
+// transferSh uploads to a transfer.sh instance, which keeps files for
+// retention rounded up to a day.
+type transferSh struct {
+	endpoint  string
+	retention time.Duration
+}
+
+// NewTransferSh returns the provider uploading to the transfer.sh instance
+// at endpoint, asking for files to be kept for retention.
+func NewTransferSh(endpoint string, retention time.Duration) Provider {
+	return &transferSh{endpoint: strings.TrimSuffix(endpoint, "/"), retention: retention}
+}
+
+func (t *transferSh) Name() string  { return TransferSh }
+func (t *transferSh) Label() string { return "Upload to transfer.sh" }
+
+func (t *transferSh) Upload(ctx context.Context, r Request) (string, error) {
+	data, err := os.ReadFile(r.File)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		t.endpoint+"/"+url.PathEscape(filepath.Base(r.File)), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Max-Days", strconv.Itoa(int(math.Ceil(t.retention.Hours()/24))))
+
+	return plainURL(send(req))
+}
+
+// plainURL returns the URL answered in plain text by file hosts.
+func plainURL(data []byte, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	link := strings.TrimSpace(string(data))
+	if u, err := url.Parse(link); err != nil || u.Host == "" {
+		return "", fmt.Errorf("unexpected answer: %q", link)
+	}
+	return link, nil
+}
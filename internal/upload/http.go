@@ -0,0 +1,136 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+	"github.com/chmouel/sway-easyshot/internal/trace"
+)
+
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+// videoClient sends videos, whose upload can take longer than any timeout
+// fitting images; the context still bounds it.
+var videoClient = &http.Client{}
+
+// send sends req and returns the body of its answer, failing on anything
+// but a success status.
+func send(req *http.Request) ([]byte, error) {
+	return sendWith(client, req)
+}
+
+// sendWith sends req with c, failing on anything but a success status.
+func sendWith(c *http.Client, req *http.Request) ([]byte, error) {
+	status, data, err := exchangeWith(c, req)
+	if err != nil {
+		return nil, err
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("%s answered %d %s: %s", req.URL.Host, status, http.StatusText(status), bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// exchange sends req and returns the status and body of its answer.
+func exchange(req *http.Request) (int, []byte, error) {
+	return exchangeWith(client, req)
+}
+
+func exchangeWith(c *http.Client, req *http.Request) (int, []byte, error) {
+	url := req.URL.Redacted()
+	resp, err := c.Do(req)
+	if err != nil {
+		trace.Add(trace.KindHTTP, "%s %s (error: %v)", req.Method, url, err)
+		return 0, nil, fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	trace.Add(trace.KindHTTP, "%s %s (status %d)", req.Method, url, resp.StatusCode)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read answer: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// secret returns value, or what command prints when value is empty, to keep
+// passwords in the keyring (e.g. "secret-tool lookup service minio").
+func secret(ctx context.Context, value, command string) (string, error) {
+	if value != "" || command == "" {
+		return value, nil
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("blank secret command %q", command)
+	}
+	output, err := external.Output(ctx, exec.CommandContext(ctx, args[0], args[1:]...)) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// multipartFile returns a multipart form holding file under field along
+// with the other fields, and its content type.
+func multipartFile(field, file string, fields map[string]string) (*bytes.Buffer, string, error) {
+	data, err := os.ReadFile(file) //nolint:gosec
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	part, err := w.CreateFormFile(field, filepath.Base(file))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, w.FormDataContentType(), nil
+}
+
+// multipartStream returns a multipart body which write fills as it is sent,
+// so that videos are not held in memory, and the writer to get its boundary
+// from.
+func multipartStream(write func(w *multipart.Writer) error) (io.Reader, *multipart.Writer) {
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		err := write(w)
+		if err == nil {
+			err = w.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, w
+}
+
+// copyFile copies file into w.
+func copyFile(w io.Writer, file string) error {
+	f, err := os.Open(file) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,62 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Imgur is the name of the imgur provider.
+const Imgur = "imgur"
+
+const imgurEndpoint = "https://api.imgur.com/3/image"
+
+// imgur uploads anonymously to imgur with the client ID of a registered
+// application.
+type imgur struct {
+	clientID string
+}
+
+// NewImgur returns the imgur provider using clientID.
+func NewImgur(clientID string) Provider {
+	return &imgur{clientID: clientID}
+}
+
+func (i *imgur) Name() string  { return Imgur }
+func (i *imgur) Label() string { return "Upload to imgur" }
+
+func (i *imgur) Upload(ctx context.Context, r Request) (string, error) {
+	body, contentType, err := multipartFile("image", r.File, map[string]string{"type": "file"})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, imgurEndpoint, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Client-ID "+i.clientID)
+
+	data, err := send(req)
+	if err != nil {
+		return "", err
+	}
+
+	var answer struct {
+		Data struct {
+			Link string `json:"link"`
+			// Error is a message or an object depending on the failure
+			Error interface{} `json:"error"`
+		} `json:"data"`
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", fmt.Errorf("failed to parse answer: %w", err)
+	}
+	if !answer.Success || answer.Data.Link == "" {
+		return "", fmt.Errorf("imgur refused the upload: %v", answer.Data.Error)
+	}
+	return answer.Data.Link, nil
+}
@@ -0,0 +1,167 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Nextcloud is the name of the Nextcloud provider.
+const Nextcloud = "nextcloud"
+
+// NextcloudOptions configures the Nextcloud provider.
+type NextcloudOptions struct {
+	// URL is the address of the Nextcloud server.
+	URL  string `yaml:"url"`
+	User string `yaml:"user"`
+	// Password is best an app password, created in the security settings.
+	Password string `yaml:"password"`
+	// PasswordCommand prints the password instead, to keep it in the
+	// keyring.
+	PasswordCommand string `yaml:"password_command"`
+	// Folder receives the captures, "Screenshots" by default.
+	Folder string `yaml:"folder"`
+	// Expires sets the expiry of share links, rounded up to a day.
+	Expires time.Duration `yaml:"expires"`
+}
+
+// Configured reports whether the options are set at all.
+func (o NextcloudOptions) Configured() bool {
+	return o.URL != ""
+}
+
+// Valid checks that configured options can be used.
+func (o NextcloudOptions) Valid() error {
+	if u, err := url.Parse(o.URL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %q", o.URL)
+	}
+	if o.User == "" || (o.Password == "" && o.PasswordCommand == "") {
+		return fmt.Errorf("a user and a password or password command are required")
+	}
+	if o.Expires < 0 {
+		return fmt.Errorf("expires cannot be negative")
+	}
+	return nil
+}
+
+// nextcloud uploads with WebDAV and shares the file with a public link made
+// with the OCS sharing API.
+type nextcloud struct {
+	opts NextcloudOptions
+}
+
+// NewNextcloud returns the Nextcloud provider using opts, which must be
+// valid.
+func NewNextcloud(opts NextcloudOptions) Provider {
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	if opts.Folder == "" {
+		opts.Folder = "Screenshots"
+	}
+	opts.Folder = strings.Trim(opts.Folder, "/")
+	return &nextcloud{opts: opts}
+}
+
+func (n *nextcloud) Name() string  { return Nextcloud }
+func (n *nextcloud) Label() string { return "Share with Nextcloud" }
+
+func (n *nextcloud) Upload(ctx context.Context, r Request) (string, error) {
+	data, err := os.ReadFile(r.File)
+	if err != nil {
+		return "", err
+	}
+	password, err := secret(ctx, n.opts.Password, n.opts.PasswordCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Nextcloud password: %w", err)
+	}
+
+	if err := n.makeFolder(ctx, password); err != nil {
+		return "", err
+	}
+
+	name := path.Join(n.opts.Folder, filepath.Base(r.File))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, n.davURL(name), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(n.opts.User, password)
+	if _, err := send(req); err != nil {
+		return "", err
+	}
+
+	return n.share(ctx, password, name)
+}
+
+// makeFolder creates the folder receiving the captures unless it exists.
+func (n *nextcloud) makeFolder(ctx context.Context, password string) error {
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", n.davURL(n.opts.Folder), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(n.opts.User, password)
+
+	status, data, err := exchange(req)
+	if err != nil {
+		return err
+	}
+	// Method Not Allowed means that the folder is already there
+	if status/100 != 2 && status != http.StatusMethodNotAllowed {
+		return fmt.Errorf("failed to create folder %s: %d %s: %s", n.opts.Folder, status, http.StatusText(status), bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+// share creates a public link to the file at name and returns it.
+func (n *nextcloud) share(ctx context.Context, password, name string) (string, error) {
+	form := url.Values{
+		"path":      {"/" + name},
+		"shareType": {"3"}, // public link
+	}
+	if n.opts.Expires > 0 {
+		form.Set("expireDate", time.Now().Add(n.opts.Expires).Add(24*time.Hour-1).Format("2006-01-02"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		n.opts.URL+"/ocs/v2.php/apps/files_sharing/api/v1/shares?format=json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(n.opts.User, password)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("OCS-APIRequest", "true")
+
+	data, err := send(req)
+	if err != nil {
+		return "", fmt.Errorf("uploaded to %s but failed to share it: %w", name, err)
+	}
+
+	var answer struct {
+		OCS struct {
+			Meta struct {
+				Message string `json:"message"`
+			} `json:"meta"`
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		} `json:"ocs"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", fmt.Errorf("failed to parse answer: %w", err)
+	}
+	if answer.OCS.Data.URL == "" {
+		return "", fmt.Errorf("uploaded to %s but Nextcloud refused to share it: %s", name, answer.OCS.Meta.Message)
+	}
+	return answer.OCS.Data.URL, nil
+}
+
+// davURL returns the WebDAV URL of name, relative to the files of the user.
+func (n *nextcloud) davURL(name string) string {
+	return n.opts.URL + "/remote.php/dav/files/" + url.PathEscape(n.opts.User) + escapePath("/"+name)
+}
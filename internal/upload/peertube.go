@@ -0,0 +1,212 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PeerTube is the name of the PeerTube provider.
+const PeerTube = "peertube"
+
+// peerTubePrivacy maps privacy levels to the identifiers of the PeerTube API.
+var peerTubePrivacy = map[string]int{Public: 1, Unlisted: 2, Private: 3}
+
+// PeerTubeOptions configures the PeerTube provider.
+type PeerTubeOptions struct {
+	// URL is the address of the PeerTube instance.
+	URL  string `yaml:"url"`
+	User string `yaml:"user"`
+	// Password is the password of the account, or PasswordCommand prints
+	// it to keep it in the keyring.
+	Password        string `yaml:"password"`
+	PasswordCommand string `yaml:"password_command"`
+	// Channel is the name of the channel to publish to, the first one of
+	// the account by default.
+	Channel string `yaml:"channel"`
+}
+
+// Configured reports whether the options are set at all.
+func (o PeerTubeOptions) Configured() bool {
+	return o.URL != ""
+}
+
+// Valid checks that configured options can be used.
+func (o PeerTubeOptions) Valid() error {
+	if u, err := url.Parse(o.URL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %q", o.URL)
+	}
+	if o.User == "" || (o.Password == "" && o.PasswordCommand == "") {
+		return fmt.Errorf("a user and a password or password command are required")
+	}
+	return nil
+}
+
+// peerTube publishes videos with the REST API of a PeerTube instance.
+type peerTube struct {
+	opts    PeerTubeOptions
+	privacy string
+}
+
+// NewPeerTube returns the PeerTube provider using opts, which must be
+// valid, publishing videos with the given privacy.
+func NewPeerTube(opts PeerTubeOptions, privacy string) Provider {
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	return &peerTube{opts: opts, privacy: privacy}
+}
+
+func (p *peerTube) Name() string  { return PeerTube }
+func (p *peerTube) Label() string { return "Publish to PeerTube" }
+
+func (p *peerTube) Upload(ctx context.Context, r Request) (string, error) {
+	token, err := p.login(ctx)
+	if err != nil {
+		return "", err
+	}
+	channel, err := p.channel(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	title := r.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(r.File), filepath.Ext(r.File))
+	}
+	body, w := multipartStream(func(w *multipart.Writer) error {
+		fields := map[string]string{
+			"channelId":   strconv.Itoa(channel),
+			"name":        title,
+			"description": r.Description,
+			"privacy":     strconv.Itoa(peerTubePrivacy[p.privacy]),
+		}
+		for k, v := range fields {
+			if err := w.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+		part, err := w.CreateFormFile("videofile", filepath.Base(r.File))
+		if err != nil {
+			return err
+		}
+		return copyFile(part, r.File)
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.URL+"/api/v1/videos/upload", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	data, err := sendWith(videoClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var answer struct {
+		Video struct {
+			UUID      string `json:"uuid"`
+			ShortUUID string `json:"shortUUID"`
+		} `json:"video"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", fmt.Errorf("failed to parse answer: %w", err)
+	}
+	id := answer.Video.ShortUUID
+	if id == "" {
+		id = answer.Video.UUID
+	}
+	if id == "" {
+		return "", fmt.Errorf("PeerTube did not return the published video")
+	}
+	return p.opts.URL + "/w/" + id, nil
+}
+
+// login returns an access token for the account, getting the OAuth client
+// of the instance first.
+func (p *peerTube) login(ctx context.Context) (string, error) {
+	var oauth struct {
+		ID     string `json:"client_id"`
+		Secret string `json:"client_secret"`
+	}
+	if err := p.getJSON(ctx, "/api/v1/oauth-clients/local", "", &oauth); err != nil {
+		return "", err
+	}
+
+	password, err := secret(ctx, p.opts.Password, p.opts.PasswordCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the PeerTube password: %w", err)
+	}
+	form := url.Values{
+		"client_id":     {oauth.ID},
+		"client_secret": {oauth.Secret},
+		"grant_type":    {"password"},
+		"username":      {p.opts.User},
+		"password":      {password},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.URL+"/api/v1/users/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	data, err := send(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to PeerTube: %w", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to log in to PeerTube: no access token")
+	}
+	return token.AccessToken, nil
+}
+
+// channel returns the ID of the channel to publish to.
+func (p *peerTube) channel(ctx context.Context, token string) (int, error) {
+	var me struct {
+		VideoChannels []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"videoChannels"`
+	}
+	if err := p.getJSON(ctx, "/api/v1/users/me", token, &me); err != nil {
+		return 0, err
+	}
+	for _, c := range me.VideoChannels {
+		if p.opts.Channel == "" || c.Name == p.opts.Channel {
+			return c.ID, nil
+		}
+	}
+	if p.opts.Channel != "" {
+		return 0, fmt.Errorf("no PeerTube channel named %s", p.opts.Channel)
+	}
+	return 0, fmt.Errorf("the PeerTube account has no channel")
+}
+
+// getJSON gets path from the instance, with token when set, and decodes the
+// answer into v.
+func (p *peerTube) getJSON(ctx context.Context, path, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.URL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	data, err := send(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 is the name of the S3 provider.
+const S3 = "s3"
+
+// MaxPresignExpiry is the longest validity of a presigned link allowed by
+// the signature.
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// S3Options configures the S3 provider. Any S3-compatible storage works, such
+// as MinIO, Garage or Ceph, by pointing Endpoint at it.
+type S3Options struct {
+	Bucket string `yaml:"bucket"`
+	// Endpoint defaults to AWS in Region.
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	// PathStyle addresses the bucket in the path rather than the host name,
+	// which self-hosted storage usually needs.
+	PathStyle bool   `yaml:"path_style"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// SecretKeyCommand prints the secret key instead, e.g. "secret-tool
+	// lookup service minio" to keep it in the keyring.
+	SecretKeyCommand string `yaml:"secret_key_command"`
+	// Prefix is prepended to the names of the objects (e.g. "screenshots/").
+	Prefix string `yaml:"prefix"`
+	// PublicURL is where the bucket is readable by anyone. Links are made
+	// from it when set, and presigned for Expires otherwise.
+	PublicURL string        `yaml:"public_url"`
+	Expires   time.Duration `yaml:"expires"`
+}
+
+// Configured reports whether the options are set at all.
+func (o S3Options) Configured() bool {
+	return o.Bucket != ""
+}
+
+// Valid checks that configured options can be used.
+func (o S3Options) Valid() error {
+	if o.AccessKey == "" || (o.SecretKey == "" && o.SecretKeyCommand == "") {
+		return fmt.Errorf("an access key and a secret key or secret key command are required")
+	}
+	if o.Endpoint != "" {
+		if u, err := url.Parse(o.Endpoint); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %q", o.Endpoint)
+		}
+	}
+	if o.Expires < 0 || o.Expires > MaxPresignExpiry {
+		return fmt.Errorf("expires must be at most %s", MaxPresignExpiry)
+	}
+	return nil
+}
+
+// s3 uploads to a bucket with the AWS signature version 4.
+type s3 struct {
+	opts S3Options
+}
+
+// NewS3 returns the S3 provider using opts, which must be valid.
+func NewS3(opts S3Options) Provider {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", opts.Region)
+	}
+	if opts.Expires == 0 {
+		opts.Expires = MaxPresignExpiry
+	}
+	return &s3{opts: opts}
+}
+
+func (s *s3) Name() string  { return S3 }
+func (s *s3) Label() string { return "Upload to S3" }
+
+func (s *s3) Upload(ctx context.Context, r Request) (string, error) {
+	data, err := os.ReadFile(r.File)
+	if err != nil {
+		return "", err
+	}
+	secretKey, err := secret(ctx, s.opts.SecretKey, s.opts.SecretKeyCommand)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the S3 secret key: %w", err)
+	}
+
+	key := s.opts.Prefix + filepath.Base(r.File)
+	object := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, object.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(r.File)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, secretKey, sha256Hex(data), time.Now())
+
+	if _, err := send(req); err != nil {
+		return "", err
+	}
+
+	if s.opts.PublicURL != "" {
+		return strings.TrimSuffix(s.opts.PublicURL, "/") + "/" + escapePath(key), nil
+	}
+	return s.presign(object, secretKey, time.Now()), nil
+}
+
+// objectURL returns the URL of the object named key.
+func (s *s3) objectURL(key string) *url.URL {
+	// Validated when loading the configuration
+	u, _ := url.Parse(s.opts.Endpoint)
+	if s.opts.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.opts.Bucket + "/" + key
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	return u
+}
+
+// sign adds the signature of req, whose payload has the given hash, to its
+// headers.
+func (s *s3) sign(req *http.Request, secret, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	signature, scope := s.signature(secret, req.Method, req.URL.RawPath, "", headers, signed, payloadHash, now)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+// presign returns a link to object valid for the configured expiry.
+func (s *s3) presign(object *url.URL, secret string, now time.Time) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.opts.AccessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {fmt.Sprintf("%d", int(s.opts.Expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+	signature, _ := s.signature(secret, http.MethodGet, object.RawPath, canonicalQuery,
+		map[string]string{"host": object.Host}, []string{"host"}, "UNSIGNED-PAYLOAD", now)
+
+	link := *object
+	link.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return link.String()
+}
+
+// signature computes the signature version 4 of a request and returns it
+// with its credential scope.
+func (s *s3) signature(secret, method, path, query string, headers map[string]string, signed []string, payloadHash string, now time.Time) (string, string) {
+	sort.Strings(signed)
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	canonicalRequest := strings.Join([]string{
+		method, path, query, canonicalHeaders.String(), strings.Join(signed, ";"), payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", now.UTC().Format("20060102T150405Z"), scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{now.UTC().Format("20060102"), s.opts.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)), scope
+}
+
+func (s *s3) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.opts.Region + "/s3/aws4_request"
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// escapePath escapes a path the way the signature expects: everything but
+// unreserved characters and slashes.
+func escapePath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
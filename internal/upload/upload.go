@@ -0,0 +1,82 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/chmouel/sway-easyshot/internal/tags"
+)
+
+// Request is a capture to upload.
+type Request struct {
+	// File is the capture on disk, a temporary copy for clipboard captures.
+	File string
+	Tags tags.Tags
+	// Title and Description describe videos on video hosts.
+	Title       string
+	Description string
+}
+
+// Provider uploads captures somewhere they can be shared from.
+type Provider interface {
+	// Name identifies the provider in the configuration and in the queue
+	// of uploads to retry.
+	Name() string
+	// Label is shown to the user in actions and notifications.
+	Label() string
+	// Upload uploads a capture and returns the URL it is shared at, empty
+	// when the provider has none to give.
+	Upload(ctx context.Context, req Request) (string, error)
+}
+
+// Func is a provider backed by a function, such as an upload hook running a
+// command of the user.
+type Func struct {
+	ID    string
+	Title string
+	Run   func(ctx context.Context, req Request) (string, error)
+}
+
+// Name returns the ID of the provider.
+func (f Func) Name() string { return f.ID }
+
+// Label returns the title of the provider.
+func (f Func) Label() string { return f.Title }
+
+// Upload runs the function.
+func (f Func) Upload(ctx context.Context, req Request) (string, error) { return f.Run(ctx, req) }
+
+// Privacy levels of videos published to video hosts.
+const (
+	Public   = "public"
+	Unlisted = "unlisted"
+	Private  = "private"
+)
+
+// ValidPrivacy checks that privacy is a known privacy level.
+func ValidPrivacy(privacy string) error {
+	switch privacy {
+	case Public, Unlisted, Private:
+		return nil
+	}
+	return fmt.Errorf("invalid privacy %q (expected %s, %s or %s)", privacy, Public, Unlisted, Private)
+}
+
+// Providers holds the available providers by name.
+type Providers map[string]Provider
+
+// Add makes a provider available, replacing any other of the same name.
+func (p Providers) Add(provider Provider) {
+	p[provider.Name()] = provider
+}
+
+// Names returns the names of the available providers, sorted.
+func (p Providers) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,266 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// YouTube is the name of the YouTube provider.
+const YouTube = "youtube"
+
+const (
+	googleDeviceEndpoint = "https://oauth2.googleapis.com/device/code"
+	googleTokenEndpoint  = "https://oauth2.googleapis.com/token"
+	youTubeUploadURL     = "https://www.googleapis.com/upload/youtube/v3/videos?uploadType=multipart&part=snippet,status"
+	// youTubeScope is the narrowest scope allowing uploads which Google
+	// grants to the device flow.
+	youTubeScope = "https://www.googleapis.com/auth/youtube"
+	// youTubeTitleLength is the longest title YouTube accepts.
+	youTubeTitleLength = 100
+)
+
+// YouTubeOptions configures the YouTube provider, with the OAuth client of
+// a Google Cloud project of type "TVs and Limited Input devices".
+type YouTubeOptions struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// Configured reports whether the options are set at all.
+func (o YouTubeOptions) Configured() bool {
+	return o.ClientID != ""
+}
+
+// Valid checks that configured options can be used.
+func (o YouTubeOptions) Valid() error {
+	if o.ClientSecret == "" {
+		return fmt.Errorf("a client secret is required along the client ID")
+	}
+	return nil
+}
+
+// Prompt asks the user to authorise sway-easyshot by entering code at
+// verificationURL.
+type Prompt func(code, verificationURL string)
+
+// youTube publishes videos with the YouTube Data API, authorising with the
+// OAuth device flow on first use and keeping the refresh token in tokenFile.
+type youTube struct {
+	opts      YouTubeOptions
+	privacy   string
+	tokenFile string
+	prompt    Prompt
+}
+
+// NewYouTube returns the YouTube provider using opts, which must be valid,
+// publishing videos with the given privacy. The refresh token is kept in
+// tokenFile, and prompt is called when the user needs to authorise it.
+func NewYouTube(opts YouTubeOptions, privacy, tokenFile string, prompt Prompt) Provider {
+	return &youTube{opts: opts, privacy: privacy, tokenFile: tokenFile, prompt: prompt}
+}
+
+func (y *youTube) Name() string  { return YouTube }
+func (y *youTube) Label() string { return "Publish to YouTube" }
+
+func (y *youTube) Upload(ctx context.Context, r Request) (string, error) {
+	token, err := y.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	title := r.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(r.File), filepath.Ext(r.File))
+	}
+	if runes := []rune(title); len(runes) > youTubeTitleLength {
+		title = string(runes[:youTubeTitleLength])
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"snippet": map[string]string{"title": title, "description": r.Description},
+		"status":  map[string]string{"privacyStatus": y.privacy},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	videoType := mime.TypeByExtension(filepath.Ext(r.File))
+	if videoType == "" {
+		videoType = "video/mp4"
+	}
+	body, w := multipartStream(func(w *multipart.Writer) error {
+		part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(metadata); err != nil {
+			return err
+		}
+		part, err = w.CreatePart(textproto.MIMEHeader{"Content-Type": {videoType}})
+		if err != nil {
+			return err
+		}
+		return copyFile(part, r.File)
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, youTubeUploadURL, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+w.Boundary())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	data, err := sendWith(videoClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var answer struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil || answer.ID == "" {
+		return "", fmt.Errorf("YouTube did not return the published video")
+	}
+	return "https://youtu.be/" + answer.ID, nil
+}
+
+// tokenAnswer is the answer of the Google token endpoint.
+type tokenAnswer struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Description  string `json:"error_description,omitempty"`
+}
+
+// accessToken returns an access token, from the saved refresh token or by
+// authorising with the device flow when there is none or it was revoked.
+func (y *youTube) accessToken(ctx context.Context) (string, error) {
+	if data, err := os.ReadFile(y.tokenFile); err == nil {
+		var saved tokenAnswer
+		if json.Unmarshal(data, &saved) == nil && saved.RefreshToken != "" {
+			answer, err := y.token(ctx, url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {saved.RefreshToken},
+			})
+			if err == nil {
+				return answer.AccessToken, nil
+			}
+			if answer.Error != "invalid_grant" {
+				return "", err
+			}
+			// The authorisation was revoked or expired, ask again
+		}
+	}
+	return y.authorise(ctx)
+}
+
+// authorise runs the OAuth device flow: the user enters a code on another
+// page while the token endpoint is polled until they have.
+func (y *youTube) authorise(ctx context.Context) (string, error) {
+	form := url.Values{"client_id": {y.opts.ClientID}, "scope": {youTubeScope}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleDeviceEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	data, err := send(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to start the YouTube authorisation: %w", err)
+	}
+
+	var device struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURL string `json:"verification_url"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	if err := json.Unmarshal(data, &device); err != nil || device.DeviceCode == "" {
+		return "", fmt.Errorf("failed to start the YouTube authorisation: unexpected answer")
+	}
+	y.prompt(device.UserCode, device.VerificationURL)
+
+	interval := time.Duration(max(device.Interval, 5)) * time.Second
+	deadline := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		answer, err := y.token(ctx, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {device.DeviceCode},
+		})
+		switch answer.Error {
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("YouTube authorisation failed: %w", err)
+		}
+
+		if err := y.saveToken(answer); err != nil {
+			return "", err
+		}
+		return answer.AccessToken, nil
+	}
+	return "", fmt.Errorf("YouTube authorisation timed out, publish again to get a new code")
+}
+
+// token asks the token endpoint for an access token with the grant in form.
+// Refused grants return the answer along the error, to tell why.
+func (y *youTube) token(ctx context.Context, form url.Values) (tokenAnswer, error) {
+	form.Set("client_id", y.opts.ClientID)
+	form.Set("client_secret", y.opts.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenAnswer{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, data, err := exchange(req)
+	if err != nil {
+		return tokenAnswer{}, err
+	}
+	var answer tokenAnswer
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return tokenAnswer{}, fmt.Errorf("failed to parse answer: %w", err)
+	}
+	if answer.Error != "" {
+		return answer, errors.New(answer.Error + ": " + answer.Description)
+	}
+	if answer.AccessToken == "" {
+		return answer, fmt.Errorf("no access token given")
+	}
+	return answer, nil
+}
+
+// saveToken keeps the refresh token of answer for the next uploads.
+func (y *youTube) saveToken(answer tokenAnswer) error {
+	if answer.RefreshToken == "" {
+		return nil
+	}
+	data, err := json.Marshal(tokenAnswer{RefreshToken: answer.RefreshToken})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(y.tokenFile), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(y.tokenFile, data, 0o600)
+}
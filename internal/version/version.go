@@ -0,0 +1,88 @@
+// Package version describes the build of the binary, as set at link time:
+//
+//	go build -ldflags "-X sway-easyshot/internal/version.Version=1.2.3 \
+//		-X sway-easyshot/internal/version.Commit=abc123 \
+//		-X sway-easyshot/internal/version.Date=2025-01-01T10:00:00Z"
+//
+// Builds without them, such as go install, fall back to what the Go
+// toolchain recorded.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata set with -ldflags -X.
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes a build.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Go      string `json:"go"`
+}
+
+// Get returns the build of the running binary.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, Go: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info.withDefaults()
+	}
+
+	if info.Version == "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	revision, modified := "", false
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if info.Commit == "" && revision != "" {
+		info.Commit = revision
+		if modified {
+			info.Commit += "-dirty"
+		}
+	}
+	return info.withDefaults()
+}
+
+func (i Info) withDefaults() Info {
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	return i
+}
+
+// String returns the version with the commit, date and Go version it was
+// built from.
+func (i Info) String() string {
+	s := i.Version
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit
+		if i.Date != "" {
+			s += ", " + i.Date
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s, %s", s, i.Go)
+}
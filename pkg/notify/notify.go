@@ -0,0 +1,84 @@
+// Package notify sends desktop notifications with notify-send, optionally
+// with action buttons.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package notify
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/chmouel/sway-easyshot/internal/trace"
+)
+
+// title is the title of notifications, whose message becomes the body when
+// set.
+var title string
+
+// SetTitle sets the title of the notifications sent from now on, e.g. to
+// tell the machine which sends them.
+func SetTitle(t string) {
+	title = t
+}
+
+// text returns the title and body arguments of notify-send for message.
+func text(message string) []string {
+	if title == "" {
+		return []string{message}
+	}
+	return []string{title, message}
+}
+
+// Send sends a desktop notification with a timeout, optional icon, and message.
+func Send(timeout int, icon, message string) error {
+	args := []string{
+		"-t", strconv.Itoa(timeout),
+	}
+	if icon != "" {
+		args = append(args, "-i", icon)
+	}
+	args = append(args, text(message)...)
+
+	cmd := exec.Command("notify-send", args...) //nolint:gosec
+	return trace.Run(cmd)
+}
+
+// Action is a notification button.
+type Action struct {
+	ID    string
+	Label string
+}
+
+// SendWithActions sends a notification with action buttons, in order, and returns the selected action.
+func SendWithActions(timeout int, icon, message string, actions []Action) (string, error) {
+	args := []string{
+		"-t", strconv.Itoa(timeout),
+	}
+	if icon != "" {
+		args = append(args, "-i", icon)
+	}
+
+	for _, action := range actions {
+		args = append(args, "-A", fmt.Sprintf("%s=%s", action.ID, action.Label))
+	}
+	args = append(args, text(message)...)
+
+	cmd := exec.Command("notify-send", args...) //nolint:gosec
+	output, err := trace.Output(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+// CaptureDelay sends a countdown notification if the delay is more than 2 seconds.
+func CaptureDelay(waitSeconds int, label, icon string) error {
+	if waitSeconds > 2 {
+		msg := fmt.Sprintf("Capturing %s in %d seconds", label, waitSeconds)
+		return Send((waitSeconds-1)*1000, icon, msg)
+	}
+	return nil
+}
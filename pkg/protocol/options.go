@@ -0,0 +1,306 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// NewRequest returns the request of action with options, one of the option
+// structs below or nil.
+func NewRequest(action string, options interface{}) Request {
+	req := Request{Command: "execute", Action: action, Version: Version}
+	if options == nil {
+		return req
+	}
+	// The option structs always encode to an object
+	data, _ := json.Marshal(options)
+	_ = json.Unmarshal(data, &req.Options)
+	return req
+}
+
+// DecodeOptions decodes the options of the request into v, a pointer to one
+// of the option structs below, and validates them. Options v has no field
+// for, and options of the wrong type, are errors.
+func (r Request) DecodeOptions(v interface{}) error {
+	if len(r.Options) == 0 {
+		return validate(v)
+	}
+	data, err := json.Marshal(r.Options)
+	if err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid options for %s: %w", r.Action, err)
+	}
+	if err := validate(v); err != nil {
+		return fmt.Errorf("invalid options for %s: %w", r.Action, err)
+	}
+	return nil
+}
+
+func validate(v interface{}) error {
+	if validator, ok := v.(interface{ Validate() error }); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// CaptureOptions are the options of the screenshot and recording actions,
+// each action using those relevant to it.
+type CaptureOptions struct {
+	// Delay is the number of seconds to wait before capturing
+	Delay            int  `json:"delay,omitempty"`
+	UseCurrentScreen bool `json:"use_current_screen,omitempty"`
+	Transparent      bool `json:"transparent,omitempty"`
+	LastRegion       bool `json:"last_region,omitempty"`
+	Composite        bool `json:"composite,omitempty"`
+	// For is the duration after which a recording stops, e.g. 30s
+	For       string `json:"for,omitempty"`
+	Colors    int    `json:"colors,omitempty"`
+	Save      bool   `json:"save,omitempty"`
+	Lang      string `json:"lang,omitempty"`
+	Translate string `json:"translate,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	AppID     string `json:"app_id,omitempty"`
+	// Bars is include or exclude, the configured behaviour when empty
+	Bars        string  `json:"bars,omitempty"`
+	Zoom        float64 `json:"zoom,omitempty"`
+	Pretty      bool    `json:"pretty,omitempty"`
+	Decorations string  `json:"decorations,omitempty"`
+	MaxFrames   int     `json:"max_frames,omitempty"`
+	Timer       string  `json:"timer,omitempty"`
+	Upload      bool    `json:"upload,omitempty"`
+	Ephemeral   bool    `json:"ephemeral,omitempty"`
+	Encrypt     bool    `json:"encrypt,omitempty"`
+}
+
+// Validate checks the options are consistent.
+func (o CaptureOptions) Validate() error {
+	switch {
+	case o.Delay < 0:
+		return errors.New("delay must not be negative")
+	case o.Colors < 0:
+		return errors.New("colors must not be negative")
+	case o.Zoom < 0:
+		return errors.New("zoom must not be negative")
+	case o.MaxFrames < 0:
+		return errors.New("max_frames must not be negative")
+	case o.Bars != "" && o.Bars != "include" && o.Bars != "exclude":
+		return fmt.Errorf("bars must be include or exclude, not %q", o.Bars)
+	case o.Encrypt && o.Upload:
+		return errors.New("--encrypt and --upload are mutually exclusive")
+	}
+	return validDuration("for", o.For)
+}
+
+// CaptureFields are the options of CaptureOptions each capture and
+// recording action takes, by their JSON names. Actions refuse the others
+// rather than ignore them.
+var CaptureFields = map[string][]string{
+	"current-window-clipboard": {"delay", "transparent", "pretty", "decorations", "upload"},
+	"current-window-file":      {"delay", "transparent", "pretty", "decorations", "upload", "ephemeral", "encrypt"},
+	"window-file":              {"delay", "workspace", "app_id", "pretty", "upload", "ephemeral", "encrypt"},
+	"current-screen-clipboard": {"delay", "use_current_screen", "bars", "upload"},
+	"selection-file":           {"delay", "last_region", "pretty", "upload", "ephemeral", "encrypt"},
+	"selection-edit":           {"delay", "last_region"},
+	"selection-clipboard":      {"delay", "last_region", "pretty", "upload"},
+	"selection-multi":          {"delay", "composite", "ephemeral", "encrypt"},
+	"scroll-capture":           {"delay", "max_frames", "pretty", "upload", "ephemeral", "encrypt"},
+	"pick-palette":             {"delay", "last_region", "colors", "save"},
+	"ocr-selection":            {"delay", "last_region", "lang", "translate"},
+	"scan-qr":                  {"delay", "last_region"},
+	"alt-text-selection":       {"delay", "last_region"},
+	"movie-selection":          {"delay", "last_region", "for", "timer"},
+	"movie-screen":             {"delay", "use_current_screen", "bars", "for", "timer"},
+	"movie-zoom":               {"delay", "use_current_screen", "zoom", "for", "timer"},
+	"movie-current-window":     {"delay", "decorations", "for", "timer"},
+	"toggle-record":            {"delay", "use_current_screen", "last_region", "for", "timer"},
+}
+
+// ValidFor checks action takes every option set.
+func (o CaptureOptions) ValidFor(action string) error {
+	// Only the options set are encoded
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	var set map[string]json.RawMessage
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(set)) {
+		if !slices.Contains(CaptureFields[action], name) {
+			return fmt.Errorf("%s does not take %s", action, name)
+		}
+	}
+	return nil
+}
+
+// ToggleRecordOptions are the options of the toggle-record action.
+type ToggleRecordOptions struct {
+	CaptureOptions
+	// StartAction is the recording started, movie-selection when empty
+	StartAction string `json:"start_action,omitempty"`
+}
+
+// RetargetOptions are the options of the retarget action.
+type RetargetOptions struct {
+	// Target is the new recording target, movie-selection when empty
+	Target           string `json:"target,omitempty"`
+	UseCurrentScreen bool   `json:"use_current_screen,omitempty"`
+}
+
+// RepeatLastOptions are the options of the repeat-last action.
+type RepeatLastOptions struct {
+	Delay int `json:"delay,omitempty"`
+}
+
+// Validate checks the options are consistent.
+func (o RepeatLastOptions) Validate() error {
+	if o.Delay < 0 {
+		return errors.New("delay must not be negative")
+	}
+	return nil
+}
+
+// ComposeOptions are the options of the compose action.
+type ComposeOptions struct {
+	// Files are the images composed, the last captures when empty
+	Files []string `json:"files,omitempty"`
+	Last  int      `json:"last,omitempty"`
+	// Columns is the number of columns, chosen from the count when 0
+	Columns int `json:"columns,omitempty"`
+	// Labels tells whether to label the images, true when unset
+	Labels    *bool `json:"labels,omitempty"`
+	Upload    bool  `json:"upload,omitempty"`
+	Ephemeral bool  `json:"ephemeral,omitempty"`
+	Encrypt   bool  `json:"encrypt,omitempty"`
+}
+
+// Validate checks the options are consistent.
+func (o ComposeOptions) Validate() error {
+	switch {
+	case o.Last < 0:
+		return errors.New("last must not be negative")
+	case o.Columns < 0:
+		return errors.New("columns must not be negative")
+	case o.Encrypt && o.Upload:
+		return errors.New("--encrypt and --upload are mutually exclusive")
+	}
+	return nil
+}
+
+// GalleryOptions are the options of the gallery action.
+type GalleryOptions struct {
+	// Limit is the number of captures shown, 30 when unset and all when 0
+	Limit *int `json:"limit,omitempty"`
+}
+
+// ActionOptions are the options of the action action.
+type ActionOptions struct {
+	// Name is the post-capture action run, picked from a menu when empty
+	Name string `json:"name,omitempty"`
+	// File is the file it runs on, the last capture when empty
+	File string `json:"file,omitempty"`
+}
+
+// ShutdownOptions are the options of the shutdown action.
+type ShutdownOptions struct {
+	// Force stops the recording in progress rather than refusing to stop
+	Force bool `json:"force,omitempty"`
+}
+
+// OBSSourceOptions are the options of the obs-toggle-source action.
+type OBSSourceOptions struct {
+	// Scene holds the source, the current scene when empty
+	Scene  string `json:"scene,omitempty"`
+	Source string `json:"source"`
+}
+
+// Validate checks the options are consistent.
+func (o OBSSourceOptions) Validate() error {
+	if o.Source == "" {
+		return errors.New("no source to toggle")
+	}
+	return nil
+}
+
+// WaybarStatusOptions are the options of the waybar-status action.
+type WaybarStatusOptions struct {
+	// Icons replace the icons of the states named Idle, Recording, Paused,
+	// ObsRecording, ObsPaused, ObsStreaming and Countdown
+	Icons map[string]string `json:"icons,omitempty"`
+}
+
+// SubscribeOptions are the options of the subscribe action.
+type SubscribeOptions struct {
+	// Icons replace the icons of the status, as for waybar-status
+	Icons map[string]string `json:"icons,omitempty"`
+}
+
+// HistoryOptions are the options of the history action.
+type HistoryOptions struct {
+	// Kind is screenshot or recording, both when empty
+	Kind  string `json:"kind,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+	// Since only keeps the captures taken within this duration, e.g. 24h
+	Since string `json:"since,omitempty"`
+}
+
+// Validate checks the options are consistent.
+func (o HistoryOptions) Validate() error {
+	if o.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	return validDuration("since", o.Since)
+}
+
+// LastOptions are the options of the last action.
+type LastOptions struct {
+	// Kind is screenshot or recording, both when empty
+	Kind string `json:"kind,omitempty"`
+	// Copy also copies the path to the clipboard
+	Copy bool `json:"copy,omitempty"`
+}
+
+// CleanupOptions are the options of the cleanup action.
+type CleanupOptions struct {
+	DryRun bool `json:"dry_run,omitempty"`
+	// OlderThan replaces the configured age of the captures removed
+	OlderThan string `json:"older_than,omitempty"`
+}
+
+// Validate checks the options are consistent.
+func (o CleanupOptions) Validate() error {
+	return validDuration("older_than", o.OlderThan)
+}
+
+// LogsOptions are the options of the logs action.
+type LogsOptions struct {
+	// After only returns the lines numbered after it
+	After uint64 `json:"after,omitempty"`
+	// Lines is the number of lines returned, all of them when 0
+	Lines int `json:"lines,omitempty"`
+}
+
+// validDuration checks value, named name, is empty or a positive duration.
+func validDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration for %s: %w", name, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be a positive duration, not %q", name, value)
+	}
+	return nil
+}
@@ -1,10 +1,37 @@
+// Package protocol defines the JSON messages exchanged with the daemon over
+// its socket, or its TCP endpoint when enabled.
+//
+// Each message is a JSON object on a line of its own. A client sends a
+// request and reads responses until one carries neither progress nor an
+// event, which is the final one. The daemon then closes the connection,
+// unless the request asked to keep it alive for the next one.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
 package protocol
 
+// Version is the version of the protocol, increased by changes which older
+// clients or daemons cannot understand.
+const Version = 1
+
 // Request represents a command request to the daemon
 type Request struct {
 	Command string                 `json:"command"`
 	Action  string                 `json:"action"`
 	Options map[string]interface{} `json:"options,omitempty"`
+	// Version is the version of the protocol the client speaks, 0 for
+	// clients older than versioning, which speak the first one
+	Version int `json:"version,omitempty"`
+	// ID identifies the request in the responses to it
+	ID string `json:"id,omitempty"`
+	// Progress asks for progress responses before the final one
+	Progress bool `json:"progress,omitempty"`
+	// KeepAlive keeps the connection open for another request once the
+	// response is sent, rather than closing it
+	KeepAlive bool `json:"keep_alive,omitempty"`
+	// Token authenticates requests sent over TCP, and is ignored on the
+	// socket
+	Token string `json:"token,omitempty"`
 }
 
 // Response represents a response from the daemon
@@ -12,15 +39,109 @@ type Response struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
 	State   *State `json:"state,omitempty"`
+	// Files are the paths the command saved captures to
+	Files []string `json:"files,omitempty"`
+	// Code tells why a command failed, when known
+	Code string `json:"code,omitempty"`
+	// Health answers the health action
+	Health *Health `json:"health,omitempty"`
+	// Version is the version of the protocol the daemon speaks
+	Version int `json:"version,omitempty"`
+	// ID is the ID of the request answered
+	ID string `json:"id,omitempty"`
+	// Progress is only set on the responses streamed before the final one
+	// to requests asking for progress
+	Progress *Progress `json:"progress,omitempty"`
+	// Event is set on the responses pushed to subscribed clients
+	Event *Event `json:"event,omitempty"`
+}
+
+// Event is pushed to the clients subscribed with the subscribe action, the
+// current one first.
+type Event struct {
+	// Type is one of the event types below
+	Type  string `json:"type"`
+	State *State `json:"state,omitempty"`
+	// Status is the status for bars
+	Status *WaybarStatus `json:"status,omitempty"`
+}
+
+// Event types.
+const (
+	// EventState is sent when the state or the status for bars changes
+	EventState = "state"
+)
+
+// Progress reports how far a long operation has gone.
+type Progress struct {
+	// Stage is one of the stages below
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+	// Remaining is the number of seconds left in a countdown, or of
+	// requests ahead in the queue
+	Remaining int `json:"remaining,omitempty"`
 }
 
+// Stages of the operations reporting progress.
+const (
+	// StageQueued is waiting for the captures requested before to finish
+	StageQueued = "queued"
+	// StageCountdown is the delay before a capture or recording
+	StageCountdown = "countdown"
+	// StageSelecting is waiting for the user to select a region
+	StageSelecting = "selecting"
+	// StageRecording is a recording having started
+	StageRecording = "recording"
+	// StageConverting is a finished recording being converted
+	StageConverting = "converting"
+	// StageWorking is sent every few seconds while nothing else is, to
+	// tell the client the request is still being served
+	StageWorking = "working"
+)
+
+// Codes of failed responses.
+const (
+	// CodeCancelled is the code of commands the user cancelled
+	CodeCancelled = "cancelled"
+	// CodeUnavailable is the code of commands needing a missing tool
+	CodeUnavailable = "unavailable"
+	// CodeBusy is the code of commands ignored because the same command
+	// is still in progress
+	CodeBusy = "busy"
+	// CodeInvalid is the code of requests with invalid options
+	CodeInvalid = "invalid"
+	// CodeUnsupported is the code of requests from clients speaking a
+	// newer version of the protocol than the daemon
+	CodeUnsupported = "unsupported"
+	// CodeUnauthorized is the code of requests over TCP without a valid
+	// token, or whose token is not allowed the action
+	CodeUnauthorized = "unauthorized"
+)
+
 // State represents the current daemon state
 type State struct {
+	// Host is the label of the machine the daemon runs on
+	Host          string `json:"host,omitempty"`
 	Recording     bool   `json:"recording"`
 	Paused        bool   `json:"paused"`
 	RecordingFile string `json:"recording_file,omitempty"`
 	OBSRecording  bool   `json:"obs_recording"`
 	OBSPaused     bool   `json:"obs_paused"`
+	OBSStreaming  bool   `json:"obs_streaming"`
+	// Capabilities reports which optional features are usable
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+}
+
+// Health reports whether the daemon works: what it runs and which of the
+// external tools it can run.
+type Health struct {
+	// Protocol is the version of the protocol the daemon speaks
+	Protocol int    `json:"protocol"`
+	Version  string `json:"version"`
+	// Uptime is the number of seconds the daemon has been running for
+	Uptime float64 `json:"uptime"`
+	// Tools maps the tools the daemon runs to whether they are installed
+	Tools map[string]bool `json:"tools"`
 }
 
 // WaybarStatus represents the status for waybar integration
@@ -29,4 +150,6 @@ type WaybarStatus struct {
 	Tooltip string `json:"tooltip"`
 	Class   string `json:"class"`
 	Alt     string `json:"alt"`
+	// Thumbnail is the path of a preview of the most recent capture
+	Thumbnail string `json:"thumbnail,omitempty"`
 }
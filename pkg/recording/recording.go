@@ -0,0 +1,40 @@
+// Package recording records the screen on wlroots compositors with
+// wf-recorder.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package recording
+
+import (
+	"context"
+	"os"
+	"os/exec"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// Start starts recording the geometry, given as "x,y wxh", or the output,
+// or the focused output when both are empty, to filename with wf-recorder.
+// The recording stops when the command is interrupted with SIGINT.
+func Start(ctx context.Context, geometry, output, filename string) (*exec.Cmd, error) {
+	args := []string{}
+
+	if geometry != "" {
+		args = append(args, "-g", geometry)
+	}
+	if output != "" {
+		args = append(args, "-o", output)
+	}
+
+	args = append(args, "-f", filename)
+
+	cmd := exec.CommandContext(ctx, "wf-recorder", args...) //nolint:gosec
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := external.Start(ctx, cmd); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,742 @@
+// The gRPC service of the daemon, mirroring the messages of the protocol
+// package: see its documentation for the actions and their options.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: daemon.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Request runs an action.
+type Request struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Action string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	// Options are those of the action, as in the protocol
+	Options *structpb.Struct `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	// Progress asks for progress responses before the final one
+	Progress      bool `protobuf:"varint,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Request) Reset() {
+	*x = Request{}
+	mi := &file_daemon_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Request) ProtoMessage() {}
+
+func (x *Request) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Request.ProtoReflect.Descriptor instead.
+func (*Request) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Request) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *Request) GetOptions() *structpb.Struct {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *Request) GetProgress() bool {
+	if x != nil {
+		return x.Progress
+	}
+	return false
+}
+
+// Response answers a request.
+type Response struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	State   *State                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	// Files are the paths the command saved captures to
+	Files []string `protobuf:"bytes,4,rep,name=files,proto3" json:"files,omitempty"`
+	// Code tells why a command failed, when known
+	Code   string  `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+	Health *Health `protobuf:"bytes,6,opt,name=health,proto3" json:"health,omitempty"`
+	// Progress is only set on the responses streamed before the final one
+	Progress      *Progress `protobuf:"bytes,7,opt,name=progress,proto3" json:"progress,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	mi := &file_daemon_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Response) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Response) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Response) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *Response) GetFiles() []string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *Response) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Response) GetHealth() *Health {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+func (x *Response) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+// State is the state of the daemon.
+type State struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Recording     bool                   `protobuf:"varint,2,opt,name=recording,proto3" json:"recording,omitempty"`
+	Paused        bool                   `protobuf:"varint,3,opt,name=paused,proto3" json:"paused,omitempty"`
+	RecordingFile string                 `protobuf:"bytes,4,opt,name=recording_file,json=recordingFile,proto3" json:"recording_file,omitempty"`
+	ObsRecording  bool                   `protobuf:"varint,5,opt,name=obs_recording,json=obsRecording,proto3" json:"obs_recording,omitempty"`
+	ObsPaused     bool                   `protobuf:"varint,6,opt,name=obs_paused,json=obsPaused,proto3" json:"obs_paused,omitempty"`
+	ObsStreaming  bool                   `protobuf:"varint,7,opt,name=obs_streaming,json=obsStreaming,proto3" json:"obs_streaming,omitempty"`
+	// Capabilities reports which optional features are usable
+	Capabilities  map[string]bool `protobuf:"bytes,8,rep,name=capabilities,proto3" json:"capabilities,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *State) Reset() {
+	*x = State{}
+	mi := &file_daemon_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *State) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*State) ProtoMessage() {}
+
+func (x *State) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use State.ProtoReflect.Descriptor instead.
+func (*State) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *State) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *State) GetRecording() bool {
+	if x != nil {
+		return x.Recording
+	}
+	return false
+}
+
+func (x *State) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *State) GetRecordingFile() string {
+	if x != nil {
+		return x.RecordingFile
+	}
+	return ""
+}
+
+func (x *State) GetObsRecording() bool {
+	if x != nil {
+		return x.ObsRecording
+	}
+	return false
+}
+
+func (x *State) GetObsPaused() bool {
+	if x != nil {
+		return x.ObsPaused
+	}
+	return false
+}
+
+func (x *State) GetObsStreaming() bool {
+	if x != nil {
+		return x.ObsStreaming
+	}
+	return false
+}
+
+func (x *State) GetCapabilities() map[string]bool {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// Health reports whether the daemon works.
+type Health struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Protocol int32                  `protobuf:"varint,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Version  string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// Uptime is the number of seconds the daemon has been running for
+	Uptime float64 `protobuf:"fixed64,3,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	// Tools maps the tools the daemon runs to whether they are installed
+	Tools         map[string]bool `protobuf:"bytes,4,rep,name=tools,proto3" json:"tools,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Health) Reset() {
+	*x = Health{}
+	mi := &file_daemon_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Health) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Health) ProtoMessage() {}
+
+func (x *Health) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Health.ProtoReflect.Descriptor instead.
+func (*Health) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Health) GetProtocol() int32 {
+	if x != nil {
+		return x.Protocol
+	}
+	return 0
+}
+
+func (x *Health) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Health) GetUptime() float64 {
+	if x != nil {
+		return x.Uptime
+	}
+	return 0
+}
+
+func (x *Health) GetTools() map[string]bool {
+	if x != nil {
+		return x.Tools
+	}
+	return nil
+}
+
+// Progress reports how far a long operation has gone.
+type Progress struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Stage   string                 `protobuf:"bytes,1,opt,name=stage,proto3" json:"stage,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Remaining is the number of seconds left in a countdown, or of
+	// requests ahead in the queue
+	Remaining     int32 `protobuf:"varint,3,opt,name=remaining,proto3" json:"remaining,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_daemon_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Progress) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *Progress) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Progress) GetRemaining() int32 {
+	if x != nil {
+		return x.Remaining
+	}
+	return 0
+}
+
+// SubscribeRequest subscribes to the events.
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Icons replace the icons of the status, as for waybar-status
+	Icons         map[string]string `protobuf:"bytes,1,rep,name=icons,proto3" json:"icons,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_daemon_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SubscribeRequest) GetIcons() map[string]string {
+	if x != nil {
+		return x.Icons
+	}
+	return nil
+}
+
+// Event is pushed to subscribed clients.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	State *State                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	// Status is the status for bars
+	Status        *WaybarStatus `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_daemon_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *Event) GetStatus() *WaybarStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+// WaybarStatus is the status for bars.
+type WaybarStatus struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Text    string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Tooltip string                 `protobuf:"bytes,2,opt,name=tooltip,proto3" json:"tooltip,omitempty"`
+	Class   string                 `protobuf:"bytes,3,opt,name=class,proto3" json:"class,omitempty"`
+	Alt     string                 `protobuf:"bytes,4,opt,name=alt,proto3" json:"alt,omitempty"`
+	// Thumbnail is the path of a preview of the most recent capture
+	Thumbnail     string `protobuf:"bytes,5,opt,name=thumbnail,proto3" json:"thumbnail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WaybarStatus) Reset() {
+	*x = WaybarStatus{}
+	mi := &file_daemon_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WaybarStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WaybarStatus) ProtoMessage() {}
+
+func (x *WaybarStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_daemon_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WaybarStatus.ProtoReflect.Descriptor instead.
+func (*WaybarStatus) Descriptor() ([]byte, []int) {
+	return file_daemon_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WaybarStatus) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *WaybarStatus) GetTooltip() string {
+	if x != nil {
+		return x.Tooltip
+	}
+	return ""
+}
+
+func (x *WaybarStatus) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *WaybarStatus) GetAlt() string {
+	if x != nil {
+		return x.Alt
+	}
+	return ""
+}
+
+func (x *WaybarStatus) GetThumbnail() string {
+	if x != nil {
+		return x.Thumbnail
+	}
+	return ""
+}
+
+var File_daemon_proto protoreflect.FileDescriptor
+
+const file_daemon_proto_rawDesc = "" +
+	"\n" +
+	"\fdaemon.proto\x12\x10sway_easyshot.v1\x1a\x1cgoogle/protobuf/struct.proto\"p\n" +
+	"\aRequest\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x121\n" +
+	"\aoptions\x18\x02 \x01(\v2\x17.google.protobuf.StructR\aoptions\x12\x1a\n" +
+	"\bprogress\x18\x03 \x01(\bR\bprogress\"\x81\x02\n" +
+	"\bResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12-\n" +
+	"\x05state\x18\x03 \x01(\v2\x17.sway_easyshot.v1.StateR\x05state\x12\x14\n" +
+	"\x05files\x18\x04 \x03(\tR\x05files\x12\x12\n" +
+	"\x04code\x18\x05 \x01(\tR\x04code\x120\n" +
+	"\x06health\x18\x06 \x01(\v2\x18.sway_easyshot.v1.HealthR\x06health\x126\n" +
+	"\bprogress\x18\a \x01(\v2\x1a.sway_easyshot.v1.ProgressR\bprogress\"\xf1\x02\n" +
+	"\x05State\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x1c\n" +
+	"\trecording\x18\x02 \x01(\bR\trecording\x12\x16\n" +
+	"\x06paused\x18\x03 \x01(\bR\x06paused\x12%\n" +
+	"\x0erecording_file\x18\x04 \x01(\tR\rrecordingFile\x12#\n" +
+	"\robs_recording\x18\x05 \x01(\bR\fobsRecording\x12\x1d\n" +
+	"\n" +
+	"obs_paused\x18\x06 \x01(\bR\tobsPaused\x12#\n" +
+	"\robs_streaming\x18\a \x01(\bR\fobsStreaming\x12M\n" +
+	"\fcapabilities\x18\b \x03(\v2).sway_easyshot.v1.State.CapabilitiesEntryR\fcapabilities\x1a?\n" +
+	"\x11CapabilitiesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\bR\x05value:\x028\x01\"\xcb\x01\n" +
+	"\x06Health\x12\x1a\n" +
+	"\bprotocol\x18\x01 \x01(\x05R\bprotocol\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x16\n" +
+	"\x06uptime\x18\x03 \x01(\x01R\x06uptime\x129\n" +
+	"\x05tools\x18\x04 \x03(\v2#.sway_easyshot.v1.Health.ToolsEntryR\x05tools\x1a8\n" +
+	"\n" +
+	"ToolsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\bR\x05value:\x028\x01\"X\n" +
+	"\bProgress\x12\x14\n" +
+	"\x05stage\x18\x01 \x01(\tR\x05stage\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1c\n" +
+	"\tremaining\x18\x03 \x01(\x05R\tremaining\"\x91\x01\n" +
+	"\x10SubscribeRequest\x12C\n" +
+	"\x05icons\x18\x01 \x03(\v2-.sway_easyshot.v1.SubscribeRequest.IconsEntryR\x05icons\x1a8\n" +
+	"\n" +
+	"IconsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12-\n" +
+	"\x05state\x18\x02 \x01(\v2\x17.sway_easyshot.v1.StateR\x05state\x126\n" +
+	"\x06status\x18\x03 \x01(\v2\x1e.sway_easyshot.v1.WaybarStatusR\x06status\"\x82\x01\n" +
+	"\fWaybarStatus\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x18\n" +
+	"\atooltip\x18\x02 \x01(\tR\atooltip\x12\x14\n" +
+	"\x05class\x18\x03 \x01(\tR\x05class\x12\x10\n" +
+	"\x03alt\x18\x04 \x01(\tR\x03alt\x12\x1c\n" +
+	"\tthumbnail\x18\x05 \x01(\tR\tthumbnail2\x98\x01\n" +
+	"\x06Daemon\x12B\n" +
+	"\aExecute\x12\x19.sway_easyshot.v1.Request\x1a\x1a.sway_easyshot.v1.Response0\x01\x12J\n" +
+	"\tSubscribe\x12\".sway_easyshot.v1.SubscribeRequest\x1a\x17.sway_easyshot.v1.Event0\x01B*Z(github.com/chmouel/sway-easyshot/pkg/rpcb\x06proto3"
+
+var (
+	file_daemon_proto_rawDescOnce sync.Once
+	file_daemon_proto_rawDescData []byte
+)
+
+func file_daemon_proto_rawDescGZIP() []byte {
+	file_daemon_proto_rawDescOnce.Do(func() {
+		file_daemon_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_daemon_proto_rawDesc), len(file_daemon_proto_rawDesc)))
+	})
+	return file_daemon_proto_rawDescData
+}
+
+var file_daemon_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_daemon_proto_goTypes = []any{
+	(*Request)(nil),          // 0: sway_easyshot.v1.Request
+	(*Response)(nil),         // 1: sway_easyshot.v1.Response
+	(*State)(nil),            // 2: sway_easyshot.v1.State
+	(*Health)(nil),           // 3: sway_easyshot.v1.Health
+	(*Progress)(nil),         // 4: sway_easyshot.v1.Progress
+	(*SubscribeRequest)(nil), // 5: sway_easyshot.v1.SubscribeRequest
+	(*Event)(nil),            // 6: sway_easyshot.v1.Event
+	(*WaybarStatus)(nil),     // 7: sway_easyshot.v1.WaybarStatus
+	nil,                      // 8: sway_easyshot.v1.State.CapabilitiesEntry
+	nil,                      // 9: sway_easyshot.v1.Health.ToolsEntry
+	nil,                      // 10: sway_easyshot.v1.SubscribeRequest.IconsEntry
+	(*structpb.Struct)(nil),  // 11: google.protobuf.Struct
+}
+var file_daemon_proto_depIdxs = []int32{
+	11, // 0: sway_easyshot.v1.Request.options:type_name -> google.protobuf.Struct
+	2,  // 1: sway_easyshot.v1.Response.state:type_name -> sway_easyshot.v1.State
+	3,  // 2: sway_easyshot.v1.Response.health:type_name -> sway_easyshot.v1.Health
+	4,  // 3: sway_easyshot.v1.Response.progress:type_name -> sway_easyshot.v1.Progress
+	8,  // 4: sway_easyshot.v1.State.capabilities:type_name -> sway_easyshot.v1.State.CapabilitiesEntry
+	9,  // 5: sway_easyshot.v1.Health.tools:type_name -> sway_easyshot.v1.Health.ToolsEntry
+	10, // 6: sway_easyshot.v1.SubscribeRequest.icons:type_name -> sway_easyshot.v1.SubscribeRequest.IconsEntry
+	2,  // 7: sway_easyshot.v1.Event.state:type_name -> sway_easyshot.v1.State
+	7,  // 8: sway_easyshot.v1.Event.status:type_name -> sway_easyshot.v1.WaybarStatus
+	0,  // 9: sway_easyshot.v1.Daemon.Execute:input_type -> sway_easyshot.v1.Request
+	5,  // 10: sway_easyshot.v1.Daemon.Subscribe:input_type -> sway_easyshot.v1.SubscribeRequest
+	1,  // 11: sway_easyshot.v1.Daemon.Execute:output_type -> sway_easyshot.v1.Response
+	6,  // 12: sway_easyshot.v1.Daemon.Subscribe:output_type -> sway_easyshot.v1.Event
+	11, // [11:13] is the sub-list for method output_type
+	9,  // [9:11] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_daemon_proto_init() }
+func file_daemon_proto_init() {
+	if File_daemon_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_daemon_proto_rawDesc), len(file_daemon_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_daemon_proto_goTypes,
+		DependencyIndexes: file_daemon_proto_depIdxs,
+		MessageInfos:      file_daemon_proto_msgTypes,
+	}.Build()
+	File_daemon_proto = out.File
+	file_daemon_proto_goTypes = nil
+	file_daemon_proto_depIdxs = nil
+}
@@ -0,0 +1,92 @@
+// Package rpc is the gRPC service of the daemon, for programs embedding
+// typed control of it: the messages mirror those of the protocol package,
+// and DaemonClient is the generated client. The daemon serves it on the
+// grpc.sock socket next to its own, when SWAY_SCREENSHOT_GRPC is set.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative daemon.proto
+
+import (
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Dial returns a client of the daemon serving the service on the socket at
+// path; closing conn releases it.
+func Dial(path string) (client DaemonClient, conn *grpc.ClientConn, err error) {
+	// The socket is private to the user, credentials add nothing
+	conn, err = grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewDaemonClient(conn), conn, nil
+}
+
+// NewResponse returns the message of resp.
+func NewResponse(resp protocol.Response) *Response {
+	msg := &Response{
+		Success: resp.Success,
+		Message: resp.Message,
+		State:   NewState(resp.State),
+		Files:   resp.Files,
+		Code:    resp.Code,
+	}
+	if resp.Health != nil {
+		msg.Health = &Health{
+			Protocol: int32(resp.Health.Protocol), //nolint:gosec // a small version number
+			Version:  resp.Health.Version,
+			Uptime:   resp.Health.Uptime,
+			Tools:    resp.Health.Tools,
+		}
+	}
+	if resp.Progress != nil {
+		msg.Progress = NewProgress(*resp.Progress)
+	}
+	return msg
+}
+
+// NewProgress returns the message of progress.
+func NewProgress(progress protocol.Progress) *Progress {
+	return &Progress{
+		Stage:     progress.Stage,
+		Message:   progress.Message,
+		Remaining: int32(progress.Remaining), //nolint:gosec // seconds or requests
+	}
+}
+
+// NewState returns the message of state, nil when it is.
+func NewState(state *protocol.State) *State {
+	if state == nil {
+		return nil
+	}
+	return &State{
+		Host:          state.Host,
+		Recording:     state.Recording,
+		Paused:        state.Paused,
+		RecordingFile: state.RecordingFile,
+		ObsRecording:  state.OBSRecording,
+		ObsPaused:     state.OBSPaused,
+		ObsStreaming:  state.OBSStreaming,
+		Capabilities:  state.Capabilities,
+	}
+}
+
+// NewEvent returns the message of event.
+func NewEvent(event protocol.Event) *Event {
+	msg := &Event{Type: event.Type, State: NewState(event.State)}
+	if status := event.Status; status != nil {
+		msg.Status = &WaybarStatus{
+			Text:      status.Text,
+			Tooltip:   status.Tooltip,
+			Class:     status.Class,
+			Alt:       status.Alt,
+			Thumbnail: status.Thumbnail,
+		}
+	}
+	return msg
+}
@@ -0,0 +1,94 @@
+// Package screenshot takes screenshots on wlroots compositors with grim,
+// selecting regions with slurp.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// Capture captures the geometry, given as "x,y wxh", or the output, or
+// everything when both are empty. The PNG image is written to filename, or
+// returned when filename is empty.
+func Capture(ctx context.Context, geometry, output, filename string) ([]byte, error) {
+	args := []string{"-t", "png"}
+
+	if geometry != "" {
+		args = append(args, "-g", geometry)
+	}
+	if output != "" {
+		args = append(args, "-o", output)
+	}
+
+	if filename == "" {
+		args = append(args, "-")
+	} else {
+		args = append(args, filename)
+	}
+
+	cmd := exec.CommandContext(ctx, "grim", args...)
+
+	if filename == "" {
+		return external.Output(ctx, cmd)
+	}
+
+	return nil, external.Run(ctx, cmd)
+}
+
+// ErrCancelled is returned when the user cancels the selection of a region.
+var ErrCancelled = errors.New("selection cancelled")
+
+// Select lets the user select a region with slurp, drawn in color when
+// set, and returns its geometry.
+func Select(ctx context.Context, color string) (string, error) {
+	args := []string{}
+	if color != "" {
+		args = append(args, "-c", color)
+	}
+
+	cmd := exec.CommandContext(ctx, "slurp", args...) //nolint:gosec
+	return selection(ctx, cmd)
+}
+
+// SelectWith lets the user select a region with the given selector command,
+// falling back to slurp when it is blank. Selectors must print the geometry
+// in the same "x,y wxh" format as slurp, and get the color in the
+// SWAY_SCREENSHOT_COLOR environment variable.
+func SelectWith(ctx context.Context, selector, color string) (string, error) {
+	args := strings.Fields(selector)
+	if len(args) == 0 {
+		return Select(ctx, color)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec
+	cmd.Env = append(os.Environ(), "SWAY_SCREENSHOT_COLOR="+color)
+	return selection(ctx, cmd)
+}
+
+// selection runs a selector and returns the geometry it prints. Selectors
+// exit with an error or print nothing when the user presses Escape, which
+// is a cancellation rather than a failure.
+func selection(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	output, err := external.Output(ctx, cmd)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return "", ErrCancelled
+	}
+	if err != nil {
+		return "", err
+	}
+
+	geom := strings.TrimSpace(string(output))
+	if geom == "" {
+		return "", ErrCancelled
+	}
+	return geom, nil
+}
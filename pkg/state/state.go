@@ -0,0 +1,482 @@
+// Package state tracks the recordings, OBS and last captures of the daemon
+// and renders them as status for bars.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/pkg/protocol"
+)
+
+// State tracks the current state of recordings and OBS.
+type State struct {
+	mu                 sync.RWMutex
+	host               string
+	recording          bool
+	paused             bool
+	recordingFile      string
+	recordingPID       int
+	recordingStartTime time.Time
+	recordingDeadline  time.Time
+	obsRecording       bool
+	obsPaused          bool
+	obsStreaming       bool
+	textTemplate       *template.Template
+	tooltipTemplate    *template.Template
+	countdownRemaining int
+	icons              Icons
+	lastRegions        map[string]string
+	lastRegionAction   string
+	capabilities       map[string]bool
+	lastCaptureFile    string
+	lastCaptureTime    time.Time
+	lastThumbnail      string
+	changes            chan struct{}
+}
+
+// Icons holds custom icons for different states.
+type Icons struct {
+	Idle         string
+	Recording    string
+	Paused       string
+	ObsRecording string
+	ObsPaused    string
+	ObsStreaming string
+	Countdown    string
+}
+
+// DefaultIcons returns the default icon set.
+func DefaultIcons() Icons {
+	return Icons{
+		Idle:         "",
+		Recording:    "󰑊",
+		Paused:       "󰏤",
+		ObsRecording: "󰑊",
+		ObsPaused:    "󰏤",
+		ObsStreaming: "󰑊",
+		Countdown:    "⏱",
+	}
+}
+
+// NewState creates a new state instance with default icons.
+func NewState() *State {
+	return NewStateWithIcons(DefaultIcons())
+}
+
+// NewStateWithIcons creates a new State with custom icons.
+func NewStateWithIcons(icons Icons) *State {
+	return &State{
+		icons:       icons,
+		lastRegions: make(map[string]string),
+	}
+}
+
+// GetState returns the current state snapshot.
+func (s *State) GetState() *protocol.State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	capabilities := make(map[string]bool, len(s.capabilities))
+	for name, ok := range s.capabilities {
+		capabilities[name] = ok
+	}
+
+	return &protocol.State{
+		Host:          s.host,
+		Recording:     s.recording,
+		Paused:        s.paused,
+		RecordingFile: s.recordingFile,
+		OBSRecording:  s.obsRecording,
+		OBSPaused:     s.obsPaused,
+		OBSStreaming:  s.obsStreaming,
+		Capabilities:  capabilities,
+	}
+}
+
+// Changed returns a channel closed the next time the recording, OBS,
+// countdown, last capture or look of the status changes, for following the
+// state without polling it.
+func (s *State) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changes == nil {
+		s.changes = make(chan struct{})
+	}
+	return s.changes
+}
+
+// changed wakes up those waiting on Changed; s.mu must be held.
+func (s *State) changed() {
+	if s.changes != nil {
+		close(s.changes)
+		s.changes = nil
+	}
+}
+
+// SetHost records the label of the machine, reported in the state.
+func (s *State) SetHost(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.host = host
+}
+
+// SetCapabilities records which optional features are usable.
+func (s *State) SetCapabilities(capabilities map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capabilities = capabilities
+}
+
+// SetRecording sets the recording state and file information.
+func (s *State) SetRecording(recording bool, file string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+
+	s.recording = recording
+	s.recordingFile = file
+	s.recordingPID = pid
+	s.recordingDeadline = time.Time{}
+	if recording {
+		s.recordingStartTime = time.Now()
+	} else {
+		s.recordingStartTime = time.Time{}
+	}
+}
+
+// SetRecordingSegment switches the current recording to a new segment file
+// and process, keeping its start time and deadline.
+func (s *State) SetRecordingSegment(file string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.recordingFile = file
+	s.recordingPID = pid
+}
+
+// EndRecording clears the recording state if pid is still the current
+// recording process.
+func (s *State) EndRecording(pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.recording || s.recordingPID != pid {
+		return
+	}
+	s.changed()
+	s.recording = false
+	s.paused = false
+	s.recordingFile = ""
+	s.recordingPID = 0
+	s.recordingStartTime = time.Time{}
+	s.recordingDeadline = time.Time{}
+}
+
+// GetRecordingDeadline returns when the current recording stops
+// automatically, zero when it does not.
+func (s *State) GetRecordingDeadline() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recordingDeadline
+}
+
+// SetRecordingDeadline sets when the current recording stops automatically.
+func (s *State) SetRecordingDeadline(deadline time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.recordingDeadline = deadline
+}
+
+// SetOBSState sets the OBS recording and pause state.
+func (s *State) SetOBSState(recording, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+
+	s.obsRecording = recording
+	s.obsPaused = paused
+}
+
+// SetOBSStreaming sets whether OBS is streaming.
+func (s *State) SetOBSStreaming(streaming bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+
+	s.obsStreaming = streaming
+}
+
+// GetRecordingPID returns the process ID of the current recording.
+func (s *State) GetRecordingPID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recordingPID
+}
+
+// SetPaused sets the pause state of the current recording.
+func (s *State) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.paused = paused
+}
+
+// SetCountdown sets the countdown remaining seconds.
+func (s *State) SetCountdown(seconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.countdownRemaining = seconds
+}
+
+// ClearCountdown clears the countdown state.
+func (s *State) ClearCountdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.countdownRemaining = 0
+}
+
+// SetLastCapture records the most recent capture file (empty for clipboard
+// only captures) and the path of its thumbnail.
+func (s *State) SetLastCapture(file, thumbnail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.lastCaptureFile = file
+	s.lastCaptureTime = time.Now()
+	s.lastThumbnail = thumbnail
+}
+
+// SetLastCaptureAt records the capture file taken at the given time and its
+// thumbnail, unless a later capture is already recorded. It returns the
+// thumbnail no longer in use: the previous one, or thumbnail itself when
+// the capture came too late.
+func (s *State) SetLastCaptureAt(file, thumbnail string, taken time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if taken.Before(s.lastCaptureTime) {
+		return thumbnail
+	}
+	s.changed()
+	previous := s.lastThumbnail
+	s.lastCaptureFile = file
+	s.lastCaptureTime = taken
+	s.lastThumbnail = thumbnail
+	return previous
+}
+
+// GetLastCapture returns the most recent capture file, empty when it only
+// went to the clipboard.
+func (s *State) GetLastCapture() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastCaptureFile
+}
+
+// GetLastThumbnail returns the path of the most recent capture thumbnail.
+func (s *State) GetLastThumbnail() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastThumbnail
+}
+
+// GetWaybarStatus returns the current waybar status representation.
+func (s *State) GetWaybarStatus() *protocol.WaybarStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := s.waybarStatus()
+	status.Thumbnail = s.lastThumbnail
+	return status
+}
+
+// StatusData is what the waybar templates receive.
+type StatusData struct {
+	// State is idle, countdown, recording, paused or streaming
+	State string
+	Icon  string
+	// OBS reports whether the state is that of OBS
+	OBS bool
+	// Text and Tooltip are the default text and tooltip
+	Text    string
+	Tooltip string
+	// Elapsed is the time recorded so far, as MM:SS
+	Elapsed string
+	// Remaining is the time left before the recording stops automatically,
+	// as MM:SS, empty without a limit
+	Remaining string
+	// Countdown is the number of seconds left before a delayed capture
+	Countdown int
+	File      string
+	FileBase  string
+	Host      string
+}
+
+// SetTemplates sets the Go templates rendering the waybar text and tooltip,
+// receiving a StatusData. Empty ones keep the default text or tooltip.
+func (s *State) SetTemplates(text, tooltip string) error {
+	var templates [2]*template.Template
+	for i, source := range []string{text, tooltip} {
+		if source == "" {
+			continue
+		}
+		tmpl, err := template.New("waybar").Parse(source)
+		if err != nil {
+			return err
+		}
+		templates[i] = tmpl
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.textTemplate, s.tooltipTemplate = templates[0], templates[1]
+	return nil
+}
+
+func (s *State) waybarStatus() *protocol.WaybarStatus {
+	data := s.statusData()
+	status := &protocol.WaybarStatus{
+		Text:    data.Text,
+		Tooltip: data.Tooltip,
+		Class:   data.State,
+		Alt:     data.State,
+	}
+	// A template failing on some state falls back to the default
+	if text, err := render(s.textTemplate, data); err == nil {
+		status.Text = text
+	}
+	if tooltip, err := render(s.tooltipTemplate, data); err == nil {
+		status.Tooltip = tooltip
+	}
+	return status
+}
+
+// render renders tmpl with data, failing when there is no template.
+func render(tmpl *template.Template, data StatusData) (string, error) {
+	if tmpl == nil {
+		return "", fmt.Errorf("no template")
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (s *State) statusData() StatusData {
+	data := StatusData{Host: s.host}
+
+	// Priority: countdown > wf-recorder > OBS
+	switch {
+	case s.countdownRemaining > 0:
+		data.State = "countdown"
+		data.Icon = s.icons.Countdown
+		data.Countdown = s.countdownRemaining
+		data.Text = fmt.Sprintf("%s %d", s.icons.Countdown, s.countdownRemaining)
+		data.Tooltip = fmt.Sprintf("Starting in %d seconds", s.countdownRemaining)
+
+	case s.recording:
+		data.File = s.recordingFile
+		data.FileBase = filepath.Base(s.recordingFile)
+		data.Elapsed = clock(time.Since(s.recordingStartTime))
+		if !s.recordingDeadline.IsZero() {
+			data.Remaining = clock(max(time.Until(s.recordingDeadline).Round(time.Second), 0))
+		}
+
+		switch {
+		case s.paused:
+			data.State = "paused"
+			data.Icon = s.icons.Paused
+			data.Text = s.icons.Paused
+			data.Tooltip = "Recording paused"
+		case data.Remaining != "":
+			data.State = "recording"
+			data.Icon = s.icons.Recording
+			data.Text = fmt.Sprintf("%s -%s", s.icons.Recording, data.Remaining)
+			data.Tooltip = fmt.Sprintf("Recording: %s (stops in %s)", s.recordingFile, data.Remaining)
+		default:
+			data.State = "recording"
+			data.Icon = s.icons.Recording
+			data.Text = fmt.Sprintf("%s %s", s.icons.Recording, data.Elapsed)
+			data.Tooltip = fmt.Sprintf("Recording: %s (%s)", s.recordingFile, data.Elapsed)
+		}
+
+	case s.obsRecording && s.obsPaused:
+		data.State = "paused"
+		data.OBS = true
+		data.Icon = s.icons.ObsPaused
+		data.Text = s.icons.ObsPaused
+		data.Tooltip = "OBS recording paused"
+
+	case s.obsRecording:
+		data.State = "recording"
+		data.OBS = true
+		data.Icon = s.icons.ObsRecording
+		data.Text = s.icons.ObsRecording
+		data.Tooltip = "OBS recording in progress"
+
+	case s.obsStreaming:
+		data.State = "streaming"
+		data.OBS = true
+		data.Icon = s.icons.ObsStreaming
+		data.Text = s.icons.ObsStreaming
+		data.Tooltip = "OBS streaming"
+
+	default:
+		data.State = "idle"
+		data.Icon = s.icons.Idle
+		data.Text = s.icons.Idle
+		data.Tooltip = "Ready for screenshot/recording"
+	}
+	return data
+}
+
+// clock formats a duration as MM:SS.
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// SetIcons updates the icons used for waybar status.
+func (s *State) SetIcons(icons Icons) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed()
+	s.icons = icons
+}
+
+// SetLastRegion remembers the region selected for an action.
+func (s *State) SetLastRegion(action, geometry string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRegions[action] = geometry
+	s.lastRegionAction = action
+}
+
+// GetLastRegion returns the last region selected for an action, falling back
+// to the most recently selected region of any action.
+func (s *State) GetLastRegion(action string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if geometry, ok := s.lastRegions[action]; ok {
+		return geometry, true
+	}
+	geometry, ok := s.lastRegions[s.lastRegionAction]
+	return geometry, ok
+}
+
+// GetLastRegionAction returns the action which most recently selected a region.
+func (s *State) GetLastRegionAction() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastRegionAction
+}
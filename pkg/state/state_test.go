@@ -0,0 +1,30 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetLastCaptureAt(t *testing.T) {
+	s := NewState()
+	first := time.Now()
+	second := first.Add(time.Millisecond)
+
+	if stale := s.SetLastCaptureAt("second.png", "thumb-2.png", second); stale != "" {
+		t.Errorf("the first capture left %q unused", stale)
+	}
+	// The thumbnail of an earlier capture comes too late
+	if stale := s.SetLastCaptureAt("first.png", "thumb-1.png", first); stale != "thumb-1.png" {
+		t.Errorf("a late capture left %q unused, want its own thumbnail", stale)
+	}
+	if got := s.GetLastCapture(); got != "second.png" {
+		t.Errorf("GetLastCapture() = %q, want the later capture", got)
+	}
+
+	if stale := s.SetLastCaptureAt("third.png", "thumb-3.png", second.Add(time.Millisecond)); stale != "thumb-2.png" {
+		t.Errorf("a new capture left %q unused, want the previous thumbnail", stale)
+	}
+	if got := s.GetLastThumbnail(); got != "thumb-3.png" {
+		t.Errorf("GetLastThumbnail() = %q, want that of the new capture", got)
+	}
+}
@@ -0,0 +1,191 @@
+// Package transcode converts, crops, joins and inspects screen recordings
+// with ffmpeg.
+//
+// Like all the packages under pkg, its API follows semantic versioning:
+// exported identifiers are only removed or changed in a new major version.
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chmouel/sway-easyshot/internal/external"
+)
+
+// Timer overlays burned into recordings when converting them.
+const (
+	TimerOff     = "off"
+	TimerElapsed = "elapsed"
+	TimerClock   = "clock"
+)
+
+// ValidTimer returns an error when mode is not a known timer overlay.
+func ValidTimer(mode string) error {
+	switch mode {
+	case TimerOff, TimerElapsed, TimerClock:
+		return nil
+	}
+	return fmt.Errorf("unknown timer %q (want %s, %s or %s)", mode, TimerOff, TimerElapsed, TimerClock)
+}
+
+// TimerFilter returns the ffmpeg filter drawing a timer in the top-left
+// corner: the recorded time, or the wall-clock time counted from start. It
+// is empty when the timer is off.
+func TimerFilter(mode string, start time.Time) string {
+	var text string
+	switch mode {
+	case TimerElapsed:
+		text = `%{pts\:hms}`
+	case TimerClock:
+		text = fmt.Sprintf(`%%{pts\:localtime\:%d}`, start.Unix())
+	default:
+		return ""
+	}
+	return "drawtext=text='" + text + "':x=16:y=16:fontsize=h/24:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=8"
+}
+
+// withFilter appends an optional filter to a filter chain.
+func withFilter(chain, filter string) string {
+	if filter == "" {
+		return chain
+	}
+	return chain + "," + filter
+}
+
+// Convert converts a video file to an H.264 MP4 of at most 1920 pixels
+// wide, applying the overlay filter if any.
+func Convert(ctx context.Context, inputFile, outputFile, overlay string) error {
+	args := []string{
+		"-i", fmt.Sprintf("file:%s", inputFile),
+		"-vf", withFilter("scale='min(1920,iw)':-2", overlay),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "23",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outputFile,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...) //nolint:gosec
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return external.Run(ctx, cmd)
+}
+
+// LastFrame extracts the last frame written so far to a video file,
+// possibly still being recorded, into an image file
+func LastFrame(ctx context.Context, inputFile, outputFile string) error {
+	// Each decoded frame overwrites the image, leaving the last one
+	output := []string{"-update", "1", outputFile}
+
+	// Seeking from the end needs the duration, which a file still being
+	// written may not tell: decode it all then
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-y", "-loglevel", "error", "-sseof", "-1", "-i", "file:" + inputFile}, output...)...) //nolint:gosec
+	if err := external.Run(ctx, cmd); err == nil {
+		if info, err := os.Stat(outputFile); err == nil && info.Size() > 0 {
+			return nil
+		}
+	}
+
+	cmd = exec.CommandContext(ctx, "ffmpeg", append([]string{"-y", "-loglevel", "error", "-i", "file:" + inputFile}, output...)...) //nolint:gosec
+	out, err := external.CombinedOutput(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Zoom converts a video file, cropping it to width×height pixels at the
+// positions given over time by the sendcmd commands file and scaling it back
+// up to outWidth pixels wide, applying the overlay filter if any
+func Zoom(ctx context.Context, inputFile, outputFile, commandsFile string, width, height, outWidth int, overlay string) error {
+	filter := fmt.Sprintf("sendcmd=f='%s',crop=w=%d:h=%d,scale=%d:-2",
+		strings.ReplaceAll(commandsFile, "'", `\'`), width, height, min(outWidth, 1920))
+	args := []string{
+		"-i", fmt.Sprintf("file:%s", inputFile),
+		"-vf", withFilter(filter, overlay),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "23",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outputFile,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...) //nolint:gosec
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return external.Run(ctx, cmd)
+}
+
+// Concat joins several video files into one, scaling and letterboxing
+// each of them to the largest dimensions found among them, applying the
+// overlay filter if any
+func Concat(ctx context.Context, inputFiles []string, outputFile, overlay string) error {
+	width, height := 0, 0
+	for _, input := range inputFiles {
+		w, h, err := Size(ctx, input)
+		if err != nil {
+			return err
+		}
+		width, height = max(width, w), max(height, h)
+	}
+	if width > 1920 {
+		height = height * 1920 / width
+		width = 1920
+	}
+	// libx264 with yuv420p requires even dimensions
+	width, height = width+width%2, height+height%2
+
+	args := []string{}
+	var filter strings.Builder
+	for i, input := range inputFiles {
+		args = append(args, "-i", fmt.Sprintf("file:%s", input))
+		fmt.Fprintf(&filter, "[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1[v%d];",
+			i, width, height, width, height, i)
+	}
+	for i := range inputFiles {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	fmt.Fprintf(&filter, "%s[out]", withFilter(fmt.Sprintf("concat=n=%d:v=1:a=0", len(inputFiles)), overlay))
+
+	args = append(args,
+		"-filter_complex", filter.String(),
+		"-map", "[out]",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "23",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outputFile,
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...) //nolint:gosec
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return external.Run(ctx, cmd)
+}
+
+// Size returns the dimensions of the first video stream of a file
+func Size(ctx context.Context, file string) (width, height int, err error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", //nolint:gosec
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=p=0:s=x",
+		fmt.Sprintf("file:%s", file),
+	)
+	output, err := external.Output(ctx, cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to probe %s: %w", file, err)
+	}
+
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse size of %s: %w", file, err)
+	}
+	return width, height, nil
+}
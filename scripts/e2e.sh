@@ -0,0 +1,552 @@
+#!/usr/bin/env bash
+# Runs the client against a daemon on a temporary socket, with stubs of the
+# external tools first on PATH, so that the whole CLI → socket → handler →
+# tool pipeline is exercised without Wayland, e.g. in CI.
+set -euo pipefail
+
+ROOT=$(cd "$(dirname "$0")/.." && pwd)
+E2E_DIR=$(mktemp -d)
+export E2E_DIR
+DAEMON_PID=""
+BUS_PID=""
+SWAY_PID=""
+
+cleanup() {
+	if [[ -n ${DAEMON_PID} ]]; then
+		kill "${DAEMON_PID}" 2>/dev/null || true
+		wait "${DAEMON_PID}" 2>/dev/null || true
+	fi
+	if [[ -n ${BUS_PID} ]]; then
+		kill "${BUS_PID}" 2>/dev/null || true
+	fi
+	if [[ -n ${SWAY_PID} ]]; then
+		kill "${SWAY_PID}" 2>/dev/null || true
+	fi
+	rm -rf "${E2E_DIR}"
+}
+trap cleanup EXIT
+
+FAILED=0
+pass() { echo "✓ $*"; }
+fail() {
+	echo "✗ $*"
+	FAILED=$((FAILED + 1))
+}
+
+# expect_status STATUS DESCRIPTION COMMAND... runs the client and checks it
+# exits with STATUS, keeping its output in ${E2E_DIR}/out.
+expect_status() {
+	local want=$1 description=$2 got=0
+	shift 2
+	sway-easyshot "$@" >"${E2E_DIR}/out" 2>"${E2E_DIR}/err" || got=$?
+	if [[ ${got} -eq ${want} ]]; then
+		pass "${description}"
+		return 0
+	fi
+	fail "${description}: exit status ${got}, expected ${want}"
+	sed 's/^/    /' "${E2E_DIR}/err"
+	return 1
+}
+
+stub() {
+	cat >"${E2E_DIR}/bin/$1"
+	chmod +x "${E2E_DIR}/bin/$1"
+}
+
+mkdir -p "${E2E_DIR}/bin" "${E2E_DIR}/runtime" "${E2E_DIR}/home"
+chmod 700 "${E2E_DIR}/runtime"
+(cd "${ROOT}" && go build -o "${E2E_DIR}/bin/sway-easyshot" ./cmd/sway-easyshot)
+
+# A 1x1 PNG, which is all grim captures
+base64 -d >"${E2E_DIR}/fixture.png" <<'EOF'
+iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==
+EOF
+
+stub grim <<'EOF'
+#!/usr/bin/env bash
+echo "grim $*" >>"${E2E_DIR}/calls"
+out=${!#}
+if [[ ${out} == - ]]; then
+	cat "${E2E_DIR}/fixture.png"
+else
+	cp "${E2E_DIR}/fixture.png" "${out}"
+fi
+EOF
+
+# Touching slurp-cancel makes the selection cancelled, as Escape does, and
+# slurp-hold keeps the selection waiting until it is removed
+stub slurp <<'EOF'
+#!/usr/bin/env bash
+echo "slurp $*" >>"${E2E_DIR}/calls"
+while [[ -e ${E2E_DIR}/slurp-hold ]]; do sleep 0.1; done
+if [[ -e ${E2E_DIR}/slurp-cancel ]]; then
+	echo "selection cancelled" >&2
+	exit 1
+fi
+echo "10,20 100x50"
+EOF
+
+stub wl-copy <<'EOF'
+#!/usr/bin/env bash
+echo "wl-copy $*" >>"${E2E_DIR}/calls"
+if [[ ${1:-} == --clear ]]; then
+	rm -f "${E2E_DIR}/clipboard"
+else
+	cat >"${E2E_DIR}/clipboard"
+fi
+EOF
+
+stub wl-paste <<'EOF'
+#!/usr/bin/env bash
+[[ -e ${E2E_DIR}/clipboard ]] || { echo "Nothing is copied" >&2; exit 1; }
+[[ ${1:-} == --list-types ]] && { echo image/png; exit 0; }
+cat "${E2E_DIR}/clipboard"
+EOF
+
+stub notify-send <<'EOF'
+#!/usr/bin/env bash
+echo "notify-send $*" >>"${E2E_DIR}/notifications"
+EOF
+
+stub swaymsg <<'EOF'
+#!/usr/bin/env bash
+case "$*" in
+"-t get_tree")
+	cat <<'JSON'
+{"id":1,"type":"root","rect":{"x":0,"y":0,"width":1920,"height":1080},"nodes":[
+ {"id":2,"type":"output","name":"E2E-1","rect":{"x":0,"y":0,"width":1920,"height":1080},"nodes":[
+  {"id":3,"type":"workspace","name":"1","rect":{"x":0,"y":0,"width":1920,"height":1080},"nodes":[
+   {"id":4,"type":"con","name":"Terminal","app_id":"foot","pid":1,"focused":true,"visible":true,
+    "rect":{"x":0,"y":0,"width":960,"height":1080},"window_rect":{"x":0,"y":0,"width":960,"height":1080},
+    "deco_rect":{"x":0,"y":0,"width":0,"height":0},"nodes":[],"floating_nodes":[]}
+  ],"floating_nodes":[]}
+ ],"floating_nodes":[]}
+],"floating_nodes":[]}
+JSON
+	;;
+"-t get_outputs")
+	echo '[{"name":"E2E-1","active":true,"focused":true,"scale":1,"rect":{"x":0,"y":0,"width":1920,"height":1080}}]'
+	;;
+"-t get_workspaces")
+	echo '[{"name":"1","focused":true,"visible":true,"output":"E2E-1","rect":{"x":0,"y":0,"width":1920,"height":1080}}]'
+	;;
+"-t get_config")
+	echo '{"config":""}'
+	;;
+-t\ subscribe*)
+	exec sleep infinity
+	;;
+*)
+	echo '[{"success":true}]'
+	;;
+esac
+EOF
+
+# wf-recorder writes its file until interrupted, as the real one does
+stub wf-recorder <<'EOF'
+#!/usr/bin/env bash
+echo "wf-recorder $*" >>"${E2E_DIR}/calls"
+while [[ $# -gt 0 ]]; do
+	case $1 in
+	-f) out=$2; shift ;;
+	-f*) out=${1#-f} ;;
+	--file=*) out=${1#--file=} ;;
+	esac
+	shift
+done
+echo recording >"${out}"
+trap 'exit 0' INT TERM
+while :; do sleep 0.1; done
+EOF
+
+stub killall <<'EOF'
+#!/usr/bin/env bash
+signal=TERM
+[[ ${1:-} == -s ]] && { signal=$2; shift 2; }
+pkill "-${signal}" -x "$1"
+EOF
+
+# ffmpeg copies its input to its output, the last argument
+stub ffmpeg <<'EOF'
+#!/usr/bin/env bash
+echo "ffmpeg $*" >>"${E2E_DIR}/calls"
+in=""
+args=("$@")
+for ((i = 0; i < ${#args[@]}; i++)); do
+	[[ ${args[i]} == -i ]] && in=${args[i + 1]#file:}
+done
+out=${args[-1]#file:}
+[[ -n ${in} && -e ${in} ]] && cp "${in}" "${out}" || echo converted >"${out}"
+EOF
+
+export PATH="${E2E_DIR}/bin:${PATH}"
+export HOME="${E2E_DIR}/home"
+export XDG_RUNTIME_DIR="${E2E_DIR}/runtime"
+export XDG_CONFIG_HOME="${E2E_DIR}/home/.config"
+export XDG_STATE_HOME="${E2E_DIR}/home/.local/state"
+export XDG_DATA_HOME="${E2E_DIR}/home/.local/share"
+export WAYLAND_DISPLAY=wayland-e2e
+export SWAYSOCK="${E2E_DIR}/runtime/sway-ipc.sock"
+export SWAY_SCREENSHOT_SAVE_LOCATION="${E2E_DIR}/captures"
+export SWAY_SCREENSHOT_OBS_WATCH=false
+mkdir -p "${SWAY_SCREENSHOT_SAVE_LOCATION}"
+# Stand-ins for the sockets of the session, for the client to start the
+# daemon itself and the daemon to see the session is alive. Sway's accepts
+# connections, which is all the daemon asks of it
+touch "${XDG_RUNTIME_DIR}/${WAYLAND_DISPLAY}"
+mkdir "${E2E_DIR}/sway"
+cat >"${E2E_DIR}/sway/main.go" <<'EOF'
+package main
+
+import (
+	"net"
+	"os"
+)
+
+func main() {
+	listener, err := net.Listen("unix", os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			panic(err)
+		}
+		_ = conn.Close()
+	}
+}
+EOF
+(cd "${E2E_DIR}/sway" && go build -o sway main.go)
+"${E2E_DIR}/sway/sway" "${SWAYSOCK}" &
+SWAY_PID=$!
+for _ in $(seq 50); do
+	[[ -S ${SWAYSOCK} ]] && break
+	sleep 0.1
+done
+
+SOCKET="${XDG_RUNTIME_DIR}/sway-easyshot/${WAYLAND_DISPLAY}/daemon.sock"
+sway-easyshot daemon >"${E2E_DIR}/daemon.log" 2>&1 &
+DAEMON_PID=$!
+for _ in $(seq 50); do
+	[[ -S ${SOCKET} ]] && break
+	sleep 0.1
+done
+if [[ ! -S ${SOCKET} ]]; then
+	cat "${E2E_DIR}/daemon.log"
+	echo "✗ daemon did not start"
+	exit 1
+fi
+
+expect_status 1 "a second daemon refuses to start" daemon || true
+
+if expect_status 0 "selection-file saves the capture" --json selection-file; then
+	file=$(jq -r '.files[0] // empty' "${E2E_DIR}/out")
+	if [[ -n ${file} ]] && cmp -s "${file}" "${E2E_DIR}/fixture.png"; then
+		pass "selection-file reports the saved file"
+	else
+		fail "selection-file reports the saved file: got '${file}'"
+	fi
+	grep -q -- "grim .*-g 10,20 100x50" "${E2E_DIR}/calls" && pass "grim captures the selected region" ||
+		fail "grim captures the selected region"
+fi
+
+# script gives the client the terminal it shows progress on
+if script -qec "sway-easyshot selection-file --delay 1" /dev/null >"${E2E_DIR}/out" 2>&1; then
+	pass "a delayed selection-file succeeds on a terminal"
+	grep -q "Starting in 1s" "${E2E_DIR}/out" && pass "the countdown is shown" ||
+		fail "the countdown is shown"
+	grep -q "Waiting for a selection" "${E2E_DIR}/out" && pass "the selection is awaited" ||
+		fail "the selection is awaited"
+else
+	fail "a delayed selection-file succeeds on a terminal"
+	sed 's/^/    /' "${E2E_DIR}/out"
+fi
+
+if expect_status 0 "selection-clipboard copies the capture" selection-clipboard; then
+	cmp -s "${E2E_DIR}/clipboard" "${E2E_DIR}/fixture.png" && pass "the clipboard holds the capture" ||
+		fail "the clipboard holds the capture"
+fi
+
+touch "${E2E_DIR}/slurp-cancel"
+expect_status 2 "a cancelled selection exits with status 2" selection-file || true
+rm -f "${E2E_DIR}/slurp-cancel"
+
+touch "${E2E_DIR}/slurp-hold"
+selections=$(grep -c "^slurp" "${E2E_DIR}/calls")
+sway-easyshot selection-file >/dev/null 2>&1 &
+HELD_PID=$!
+for _ in $(seq 50); do
+	[[ $(grep -c "^slurp" "${E2E_DIR}/calls") -gt ${selections} ]] && break
+	sleep 0.1
+done
+if expect_status 1 "a repeated selection is ignored while the first waits" --json selection-file; then
+	[[ $(jq -r .code "${E2E_DIR}/out") == busy ]] && pass "the repeated selection is reported busy" ||
+		fail "the repeated selection is reported busy"
+fi
+rm -f "${E2E_DIR}/slurp-hold"
+wait "${HELD_PID}" && pass "the first selection completes" || fail "the first selection completes"
+
+touch "${E2E_DIR}/slurp-hold"
+selections=$(grep -c "^slurp" "${E2E_DIR}/calls")
+sway-easyshot selection-file >/dev/null 2>&1 &
+HELD_PID=$!
+for _ in $(seq 50); do
+	[[ $(grep -c "^slurp" "${E2E_DIR}/calls") -gt ${selections} ]] && break
+	sleep 0.1
+done
+expect_status 0 "cancel cancels the waiting selection" cancel || true
+held=0
+wait "${HELD_PID}" || held=$?
+[[ ${held} -eq 2 ]] && pass "the cancelled selection exits with status 2" ||
+	fail "the cancelled selection exits with status 2, not ${held}"
+rm -f "${E2E_DIR}/slurp-hold"
+
+touch "${E2E_DIR}/slurp-hold"
+selections=$(grep -c "^slurp" "${E2E_DIR}/calls")
+sway-easyshot selection-file >/dev/null 2>&1 &
+HELD_PID=$!
+for _ in $(seq 50); do
+	[[ $(grep -c "^slurp" "${E2E_DIR}/calls") -gt ${selections} ]] && break
+	sleep 0.1
+done
+script -qec "sway-easyshot selection-clipboard" /dev/null >"${E2E_DIR}/queued" 2>&1 &
+QUEUED_PID=$!
+sleep 0.5
+[[ $(grep -c "^slurp" "${E2E_DIR}/calls") -eq $((selections + 1)) ]] &&
+	pass "another selection waits for the first" || fail "another selection waits for the first"
+rm -f "${E2E_DIR}/slurp-hold"
+wait "${HELD_PID}" && pass "the first selection completes" || fail "the first selection completes"
+wait "${QUEUED_PID}" && pass "the queued selection completes" || fail "the queued selection completes"
+grep -q "Waiting for 1 capture to finish" "${E2E_DIR}/queued" && pass "the queued selection shows its place" ||
+	fail "the queued selection shows its place"
+
+touch "${E2E_DIR}/slurp-hold"
+expect_status 3 "a selection waiting longer than --timeout times out" --timeout 1s selection-file || true
+grep -q "raise it with --timeout" "${E2E_DIR}/err" && pass "the timeout is explained" ||
+	fail "the timeout is explained"
+expect_status 0 "cancel cancels the selection left waiting" cancel || true
+rm -f "${E2E_DIR}/slurp-hold"
+# Give the cancelled selection the time to end before the next capture
+sleep 0.5
+
+sway-easyshot selection-file --delay 5 >/dev/null 2>&1 &
+HELD_PID=$!
+sleep 0.5
+expect_status 0 "cancel cancels the countdown" cancel || true
+held=0
+wait "${HELD_PID}" || held=$?
+[[ ${held} -eq 2 ]] && pass "the cancelled countdown exits with status 2" ||
+	fail "the cancelled countdown exits with status 2, not ${held}"
+
+if expect_status 1 "a negative delay is refused" --json selection-file --delay -1; then
+	[[ $(jq -r .code "${E2E_DIR}/out") == invalid ]] && pass "the negative delay is reported invalid" ||
+		fail "the negative delay is reported invalid"
+fi
+
+if expect_status 0 "history lists the saved capture" history list --json; then
+	[[ $(jq '.message | fromjson | map(select(.file != null)) | length' "${E2E_DIR}/out") -ge 1 ]] &&
+		pass "history holds the capture" || fail "history holds the capture"
+fi
+
+sway-easyshot events >"${E2E_DIR}/events" 2>/dev/null &
+EVENTS_PID=$!
+sway-easyshot waybar-status --follow >"${E2E_DIR}/follow" 2>/dev/null &
+FOLLOW_PID=$!
+sleep 0.5
+
+if expect_status 0 "movie-selection starts a recording" movie-selection; then
+	expect_status 0 "status reports the recording" status || true
+	jq -e .recording "${E2E_DIR}/out" >/dev/null && pass "the daemon is recording" ||
+		fail "the daemon is recording"
+	if expect_status 0 "stop-recording stops it" --json stop-recording; then
+		file=$(jq -r '.files[0] // empty' "${E2E_DIR}/out")
+		[[ ${file} == *.mp4 && -e ${file} ]] && pass "the recording is converted to MP4" ||
+			fail "the recording is converted to MP4: got '${file}'"
+	fi
+fi
+
+sleep 0.5
+{
+	kill "${EVENTS_PID}" "${FOLLOW_PID}"
+	wait "${EVENTS_PID}" "${FOLLOW_PID}"
+} 2>/dev/null || true
+[[ $(jq -s 'map(.state.recording) | index(true) != null and last == false' "${E2E_DIR}/events") == true ]] &&
+	pass "events follow the recording as it starts and stops" ||
+	fail "events follow the recording as it starts and stops"
+[[ $(jq -s 'map(.class) | index("recording") != null and last == "idle"' "${E2E_DIR}/follow") == true ]] &&
+	pass "waybar-status --follow shows the recording" ||
+	fail "waybar-status --follow shows the recording"
+
+mkdir -p "${XDG_CONFIG_HOME}/sway-easyshot"
+echo "waybar: {text: 'e2e {{.State}}'}" >"${XDG_CONFIG_HOME}/sway-easyshot/config.yaml"
+if expect_status 0 "reload-config reloads the configuration" reload-config; then
+	expect_status 0 "waybar-status renders the reloaded template" waybar-status || true
+	[[ $(jq -r .text "${E2E_DIR}/out") == "e2e idle" ]] && pass "the reloaded template is used" ||
+		fail "the reloaded template is used: got $(jq -r .text "${E2E_DIR}/out")"
+	if expect_status 0 "logs shows the daemon log" logs --lines 20; then
+		grep -q "Configuration reloaded" "${E2E_DIR}/out" && pass "the log holds the reload" ||
+			fail "the log holds the reload"
+	fi
+	# Following keeps asking on a single connection
+	sway-easyshot logs --follow --lines 1 >"${E2E_DIR}/follow-logs" 2>&1 &
+	LOGS_PID=$!
+	sleep 1
+	sway-easyshot reload-config
+	sleep 1
+	{
+		kill "${LOGS_PID}"
+		wait "${LOGS_PID}"
+	} 2>/dev/null || true
+	tail -n +2 "${E2E_DIR}/follow-logs" | grep -q "Configuration reloaded" &&
+		pass "logs --follow prints the lines as they are logged" ||
+		fail "logs --follow prints the lines as they are logged"
+fi
+
+if expect_status 0 "daemon-stop stops the daemon" daemon-stop; then
+	[[ ! -e ${SOCKET} ]] && pass "the daemon removed its socket" || fail "the daemon removed its socket"
+	wait "${DAEMON_PID}" && pass "the daemon exited successfully" || fail "the daemon exited successfully"
+	DAEMON_PID=""
+fi
+
+# A crashed daemon leaves its socket behind, which the next one replaces
+sway-easyshot daemon >>"${E2E_DIR}/daemon.log" 2>&1 &
+DAEMON_PID=$!
+for _ in $(seq 50); do
+	[[ -S ${SOCKET} ]] && break
+	sleep 0.1
+done
+{
+	kill -KILL "${DAEMON_PID}"
+	wait "${DAEMON_PID}" || true
+} 2>/dev/null
+DAEMON_PID=""
+if expect_status 0 "a daemon starts again after a crash" status; then
+	expect_status 0 "daemon-stop stops it" daemon-stop || true
+fi
+
+# Remote clients connect over TCP, each token allowed its scopes alone
+PORT=$((20000 + RANDOM % 20000))
+cat >"${XDG_CONFIG_HOME}/sway-easyshot/config.yaml" <<EOF
+listen:
+  address: 127.0.0.1:${PORT}
+  tokens:
+    - {name: widget, token: widget-0123456789}
+    - {name: deck, token: deck-0123456789abc, scopes: [screenshot]}
+EOF
+SWAY_SCREENSHOT_HTTP_ADDRESS=127.0.0.1:$((PORT + 1)) SWAY_SCREENSHOT_GRPC=1 sway-easyshot daemon >>"${E2E_DIR}/daemon.log" 2>&1 &
+DAEMON_PID=$!
+for _ in $(seq 50); do
+	grep -q "Serving the HTTP API" "${E2E_DIR}/daemon.log" && break
+	sleep 0.1
+done
+export SWAY_SCREENSHOT_REMOTE=127.0.0.1:${PORT}
+SWAY_SCREENSHOT_REMOTE_TOKEN=widget-0123456789 expect_status 0 "a remote client reads the status" status || true
+if SWAY_SCREENSHOT_REMOTE_TOKEN=widget-0123456789 expect_status 1 "a status token cannot capture" --json current-screen-clipboard; then
+	[[ $(jq -r .code "${E2E_DIR}/out") == unauthorized ]] && pass "the capture is reported unauthorized" ||
+		fail "the capture is reported unauthorized"
+fi
+SWAY_SCREENSHOT_REMOTE_TOKEN=deck-0123456789abc expect_status 0 "a screenshot token captures" current-screen-clipboard || true
+SWAY_SCREENSHOT_REMOTE_TOKEN=deck-0123456789abc expect_status 1 "a screenshot token cannot record" movie-screen || true
+SWAY_SCREENSHOT_REMOTE_TOKEN=wrong-0123456789 expect_status 1 "an invalid token is refused" status || true
+# The daemon hangs up on a remote request too large to be one instead of
+# reading on, the client still holding the connection open
+status=0
+{
+	printf '{"action": "'
+	head -c 100000 /dev/zero | tr '\0' a
+} | timeout 5 bash -c "exec 3<>/dev/tcp/127.0.0.1/${PORT}; cat >&3; read -r _ <&3" 2>/dev/null || status=$?
+[[ ${status} != 124 ]] && pass "an oversized remote request is refused" ||
+	fail "an oversized remote request is refused"
+unset SWAY_SCREENSHOT_REMOTE
+
+# The HTTP API answers local clients other than web pages, given a token
+HTTP=http://127.0.0.1:$((PORT + 1))
+# http_status DESCRIPTION STATUS CURL_ARGS... checks the API answers with
+# STATUS, keeping the body in ${E2E_DIR}/out.
+http_status() {
+	local description=$1 want=$2 got
+	shift 2
+	got=$(curl -s -o "${E2E_DIR}/out" -w '%{http_code}' "$@" || true)
+	[[ ${got} == "${want}" ]] && pass "${description}" || fail "${description}: HTTP ${got}, expected ${want}"
+}
+STATUS_TOKEN=(-H "Authorization: Bearer widget-0123456789")
+SCREENSHOT_TOKEN=(-H "Authorization: Bearer deck-0123456789abc")
+http_status "requests without a token are refused" 401 "${HTTP}/status"
+http_status "GET /status answers" 200 "${STATUS_TOKEN[@]}" "${HTTP}/status"
+http_status "a status token cannot capture over HTTP" 401 "${STATUS_TOKEN[@]}" -X POST "${HTTP}/capture/window"
+http_status "POST /capture/window captures" 200 "${SCREENSHOT_TOKEN[@]}" -X POST "${HTTP}/capture/window"
+captured=$(jq -r '.files[0]' "${E2E_DIR}/out")
+http_status "GET /last answers" 200 "${STATUS_TOKEN[@]}" "${HTTP}/last"
+[[ ${captured} != null && $(jq -r .message "${E2E_DIR}/out") == "${captured}" ]] && pass "GET /last returns the capture" ||
+	fail "GET /last returns the capture"
+http_status "invalid options are a bad request" 400 "${SCREENSHOT_TOKEN[@]}" -X POST -d '{"delay": -1}' "${HTTP}/actions/selection-file"
+http_status "options an action does not take are refused" 400 "${SCREENSHOT_TOKEN[@]}" -X POST -d '{"encrypt": true}' "${HTTP}/actions/selection-clipboard"
+[[ $(jq -r .message "${E2E_DIR}/out") == *"does not take encrypt"* ]] && pass "the option refused is named" ||
+	fail "the option refused is named"
+http_status "a client giving up leaves the countdown" 000 "${SCREENSHOT_TOKEN[@]}" --max-time 1 -X POST -d '{"delay": 30}' "${HTTP}/actions/selection-file"
+sleep 0.5
+http_status "the countdown of a client gone away is cancelled" 200 "${SCREENSHOT_TOKEN[@]}" -X POST "${HTTP}/actions/selection-file"
+http_status "web pages are refused" 403 "${STATUS_TOKEN[@]}" -H "Origin: http://example.com" "${HTTP}/status"
+[[ -S $(dirname "${SOCKET}")/grpc.sock ]] && pass "the gRPC service has its socket" || fail "the gRPC service has its socket"
+if expect_status 0 "daemon-stop stops the daemon listening remotely" daemon-stop; then
+	wait "${DAEMON_PID}" || true
+	DAEMON_PID=""
+	[[ ! -e $(dirname "${SOCKET}")/grpc.sock ]] && pass "the gRPC socket is removed" || fail "the gRPC socket is removed"
+fi
+rm "${XDG_CONFIG_HOME}/sway-easyshot/config.yaml"
+
+# The D-Bus service, tried on a bus of its own when one can be started
+if command -v dbus-daemon >/dev/null && command -v gdbus >/dev/null; then
+	{
+		read -r DBUS_SESSION_BUS_ADDRESS
+		read -r BUS_PID
+	} < <(dbus-daemon --session --fork --print-address=1 --print-pid=1 2>/dev/null)
+	export DBUS_SESSION_BUS_ADDRESS
+	# bus_call METHOD [OPTIONS] calls METHOD of the daemon on the bus
+	bus_call() {
+		gdbus call --session -d org.sway.Screenshot -o /org/sway/Screenshot -m "org.sway.Screenshot.$1" "${2:-{\}}" \
+			>"${E2E_DIR}/out" 2>"${E2E_DIR}/err"
+	}
+	SWAY_SCREENSHOT_DBUS=1 sway-easyshot daemon >>"${E2E_DIR}/daemon.log" 2>&1 &
+	DAEMON_PID=$!
+	for _ in $(seq 50); do
+		grep -q "Registered on the session bus" "${E2E_DIR}/daemon.log" && break
+		sleep 0.1
+	done
+	gdbus monitor --session -d org.sway.Screenshot >"${E2E_DIR}/bus-monitor" 2>/dev/null &
+	MONITOR_PID=$!
+	sleep 0.5
+	bus_call Ping && grep -q pong "${E2E_DIR}/out" && pass "Ping answers on the bus" || fail "Ping answers on the bus"
+	bus_call MovieSelection && pass "MovieSelection starts a recording" || fail "MovieSelection starts a recording"
+	sleep 1.5
+	bus_call StopRecording && grep -q '\.mp4' "${E2E_DIR}/out" && pass "StopRecording returns the recording" ||
+		fail "StopRecording returns the recording"
+	sleep 1.5
+	grep -q "'Recording': <true>" "${E2E_DIR}/bus-monitor" && pass "PropertiesChanged follows the recording" ||
+		fail "PropertiesChanged follows the recording"
+	! bus_call SelectionFile "{'delay': <-1>}" && grep -q "org.sway.Screenshot.Error.Invalid" "${E2E_DIR}/err" &&
+		pass "invalid options fail with a named error" || fail "invalid options fail with a named error"
+	if bus_call Shutdown; then
+		pass "Shutdown stops the daemon"
+		wait "${DAEMON_PID}" || true
+		DAEMON_PID=""
+	else
+		fail "Shutdown stops the daemon"
+	fi
+	{
+		kill "${MONITOR_PID}" "${BUS_PID}"
+		wait "${MONITOR_PID}"
+	} 2>/dev/null || true
+	BUS_PID=""
+	unset DBUS_SESSION_BUS_ADDRESS
+fi
+
+XDG_RUNTIME_DIR="${E2E_DIR}/home" expect_status 3 "an unreachable daemon exits with status 3" trace || true
+
+if [[ ${FAILED} -gt 0 ]]; then
+	echo "${FAILED} check(s) failed; daemon log:"
+	sed 's/^/    /' "${E2E_DIR}/daemon.log"
+	exit 1
+fi
+echo "All checks passed"